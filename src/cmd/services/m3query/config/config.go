@@ -57,6 +57,10 @@ type Configuration struct {
 	// DecompressWorkerPoolSize is the size of the worker pool given to each
 	// fetch request.
 	DecompressWorkerPoolSize int `yaml:"workerPoolSize"`
+
+	// NativeReadNaNEncoding is how the native read endpoint renders NaN and
+	// infinite datapoints, either "string" (the default) or "skip".
+	NativeReadNaNEncoding string `yaml:"nativeReadNaNEncoding"`
 }
 
 // LocalConfiguration is the local embedded configuration if running
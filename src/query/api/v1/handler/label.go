@@ -0,0 +1,122 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/util/logging"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	labelNameVar = "name"
+
+	// LabelNamesURL is the url to search for the label names of matching series
+	LabelNamesURL = "/search/labels"
+
+	// LabelNamesHTTPMethod is the HTTP method used with this resource.
+	LabelNamesHTTPMethod = http.MethodPost
+
+	// LabelValuesHTTPMethod is the HTTP method used with this resource.
+	LabelValuesHTTPMethod = http.MethodPost
+)
+
+var (
+	// LabelValuesURL is the url to search for the values of a label on matching series
+	LabelValuesURL = fmt.Sprintf("/search/label/{%s}/values", labelNameVar)
+
+	errEmptyLabelName = errors.New("must specify a label name")
+)
+
+// LabelNamesHandler represents a handler for the label names endpoint
+type LabelNamesHandler struct {
+	search *SearchHandler
+}
+
+// NewLabelNamesHandler returns a new instance of handler
+func NewLabelNamesHandler(storage storage.Storage) http.Handler {
+	return &LabelNamesHandler{search: &SearchHandler{store: storage}}
+}
+
+func (h *LabelNamesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.WithContext(r.Context())
+
+	query, rErr := h.search.parseBody(r)
+	if rErr != nil {
+		logger.Error("unable to parse request", zap.Any("error", rErr))
+		Error(w, rErr.Inner(), rErr.Code())
+		return
+	}
+	opts := h.search.parseURLParams(r)
+
+	names, err := storage.LabelNames(r.Context(), h.search.store, query.TagMatchers, query.Start, query.End, opts)
+	if err != nil {
+		logger.Error("unable to fetch label names", zap.Any("error", err))
+		Error(w, err, http.StatusBadRequest)
+		return
+	}
+
+	WriteJSONResponse(w, names, logger)
+}
+
+// LabelValuesHandler represents a handler for the label values endpoint
+type LabelValuesHandler struct {
+	search *SearchHandler
+}
+
+// NewLabelValuesHandler returns a new instance of handler
+func NewLabelValuesHandler(storage storage.Storage) http.Handler {
+	return &LabelValuesHandler{search: &SearchHandler{store: storage}}
+}
+
+func (h *LabelValuesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.WithContext(r.Context())
+
+	name := mux.Vars(r)[labelNameVar]
+	if name == "" {
+		logger.Error("no label name to search", zap.Any("error", errEmptyLabelName))
+		Error(w, errEmptyLabelName, http.StatusBadRequest)
+		return
+	}
+
+	query, rErr := h.search.parseBody(r)
+	if rErr != nil {
+		logger.Error("unable to parse request", zap.Any("error", rErr))
+		Error(w, rErr.Inner(), rErr.Code())
+		return
+	}
+	opts := h.search.parseURLParams(r)
+
+	values, err := storage.LabelValues(r.Context(), h.search.store, name, query.TagMatchers, query.Start, query.End, opts)
+	if err != nil {
+		logger.Error("unable to fetch label values", zap.Any("error", err))
+		Error(w, err, http.StatusBadRequest)
+		return
+	}
+
+	WriteJSONResponse(w, values, logger)
+}
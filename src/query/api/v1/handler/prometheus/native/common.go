@@ -23,6 +23,7 @@ package native
 import (
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -57,10 +58,21 @@ func parseTime(r *http.Request, key string) (time.Time, error) {
 	return time.Time{}, errors.ErrNotFound
 }
 
+// parseDuration parses Prometheus style durations, such as 1h30m or 1w, and for compatibility
+// with existing clients also Go style durations, such as 1.5s or 500us
 // nolint: unparam
 func parseDuration(r *http.Request, key string) (time.Duration, error) {
 	if d := r.FormValue(key); d != "" {
-		return time.ParseDuration(d)
+		duration, err := util.ParseDuration(d)
+		if err == nil {
+			return duration, nil
+		}
+
+		if duration, goErr := time.ParseDuration(d); goErr == nil {
+			return duration, nil
+		}
+
+		return 0, err
 	}
 
 	return 0, errors.ErrNotFound
@@ -90,8 +102,9 @@ func parseParams(r *http.Request) (models.RequestParams, *handler.ParseError) {
 	}
 	params.End = end
 
+	// A missing step is left as 0 for the engine to resolve from the range
 	step, err := parseDuration(r, stepParam)
-	if err != nil {
+	if err != nil && err != errors.ErrNotFound {
 		return params, handler.NewParseError(fmt.Errorf(formatErrStr, stepParam, err), http.StatusBadRequest)
 	}
 	params.Step = step
@@ -129,7 +142,31 @@ func parseTarget(r *http.Request) (string, error) {
 	return targetQueries[0], nil
 }
 
-func renderResultsJSON(w io.Writer, series []*ts.Series) {
+// NaNEncoding is how datapoints which are NaN or infinite are rendered, as JSON has no literal
+// for those values
+type NaNEncoding int
+
+const (
+	// NaNAsString renders the values as the strings Prometheus uses: "NaN", "+Inf" and "-Inf"
+	NaNAsString NaNEncoding = iota
+	// NaNSkip omits the datapoints entirely, for clients which are unable to parse the strings
+	NaNSkip
+)
+
+// ParseNaNEncoding parses the name of a NaN encoding, either "string" or "skip". An empty name
+// is the default NaNAsString
+func ParseNaNEncoding(name string) (NaNEncoding, error) {
+	switch name {
+	case "", "string":
+		return NaNAsString, nil
+	case "skip":
+		return NaNSkip, nil
+	default:
+		return NaNAsString, fmt.Errorf("unknown NaN encoding: %s", name)
+	}
+}
+
+func renderResultsJSON(w io.Writer, series []*ts.Series, nanEncoding NaNEncoding) {
 	jw := json.NewWriter(w)
 	jw.BeginArray()
 	for _, s := range series {
@@ -150,8 +187,18 @@ func renderResultsJSON(w io.Writer, series []*ts.Series) {
 		vals := s.Values()
 		for i := 0; i < s.Len(); i++ {
 			dp := vals.DatapointAt(i)
+			special := math.IsNaN(dp.Value) || math.IsInf(dp.Value, 0)
+			if special && nanEncoding == NaNSkip {
+				continue
+			}
+
 			jw.BeginArray()
-			jw.WriteFloat64(dp.Value)
+			if special {
+				jw.WriteString(formatSpecialValue(dp.Value))
+			} else {
+				jw.WriteFloat64(dp.Value)
+			}
+
 			jw.WriteInt(int(dp.Timestamp.Unix()))
 			jw.EndArray()
 		}
@@ -168,3 +215,15 @@ func renderResultsJSON(w io.Writer, series []*ts.Series) {
 	jw.EndArray()
 	jw.Close()
 }
+
+// formatSpecialValue renders NaN or an infinity the way Prometheus does
+func formatSpecialValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return "NaN"
+	}
+}
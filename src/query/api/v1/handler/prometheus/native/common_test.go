@@ -21,11 +21,17 @@
 package native
 
 import (
+	"bytes"
+	"encoding/json"
+	"math"
 	"net/http"
 	"net/url"
 	"testing"
 	"time"
 
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/ts"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -53,6 +59,36 @@ func TestParamParsing(t *testing.T) {
 	require.Equal(t, promQuery, r.Target)
 }
 
+func TestParseStep(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected time.Duration
+	}{
+		{in: "10s", expected: 10 * time.Second},
+		{in: "1h30m", expected: 90 * time.Minute},
+		{in: "1w", expected: 7 * 24 * time.Hour},
+		{in: "1.5s", expected: 1500 * time.Millisecond},
+		{in: "500us", expected: 500 * time.Microsecond},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", PromReadURL, nil)
+		vals := defaultParams()
+		vals.Set(stepParam, tt.in)
+		req.URL.RawQuery = vals.Encode()
+		d, err := parseDuration(req, stepParam)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.expected, d, tt.in)
+	}
+
+	req, _ := http.NewRequest("GET", PromReadURL, nil)
+	vals := defaultParams()
+	vals.Set(stepParam, "1x")
+	req.URL.RawQuery = vals.Encode()
+	_, err := parseDuration(req, stepParam)
+	assert.Error(t, err)
+}
+
 func TestInvalidStart(t *testing.T) {
 	req, _ := http.NewRequest("GET", PromReadURL, nil)
 	vals := defaultParams()
@@ -74,3 +110,49 @@ func TestInvalidTarget(t *testing.T) {
 	assert.NotNil(t, p.Start)
 	require.Equal(t, err.Code(), http.StatusBadRequest)
 }
+
+func renderDatapoints(t *testing.T, nanEncoding NaNEncoding) [][]interface{} {
+	start := time.Unix(600, 0)
+	values := ts.NewFixedStepValues(time.Minute, 5, math.NaN(), start)
+	values.SetValueAt(0, 1)
+	values.SetValueAt(2, math.Inf(1))
+	values.SetValueAt(3, math.Inf(-1))
+	values.SetValueAt(4, 2)
+	series := ts.NewSeries("up", values, models.Tags{"job": "a"})
+
+	buf := &bytes.Buffer{}
+	renderResultsJSON(buf, []*ts.Series{series}, nanEncoding)
+
+	var results []struct {
+		Datapoints [][]interface{} `json:"datapoints"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &results))
+	require.Len(t, results, 1)
+	return results[0].Datapoints
+}
+
+func TestRenderResultsJSONWithNaNs(t *testing.T) {
+	assert.Equal(t, [][]interface{}{
+		{1.0, 600.0},
+		{"NaN", 660.0},
+		{"+Inf", 720.0},
+		{"-Inf", 780.0},
+		{2.0, 840.0},
+	}, renderDatapoints(t, NaNAsString))
+
+	assert.Equal(t, [][]interface{}{
+		{1.0, 600.0},
+		{2.0, 840.0},
+	}, renderDatapoints(t, NaNSkip))
+}
+
+func TestParseNaNEncoding(t *testing.T) {
+	for name, expected := range map[string]NaNEncoding{"": NaNAsString, "string": NaNAsString, "skip": NaNSkip} {
+		encoding, err := ParseNaNEncoding(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, encoding, name)
+	}
+
+	_, err := ParseNaNEncoding("null")
+	assert.Error(t, err)
+}
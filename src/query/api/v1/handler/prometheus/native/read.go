@@ -55,7 +55,18 @@ var (
 
 // PromReadHandler represents a handler for prometheus read endpoint.
 type PromReadHandler struct {
-	engine *executor.Engine
+	engine      *executor.Engine
+	nanEncoding NaNEncoding
+}
+
+// ReadOption configures the handler
+type ReadOption func(*PromReadHandler)
+
+// WithNaNEncoding sets how NaN and infinite datapoints are rendered, which defaults to NaNAsString
+func WithNaNEncoding(nanEncoding NaNEncoding) ReadOption {
+	return func(h *PromReadHandler) {
+		h.nanEncoding = nanEncoding
+	}
 }
 
 // ReadResponse is the response that gets returned to the user
@@ -69,8 +80,13 @@ type blockWithMeta struct {
 }
 
 // NewPromReadHandler returns a new instance of handler.
-func NewPromReadHandler(engine *executor.Engine) http.Handler {
-	return &PromReadHandler{engine: engine}
+func NewPromReadHandler(engine *executor.Engine, options ...ReadOption) http.Handler {
+	h := &PromReadHandler{engine: engine}
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
 }
 
 func (h *PromReadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -96,7 +112,7 @@ func (h *PromReadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// TODO: Support multiple result types
 	w.Header().Set("Content-Type", "application/json")
-	renderResultsJSON(w, result)
+	renderResultsJSON(w, result, h.nanEncoding)
 }
 
 func (h *PromReadHandler) read(reqCtx context.Context, w http.ResponseWriter, params models.RequestParams) ([]*ts.Series, error) {
@@ -25,6 +25,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/executor"
@@ -61,3 +62,24 @@ func TestPromRead(t *testing.T) {
 		assert.Equal(t, float64(i), s.Values().ValueAt(i))
 	}
 }
+
+func TestPromReadWithoutStep(t *testing.T) {
+	logging.InitWithCores(nil)
+
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	mockStorage := mock.NewMockStorage()
+	mockStorage.SetFetchBlocksResult(block.Result{Blocks: []block.Block{test.NewBlockFromValues(bounds, values)}}, nil)
+
+	vals := defaultParams()
+	vals.Del(stepParam)
+	req, _ := http.NewRequest("GET", PromReadURL, nil)
+	req.URL.RawQuery = vals.Encode()
+
+	r, parseErr := parseParams(req)
+	require.Nil(t, parseErr)
+	assert.Equal(t, time.Duration(0), r.Step, "the engine resolves a missing step")
+
+	recorder := httptest.NewRecorder()
+	NewPromReadHandler(executor.NewEngine(mockStorage)).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
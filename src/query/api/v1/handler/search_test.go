@@ -32,6 +32,7 @@ import (
 	"time"
 
 	"github.com/m3db/m3/src/dbnode/client"
+	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/storage"
 	"github.com/m3db/m3/src/query/test/local"
@@ -40,6 +41,7 @@ import (
 	"github.com/m3db/m3x/ident"
 
 	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -128,3 +130,39 @@ func TestSearchEndpoint(t *testing.T) {
 	defer resp.Body.Close()
 	require.NotNil(t, resp)
 }
+
+func TestSeriesSearchEndpoint(t *testing.T) {
+	seriesSearchHandler := &SeriesSearchHandler{search: searchServer(t)}
+	server := httptest.NewServer(seriesSearchHandler)
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, generateSearchBody(t))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var metas []block.SeriesMeta
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&metas))
+	require.Len(t, metas, 1)
+	assert.Equal(t, models.Tags{"foo": "bar"}, metas[0].Tags)
+}
+
+func TestLabelValuesEndpoint(t *testing.T) {
+	router := mux.NewRouter()
+	router.Handle(LabelValuesURL, &LabelValuesHandler{search: searchServer(t)})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+"/search/label/foo/values", generateSearchBody(t))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var values []string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&values))
+	assert.Equal(t, []string{"bar"}, values)
+}
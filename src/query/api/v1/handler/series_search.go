@@ -0,0 +1,69 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/util/logging"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// SeriesSearchURL is the url to search for the label sets of matching series
+	SeriesSearchURL = "/search/series"
+
+	// SeriesSearchHTTPMethod is the HTTP method used with this resource.
+	SeriesSearchHTTPMethod = http.MethodPost
+)
+
+// SeriesSearchHandler represents a handler for the series search endpoint
+type SeriesSearchHandler struct {
+	search *SearchHandler
+}
+
+// NewSeriesSearchHandler returns a new instance of handler
+func NewSeriesSearchHandler(storage storage.Storage) http.Handler {
+	return &SeriesSearchHandler{search: &SearchHandler{store: storage}}
+}
+
+func (h *SeriesSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.WithContext(r.Context())
+
+	query, rErr := h.search.parseBody(r)
+	if rErr != nil {
+		logger.Error("unable to parse request", zap.Any("error", rErr))
+		Error(w, rErr.Inner(), rErr.Code())
+		return
+	}
+	opts := h.search.parseURLParams(r)
+
+	metas, err := storage.SearchSeries(r.Context(), h.search.store, query.TagMatchers, query.Start, query.End, opts)
+	if err != nil {
+		logger.Error("unable to search series", zap.Any("error", err))
+		Error(w, err, http.StatusBadRequest)
+		return
+	}
+
+	WriteJSONResponse(w, metas, logger)
+}
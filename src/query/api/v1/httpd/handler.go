@@ -119,8 +119,17 @@ func (h *Handler) RegisterRoutes() error {
 
 	h.Router.HandleFunc(remote.PromReadURL, logged(promRemoteReadHandler).ServeHTTP).Methods(remote.PromReadHTTPMethod)
 	h.Router.HandleFunc(remote.PromWriteURL, logged(promRemoteWriteHandler).ServeHTTP).Methods(remote.PromWriteHTTPMethod)
-	h.Router.HandleFunc(native.PromReadURL, logged(native.NewPromReadHandler(h.engine)).ServeHTTP).Methods(native.PromReadHTTPMethod)
+	nanEncoding, err := native.ParseNaNEncoding(h.config.NativeReadNaNEncoding)
+	if err != nil {
+		return err
+	}
+
+	promNativeReadHandler := native.NewPromReadHandler(h.engine, native.WithNaNEncoding(nanEncoding))
+	h.Router.HandleFunc(native.PromReadURL, logged(promNativeReadHandler).ServeHTTP).Methods(native.PromReadHTTPMethod)
 	h.Router.HandleFunc(handler.SearchURL, logged(handler.NewSearchHandler(h.storage)).ServeHTTP).Methods(handler.SearchHTTPMethod)
+	h.Router.HandleFunc(handler.SeriesSearchURL, logged(handler.NewSeriesSearchHandler(h.storage)).ServeHTTP).Methods(handler.SeriesSearchHTTPMethod)
+	h.Router.HandleFunc(handler.LabelNamesURL, logged(handler.NewLabelNamesHandler(h.storage)).ServeHTTP).Methods(handler.LabelNamesHTTPMethod)
+	h.Router.HandleFunc(handler.LabelValuesURL, logged(handler.NewLabelValuesHandler(h.storage)).ServeHTTP).Methods(handler.LabelValuesHTTPMethod)
 
 	if h.clusterClient != nil {
 		placement.RegisterRoutes(h.Router, h.clusterClient, h.config)
@@ -106,6 +106,23 @@ func TestPromNativeReadPost(t *testing.T) {
 	require.Equal(t, res.Code, http.StatusMethodNotAllowed, "POST method not defined")
 }
 
+func TestPromNativeReadWithNaNEncoding(t *testing.T) {
+	logging.InitWithCores(nil)
+
+	ctrl := gomock.NewController(t)
+	storage, _ := local.NewStorageAndSession(t, ctrl)
+
+	h, err := NewHandler(storage, nil, executor.NewEngine(storage), nil,
+		config.Configuration{NativeReadNaNEncoding: "skip"}, nil, tally.NewTestScope("", nil))
+	require.NoError(t, err, "unable to setup handler")
+	require.NoError(t, h.RegisterRoutes())
+
+	h, err = NewHandler(storage, nil, executor.NewEngine(storage), nil,
+		config.Configuration{NativeReadNaNEncoding: "null"}, nil, tally.NewTestScope("", nil))
+	require.NoError(t, err, "unable to setup handler")
+	assert.Error(t, h.RegisterRoutes(), "unknown encodings fail to register")
+}
+
 func TestRoutesGet(t *testing.T) {
 	logging.InitWithCores(nil)
 
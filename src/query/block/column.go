@@ -22,6 +22,7 @@ package block
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -34,6 +35,9 @@ type columnBlock struct {
 	columns    []column
 	meta       Metadata
 	seriesMeta []SeriesMeta
+
+	rowsOnce sync.Once
+	rows     [][]float64
 }
 
 // Meta returns the metadata for the block
@@ -68,6 +72,31 @@ func (c *columnBlock) StepCount() int {
 	return len(c.columns)
 }
 
+// Rows returns the values of each series, transposing the columns on the first call only
+func (c *columnBlock) Rows() ([][]float64, error) {
+	c.rowsOnce.Do(func() {
+		numSeries := len(c.seriesMeta)
+		if len(c.columns) > 0 {
+			numSeries = len(c.columns[0].Values)
+		}
+
+		rows := make([][]float64, numSeries)
+		for i := range rows {
+			rows[i] = make([]float64, len(c.columns))
+		}
+
+		for j, col := range c.columns {
+			for i, value := range col.Values {
+				rows[i][j] = value
+			}
+		}
+
+		c.rows = rows
+	})
+
+	return c.rows, nil
+}
+
 // Close frees up any resources
 // TODO: actually free up the resources
 func (c *columnBlock) Close() error {
@@ -149,20 +178,36 @@ func NewColumnBlockBuilder(meta Metadata, seriesMeta []SeriesMeta) Builder {
 	}
 }
 
-// AppendValue adds a value to a column at index
+// AppendValue adds a value to a column at index. It fails rather than panics for indices outside
+// of the columns added with AddCols, and for columns which already have a value for every series
 func (cb ColumnBlockBuilder) AppendValue(idx int, value float64) error {
 	columns := cb.block.columns
-	if len(columns) <= idx {
-		return fmt.Errorf("idx out of range for append: %d", idx)
+	if len(columns) == 0 {
+		return fmt.Errorf("unable to append at idx %d, no columns have been added with AddCols", idx)
+	}
+
+	if idx < 0 || idx >= len(columns) {
+		return fmt.Errorf("idx out of range for append: %d, columns: %d", idx, len(columns))
+	}
+
+	if numSeries := len(cb.block.seriesMeta); len(columns[idx].Values) >= numSeries {
+		return fmt.Errorf("unable to append at idx %d, column already has values for all %d series", idx, numSeries)
 	}
 
 	columns[idx].Values = append(columns[idx].Values, value)
 	return nil
 }
 
-// AddCols adds new columns
+// AddCols adds new columns, each sized for a value per series so that appends do not reallocate
 func (cb ColumnBlockBuilder) AddCols(num int) error {
+	numSeries := len(cb.block.seriesMeta)
+	values := make([]float64, num*numSeries)
 	newCols := make([]column, num)
+	for i := range newCols {
+		// The capacity is capped so that each column only grows into its own values
+		newCols[i].Values = values[i*numSeries : i*numSeries : (i+1)*numSeries]
+	}
+
 	cb.block.columns = append(cb.block.columns, newCols...)
 	return nil
 }
@@ -199,8 +244,9 @@ func (m *columnBlockSeriesIter) SeriesMeta() []SeriesMeta {
 
 func (m *columnBlockSeriesIter) SeriesCount() int {
 	cols := m.columns
+	// Blocks without steps still have their series
 	if len(cols) == 0 {
-		return 0
+		return len(m.seriesMeta)
 	}
 
 	return len(cols[0].Values)
@@ -0,0 +1,130 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnBlockBuilderAppendValidation(t *testing.T) {
+	now := time.Now()
+	meta := Metadata{Bounds: Bounds{Start: now, End: now.Add(time.Minute), StepSize: time.Minute}}
+	builder := NewColumnBlockBuilder(meta, []SeriesMeta{{Tags: models.Tags{"a": "1"}}})
+
+	assert.EqualError(t, builder.AppendValue(0, 1), "unable to append at idx 0, no columns have been added with AddCols")
+
+	require.NoError(t, builder.AddCols(2))
+	assert.EqualError(t, builder.AppendValue(2, 1), "idx out of range for append: 2, columns: 2")
+	assert.EqualError(t, builder.AppendValue(-1, 1), "idx out of range for append: -1, columns: 2")
+
+	require.NoError(t, builder.AppendValue(0, 1))
+	assert.EqualError(t, builder.AppendValue(0, 2), "unable to append at idx 0, column already has values for all 1 series")
+	require.NoError(t, builder.AppendValue(1, 3))
+
+	iter, err := builder.Build().SeriesIter()
+	require.NoError(t, err)
+	require.True(t, iter.Next())
+	series, err := iter.Current()
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 3}, series.Values())
+}
+
+func buildColumnBlock(b testing.TB, numSeries, numSteps int) Block {
+	now := time.Now()
+	meta := Metadata{Bounds: Bounds{Start: now, End: now.Add(time.Duration(numSteps-1) * time.Minute), StepSize: time.Minute}}
+	builder := NewColumnBlockBuilder(meta, make([]SeriesMeta, numSeries))
+	require.NoError(b, builder.AddCols(numSteps))
+	for i := 0; i < numSteps; i++ {
+		for j := 0; j < numSeries; j++ {
+			if err := builder.AppendValue(i, float64(i*numSeries+j)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	return builder.Build()
+}
+
+func TestColumnBlockBuilderValues(t *testing.T) {
+	iter, err := buildColumnBlock(t, 3, 2).StepIter()
+	require.NoError(t, err)
+
+	var values [][]float64
+	for iter.Next() {
+		step, err := iter.Current()
+		require.NoError(t, err)
+		values = append(values, step.Values())
+		assert.Equal(t, 3, cap(step.Values()), "columns are sized for the series")
+	}
+
+	assert.Equal(t, [][]float64{{0, 1, 2}, {3, 4, 5}}, values)
+}
+
+func TestColumnBlockIteratesMoreThanOnce(t *testing.T) {
+	b := buildColumnBlock(t, 2, 3)
+	stepValues := func() [][]float64 {
+		iter, err := b.StepIter()
+		require.NoError(t, err)
+		var values [][]float64
+		for iter.Next() {
+			step, err := iter.Current()
+			require.NoError(t, err)
+			values = append(values, step.Values())
+		}
+
+		return values
+	}
+
+	// A partially consumed iterator does not affect the next one
+	partial, err := b.StepIter()
+	require.NoError(t, err)
+	require.True(t, partial.Next())
+
+	expected := [][]float64{{0, 1}, {2, 3}, {4, 5}}
+	assert.Equal(t, expected, stepValues())
+	assert.Equal(t, expected, stepValues())
+
+	for i := 0; i < 2; i++ {
+		iter, err := b.SeriesIter()
+		require.NoError(t, err)
+		var values [][]float64
+		for iter.Next() {
+			series, err := iter.Current()
+			require.NoError(t, err)
+			values = append(values, series.Values())
+		}
+
+		assert.Equal(t, [][]float64{{0, 2, 4}, {1, 3, 5}}, values)
+	}
+}
+
+func BenchmarkColumnBlockBuilder(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buildColumnBlock(b, 10000, 60)
+	}
+}
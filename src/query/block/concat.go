@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errNoBlocksToConcat = errors.New("no blocks to concatenate")
+
+// Concat stitches time adjacent blocks, given in time order, into a single block covering all
+// of their steps. Each block must start one step after the previous block ends, with the same
+// step size. Series are matched across blocks by their tags, and a series missing from a
+// block has NaN values for that block's steps
+func Concat(newBuilder BuilderFn, blocks ...Block) (Block, error) {
+	if len(blocks) == 0 {
+		return nil, errNoBlocksToConcat
+	}
+
+	var (
+		meta    Metadata
+		metas   []SeriesMeta
+		indices = make(map[string]int)
+		values  [][]float64
+		steps   int
+	)
+
+	for i, b := range blocks {
+		iter, err := b.SeriesIter()
+		if err != nil {
+			return nil, err
+		}
+
+		bounds := iter.Meta().Bounds
+		if i == 0 {
+			meta = iter.Meta()
+		} else if err := checkAdjacent(meta.Bounds, bounds); err != nil {
+			iter.Close()
+			return nil, err
+		}
+
+		seen := make(map[int]struct{}, iter.SeriesCount())
+		for iter.Next() {
+			series, err := iter.Current()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+
+			id := series.Meta.Tags.ID()
+			idx, ok := indices[id]
+			if !ok {
+				idx = len(metas)
+				indices[id] = idx
+				metas = append(metas, series.Meta)
+				values = append(values, nanValues(steps))
+			}
+
+			if _, ok := seen[idx]; ok {
+				iter.Close()
+				return nil, fmt.Errorf("unable to concatenate block %d, duplicate series: %s", i, id)
+			}
+
+			seen[idx] = struct{}{}
+			values[idx] = append(values[idx], series.Values()...)
+		}
+
+		iter.Close()
+		steps += bounds.Steps()
+		for idx := range values {
+			if len(values[idx]) < steps {
+				values[idx] = append(values[idx], nanValues(steps-len(values[idx]))...)
+			}
+		}
+
+		meta.Bounds.End = bounds.End
+	}
+
+	builder, err := newBuilder(meta, metas)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return nil, err
+	}
+
+	for step := 0; step < steps; step++ {
+		for _, series := range values {
+			if err := builder.AppendValue(step, series[step]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// checkAdjacent returns an error unless the next bounds start one step after the current bounds
+func checkAdjacent(current, next Bounds) error {
+	if next.StepSize != current.StepSize {
+		return fmt.Errorf("unable to concatenate blocks with step sizes %v and %v",
+			current.StepSize, next.StepSize)
+	}
+
+	expected := current.End.Add(current.StepSize)
+	if next.Start.After(expected) {
+		return fmt.Errorf("unable to concatenate blocks, gap between %v and %v", current.End, next.Start)
+	}
+
+	if next.Start.Before(expected) {
+		return fmt.Errorf("unable to concatenate blocks, overlap between %v and %v", next.Start, current.End)
+	}
+
+	return nil
+}
+
+func nanValues(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+
+	return values
+}
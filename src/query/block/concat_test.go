@@ -0,0 +1,105 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcat(t *testing.T) {
+	start := time.Unix(600, 0)
+	concatenated, err := Concat(UnlimitedBuilder, newSliceTestBlock(t, start), newSliceTestBlock(t, start.Add(5*time.Minute)))
+	require.NoError(t, err)
+
+	meta, values := sliceValues(t, concatenated)
+	assert.Equal(t, Bounds{Start: start, End: start.Add(9 * time.Minute), StepSize: time.Minute}, meta.Bounds)
+	assert.Equal(t, [][]float64{
+		{0, 1, 2, 3, 4, 0, 1, 2, 3, 4},
+		{10, 11, 12, 13, 14, 10, 11, 12, 13, 14},
+	}, values)
+}
+
+func TestConcatWithMissingSeries(t *testing.T) {
+	start := time.Unix(600, 0)
+	next := start.Add(5 * time.Minute)
+	bounds := Bounds{Start: next, End: next.Add(time.Minute), StepSize: time.Minute}
+	builder := NewColumnBlockBuilder(Metadata{Bounds: bounds}, []SeriesMeta{
+		{Tags: models.Tags{"a": "3"}},
+		{Tags: models.Tags{"a": "1"}},
+	})
+	require.NoError(t, builder.AddCols(2))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, builder.AppendValue(i, float64(20+i)))
+		require.NoError(t, builder.AppendValue(i, float64(5+i)))
+	}
+
+	concatenated, err := Concat(UnlimitedBuilder, newSliceTestBlock(t, start), builder.Build())
+	require.NoError(t, err)
+
+	iter, err := concatenated.SeriesIter()
+	require.NoError(t, err)
+	var (
+		tags   []models.Tags
+		values [][]float64
+	)
+	for iter.Next() {
+		series, err := iter.Current()
+		require.NoError(t, err)
+		tags = append(tags, series.Meta.Tags)
+		values = append(values, series.Values())
+	}
+
+	nan := math.NaN()
+	assert.Equal(t, []models.Tags{{"a": "1"}, {"a": "2"}, {"a": "3"}}, tags)
+	assert.Equal(t, []float64{0, 1, 2, 3, 4, 5, 6}, values[0])
+	assertNaNsEqual(t, []float64{10, 11, 12, 13, 14, nan, nan}, values[1])
+	assertNaNsEqual(t, []float64{nan, nan, nan, nan, nan, 20, 21}, values[2])
+}
+
+func TestConcatWithInvalidBounds(t *testing.T) {
+	start := time.Unix(600, 0)
+	_, err := Concat(UnlimitedBuilder, newSliceTestBlock(t, start), newSliceTestBlock(t, start.Add(6*time.Minute)))
+	assert.Error(t, err, "gap between blocks")
+
+	_, err = Concat(UnlimitedBuilder, newSliceTestBlock(t, start), newSliceTestBlock(t, start.Add(4*time.Minute)))
+	assert.Error(t, err, "overlapping blocks")
+
+	_, err = Concat(UnlimitedBuilder)
+	assert.Error(t, err)
+}
+
+func assertNaNsEqual(t *testing.T, expected, actual []float64) {
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		if math.IsNaN(expected[i]) {
+			assert.True(t, math.IsNaN(actual[i]), "expected NaN at %d", i)
+		} else {
+			assert.Equal(t, expected[i], actual[i], "at %d", i)
+		}
+	}
+}
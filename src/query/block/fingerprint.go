@@ -0,0 +1,103 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// Fingerprint returns a hash of the bounds, series and values of the block, which is stable
+// regardless of the order of the series. All NaNs hash identically
+func Fingerprint(b Block) (uint64, error) {
+	iter, err := b.SeriesIter()
+	if err != nil {
+		return 0, err
+	}
+
+	defer iter.Close()
+	seriesHashes := make([]uint64, 0, iter.SeriesCount())
+	for iter.Next() {
+		series, err := iter.Current()
+		if err != nil {
+			return 0, err
+		}
+
+		seriesHashes = append(seriesHashes, seriesFingerprint(series))
+	}
+
+	sort.Slice(seriesHashes, func(i, j int) bool { return seriesHashes[i] < seriesHashes[j] })
+
+	bounds := iter.Meta().Bounds
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	write := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf, v)
+		h.Write(buf)
+	}
+
+	write(uint64(bounds.Start.UnixNano()))
+	write(uint64(bounds.End.UnixNano()))
+	write(uint64(bounds.StepSize))
+	for _, seriesHash := range seriesHashes {
+		write(seriesHash)
+	}
+
+	return h.Sum64(), nil
+}
+
+// seriesFingerprint hashes the tags of the series and its values. Each tag name and value is
+// prefixed by its length, so that tags containing the separators of Tags.ID cannot collide
+func seriesFingerprint(series Series) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	writeString := func(s string) {
+		binary.LittleEndian.PutUint64(buf, uint64(len(s)))
+		h.Write(buf)
+		h.Write([]byte(s))
+	}
+
+	tags := series.Meta.Tags
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		writeString(name)
+		writeString(tags[name])
+	}
+
+	for i := 0; i < series.Len(); i++ {
+		value := series.ValueAtStep(i)
+		if math.IsNaN(value) {
+			value = math.NaN()
+		}
+
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(value))
+		h.Write(buf)
+	}
+
+	return h.Sum64()
+}
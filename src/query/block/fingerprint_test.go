@@ -0,0 +1,96 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildBlock(t *testing.T, metas []SeriesMeta, values [][]float64) Block {
+	now := time.Unix(1500000000, 0)
+	meta := Metadata{
+		Bounds: Bounds{
+			Start:    now,
+			End:      now.Add(2 * time.Minute),
+			StepSize: time.Minute,
+		},
+	}
+
+	builder := NewColumnBlockBuilder(meta, metas)
+	require.NoError(t, builder.AddCols(len(values[0])))
+	for _, series := range values {
+		for idx, value := range series {
+			require.NoError(t, builder.AppendValue(idx, value))
+		}
+	}
+
+	return builder.Build()
+}
+
+func fingerprint(t *testing.T, b Block) uint64 {
+	f, err := Fingerprint(b)
+	require.NoError(t, err)
+	return f
+}
+
+func TestFingerprintIgnoresSeriesOrder(t *testing.T) {
+	a := SeriesMeta{Tags: models.Tags{"job": "a"}}
+	b := SeriesMeta{Tags: models.Tags{"job": "b"}}
+	aValues := []float64{1, math.NaN(), 3}
+	bValues := []float64{4, 5, 6}
+
+	first := buildBlock(t, []SeriesMeta{a, b}, [][]float64{aValues, bValues})
+	second := buildBlock(t, []SeriesMeta{b, a}, [][]float64{bValues, aValues})
+	assert.Equal(t, fingerprint(t, first), fingerprint(t, second))
+
+	// NaNs with different payloads are normalized
+	otherNaN := math.Float64frombits(math.Float64bits(math.NaN()) | 1)
+	third := buildBlock(t, []SeriesMeta{a, b}, [][]float64{{1, otherNaN, 3}, bValues})
+	assert.Equal(t, fingerprint(t, first), fingerprint(t, third))
+}
+
+func TestFingerprintChangesWithValues(t *testing.T) {
+	metas := []SeriesMeta{{Tags: models.Tags{"job": "a"}}, {Tags: models.Tags{"job": "b"}}}
+	first := buildBlock(t, metas, [][]float64{{1, 2, 3}, {4, 5, 6}})
+	second := buildBlock(t, metas, [][]float64{{1, 2, 3}, {4, 5, 7}})
+	assert.NotEqual(t, fingerprint(t, first), fingerprint(t, second))
+
+	swapped := []SeriesMeta{metas[1], metas[0]}
+	third := buildBlock(t, swapped, [][]float64{{1, 2, 3}, {4, 5, 6}})
+	assert.NotEqual(t, fingerprint(t, first), fingerprint(t, third), "values belong to their series")
+}
+
+func TestFingerprintSeparatesTags(t *testing.T) {
+	// Both tags have the same Tags.ID, as it joins names and values with unescaped separators
+	joined := []SeriesMeta{{Tags: models.Tags{"a": "1,b=2"}}}
+	split := []SeriesMeta{{Tags: models.Tags{"a": "1", "b": "2"}}}
+	require.Equal(t, joined[0].Tags.ID(), split[0].Tags.ID())
+
+	values := [][]float64{{1, 2, 3}}
+	assert.NotEqual(t, fingerprint(t, buildBlock(t, joined, values)), fingerprint(t, buildBlock(t, split, values)))
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errNoInstants = errors.New("no instants to assemble")
+
+// FromInstants assembles the results of instant evaluations, given in time order as blocks of a
+// single step, into a range block with the step size, e.g. for alerting engines to inspect the
+// recent history of a rule. It is the inverse of slicing a range block into steps. Series are
+// matched by their tags, and a series has NaN values for the steps it is missing from, as well
+// as for steps without an evaluation. Evaluations must be a whole number of steps apart
+func FromInstants(newBuilder BuilderFn, stepSize time.Duration, instants ...Block) (Block, error) {
+	if len(instants) == 0 {
+		return nil, errNoInstants
+	}
+
+	if stepSize <= 0 {
+		return nil, fmt.Errorf("unable to assemble instants with step size: %v", stepSize)
+	}
+
+	var (
+		meta    Metadata
+		metas   []SeriesMeta
+		indices = make(map[string]int)
+		values  [][]float64
+		prev    int
+	)
+
+	for i, b := range instants {
+		iter, err := b.StepIter()
+		if err != nil {
+			return nil, err
+		}
+
+		bounds := iter.Meta().Bounds
+		if bounds.Steps() != 1 {
+			iter.Close()
+			return nil, fmt.Errorf("unable to assemble instant %d, expected 1 step, found: %d", i, bounds.Steps())
+		}
+
+		if i == 0 {
+			meta = iter.Meta()
+			meta.Bounds.StepSize = stepSize
+		}
+
+		offset := bounds.Start.Sub(meta.Bounds.Start)
+		step := int(offset / stepSize)
+		if offset%stepSize != 0 || (i > 0 && step <= prev) {
+			iter.Close()
+			return nil, fmt.Errorf("unable to assemble instant %d at %v, expected a time after %v on a step of %v",
+				i, bounds.Start, meta.Bounds.TimeForStep(prev), stepSize)
+		}
+
+		prev = step
+		if !iter.Next() {
+			iter.Close()
+			return nil, fmt.Errorf("unable to assemble instant %d, block has no steps", i)
+		}
+
+		current, err := iter.Current()
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+
+		seen := make(map[int]struct{}, len(iter.SeriesMeta()))
+		for j, seriesMeta := range iter.SeriesMeta() {
+			id := seriesMeta.Tags.ID()
+			idx, ok := indices[id]
+			if !ok {
+				idx = len(metas)
+				indices[id] = idx
+				metas = append(metas, seriesMeta)
+				values = append(values, nil)
+			}
+
+			if _, ok := seen[idx]; ok {
+				iter.Close()
+				return nil, fmt.Errorf("unable to assemble instant %d, duplicate series: %s", i, id)
+			}
+
+			seen[idx] = struct{}{}
+			if missing := step - len(values[idx]); missing > 0 {
+				values[idx] = append(values[idx], nanValues(missing)...)
+			}
+
+			values[idx] = append(values[idx], current.Values()[j])
+		}
+
+		iter.Close()
+	}
+
+	steps := prev + 1
+	meta.Bounds.End = meta.Bounds.TimeForStep(prev)
+	for idx := range values {
+		if len(values[idx]) < steps {
+			values[idx] = append(values[idx], nanValues(steps-len(values[idx]))...)
+		}
+	}
+
+	builder, err := newBuilder(meta, metas)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return nil, err
+	}
+
+	for step := 0; step < steps; step++ {
+		for _, series := range values {
+			if err := builder.AppendValue(step, series[step]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
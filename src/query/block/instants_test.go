@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newInstant(t *testing.T, at time.Time, tags []models.Tags, values ...float64) Block {
+	metas := make([]SeriesMeta, len(tags))
+	for i, tag := range tags {
+		metas[i] = SeriesMeta{Tags: tag}
+	}
+
+	builder := NewColumnBlockBuilder(Metadata{Bounds: Bounds{Start: at, End: at, StepSize: time.Minute}}, metas)
+	require.NoError(t, builder.AddCols(1))
+	for _, value := range values {
+		require.NoError(t, builder.AppendValue(0, value))
+	}
+
+	return builder.Build()
+}
+
+func TestFromInstants(t *testing.T) {
+	start := time.Unix(600, 0)
+	a, b, c := models.Tags{"a": "1"}, models.Tags{"a": "2"}, models.Tags{"a": "3"}
+	assembled, err := FromInstants(UnlimitedBuilder, time.Minute,
+		newInstant(t, start, []models.Tags{a, b}, 1, 10),
+		newInstant(t, start.Add(time.Minute), []models.Tags{c, a}, 100, 2),
+		newInstant(t, start.Add(2*time.Minute), []models.Tags{b, c}, 30, 300))
+	require.NoError(t, err)
+
+	iter, err := assembled.SeriesIter()
+	require.NoError(t, err)
+	var tags []models.Tags
+	for _, meta := range iter.SeriesMeta() {
+		tags = append(tags, meta.Tags)
+	}
+
+	meta, values := sliceValues(t, assembled)
+	nan := math.NaN()
+	assert.Equal(t, Bounds{Start: start, End: start.Add(2 * time.Minute), StepSize: time.Minute}, meta.Bounds)
+	assert.Equal(t, []models.Tags{a, b, c}, tags)
+	require.Len(t, values, 3)
+	assertNaNsEqual(t, []float64{1, 2, nan}, values[0])
+	assertNaNsEqual(t, []float64{10, nan, 30}, values[1])
+	assertNaNsEqual(t, []float64{nan, 100, 300}, values[2])
+}
+
+func TestFromInstantsWithMissedEvaluation(t *testing.T) {
+	start := time.Unix(600, 0)
+	tags := []models.Tags{{"a": "1"}}
+	assembled, err := FromInstants(UnlimitedBuilder, time.Minute,
+		newInstant(t, start, tags, 1),
+		newInstant(t, start.Add(3*time.Minute), tags, 4))
+	require.NoError(t, err)
+
+	meta, values := sliceValues(t, assembled)
+	nan := math.NaN()
+	assert.Equal(t, 4, meta.Bounds.Steps())
+	require.Len(t, values, 1)
+	assertNaNsEqual(t, []float64{1, nan, nan, 4}, values[0])
+}
+
+func TestFromInstantsErrors(t *testing.T) {
+	start := time.Unix(600, 0)
+	tags := []models.Tags{{"a": "1"}}
+	_, err := FromInstants(UnlimitedBuilder, time.Minute)
+	assert.Error(t, err)
+
+	_, err = FromInstants(UnlimitedBuilder, 0, newInstant(t, start, tags, 1))
+	assert.Error(t, err)
+
+	_, err = FromInstants(UnlimitedBuilder, time.Minute, newSliceTestBlock(t, start))
+	assert.Error(t, err, "instants have a single step")
+
+	_, err = FromInstants(UnlimitedBuilder, time.Minute, newInstant(t, start, tags, 1), newInstant(t, start.Add(30*time.Second), tags, 1))
+	assert.Error(t, err, "instants are whole steps apart")
+
+	_, err = FromInstants(UnlimitedBuilder, time.Minute, newInstant(t, start, tags, 1), newInstant(t, start, tags, 1))
+	assert.Error(t, err, "instants are in time order")
+
+	_, err = FromInstants(UnlimitedBuilder, time.Minute, newInstant(t, start, []models.Tags{{"a": "1"}, {"a": "1"}}, 1, 2))
+	assert.Error(t, err, "series are unique within an instant")
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"fmt"
+	"math"
+)
+
+// ResampleMethod is how values are computed at steps between the steps of the source block
+type ResampleMethod int
+
+const (
+	// ResampleNone does not resample, so the source block must already have the target steps
+	ResampleNone ResampleMethod = iota
+	// ResamplePrevious takes the value of the latest source step at or before the target step
+	ResamplePrevious
+	// ResampleLinear interpolates between the source steps either side of the target step
+	ResampleLinear
+)
+
+// String representation of the method
+func (m ResampleMethod) String() string {
+	switch m {
+	case ResampleNone:
+		return "none"
+	case ResamplePrevious:
+		return "previous"
+	case ResampleLinear:
+		return "linear"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(m))
+	}
+}
+
+// Resample returns a copy of the block with its series sampled at each step of the bounds.
+// Steps outside of the block's bounds, or without the source values the method needs, are NaN.
+// The caller owns the resampled block, apart from with ResampleNone which returns the block itself
+func Resample(newBuilder BuilderFn, b Block, bounds Bounds, method ResampleMethod) (Block, error) {
+	iter, err := b.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	meta := iter.Meta()
+	seriesMeta := iter.SeriesMeta()
+	source := meta.Bounds
+	iter.Close()
+	if method == ResampleNone {
+		if !source.Equal(bounds) {
+			return nil, fmt.Errorf("unable to resample block with bounds %v to %v without a method", source, bounds)
+		}
+
+		return b, nil
+	}
+
+	if source.StepSize <= 0 || bounds.StepSize <= 0 {
+		return nil, fmt.Errorf("unable to resample block with step sizes %v and %v",
+			source.StepSize, bounds.StepSize)
+	}
+
+	rows, err := Transpose(b)
+	if err != nil {
+		return nil, err
+	}
+
+	meta.Bounds = bounds
+	builder, err := newBuilder(meta, seriesMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := bounds.Steps()
+	if err := builder.AddCols(steps); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < steps; i++ {
+		t := bounds.TimeForStep(i)
+		idx, ok := source.StepForTime(t)
+		offset := t.Sub(source.TimeForStep(idx))
+		for _, row := range rows {
+			value := math.NaN()
+			if ok && idx < len(row) {
+				value = resampleValue(row, idx, float64(offset)/float64(source.StepSize), method)
+			}
+
+			if err := builder.AppendValue(i, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// resampleValue returns the value a fraction of the way from the step at idx to the next step
+func resampleValue(row []float64, idx int, fraction float64, method ResampleMethod) float64 {
+	if fraction == 0 || method == ResamplePrevious {
+		return row[idx]
+	}
+
+	if idx+1 >= len(row) {
+		return math.NaN()
+	}
+
+	// Any NaN on either side propagates, as there is no line to interpolate along
+	return row[idx] + (row[idx+1]-row[idx])*fraction
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResample(t *testing.T) {
+	start := time.Unix(600, 0)
+	source := Bounds{Start: start, End: start.Add(10 * time.Minute), StepSize: 5 * time.Minute}
+	builder := NewColumnBlockBuilder(Metadata{Bounds: source}, []SeriesMeta{{Tags: models.Tags{"a": "1"}}})
+	require.NoError(t, builder.AddCols(3))
+	for i, value := range []float64{0, 10, 20} {
+		require.NoError(t, builder.AppendValue(i, value))
+	}
+
+	coarse := builder.Build()
+	target := Bounds{Start: start.Add(3 * time.Minute), End: start.Add(12 * time.Minute), StepSize: 3 * time.Minute}
+	nan := math.NaN()
+	tests := []struct {
+		method   ResampleMethod
+		expected []float64
+	}{
+		{method: ResamplePrevious, expected: []float64{0, 10, 10, nan}},
+		{method: ResampleLinear, expected: []float64{6, 12, 18, nan}},
+	}
+
+	for _, tt := range tests {
+		resampled, err := Resample(UnlimitedBuilder, coarse, target, tt.method)
+		require.NoError(t, err)
+		meta, values := sliceValues(t, resampled)
+		assert.Equal(t, target, meta.Bounds, tt.method.String())
+		require.Len(t, values, 1)
+		assertNaNsEqual(t, tt.expected, values[0])
+	}
+
+	_, err := Resample(UnlimitedBuilder, coarse, target, ResampleNone)
+	assert.Error(t, err)
+
+	unchanged, err := Resample(UnlimitedBuilder, coarse, source, ResampleNone)
+	require.NoError(t, err)
+	assert.Equal(t, coarse, unchanged)
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/util"
+)
+
+// SignatureString renders the tags forming the matching signature of the series as a stable
+// {k="v",...} string sorted by key, so that the signatures of binary operations can be logged
+// and compared. If on, only the labels are used, otherwise they are excluded along with the
+// metric name. If includeName, the metric name is part of the signature in either case
+func (m SeriesMeta) SignatureString(on, includeName bool, labels ...string) string {
+	var tags models.Tags
+	if on {
+		if includeName {
+			labels = append([]string{models.MetricName}, labels...)
+		}
+
+		tags = m.Tags.TagsWithKeys(labels)
+	} else {
+		tags = m.Tags.TagsWithoutKeys(labels)
+		if name, ok := m.Tags[models.MetricName]; ok && includeName && !util.ContainsString(labels, models.MetricName) {
+			tags[models.MetricName] = name
+		}
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + strconv.Quote(tags[k])
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
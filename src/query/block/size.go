@@ -0,0 +1,48 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+const (
+	// valueBytes is the size of a single value in a column
+	valueBytes = 8
+	// sliceHeaderBytes is the size of the slice header of a column
+	sliceHeaderBytes = 24
+	// stringHeaderBytes is the size of the header of each name, tag name and tag value
+	stringHeaderBytes = 16
+)
+
+// EstimateBytes estimates the memory a column block with the series and number of steps holds,
+// including the column slices and the series metadata. Allocator and map overheads are ignored
+func EstimateBytes(seriesMeta []SeriesMeta, steps int) int {
+	if steps < 0 {
+		steps = 0
+	}
+
+	bytes := steps * (sliceHeaderBytes + len(seriesMeta)*valueBytes)
+	for _, meta := range seriesMeta {
+		bytes += 2*stringHeaderBytes + len(meta.Name) + len(meta.Source)
+		for name, value := range meta.Tags {
+			bytes += 2*stringHeaderBytes + len(name) + len(value)
+		}
+	}
+
+	return bytes
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateBytes(t *testing.T) {
+	metas := []SeriesMeta{
+		{Name: "up", Tags: models.Tags{"job": "x"}},
+		{Name: "up", Tags: models.Tags{"job": "yy"}},
+	}
+
+	// Each step holds a slice header and a value per series, and each series its name and tags
+	columns := 10 * (24 + 2*8)
+	series := (32 + 2 + 32 + 3 + 1) + (32 + 2 + 32 + 3 + 2)
+	assert.Equal(t, columns+series, EstimateBytes(metas, 10))
+	assert.Equal(t, series, EstimateBytes(metas, 0))
+	assert.Equal(t, 0, EstimateBytes(nil, -1))
+}
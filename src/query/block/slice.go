@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"fmt"
+	"time"
+)
+
+// Slice returns a copy of the block restricted to the steps within [start, end), keeping all
+// of its series. If no steps are within the range, the block has bounds with no steps
+func Slice(newBuilder BuilderFn, b Block, start, end time.Time) (Block, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("invalid range to slice, end %v is before start %v", end, start)
+	}
+
+	iter, err := b.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+	meta := iter.Meta()
+	bounds := meta.Bounds
+	if bounds.StepSize <= 0 {
+		return nil, fmt.Errorf("unable to slice block with step size: %v", bounds.StepSize)
+	}
+
+	first := stepAtOrAfter(bounds, start)
+	// The step at or after the end is excluded
+	last := stepAtOrAfter(bounds, end) - 1
+	if last > bounds.Steps()-1 {
+		last = bounds.Steps() - 1
+	}
+
+	sliced := meta
+	sliced.Bounds = Bounds{
+		Start:    bounds.TimeForStep(first),
+		End:      bounds.TimeForStep(last),
+		StepSize: bounds.StepSize,
+	}
+
+	builder, err := newBuilder(sliced, iter.SeriesMeta())
+	if err != nil {
+		return nil, err
+	}
+
+	if first > last {
+		return builder.Build(), nil
+	}
+
+	if err := builder.AddCols(last - first + 1); err != nil {
+		return nil, err
+	}
+
+	for idx := 0; iter.Next() && idx <= last; idx++ {
+		if idx < first {
+			continue
+		}
+
+		step, err := iter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, value := range step.Values() {
+			if err := builder.AppendValue(idx-first, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// stepAtOrAfter returns the index of the first step at or after the time, which is never negative
+func stepAtOrAfter(bounds Bounds, t time.Time) int {
+	if !t.After(bounds.Start) {
+		return 0
+	}
+
+	offset := t.Sub(bounds.Start)
+	idx := int(offset / bounds.StepSize)
+	if offset%bounds.StepSize != 0 {
+		idx++
+	}
+
+	return idx
+}
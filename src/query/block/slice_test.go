@@ -0,0 +1,132 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSliceTestBlock(t *testing.T, start time.Time) Block {
+	bounds := Bounds{Start: start, End: start.Add(4 * time.Minute), StepSize: time.Minute}
+	metas := []SeriesMeta{{Tags: models.Tags{"a": "1"}}, {Tags: models.Tags{"a": "2"}}}
+	builder := NewColumnBlockBuilder(Metadata{Bounds: bounds}, metas)
+	require.NoError(t, builder.AddCols(bounds.Steps()))
+	for i := 0; i < bounds.Steps(); i++ {
+		require.NoError(t, builder.AppendValue(i, float64(i)))
+		require.NoError(t, builder.AppendValue(i, float64(10+i)))
+	}
+
+	return builder.Build()
+}
+
+func sliceValues(t *testing.T, b Block) (Metadata, [][]float64) {
+	iter, err := b.SeriesIter()
+	require.NoError(t, err)
+	var values [][]float64
+	for iter.Next() {
+		series, err := iter.Current()
+		require.NoError(t, err)
+		values = append(values, series.Values())
+	}
+
+	return iter.Meta(), values
+}
+
+func TestSlice(t *testing.T) {
+	start := time.Unix(600, 0)
+	tests := []struct {
+		name       string
+		start, end time.Time
+		bounds     Bounds
+		expected   [][]float64
+	}{
+		{
+			name:     "interior",
+			start:    start.Add(90 * time.Second),
+			end:      start.Add(3 * time.Minute),
+			bounds:   Bounds{Start: start.Add(2 * time.Minute), End: start.Add(2 * time.Minute), StepSize: time.Minute},
+			expected: [][]float64{{2}, {12}},
+		},
+		{
+			name:     "edge aligned",
+			start:    start,
+			end:      start.Add(2 * time.Minute),
+			bounds:   Bounds{Start: start, End: start.Add(time.Minute), StepSize: time.Minute},
+			expected: [][]float64{{0, 1}, {10, 11}},
+		},
+		{
+			name:     "wider than the block",
+			start:    start.Add(-time.Hour),
+			end:      start.Add(time.Hour),
+			bounds:   Bounds{Start: start, End: start.Add(4 * time.Minute), StepSize: time.Minute},
+			expected: [][]float64{{0, 1, 2, 3, 4}, {10, 11, 12, 13, 14}},
+		},
+	}
+
+	for _, tt := range tests {
+		sliced, err := Slice(UnlimitedBuilder, newSliceTestBlock(t, start), tt.start, tt.end)
+		require.NoError(t, err, tt.name)
+		meta, values := sliceValues(t, sliced)
+		assert.True(t, tt.bounds.Equal(meta.Bounds), "%s: %v", tt.name, meta.Bounds)
+		assert.Equal(t, tt.expected, values, tt.name)
+	}
+}
+
+func TestSliceOutOfRange(t *testing.T) {
+	start := time.Unix(600, 0)
+	for _, r := range [][2]time.Time{
+		{start.Add(time.Hour), start.Add(2 * time.Hour)},
+		{start.Add(-time.Hour), start},
+		{start.Add(10 * time.Second), start.Add(20 * time.Second)},
+	} {
+		sliced, err := Slice(UnlimitedBuilder, newSliceTestBlock(t, start), r[0], r[1])
+		require.NoError(t, err)
+		iter, err := sliced.StepIter()
+		require.NoError(t, err)
+		assert.Equal(t, 0, iter.StepCount())
+		assert.Equal(t, 0, iter.Meta().Bounds.Steps())
+		assert.Len(t, iter.SeriesMeta(), 2, "series are kept")
+	}
+
+	_, err := Slice(UnlimitedBuilder, newSliceTestBlock(t, start), start.Add(time.Minute), start)
+	assert.Error(t, err)
+}
+
+func TestSliceWithLimitedBuilder(t *testing.T) {
+	start := time.Unix(1200, 0)
+	errLimit := fmt.Errorf("too many series")
+	var built []SeriesMeta
+	limited := func(meta Metadata, seriesMeta []SeriesMeta) (Builder, error) {
+		built = seriesMeta
+		return nil, errLimit
+	}
+
+	_, err := Slice(limited, newSliceTestBlock(t, start), start, start.Add(2*time.Minute))
+	assert.Equal(t, errLimit, err)
+	assert.Len(t, built, 2)
+}
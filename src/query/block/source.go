@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"time"
+)
+
+// NewSourceBlock returns the block with the source recorded on the metadata of each of its series
+// which does not already have one, e.g. from a store nested within another
+func NewSourceBlock(b Block, source string) Block {
+	return &sourceBlock{Block: b, source: source}
+}
+
+type sourceBlock struct {
+	Block
+	source string
+}
+
+func (b *sourceBlock) StepIter() (StepIter, error) {
+	iter, err := b.Block.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sourceStepIter{StepIter: iter, source: b.source}, nil
+}
+
+func (b *sourceBlock) SeriesIter() (SeriesIter, error) {
+	iter, err := b.Block.SeriesIter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sourceSeriesIter{SeriesIter: iter, source: b.source}, nil
+}
+
+// SampleTime returns the sample times of the underlying block, if it has them
+func (b *sourceBlock) SampleTime(series, step int) (time.Time, bool) {
+	sampled, ok := b.Block.(SampleTimesBlock)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return sampled.SampleTime(series, step)
+}
+
+type sourceStepIter struct {
+	StepIter
+	source string
+}
+
+func (i *sourceStepIter) SeriesMeta() []SeriesMeta {
+	return withSource(i.StepIter.SeriesMeta(), i.source)
+}
+
+type sourceSeriesIter struct {
+	SeriesIter
+	source string
+}
+
+func (i *sourceSeriesIter) SeriesMeta() []SeriesMeta {
+	return withSource(i.SeriesIter.SeriesMeta(), i.source)
+}
+
+func (i *sourceSeriesIter) Current() (Series, error) {
+	series, err := i.SeriesIter.Current()
+	if err != nil {
+		return Series{}, err
+	}
+
+	if series.Meta.Source == "" {
+		series.Meta.Source = i.source
+	}
+
+	return series, nil
+}
+
+func withSource(metas []SeriesMeta, source string) []SeriesMeta {
+	updated := make([]SeriesMeta, len(metas))
+	for i, meta := range metas {
+		if meta.Source == "" {
+			meta.Source = source
+		}
+
+		updated[i] = meta
+	}
+
+	return updated
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package block
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceBlock(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	bounds := Bounds{Start: now, End: now.Add(time.Minute), StepSize: time.Minute}
+	builder := NewColumnBlockBuilder(Metadata{Bounds: bounds}, []SeriesMeta{
+		{Tags: models.Tags{"a": "1"}},
+		{Tags: models.Tags{"a": "2"}, Source: "zone-b"},
+	})
+	require.NoError(t, builder.AddCols(bounds.Steps()))
+	b := NewSourceBlock(builder.Build(), "zone-a")
+
+	// Series which already have a source keep it
+	expected := []string{"zone-a", "zone-b"}
+	stepIter, err := b.StepIter()
+	require.NoError(t, err)
+	defer stepIter.Close()
+	for i, meta := range stepIter.SeriesMeta() {
+		assert.Equal(t, expected[i], meta.Source)
+	}
+
+	seriesIter, err := b.SeriesIter()
+	require.NoError(t, err)
+	defer seriesIter.Close()
+	for i := 0; seriesIter.Next(); i++ {
+		series, err := seriesIter.Current()
+		require.NoError(t, err)
+		assert.Equal(t, expected[i], series.Meta.Source)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"fmt"
+)
+
+// ValuesAtStep returns a copy of the values of every series at the step index, along with the
+// metadata of the series, e.g. to take a single instant out of a range block
+func ValuesAtStep(b Block, i int) ([]float64, []SeriesMeta, error) {
+	iter, err := b.StepIter()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer iter.Close()
+	if i < 0 || i >= iter.StepCount() {
+		return nil, nil, fmt.Errorf("step index out of range: %d, steps: %d", i, iter.StepCount())
+	}
+
+	for idx := 0; iter.Next(); idx++ {
+		if idx < i {
+			continue
+		}
+
+		step, err := iter.Current()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		values := make([]float64, len(step.Values()))
+		copy(values, step.Values())
+		return values, iter.SeriesMeta(), nil
+	}
+
+	return nil, nil, fmt.Errorf("block ended before step index: %d", i)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesAtStep(t *testing.T) {
+	b := newSliceTestBlock(t, time.Unix(600, 0))
+	values, metas, err := ValuesAtStep(b, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{3, 13}, values)
+	require.Len(t, metas, 2)
+	assert.Equal(t, "1", metas[0].Tags["a"])
+
+	_, _, err = ValuesAtStep(b, 5)
+	assert.EqualError(t, err, "step index out of range: 5, steps: 5")
+	_, _, err = ValuesAtStep(b, -1)
+	assert.EqualError(t, err, "step index out of range: -1, steps: 5")
+}
+
+func TestValuesAtStepWithNaNs(t *testing.T) {
+	now := time.Now()
+	builder := NewColumnBlockBuilder(Metadata{Bounds: Bounds{Start: now, End: now.Add(time.Minute), StepSize: time.Minute}},
+		[]SeriesMeta{{Name: "a"}, {Name: "b"}})
+	require.NoError(t, builder.AddCols(2))
+	for _, v := range [][]float64{{1, math.NaN()}, {math.NaN(), 2}} {
+		for i, value := range v {
+			require.NoError(t, builder.AppendValue(i, value))
+		}
+	}
+
+	values, _, err := ValuesAtStep(builder.Build(), 1)
+	require.NoError(t, err)
+	require.Len(t, values, 2)
+	assert.True(t, math.IsNaN(values[0]))
+	assert.Equal(t, 2.0, values[1])
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"sync"
+	"time"
+)
+
+// rowBlock is implemented by blocks which cache their row major representation
+type rowBlock interface {
+	Rows() ([][]float64, error)
+}
+
+// Transpose returns the values of each series in the block. Blocks which support it build the
+// row major representation once and return the cached rows on subsequent calls, so the result
+// must not be modified
+func Transpose(b Block) ([][]float64, error) {
+	if rb, ok := b.(rowBlock); ok {
+		return rb.Rows()
+	}
+
+	return transpose(b)
+}
+
+func transpose(b Block) ([][]float64, error) {
+	iter, err := b.SeriesIter()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+	rows := make([][]float64, 0, iter.SeriesCount())
+	for iter.Next() {
+		series, err := iter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, series.Values())
+	}
+
+	return rows, nil
+}
+
+// WithCachedRows returns the block caching its row major representation, e.g. for blocks from
+// storage which are read by several range functions. Blocks which already cache their rows are
+// returned as they are
+func WithCachedRows(b Block) Block {
+	if _, ok := b.(rowBlock); ok {
+		return b
+	}
+
+	return &cachedRowsBlock{Block: b}
+}
+
+type cachedRowsBlock struct {
+	Block
+	once sync.Once
+	rows [][]float64
+	err  error
+}
+
+func (b *cachedRowsBlock) Rows() ([][]float64, error) {
+	b.once.Do(func() {
+		b.rows, b.err = transpose(b.Block)
+	})
+
+	return b.rows, b.err
+}
+
+// SampleTime returns the sample times of the underlying block, if it has them
+func (b *cachedRowsBlock) SampleTime(series, step int) (time.Time, bool) {
+	sampled, ok := b.Block.(SampleTimesBlock)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return sampled.SampleTime(series, step)
+}
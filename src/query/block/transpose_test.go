@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seriesOnlyBlock hides the cached rows of the wrapped block
+type seriesOnlyBlock struct {
+	Block
+}
+
+func TestTranspose(t *testing.T) {
+	metas := []SeriesMeta{{Tags: models.Tags{"job": "a"}}, {Tags: models.Tags{"job": "b"}}}
+	values := [][]float64{{1, 2, 3}, {4, 5, 6}}
+	b := buildBlock(t, metas, values)
+
+	rows, err := Transpose(b)
+	require.NoError(t, err)
+	assert.Equal(t, values, rows)
+
+	cached, err := Transpose(b)
+	require.NoError(t, err)
+	assert.True(t, &rows[0][0] == &cached[0][0], "the rows are cached on the block")
+
+	rows, err = Transpose(seriesOnlyBlock{b})
+	require.NoError(t, err)
+	assert.Equal(t, values, rows)
+}
+
+func TestWithCachedRows(t *testing.T) {
+	metas := []SeriesMeta{{Tags: models.Tags{"job": "a"}}, {Tags: models.Tags{"job": "b"}}}
+	values := [][]float64{{1, 2, 3}, {4, 5, 6}}
+	b := buildBlock(t, metas, values)
+	assert.Equal(t, b, WithCachedRows(b), "blocks caching their rows are not wrapped")
+
+	cachedBlock := WithCachedRows(seriesOnlyBlock{b})
+	rows, err := Transpose(cachedBlock)
+	require.NoError(t, err)
+	assert.Equal(t, values, rows)
+
+	cached, err := Transpose(cachedBlock)
+	require.NoError(t, err)
+	assert.True(t, &rows[0][0] == &cached[0][0], "the rows are cached on the wrapper")
+}
+
+func TestTransposeWithoutSteps(t *testing.T) {
+	metas := []SeriesMeta{{Tags: models.Tags{"job": "a"}}}
+	b := NewColumnBlockBuilder(Metadata{}, metas).Build()
+	rows, err := Transpose(b)
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{}}, rows)
+}
+
+func benchmarkBlock(b *testing.B) Block {
+	const numSeries, numSteps = 100, 1000
+	metas := make([]SeriesMeta, numSeries)
+	builder := NewColumnBlockBuilder(Metadata{}, metas)
+	builder.AddCols(numSteps)
+	for i := 0; i < numSeries; i++ {
+		for j := 0; j < numSteps; j++ {
+			builder.AppendValue(j, float64(i*j))
+		}
+	}
+
+	return builder.Build()
+}
+
+func BenchmarkTransposeCached(b *testing.B) {
+	blk := benchmarkBlock(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Transpose(blk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTransposeUncached(b *testing.B) {
+	blk := seriesOnlyBlock{benchmarkBlock(b)}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Transpose(blk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -29,18 +29,31 @@ import (
 
 // Block represents a group of series across a time bound
 type Block interface {
-	// StepIter returns a StepIterator
+	// StepIter returns a StepIterator from the first step. Each call returns a new iterator, so a
+	// block may be iterated more than once, e.g. by several downstream nodes. Steps may share
+	// their values with the block, so callers must not modify them
 	StepIter() (StepIter, error)
-	// SeriesIter returns a SeriesIterator
+	// SeriesIter returns a SeriesIterator from the first series, with a new iterator on each call
 	SeriesIter() (SeriesIter, error)
 	// Close frees up any resources
 	Close() error
 }
 
+// SampleTimesBlock is implemented by blocks which retain the timestamps of the samples
+// consolidated onto each step, which differ from the step times when values are carried forward
+type SampleTimesBlock interface {
+	Block
+	// SampleTime returns the timestamp of the sample of the series at the step, if known
+	SampleTime(series, step int) (time.Time, bool)
+}
+
 // SeriesMeta is metadata data for the series
 type SeriesMeta struct {
 	Tags models.Tags
 	Name string
+	// Source is the storage node or zone which produced the series, if known.
+	// It is preserved by element-wise transforms and cleared when series are merged
+	Source string
 }
 
 // Bounds are the time bounds
@@ -52,8 +65,7 @@ type Bounds struct {
 
 // TimeForIndex returns the start time for a given index assuming a uniform step size
 func (b Bounds) TimeForIndex(idx int) (time.Time, error) {
-	step := b.StepSize
-	t := b.Start.Add(time.Duration(idx) * step)
+	t := b.TimeForStep(idx)
 	if t.After(b.End) {
 		return time.Time{}, fmt.Errorf("out of bounds, %d", idx)
 	}
@@ -70,6 +82,26 @@ func (b Bounds) Steps() int {
 	return int(b.End.Sub(b.Start)/b.StepSize) + 1
 }
 
+// TimeForStep returns the time of the step at the index, which may be outside of the bounds
+func (b Bounds) TimeForStep(i int) time.Time {
+	return b.Start.Add(time.Duration(i) * b.StepSize)
+}
+
+// StepForTime returns the index of the last step at or before the time, and false if
+// the time is outside of the bounds
+func (b Bounds) StepForTime(t time.Time) (int, bool) {
+	if b.StepSize <= 0 || t.Before(b.Start) || t.After(b.End) {
+		return 0, false
+	}
+
+	return int(t.Sub(b.Start) / b.StepSize), true
+}
+
+// Equal returns true if both bounds cover the same steps
+func (b Bounds) Equal(other Bounds) bool {
+	return b.Start.Equal(other.Start) && b.End.Equal(other.End) && b.StepSize == other.StepSize
+}
+
 // String representation of the bounds
 func (b Bounds) String() string {
 	return fmt.Sprintf("start: %v, end: %v, stepSize: %v, steps: %d", b.Start, b.End, b.StepSize, b.Steps())
@@ -117,6 +149,10 @@ type Step interface {
 type Metadata struct {
 	Bounds Bounds
 	Tags   models.Tags // Common tags across different series
+	// PinnedBounds are set for range selectors pinned to an instant by the @ modifier, whose blocks
+	// only cover the window fetched at the instant. Range functions evaluate the window once and
+	// repeat the result at every step of the pinned bounds, which are those of the query
+	PinnedBounds *Bounds
 }
 
 // String returns a string representation of metadata
@@ -131,7 +167,22 @@ type Builder interface {
 	AddCols(num int) error
 }
 
+// BuilderFn creates a builder for a block, e.g. the builder of a node's controller, which fails
+// once the block would exceed the limits of the query
+type BuilderFn func(meta Metadata, seriesMeta []SeriesMeta) (Builder, error)
+
+// UnlimitedBuilder creates a column block builder without any limits, for blocks which are never
+// larger than the blocks they are built from
+func UnlimitedBuilder(meta Metadata, seriesMeta []SeriesMeta) (Builder, error) {
+	return NewColumnBlockBuilder(meta, seriesMeta), nil
+}
+
 // Result is the result from a block query
 type Result struct {
 	Blocks []Block
+	// Source is the storage node or zone which served all of the blocks, if known. Results
+	// combining several sources record them on the metadata of each series instead
+	Source string
+	// Warnings are problems which did not fail the fetch, such as storage nodes which timed out
+	Warnings []string
 }
@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package block
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundsSteps(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	bounds := Bounds{Start: start, End: start.Add(2 * time.Minute), StepSize: time.Minute}
+	assert.Equal(t, 3, bounds.Steps())
+	assert.Equal(t, start.Add(2*time.Minute), bounds.TimeForStep(2))
+	assert.Equal(t, start.Add(5*time.Minute), bounds.TimeForStep(5), "steps past the end are not checked")
+
+	stepTime, err := bounds.TimeForIndex(2)
+	require.NoError(t, err)
+	assert.Equal(t, bounds.TimeForStep(2), stepTime)
+	_, err = bounds.TimeForIndex(3)
+	assert.Error(t, err)
+
+	assert.Equal(t, 0, Bounds{Start: start, End: start, StepSize: 0}.Steps())
+	assert.Equal(t, 0, Bounds{Start: start, End: start.Add(-time.Minute), StepSize: time.Minute}.Steps())
+}
+
+func TestBoundsStepForTime(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	// The end falls between steps, so the last step is a fraction of a step before it
+	bounds := Bounds{Start: start, End: start.Add(150 * time.Second), StepSize: time.Minute}
+	assert.Equal(t, 3, bounds.Steps())
+
+	tests := []struct {
+		t        time.Time
+		expected int
+		ok       bool
+	}{
+		{t: start, expected: 0, ok: true},
+		{t: start.Add(time.Minute), expected: 1, ok: true},
+		{t: start.Add(90 * time.Second), expected: 1, ok: true},
+		{t: start.Add(150 * time.Second), expected: 2, ok: true},
+		{t: start.Add(-time.Second)},
+		{t: start.Add(151 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		step, ok := bounds.StepForTime(tt.t)
+		assert.Equal(t, tt.ok, ok, "time: %v", tt.t)
+		assert.Equal(t, tt.expected, step, "time: %v", tt.t)
+	}
+
+	for i := 0; i < bounds.Steps(); i++ {
+		step, ok := bounds.StepForTime(bounds.TimeForStep(i))
+		assert.True(t, ok)
+		assert.Equal(t, i, step)
+	}
+
+	_, ok := Bounds{Start: start, End: start}.StepForTime(start)
+	assert.False(t, ok, "no step size")
+}
+
+func TestBoundsEqual(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	bounds := Bounds{Start: start, End: start.Add(time.Minute), StepSize: time.Minute}
+	assert.True(t, bounds.Equal(Bounds{Start: start.UTC(), End: start.Add(time.Minute).UTC(), StepSize: time.Minute}))
+	assert.False(t, bounds.Equal(Bounds{Start: start, End: start.Add(time.Minute), StepSize: time.Second}))
+	assert.False(t, bounds.Equal(Bounds{Start: start.Add(time.Second), End: start.Add(time.Minute), StepSize: time.Minute}))
+	assert.False(t, bounds.Equal(Bounds{Start: start, End: start.Add(2 * time.Minute), StepSize: time.Minute}))
+}
@@ -48,6 +48,9 @@ var (
 
 	// ErrNoClientAddresses is an error when there are no addresses passed to the remote client
 	ErrNoClientAddresses = errors.New("no client addresses given")
+
+	// ErrNoData is returned when the results of a query have no series and empty results are disabled.
+	ErrNoData = errors.New("no data")
 )
 
 // ErrMaxConcurrentQueriesLimitExceeded is an error when the query cannot be run
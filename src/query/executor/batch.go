@@ -0,0 +1,198 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/storage"
+)
+
+// ParsedQuery is a parsed query and its parameters, to be evaluated as part of a batch
+type ParsedQuery struct {
+	Parser parser.Parser
+	Params models.RequestParams
+}
+
+// ExecuteBatch evaluates the queries, fetching each selector they have in common only once, e.g.
+// for rule evaluation where many expressions use the same series. The results are in the same
+// order as the queries and stream back while the queries execute. Every result must be drained
+// or the context cancelled, as the queries block on results which are not read and the shared
+// blocks are only freed once all of them are done
+func (e *Engine) ExecuteBatch(ctx context.Context, queries []ParsedQuery, opts *EngineOptions) []Query {
+	store := newSharedFetchStorage(ctx, e.store)
+	results := make([]Query, len(queries))
+	states := make([]*ExecutionState, 0, len(queries))
+	for i, query := range queries {
+		state, err := e.createState(ctx, query.Parser, opts, query.Params, store)
+		if err != nil {
+			results[i] = Query{Err: err}
+			continue
+		}
+
+		results[i] = Query{Result: state.resultNode}
+		states = append(states, state)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(states))
+	for _, state := range states {
+		go func(state *ExecutionState) {
+			defer wg.Done()
+			state.run(ctx)
+		}(state)
+	}
+
+	// The shared blocks can only be freed once every query is done with them
+	go func() {
+		wg.Wait()
+		store.close()
+	}()
+
+	return results
+}
+
+// sharedFetchStorage fetches the blocks for each distinct fetch query once, sharing them
+// with every node of the batch which fetches the same query
+type sharedFetchStorage struct {
+	storage.Storage
+	// ctx is the context of the batch, which shared fetches run with rather than the context
+	// of whichever node fetches first, as every node of the batch shares their results
+	ctx     context.Context
+	mu      sync.Mutex
+	fetches map[string]*sharedFetch
+}
+
+type sharedFetch struct {
+	once   sync.Once
+	result block.Result
+	blocks []block.Block
+	err    error
+}
+
+func newSharedFetchStorage(ctx context.Context, store storage.Storage) *sharedFetchStorage {
+	return &sharedFetchStorage{
+		Storage: store,
+		ctx:     ctx,
+		fetches: make(map[string]*sharedFetch),
+	}
+}
+
+// FetchBlocks returns the shared blocks for the query, fetching them on first use
+func (s *sharedFetchStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	// Raw series are passed to the callback of the fetching node only, so they are not shared
+	if options != nil && options.RawSeries != nil {
+		return s.Storage.FetchBlocks(ctx, query, options)
+	}
+
+	key := fetchKey(query, options)
+	s.mu.Lock()
+	fetch, ok := s.fetches[key]
+	if !ok {
+		fetch = &sharedFetch{}
+		s.fetches[key] = fetch
+	}
+	s.mu.Unlock()
+
+	fetch.once.Do(func() {
+		result, err := s.Storage.FetchBlocks(s.ctx, query, options)
+		if err != nil {
+			fetch.err = err
+			return
+		}
+
+		fetch.blocks = result.Blocks
+		fetch.result = result
+		fetch.result.Blocks = make([]block.Block, len(result.Blocks))
+		for i, b := range result.Blocks {
+			fetch.result.Blocks[i] = sharedBlock{Block: b}
+		}
+	})
+
+	if fetch.err != nil {
+		return block.Result{}, fetch.err
+	}
+
+	// Each node gets its own slice as nodes may replace blocks in it
+	result := fetch.result
+	result.Blocks = append([]block.Block(nil), fetch.result.Blocks...)
+	return result, nil
+}
+
+// close frees the fetched blocks
+func (s *sharedFetchStorage) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, fetch := range s.fetches {
+		for _, b := range fetch.blocks {
+			b.Close()
+		}
+	}
+}
+
+// fetchKey identifies fetch queries which return the same blocks, which also depend on the limit
+// and consolidation of the options, e.g. the lookback of a selector
+func fetchKey(query *storage.FetchQuery, options *storage.FetchOptions) string {
+	matchers := make([]string, len(query.TagMatchers))
+	for i, m := range query.TagMatchers {
+		matchers[i] = m.String()
+	}
+
+	sort.Strings(matchers)
+	key := fmt.Sprintf("%d:%d:%d:%s", query.Start.UnixNano(), query.End.UnixNano(),
+		query.Interval, strings.Join(matchers, ","))
+	if options == nil {
+		return key
+	}
+
+	// Policies are functions, so they are told apart by their address
+	consolidation := options.Consolidation
+	return fmt.Sprintf("%s:%d:%p:%t:%d", key, options.Limit, consolidation.Policy(),
+		consolidation.RetainSampleTimes, consolidation.LookbackDuration)
+}
+
+// sharedBlock is a block used by multiple nodes, which is only closed by its owner
+type sharedBlock struct {
+	block.Block
+}
+
+func (b sharedBlock) Close() error {
+	return nil
+}
+
+// SampleTime returns the sample times of the shared block, if it has them
+func (b sharedBlock) SampleTime(series, step int) (time.Time, bool) {
+	sampled, ok := b.Block.(block.SampleTimesBlock)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return sampled.SampleTime(series, step)
+}
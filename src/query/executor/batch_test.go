@@ -0,0 +1,260 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/storage/mock"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/fixtures"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorage counts the block fetches
+type countingStorage struct {
+	mock.Storage
+	fetches int32
+}
+
+func (s *countingStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	atomic.AddInt32(&s.fetches, 1)
+	return s.Storage.FetchBlocks(ctx, query, options)
+}
+
+func resultValues(t *testing.T, result Result) [][]float64 {
+	var values [][]float64
+	for r := range result.ResultChan() {
+		require.NoError(t, r.Err)
+		iter, err := r.Block.SeriesIter()
+		require.NoError(t, err)
+		for iter.Next() {
+			series, err := iter.Current()
+			require.NoError(t, err)
+			values = append(values, series.Values())
+		}
+	}
+
+	return values
+}
+
+func TestExecuteBatchSharesFetches(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds([][]float64{
+		{-1, -2, -3, -4, -5},
+		{5, 6, 7, 8, 9},
+	}, nil)
+	store := &countingStorage{Storage: mock.NewMockStorage()}
+	store.SetFetchBlocksResult(block.Result{Blocks: []block.Block{test.NewBlockFromValues(bounds, values)}}, nil)
+
+	now := time.Now()
+	params := models.RequestParams{
+		Start: now.Add(-4 * time.Minute),
+		End:   now,
+		Now:   now,
+		Step:  time.Minute,
+	}
+
+	var queries []ParsedQuery
+	for _, q := range []string{"up", "abs(up)", "sum(up)"} {
+		p, err := promql.Parse(q)
+		require.NoError(t, err)
+		queries = append(queries, ParsedQuery{Parser: p, Params: params})
+	}
+
+	results := NewEngine(store).ExecuteBatch(context.TODO(), queries, &EngineOptions{})
+	require.Len(t, results, 3)
+	for _, r := range results {
+		require.NoError(t, r.Err)
+	}
+
+	assert.Equal(t, values, resultValues(t, results[0].Result))
+	assert.Equal(t, [][]float64{{1, 2, 3, 4, 5}, {5, 6, 7, 8, 9}}, resultValues(t, results[1].Result))
+	assert.Equal(t, [][]float64{{4, 4, 4, 4, 4}}, resultValues(t, results[2].Result))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&store.fetches), "the shared selector is fetched once")
+}
+
+func TestExecuteBatchWithInvalidQuery(t *testing.T) {
+	store := &countingStorage{Storage: mock.NewMockStorage()}
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	store.SetFetchBlocksResult(block.Result{Blocks: []block.Block{test.NewBlockFromValues(bounds, values)}}, nil)
+
+	var queries []ParsedQuery
+	for _, q := range []string{"abs(up)", "rate(up[5m])"} {
+		p, err := promql.Parse(q)
+		require.NoError(t, err)
+		queries = append(queries, ParsedQuery{Parser: p, Params: models.RequestParams{Step: time.Minute}})
+	}
+
+	results := NewEngine(store).ExecuteBatch(context.TODO(), queries, &EngineOptions{DisabledFunctions: []string{"rate"}})
+	require.Len(t, results, 2)
+	require.NoError(t, results[0].Err)
+	assert.Len(t, resultValues(t, results[0].Result), 2)
+	assert.EqualError(t, results[1].Err, "function rate is disabled")
+}
+
+// closeRecordingBlock records when the block is closed
+type closeRecordingBlock struct {
+	block.Block
+	closed chan struct{}
+}
+
+func (b closeRecordingBlock) Close() error {
+	close(b.closed)
+	return b.Block.Close()
+}
+
+func TestExecuteBatchWithUndrainedResult(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	closed := make(chan struct{})
+	blocks := []block.Block{closeRecordingBlock{Block: test.NewBlockFromValues(bounds, values), closed: closed}}
+	for len(blocks) <= channelSize {
+		blocks = append(blocks, test.NewBlockFromValues(bounds, values))
+	}
+
+	store := mock.NewMockStorage()
+	store.SetFetchBlocksResult(block.Result{Blocks: blocks}, nil)
+
+	p, err := promql.Parse("up")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	results := NewEngine(store).ExecuteBatch(ctx, []ParsedQuery{{Parser: p, Params: models.RequestParams{Step: time.Minute}}}, &EngineOptions{})
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Err)
+
+	// The result is never read, so the query only finishes and frees the blocks once cancelled
+	cancel()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the fetched blocks were not freed after cancelling the batch")
+	}
+}
+
+func TestExecuteBatchWithTimestampSampleTimes(t *testing.T) {
+	start := time.Now().Truncate(time.Minute).Add(-2 * time.Minute)
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags: models.Tags{models.MetricName: "up"},
+		Datapoints: ts.Datapoints{
+			{Timestamp: start, Value: 1},
+			{Timestamp: start.Add(90 * time.Second), Value: 2},
+		},
+	})
+
+	end := start.Add(2 * time.Minute)
+	params := models.RequestParams{Start: start, End: end, Now: end, Step: time.Minute}
+	var queries []ParsedQuery
+	for _, q := range []string{"timestamp(up)", "timestamp(up)"} {
+		p, err := promql.Parse(q)
+		require.NoError(t, err)
+		queries = append(queries, ParsedQuery{Parser: p, Params: params})
+	}
+
+	results := NewEngine(store).ExecuteBatch(context.TODO(), queries, &EngineOptions{TimestampSampleTimes: true})
+	require.Len(t, results, 2)
+
+	// The first sample is carried forward onto the second step, keeping its timestamp
+	seconds := float64(start.Unix())
+	for _, r := range results {
+		require.NoError(t, r.Err)
+		values := resultValues(t, r.Result)
+		require.Len(t, values, 1)
+		require.True(t, len(values[0]) >= 2)
+		assert.Equal(t, []float64{seconds, seconds}, values[0][:2])
+	}
+}
+
+// contextStorage fails fetches whose context is done
+type contextStorage struct {
+	mock.Storage
+}
+
+func (s contextStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return block.Result{}, err
+	}
+
+	return s.Storage.FetchBlocks(ctx, query, options)
+}
+
+func TestSharedFetchIgnoresContextOfFirstNode(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	store := contextStorage{Storage: mock.NewMockStorage()}
+	store.SetFetchBlocksResult(block.Result{Blocks: []block.Block{test.NewBlockFromValues(bounds, values)}}, nil)
+	shared := newSharedFetchStorage(context.TODO(), store)
+	defer shared.close()
+
+	// The first node giving up must not fail the fetch for the other nodes of the batch
+	cancelled, cancel := context.WithCancel(context.TODO())
+	cancel()
+	query := &storage.FetchQuery{Interval: time.Minute}
+	_, err := shared.FetchBlocks(cancelled, query, &storage.FetchOptions{})
+	require.NoError(t, err)
+
+	result, err := shared.FetchBlocks(context.TODO(), query, &storage.FetchOptions{})
+	require.NoError(t, err)
+	assert.Len(t, result.Blocks, 1)
+}
+
+func TestFetchKey(t *testing.T) {
+	now := time.Now()
+	a, err := models.NewMatcher(models.MatchEqual, "a", "1")
+	require.NoError(t, err)
+	b, err := models.NewMatcher(models.MatchEqual, "b", "2")
+	require.NoError(t, err)
+
+	query := &storage.FetchQuery{TagMatchers: models.Matchers{a, b}, Start: now, End: now.Add(time.Hour), Interval: time.Minute}
+	reordered := &storage.FetchQuery{TagMatchers: models.Matchers{b, a}, Start: now, End: now.Add(time.Hour), Interval: time.Minute}
+	assert.Equal(t, fetchKey(query, nil), fetchKey(reordered, nil))
+
+	shifted := *query
+	shifted.Start = now.Add(time.Minute)
+	assert.NotEqual(t, fetchKey(query, nil), fetchKey(&shifted, nil))
+
+	// Blocks also depend on how the options consolidate them
+	options := &storage.FetchOptions{}
+	assert.Equal(t, fetchKey(query, options), fetchKey(query, &storage.FetchOptions{}))
+	for _, other := range []*storage.FetchOptions{
+		{Limit: 10},
+		{Consolidation: ts.ConsolidationOptions{DuplicateTimestampPolicy: ts.DuplicateTimestampMax}},
+		{Consolidation: ts.ConsolidationOptions{RetainSampleTimes: true}},
+		{Consolidation: ts.ConsolidationOptions{LookbackDuration: time.Minute}},
+	} {
+		assert.NotEqual(t, fetchKey(query, options), fetchKey(query, other), "%+v", other)
+	}
+
+	defaultPolicy := &storage.FetchOptions{
+		Consolidation: ts.ConsolidationOptions{DuplicateTimestampPolicy: ts.DuplicateTimestampLast},
+	}
+	assert.Equal(t, fetchKey(query, options), fetchKey(query, defaultPolicy), "the default policy is last")
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// CacheKey identifies the result of the query over the request, for caching results. The key
+// includes the absolute bounds and step of the request, after the @ start() and @ end() modifiers
+// are resolved to the instants they pin selectors to, so the same query text over another window
+// has another key
+func (e *Engine) CacheKey(p parser.Parser, params models.RequestParams) (string, error) {
+	nodes, edges, err := p.DAG()
+	if err != nil {
+		return "", err
+	}
+
+	if params.Step <= 0 && e.stepResolver != nil {
+		params.Step = e.stepResolver(params.Start, params.End)
+	}
+
+	nodes = withQueryBounds(nodes, params)
+	var key strings.Builder
+	fmt.Fprintf(&key, "%d:%d:%d", params.Start.UnixNano(), params.End.UnixNano(), params.Step)
+	for _, node := range nodes {
+		fmt.Fprintf(&key, ";%s", node)
+	}
+
+	for _, edge := range edges {
+		fmt.Fprintf(&key, ";%s", edge)
+	}
+
+	return key.String(), nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+
+package executor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/storage/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKey(t *testing.T) {
+	engine := NewEngine(mock.NewMockStorage())
+	end := time.Unix(1500000000, 0)
+	key := func(query string, start, end time.Time) string {
+		p, err := promql.Parse(query)
+		require.NoError(t, err)
+		k, err := engine.CacheKey(p, models.RequestParams{Start: start, End: end, Step: time.Minute})
+		require.NoError(t, err)
+		return k
+	}
+
+	for _, query := range []string{`sum(rate(requests[5m]))`, `requests @ end()`, `rate(requests[5m] @ start())`} {
+		window := key(query, end.Add(-time.Hour), end)
+		assert.Equal(t, window, key(query, end.Add(-time.Hour), end), query)
+
+		// The same query text over a shifted window must not hit the result of the earlier window
+		assert.NotEqual(t, window, key(query, end.Add(-time.Hour+time.Minute), end.Add(time.Minute)), query)
+	}
+
+	// The bounds the selectors are pinned to are resolved before the key is computed
+	assert.Equal(t, key(fmt.Sprintf(`requests @ %d`, end.Unix()), end.Add(-time.Hour), end),
+		key(`requests @ end()`, end.Add(-time.Hour), end))
+	assert.NotEqual(t, key(`requests @ start()`, end.Add(-time.Hour), end),
+		key(`requests @ end()`, end.Add(-time.Hour), end))
+}
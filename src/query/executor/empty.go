@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/errors"
+)
+
+// emptyResultHook is a result hook for results without any series. They fail the query with
+// errors.ErrNoData when errorOnNoData is set, and otherwise have the bounds of the query, as the
+// nodes producing them, e.g. range functions, may have changed them
+type emptyResultHook struct {
+	bounds        block.Bounds
+	errorOnNoData bool
+}
+
+// OnResult keeps the block unchanged if it has any series
+func (h emptyResultHook) OnResult(b block.Block) (block.Block, bool, error) {
+	iter, err := b.StepIter()
+	if err != nil {
+		return nil, false, err
+	}
+
+	count, meta := len(iter.SeriesMeta()), iter.Meta()
+	iter.Close()
+	if count > 0 {
+		return nil, false, nil
+	}
+
+	if h.errorOnNoData {
+		return nil, false, errors.ErrNoData
+	}
+
+	if meta.Bounds.Equal(h.bounds) {
+		return nil, false, nil
+	}
+
+	meta.Bounds = h.bounds
+	builder := block.NewColumnBlockBuilder(meta, nil)
+	if err := builder.AddCols(h.bounds.Steps()); err != nil {
+		return nil, false, err
+	}
+
+	return builder.Build(), true, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/errors"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/test/fixtures"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// executeWithNoData runs the query over 3 minutes to end, returning the bounds and number of series
+// of each result
+func executeWithNoData(t *testing.T, query string, end time.Time, errorOnNoData bool) ([]block.Bounds, []int, error) {
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags:       models.Tags{models.MetricName: "up"},
+		Datapoints: ts.Datapoints{{Timestamp: end.Add(-30 * time.Second), Value: 1}},
+	})
+
+	p, err := promql.Parse(query)
+	require.NoError(t, err)
+
+	results := make(chan Query, 1)
+	go NewEngine(store).ExecuteExpr(context.TODO(), p, &EngineOptions{ErrorOnNoData: errorOnNoData}, models.RequestParams{
+		Start: end.Add(-3 * time.Minute),
+		End:   end,
+		Now:   end,
+		Step:  time.Minute,
+	}, results)
+
+	r := <-results
+	require.NoError(t, r.Err, query)
+
+	var (
+		bounds []block.Bounds
+		counts []int
+	)
+
+	for res := range r.Result.ResultChan() {
+		if res.Err != nil {
+			return nil, nil, res.Err
+		}
+
+		iter, err := res.Block.StepIter()
+		require.NoError(t, err)
+		bounds = append(bounds, iter.Meta().Bounds)
+		counts = append(counts, len(iter.SeriesMeta()))
+		iter.Close()
+	}
+
+	return bounds, counts, nil
+}
+
+func TestExecuteExprWithNoData(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	queryBounds := block.Bounds{Start: end.Add(-3 * time.Minute), End: end, StepSize: time.Minute}
+	for _, query := range []string{"nosuchmetric", "rate(nosuchmetric[5m])", "sum(nosuchmetric)"} {
+		bounds, counts, err := executeWithNoData(t, query, end, false)
+		require.NoError(t, err, query)
+		require.Len(t, bounds, 1, query)
+		assert.True(t, queryBounds.Equal(bounds[0]), "%s has the query bounds, not %v", query, bounds[0])
+		assert.Equal(t, []int{0}, counts, query)
+
+		_, _, err = executeWithNoData(t, query, end, true)
+		assert.Equal(t, errors.ErrNoData, err, query)
+	}
+}
+
+func TestExecuteExprWithNoDataForSelector(t *testing.T) {
+	// Selectors which match nothing do not fail queries which still have results
+	end := time.Now().Truncate(time.Minute)
+	for _, query := range []string{"count_scalar(nosuchmetric)", "count_scalar(nosuchmetric) + count_scalar(up)"} {
+		_, counts, err := executeWithNoData(t, query, end, true)
+		require.NoError(t, err, query)
+		assert.Equal(t, []int{1}, counts, query)
+	}
+}
@@ -22,13 +22,20 @@ package executor
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
 	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/parser"
 	"github.com/m3db/m3/src/query/plan"
 	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/ts"
+	"github.com/m3db/m3/src/query/util"
 	"github.com/m3db/m3/src/query/util/logging"
 
+	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 )
 
@@ -38,12 +45,279 @@ type Engine struct {
 	tracker *Tracker
 	Stats   *QueryStatistics
 	store   storage.Storage
+	// scope, when set, records the number of series flowing through each node
+	scope tally.Scope
+	// maxBlockBytes, when positive, caps the estimated size of the blocks of any node
+	maxBlockBytes int
+	// maxConcurrentFetches, when positive, bounds the storage fetches running at once for a query
+	maxConcurrentFetches int
+	// resultHooks are run in order on each final block of a query
+	resultHooks []ResultHook
+	// tagSanitizer, when set, rewrites the tag values of fetched series
+	tagSanitizer transform.TagSanitizer
+	// stepResolver chooses the step of queries which do not set one
+	stepResolver DefaultStepResolver
 }
 
 // EngineOptions can be used to pass custom flags to engine
 type EngineOptions struct {
 	// AbortCh is a channel that signals when results are no longer desired by the caller.
 	AbortCh <-chan bool
+	// AlignStepsToEpoch aligns the query steps to multiples of the step since the epoch,
+	// so that overlapping queries share identically timestamped steps.
+	AlignStepsToEpoch bool
+	// ErrorOnNoData fails queries whose results have no series with errors.ErrNoData, rather
+	// than returning an empty block with the query bounds. Selectors matching no series do not
+	// fail queries which still have results, e.g. count_scalar(nosuchmetric).
+	ErrorOnNoData bool
+	// EnabledFunctions, when set, are the only function types queries may use.
+	EnabledFunctions []string
+	// DisabledFunctions are function types which queries may not use, e.g. expensive
+	// functions on shared clusters.
+	DisabledFunctions []string
+	// WarnOnGaugeRates adds a warning to the results when rate or increase are applied
+	// to series which look like gauges. It does not change the results.
+	WarnOnGaugeRates bool
+	// MergeReplicas makes rate and increase merge series which only differ by the replica tag into
+	// one counter, so that replicas lagging one another do not look like counter resets.
+	MergeReplicas bool
+	// RegressionDecayHalfLife, when positive, exponentially weights the samples fit by deriv and
+	// predict_linear, so that a sample's weight halves for every half life it is older than the
+	// newest sample of its window, e.g. for trending volatile series.
+	RegressionDecayHalfLife time.Duration
+	// CounterMaxValue, when positive, is the value at which the counters of rate and increase wrap,
+	// as with temporal.CounterOptions, for functions which do not set their own.
+	CounterMaxValue float64
+	// MinSamples, when positive, is the number of samples a window needs before rate, increase,
+	// delta, deriv and predict_linear report a value for it, as with temporal.CounterOptions, for
+	// functions which do not set their own.
+	MinSamples int
+	// DisableExtrapolation makes rate, increase and delta return the raw change between the first and
+	// last samples of each window, as with temporal.CounterOptions, e.g. to compare with tools which
+	// do not extrapolate.
+	DisableExtrapolation bool
+	// ResetTolerance, when positive, is the largest decrease of the counters of rate and increase
+	// which is not a reset, as with temporal.CounterOptions, for functions which do not set their own,
+	// e.g. for float counters which jitter down from rounding.
+	ResetTolerance float64
+	// NonNegative makes delta clamp negative changes to 0 with a warning, as with
+	// temporal.CounterOptions, for gauges which are known to only increase. Other functions are
+	// unaffected.
+	NonNegative bool
+	// RegressionReference is the time predict_linear predicts from, for aligning with other tools.
+	// It applies where the query does not set its own reference and does not change deriv.
+	RegressionReference utils.RegressionReference
+	// InterpolationMethod determines how the quantile aggregation and quantile_over_time pick
+	// values between ranks, where the query does not set its own method.
+	InterpolationMethod utils.InterpolationMethod
+	// UnanchoredLabelReplace matches the regexes of label_replace anywhere in the source value rather
+	// than against the whole value, as with tag.LabelReplaceOptions. Queries relying on it are not
+	// portable to Prometheus.
+	UnanchoredLabelReplace bool
+	// IncludeGroupSize adds a sibling series with the size of each group to every sum, avg and count,
+	// as with aggregation.NodeParams. Aggregations are not pushed down into set operations with it.
+	IncludeGroupSize bool
+	// RollupShards, when above one, aggregates every sum and count in two phases over this many
+	// shards, as with aggregation.NodeParams, for very wide aggregations.
+	RollupShards int
+	// IncludeTies makes topk, bottomk and range_topk also take every element tied with the k-th, as
+	// with aggregation.NodeParams, so that results do not depend on how ties are broken.
+	IncludeTies bool
+	// StreamingAggregation aggregates every sum, count, avg, min and max one series at a time, as with
+	// aggregation.NodeParams, keeping memory bounded by the number of groups rather than of series.
+	// It cannot be combined with RollupShards.
+	StreamingAggregation bool
+	// LeftInclusive closes the left edge of every range window, so that a window of duration d
+	// evaluated at t covers [t-d, t] rather than the Prometheus default of (t-d, t].
+	LeftInclusive bool
+	// SeriesLimit, when positive, caps the number of series each selector returns, as with
+	// functions.FetchOp, for paging through the matched series. Selectors which page themselves are
+	// unaffected.
+	SeriesLimit int
+	// SeriesOffset skips the first series each selector matches, ordered by their tags, as with
+	// functions.FetchOp.
+	SeriesOffset int
+	// SelectorLookbacks overrides the lookback duration for the selectors of each metric name, as with
+	// functions.FetchOp, e.g. so that sparse series carry their values forward for longer than dense
+	// ones. Selectors which set their own lookback are unaffected.
+	SelectorLookbacks map[string]time.Duration
+	// MaxSeriesPerNode, when positive, fails queries as soon as any node would emit more
+	// series, e.g. a misconfigured join which fans out.
+	MaxSeriesPerNode int
+	// FuseElementWiseOps collapses chains of element-wise functions, e.g. abs(round(x)), into a
+	// single node which applies them in one pass.
+	FuseElementWiseOps bool
+	// DisableAggregationPushDown keeps aggregations of set operations, e.g. sum(a and b), in place
+	// rather than applying them to the lhs of the set operation when that is result equivalent.
+	DisableAggregationPushDown bool
+	// Consolidation configures how sources consolidate raw datapoints onto the query steps,
+	// e.g. which value is kept for datapoints sharing a timestamp.
+	Consolidation ts.ConsolidationOptions
+	// IncludeRawSamples returns the raw datapoints fetched from storage, before consolidation,
+	// alongside the results, for debugging consolidation. It is meant for debugging only.
+	IncludeRawSamples bool
+	// MaxRawSamples caps the raw datapoints returned for a query, defaulting to
+	// transform.DefaultMaxRawSamples.
+	MaxRawSamples int
+	// TimestampSampleTimes makes timestamp() return the times of the samples consolidated onto
+	// each step rather than the step times, which differ when consolidation carries a value forward.
+	TimestampSampleTimes bool
+	// MaxRangeWindow, when positive, rejects queries with range selectors over a longer range,
+	// e.g. rates over 30d, to protect storage. Ranges equal to the limit are allowed.
+	MaxRangeWindow time.Duration
+	// TrimNaNSteps removes the leading and trailing steps without any values from the results,
+	// adjusting their bounds, so that graphs are not padded when data starts late or ends early
+	// in the range. Interior steps without values are kept.
+	TrimNaNSteps bool
+}
+
+// validateFunctions ensures none of the nodes use a disabled function type
+func (o *EngineOptions) validateFunctions(nodes parser.Nodes) error {
+	if len(o.EnabledFunctions) == 0 && len(o.DisabledFunctions) == 0 {
+		return nil
+	}
+
+	for _, node := range nodes {
+		// Selectors are always allowed
+		if _, ok := node.Op.(SourceParams); ok {
+			continue
+		}
+
+		opType := node.Op.OpType()
+		if util.ContainsString(o.DisabledFunctions, opType) ||
+			(len(o.EnabledFunctions) > 0 && !util.ContainsString(o.EnabledFunctions, opType)) {
+			return fmt.Errorf("function %s is disabled", opType)
+		}
+	}
+
+	return nil
+}
+
+// validateFunctionOptions ensures the options applied to the functions of every query are valid
+func (o *EngineOptions) validateFunctionOptions() error {
+	if o.CounterMaxValue < 0 {
+		return fmt.Errorf("counter max value cannot be negative: %v", o.CounterMaxValue)
+	}
+
+	if o.ResetTolerance < 0 {
+		return fmt.Errorf("reset tolerance cannot be negative: %v", o.ResetTolerance)
+	}
+
+	if o.RollupShards < 0 {
+		return fmt.Errorf("rollup shards cannot be negative: %d", o.RollupShards)
+	}
+
+	if o.RollupShards > 1 && o.StreamingAggregation {
+		return fmt.Errorf("rollup cannot be combined with streaming aggregation")
+	}
+
+	if o.SeriesLimit < 0 {
+		return fmt.Errorf("series limit cannot be negative: %d", o.SeriesLimit)
+	}
+
+	if o.SeriesOffset < 0 {
+		return fmt.Errorf("series offset cannot be negative: %d", o.SeriesOffset)
+	}
+
+	for name, lookback := range o.SelectorLookbacks {
+		if lookback < 0 {
+			return fmt.Errorf("lookback of %s cannot be negative: %v", name, lookback)
+		}
+	}
+
+	if o.MinSamples < 0 {
+		return fmt.Errorf("min samples cannot be negative: %d", o.MinSamples)
+	}
+
+	if o.InterpolationMethod < utils.LinearInterpolation || o.InterpolationMethod > utils.NearestInterpolation {
+		return fmt.Errorf("unknown interpolation method: %d", o.InterpolationMethod)
+	}
+
+	if o.RegressionReference < utils.ReferenceEvaluationTime || o.RegressionReference > utils.ReferenceWindowEnd {
+		return fmt.Errorf("unknown regression reference: %d", o.RegressionReference)
+	}
+
+	if o.RegressionDecayHalfLife < 0 {
+		return fmt.Errorf("decay half life cannot be negative: %v", o.RegressionDecayHalfLife)
+	}
+
+	return nil
+}
+
+// rangeWindowParams are implemented by range selectors
+type rangeWindowParams interface {
+	RangeWindow() time.Duration
+	FormatExpr(inputs []string) string
+}
+
+// validateRangeWindows ensures none of the range selectors exceed the maximum range window
+func (o *EngineOptions) validateRangeWindows(nodes parser.Nodes) error {
+	if o.MaxRangeWindow <= 0 {
+		return nil
+	}
+
+	for _, node := range nodes {
+		params, ok := node.Op.(rangeWindowParams)
+		if ok && params.RangeWindow() > o.MaxRangeWindow {
+			return fmt.Errorf("range selector %s exceeds the maximum range window of %s",
+				params.FormatExpr(nil), util.FormatDuration(o.MaxRangeWindow))
+		}
+	}
+
+	return nil
+}
+
+// sampleTimesParams are implemented by ops which may use the timestamps of samples rather than of steps
+type sampleTimesParams interface {
+	UsesSampleTimes() bool
+	// WithSampleTimes returns the op using the timestamps of samples
+	WithSampleTimes() parser.Params
+}
+
+// withSampleTimes returns the nodes with the ops which may use the timestamps of samples using them
+func withSampleTimes(nodes parser.Nodes) parser.Nodes {
+	updated := make(parser.Nodes, len(nodes))
+	for i, node := range nodes {
+		if params, ok := node.Op.(sampleTimesParams); ok {
+			node.Op = params.WithSampleTimes()
+		}
+
+		updated[i] = node
+	}
+
+	return updated
+}
+
+// usesSampleTimes returns true if any of the nodes need the sources to retain sample times
+func usesSampleTimes(nodes parser.Nodes) bool {
+	for _, node := range nodes {
+		if params, ok := node.Op.(sampleTimesParams); ok && params.UsesSampleTimes() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queryBoundsParams are implemented by ops which may be pinned to the start or end of the query
+type queryBoundsParams interface {
+	// WithQueryBounds returns the op pinned to the instant of the bound it is pinned to, if any
+	WithQueryBounds(start, end time.Time) parser.Params
+}
+
+// withQueryBounds returns the nodes with the ops pinned to the start or end of the query pinned to
+// the absolute instants of the bounds of the request
+func withQueryBounds(nodes parser.Nodes, params models.RequestParams) parser.Nodes {
+	updated := make(parser.Nodes, len(nodes))
+	for i, node := range nodes {
+		if op, ok := node.Op.(queryBoundsParams); ok {
+			node.Op = op.WithQueryBounds(params.Start, params.End)
+		}
+
+		updated[i] = node
+	}
+
+	return updated
 }
 
 // Query is the result after execution
@@ -53,11 +327,38 @@ type Query struct {
 }
 
 // NewEngine returns a new instance of QueryExecutor.
-func NewEngine(store storage.Storage) *Engine {
-	return &Engine{
-		tracker: NewTracker(),
-		Stats:   &QueryStatistics{},
-		store:   store,
+func NewEngine(store storage.Storage, options ...Option) *Engine {
+	e := &Engine{
+		tracker:      NewTracker(),
+		Stats:        &QueryStatistics{},
+		store:        store,
+		stepResolver: NewDefaultStepResolver(defaultStepPoints, defaultMinStep),
+	}
+
+	for _, option := range options {
+		option(e)
+	}
+
+	return e
+}
+
+// WithMaxBlockBytes fails queries with transform.ErrResourceExhausted as soon as the blocks
+// built and fetched by their nodes are together estimated to be larger than the limit, e.g. for
+// very long range queries, rather than running out of memory. Limits which are not positive are
+// ignored
+func WithMaxBlockBytes(n int) Option {
+	return func(e *Engine) {
+		e.maxBlockBytes = n
+	}
+}
+
+// WithTagSanitizer rewrites the values of the metric name and other tags of every fetched series
+// with the sanitizer, e.g. so that results are compatible with strict consumers. Series are
+// sanitized before they are matched or grouped, so series which only differ by the characters
+// replaced join as if they were named the same. It is off by default
+func WithTagSanitizer(sanitizer transform.TagSanitizer) Option {
+	return func(e *Engine) {
+		e.tagSanitizer = sanitizer
 	}
 }
 
@@ -99,50 +400,124 @@ func (e *Engine) Execute(ctx context.Context, query *storage.FetchQuery, opts *E
 func (e *Engine) ExecuteExpr(ctx context.Context, parser parser.Parser, opts *EngineOptions, params models.RequestParams, results chan Query) {
 	defer close(results)
 
-	nodes, edges, err := parser.DAG()
+	state, err := e.createState(ctx, parser, opts, params, e.store)
 	if err != nil {
 		results <- Query{Err: err}
 		return
 	}
 
+	results <- Query{Result: state.resultNode}
+	state.run(ctx)
+}
+
+// createState plans the query DAG and creates the execution state for it against the storage
+func (e *Engine) createState(ctx context.Context, parser parser.Parser, opts *EngineOptions, params models.RequestParams, store storage.Storage) (*ExecutionState, error) {
+	nodes, edges, err := parser.DAG()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes = withQueryBounds(nodes, params)
+	if err := opts.validateFunctions(nodes); err != nil {
+		return nil, err
+	}
+
+	if err := opts.validateRangeWindows(nodes); err != nil {
+		return nil, err
+	}
+
+	if err := opts.validateFunctionOptions(); err != nil {
+		return nil, err
+	}
+
+	if opts.TimestampSampleTimes {
+		nodes = withSampleTimes(nodes)
+	}
+
+	if opts.FuseElementWiseOps {
+		nodes, edges = plan.FuseElementWise(nodes, edges)
+	}
+
+	// The series of group sizes are matched separately, so aggregations including them cannot be
+	// pushed down
+	if !opts.DisableAggregationPushDown && !opts.IncludeGroupSize {
+		nodes, edges = plan.PushDownAggregations(nodes, edges)
+	}
+
 	lp, err := plan.NewLogicalPlan(nodes, edges)
 	if err != nil {
-		results <- Query{Err: err}
-		return
+		return nil, err
 	}
 
 	if params.Debug {
 		logging.WithContext(ctx).Info("logical plan", zap.String("plan", lp.String()))
 	}
 
-	pp, err := plan.NewPhysicalPlan(lp, e.store, params)
+	if params.Step <= 0 && e.stepResolver != nil {
+		params.Step = e.stepResolver(params.Start, params.End)
+	}
+
+	pp, err := plan.NewPhysicalPlan(lp, store, params)
 	if err != nil {
-		results <- Query{Err: err}
-		return
+		return nil, err
+	}
+
+	pp.AlignStepsToEpoch = opts.AlignStepsToEpoch
+	pp.WarnOnGaugeRates = opts.WarnOnGaugeRates
+	pp.MergeReplicas = opts.MergeReplicas
+	pp.RegressionDecayHalfLife = opts.RegressionDecayHalfLife
+	pp.CounterMaxValue = opts.CounterMaxValue
+	pp.MinSamples = opts.MinSamples
+	pp.DisableExtrapolation = opts.DisableExtrapolation
+	pp.ResetTolerance = opts.ResetTolerance
+	pp.NonNegative = opts.NonNegative
+	pp.RegressionReference = opts.RegressionReference
+	pp.InterpolationMethod = opts.InterpolationMethod
+	pp.UnanchoredLabelReplace = opts.UnanchoredLabelReplace
+	pp.IncludeGroupSize = opts.IncludeGroupSize
+	pp.RollupShards = opts.RollupShards
+	pp.IncludeTies = opts.IncludeTies
+	pp.StreamingAggregation = opts.StreamingAggregation
+	pp.LeftInclusive = opts.LeftInclusive
+	pp.SeriesLimit = opts.SeriesLimit
+	pp.SeriesOffset = opts.SeriesOffset
+	pp.SelectorLookbacks = opts.SelectorLookbacks
+	pp.MaxSeriesPerNode = opts.MaxSeriesPerNode
+	pp.MaxBlockBytes = e.maxBlockBytes
+	pp.Consolidation = opts.Consolidation
+	pp.IncludeRawSamples = opts.IncludeRawSamples
+	pp.MaxRawSamples = opts.MaxRawSamples
+	pp.TagSanitizer = e.tagSanitizer
+	if usesSampleTimes(nodes) {
+		pp.Consolidation.RetainSampleTimes = true
 	}
 
 	if params.Debug {
 		logging.WithContext(ctx).Info("physical plan", zap.String("plan", pp.String()))
 	}
 
-	state, err := GenerateExecutionState(pp, e.store)
-	// free up resources
+	// The hooks of the query must not append to the hooks shared by every query
+	hooks := e.resultHooks[:len(e.resultHooks):len(e.resultHooks)]
+	if opts.TrimNaNSteps {
+		hooks = append(hooks, nanStepTrimmer{})
+	}
+
+	// Empty results are handled last, once no hook can remove any more series
+	hooks = append(hooks, emptyResultHook{
+		bounds:        pp.TimeSpec.Bounds(opts.AlignStepsToEpoch),
+		errorOnNoData: opts.ErrorOnNoData,
+	})
+
+	state, err := generateExecutionState(ctx, pp, limitFetches(store, e.maxConcurrentFetches), e.scope, hooks)
 	if err != nil {
-		results <- Query{Err: err}
-		return
+		return nil, err
 	}
 
 	if params.Debug {
 		logging.WithContext(ctx).Info("execution state", zap.String("state", state.String()))
 	}
 
-	result := state.resultNode
-	results <- Query{Result: result}
-	if err := state.Execute(ctx); err != nil {
-		result.abort(err)
-	} else {
-		result.done()
-	}
+	return state, nil
 }
 
 // Close kills all running queries and prevents new queries from being attached.
@@ -23,14 +23,29 @@ package executor
 import (
 	"context"
 	"fmt"
+	"math"
+	"regexp"
 	"testing"
+	"time"
 
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions"
+	"github.com/m3db/m3/src/query/functions/aggregation"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/parser/promql"
 	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/test/fixtures"
 	"github.com/m3db/m3/src/query/test/local"
+	"github.com/m3db/m3/src/query/ts"
 	"github.com/m3db/m3/src/query/util/logging"
 
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestExecute(t *testing.T) {
@@ -48,3 +63,908 @@ func TestExecute(t *testing.T) {
 	<-results
 	assert.Equal(t, len(engine.tracker.queries), 1)
 }
+
+func TestExecuteExprWithDisabledFunction(t *testing.T) {
+	p, err := promql.Parse("rate(http_requests_total[5m])")
+	require.NoError(t, err)
+
+	results := make(chan Query)
+	engine := NewEngine(nil)
+	go engine.ExecuteExpr(context.TODO(), p, &EngineOptions{DisabledFunctions: []string{"rate"}}, models.RequestParams{}, results)
+	result := <-results
+	require.Error(t, result.Err)
+	assert.Equal(t, "function rate is disabled", result.Err.Error())
+}
+
+func TestValidateFunctions(t *testing.T) {
+	p, err := promql.Parse("abs(rate(http_requests_total[5m]))")
+	require.NoError(t, err)
+	nodes, _, err := p.DAG()
+	require.NoError(t, err)
+
+	assert.NoError(t, (&EngineOptions{}).validateFunctions(nodes))
+	assert.NoError(t, (&EngineOptions{DisabledFunctions: []string{"deriv"}}).validateFunctions(nodes))
+	assert.NoError(t, (&EngineOptions{EnabledFunctions: []string{"abs", "rate"}}).validateFunctions(nodes), "selectors are always allowed")
+	assert.EqualError(t, (&EngineOptions{DisabledFunctions: []string{"abs"}}).validateFunctions(nodes), "function abs is disabled")
+	assert.EqualError(t, (&EngineOptions{EnabledFunctions: []string{"abs"}}).validateFunctions(nodes), "function rate is disabled")
+
+	p, err = promql.Parse("abs(http_requests_total) * 2")
+	require.NoError(t, err)
+	nodes, _, err = p.DAG()
+	require.NoError(t, err)
+	assert.NoError(t, (&EngineOptions{DisabledFunctions: []string{"scalar"}}).validateFunctions(nodes),
+		"number literals are not the scalar function")
+}
+
+func TestValidateRangeWindows(t *testing.T) {
+	p, err := promql.Parse(`sum(rate(http_requests_total{job="api"}[30d])) / sum(rate(http_requests_total[5m]))`)
+	require.NoError(t, err)
+	nodes, _, err := p.DAG()
+	require.NoError(t, err)
+
+	assert.NoError(t, (&EngineOptions{}).validateRangeWindows(nodes))
+	assert.NoError(t, (&EngineOptions{MaxRangeWindow: 30 * 24 * time.Hour}).validateRangeWindows(nodes), "ranges at the limit are allowed")
+	assert.EqualError(t, (&EngineOptions{MaxRangeWindow: 7 * 24 * time.Hour}).validateRangeWindows(nodes),
+		`range selector http_requests_total{job="api"}[30d] exceeds the maximum range window of 1w`)
+
+	results := make(chan Query)
+	go NewEngine(nil).ExecuteExpr(context.TODO(), p, &EngineOptions{MaxRangeWindow: time.Minute}, models.RequestParams{}, results)
+	result := <-results
+	require.Error(t, result.Err)
+	assert.Contains(t, result.Err.Error(), "exceeds the maximum range window of 1m")
+}
+
+func TestExecuteExprWithMaxBlockBytes(t *testing.T) {
+	tests := []struct {
+		limit   int
+		tooMany bool
+	}{
+		{limit: 10000, tooMany: true},
+		{limit: 1 << 20},
+		{limit: 0},
+	}
+
+	for _, tt := range tests {
+		store, bounds := elementWiseStorage(100, 60)
+		p, err := promql.Parse("sum by (i) (up)")
+		require.NoError(t, err)
+
+		results := make(chan Query, 1)
+		go NewEngine(store, WithMaxBlockBytes(tt.limit)).ExecuteExpr(context.TODO(), p, &EngineOptions{},
+			models.RequestParams{Start: bounds.Start, End: bounds.End, Now: bounds.End, Step: time.Minute}, results)
+
+		r := <-results
+		require.NoError(t, r.Err)
+
+		var execErr error
+		for res := range r.Result.ResultChan() {
+			if res.Err != nil {
+				execErr = res.Err
+			}
+		}
+
+		if tt.tooMany {
+			assert.Equal(t, transform.ErrResourceExhausted, errors.Cause(execErr), "limit %d: %v", tt.limit, execErr)
+		} else {
+			assert.NoError(t, execErr, "limit %d", tt.limit)
+		}
+	}
+}
+
+// executeWithMaxBlockBytes runs the query over the store with the block limit, returning the error
+// of its results
+func executeWithMaxBlockBytes(t *testing.T, store storage.Storage, query string, limit int,
+	params models.RequestParams) error {
+	p, err := promql.Parse(query)
+	require.NoError(t, err)
+
+	results := make(chan Query, 1)
+	go NewEngine(store, WithMaxBlockBytes(limit)).ExecuteExpr(context.TODO(), p, &EngineOptions{}, params, results)
+	r := <-results
+	require.NoError(t, r.Err)
+
+	var execErr error
+	for res := range r.Result.ResultChan() {
+		if res.Err != nil {
+			execErr = res.Err
+		}
+	}
+
+	return execErr
+}
+
+func TestExecuteExprWithMaxBlockBytesInTotal(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	series := make([]fixtures.TestSeries, 100)
+	for i := range series {
+		datapoints := make(ts.Datapoints, 60)
+		for j := range datapoints {
+			datapoints[j] = ts.Datapoint{Timestamp: end.Add(-time.Duration(j) * time.Minute), Value: float64(i + j)}
+		}
+
+		series[i] = fixtures.TestSeries{
+			Tags:       models.Tags{models.MetricName: "up", "i": fmt.Sprint(i)},
+			Datapoints: datapoints,
+		}
+	}
+
+	store := fixtures.NewMockStorage(series...)
+	params := models.RequestParams{Start: end.Add(-59 * time.Minute), End: end, Now: end, Step: time.Minute}
+
+	// The bytes of the fetched block, which is at least as large as any other block of the query
+	var fetched int
+	_, err := store.FetchBlocks(context.TODO(), &storage.FetchQuery{
+		Start:    params.Start,
+		End:      params.End,
+		Interval: params.Step,
+	}, &storage.FetchOptions{ChargeBlock: func(seriesMeta []block.SeriesMeta, steps int) error {
+		fetched = block.EstimateBytes(seriesMeta, steps)
+		return nil
+	}})
+	require.NoError(t, err)
+	require.True(t, fetched > 0)
+
+	limit := fetched * 3 / 2
+	assert.NoError(t, executeWithMaxBlockBytes(t, store, "up", limit, params))
+
+	// Each block fits within the limit, but the fetched block and the block of the sum do not
+	err = executeWithMaxBlockBytes(t, store, "sum by (i) (up)", limit, params)
+	assert.Equal(t, transform.ErrResourceExhausted, errors.Cause(err), "%v", err)
+}
+
+func TestExecuteExprWithMergeReplicas(t *testing.T) {
+	// The replicas are scraped alternately, and the second lags the first by 2
+	end := time.Now().Truncate(time.Minute)
+	var replicaA, replicaB, counter ts.Datapoints
+	for i := 0; i < 6; i++ {
+		dp := ts.Datapoint{Timestamp: end.Add(time.Duration(i-5) * time.Minute), Value: float64(10 * (i + 1))}
+		if i%2 == 0 {
+			replicaA = append(replicaA, dp)
+		} else {
+			dp.Value -= 2
+			replicaB = append(replicaB, dp)
+		}
+
+		counter = append(counter, dp)
+	}
+
+	p, err := promql.Parse("increase(requests[5m])")
+	require.NoError(t, err)
+	execute := func(store storage.Storage, mergeReplicas bool) [][]float64 {
+		results := make(chan Query, 1)
+		go NewEngine(store).ExecuteExpr(context.TODO(), p, &EngineOptions{MergeReplicas: mergeReplicas},
+			models.RequestParams{Start: end, End: end, Now: end, Step: time.Minute}, results)
+		r := <-results
+		require.NoError(t, r.Err)
+
+		var values [][]float64
+		for res := range r.Result.ResultChan() {
+			require.NoError(t, res.Err)
+			iter, err := res.Block.SeriesIter()
+			require.NoError(t, err)
+			for iter.Next() {
+				series, err := iter.Current()
+				require.NoError(t, err)
+				values = append(values, series.Values())
+			}
+		}
+
+		return values
+	}
+
+	replicas := fixtures.NewMockStorage(
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "requests", "replica": "a"}, Datapoints: replicaA},
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "requests", "replica": "b"}, Datapoints: replicaB},
+	)
+	single := fixtures.NewMockStorage(
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "requests"}, Datapoints: counter},
+	)
+
+	// Merged, the replicas are the counter scraped by both rather than two series
+	expected := execute(single, false)
+	require.Len(t, expected, 1)
+	assert.Equal(t, expected, execute(replicas, true))
+	assert.Len(t, execute(replicas, false), 2)
+}
+
+func TestExecuteExprWithRegressionDecayHalfLife(t *testing.T) {
+	// The series rises by 1 a minute, then by 10 a minute over its last samples
+	end := time.Now().Truncate(time.Minute)
+	var datapoints ts.Datapoints
+	value := 0.0
+	for i := 0; i < 10; i++ {
+		if i > 6 {
+			value += 10
+		} else {
+			value++
+		}
+
+		datapoints = append(datapoints, ts.Datapoint{Timestamp: end.Add(time.Duration(i-9) * time.Minute), Value: value})
+	}
+
+	store := fixtures.NewMockStorage(fixtures.TestSeries{Tags: models.Tags{models.MetricName: "disk"}, Datapoints: datapoints})
+	p, err := promql.Parse("deriv(disk[10m])")
+	require.NoError(t, err)
+	execute := func(halfLife time.Duration) (float64, error) {
+		results := make(chan Query, 1)
+		go NewEngine(store).ExecuteExpr(context.TODO(), p, &EngineOptions{RegressionDecayHalfLife: halfLife},
+			models.RequestParams{Start: end, End: end, Now: end, Step: time.Minute}, results)
+		r := <-results
+		if r.Err != nil {
+			return 0, r.Err
+		}
+
+		var values []float64
+		for res := range r.Result.ResultChan() {
+			require.NoError(t, res.Err)
+			iter, err := res.Block.SeriesIter()
+			require.NoError(t, err)
+			for iter.Next() {
+				series, err := iter.Current()
+				require.NoError(t, err)
+				values = append(values, series.Values()...)
+			}
+		}
+
+		require.Len(t, values, 1)
+		return values[0], nil
+	}
+
+	unweighted, err := execute(0)
+	require.NoError(t, err)
+	weighted, err := execute(time.Minute)
+	require.NoError(t, err)
+	assert.True(t, weighted > unweighted, "weighted %v, unweighted %v", weighted, unweighted)
+	assert.True(t, weighted < 10.0/60, "the slope is at most the recent trend")
+
+	_, err = execute(-time.Minute)
+	assert.EqualError(t, err, "decay half life cannot be negative: -1m0s")
+}
+
+// executeInstant runs the query at the single step end, returning the metadata and values of the
+// series of its result
+func executeInstant(t *testing.T, store storage.Storage, query string, opts *EngineOptions,
+	end time.Time) ([]block.SeriesMeta, [][]float64, error) {
+	return executeRange(t, store, query, opts, end, end)
+}
+
+// executeRange runs the query with a step a minute from start to end, returning the metadata and
+// values of the series of its result
+func executeRange(t *testing.T, store storage.Storage, query string, opts *EngineOptions,
+	start, end time.Time) ([]block.SeriesMeta, [][]float64, error) {
+	p, err := promql.Parse(query)
+	require.NoError(t, err)
+
+	results := make(chan Query, 1)
+	go NewEngine(store).ExecuteExpr(context.TODO(), p, opts,
+		models.RequestParams{Start: start, End: end, Now: end, Step: time.Minute}, results)
+	r := <-results
+	if r.Err != nil {
+		return nil, nil, r.Err
+	}
+
+	var (
+		metas  []block.SeriesMeta
+		values [][]float64
+	)
+
+	for res := range r.Result.ResultChan() {
+		if res.Err != nil {
+			return nil, nil, res.Err
+		}
+
+		iter, err := res.Block.SeriesIter()
+		require.NoError(t, err)
+		metas = append(metas, iter.SeriesMeta()...)
+		for iter.Next() {
+			series, err := iter.Current()
+			require.NoError(t, err)
+			values = append(values, series.Values())
+		}
+	}
+
+	return metas, values, nil
+}
+
+// counterStorage returns a storage with a single requests counter with a sample a minute up to end
+func counterStorage(end time.Time, values ...float64) storage.Storage {
+	datapoints := make(ts.Datapoints, len(values))
+	for i, value := range values {
+		datapoints[i] = ts.Datapoint{Timestamp: end.Add(time.Duration(i-len(values)+1) * time.Minute), Value: value}
+	}
+
+	return fixtures.NewMockStorage(fixtures.TestSeries{Tags: models.Tags{models.MetricName: "requests"}, Datapoints: datapoints})
+}
+
+func TestExecuteExprWithCounterMaxValue(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	wrapped := counterStorage(end, 80, 95, 10, 30)
+	execute := func(store storage.Storage, opts *EngineOptions) [][]float64 {
+		_, values, err := executeInstant(t, store, "increase(requests[5m])", opts, end)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		return values
+	}
+
+	// The counter wraps past 100 rather than resetting to zero
+	assert.Equal(t, execute(counterStorage(end, 80, 95, 110, 130), &EngineOptions{}),
+		execute(wrapped, &EngineOptions{CounterMaxValue: 100}))
+	assert.Equal(t, execute(counterStorage(end, 80, 95, 105, 125), &EngineOptions{}),
+		execute(wrapped, &EngineOptions{}))
+
+	_, _, err := executeInstant(t, wrapped, "increase(requests[5m])", &EngineOptions{CounterMaxValue: -1}, end)
+	assert.EqualError(t, err, "counter max value cannot be negative: -1")
+}
+
+func TestExecuteExprWithMinSamples(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := counterStorage(end, 10, 20, 30)
+	for _, query := range []string{"rate(requests[5m])", "deriv(requests[5m])"} {
+		_, values, err := executeInstant(t, store, query, &EngineOptions{}, end)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		assert.False(t, math.IsNaN(values[0][0]), query)
+
+		// The window has far fewer samples
+		_, values, err = executeInstant(t, store, query, &EngineOptions{MinSamples: 10}, end)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		assert.True(t, math.IsNaN(values[0][0]), query)
+	}
+
+	_, _, err := executeInstant(t, store, "rate(requests[5m])", &EngineOptions{MinSamples: -1}, end)
+	assert.EqualError(t, err, "min samples cannot be negative: -1")
+}
+
+func TestExecuteExprWithDisableExtrapolation(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := counterStorage(end, 10, 20, 30)
+	_, extrapolated, err := executeInstant(t, store, "increase(requests[5m])", &EngineOptions{}, end)
+	require.NoError(t, err)
+	_, raw, err := executeInstant(t, store, "increase(requests[5m])", &EngineOptions{DisableExtrapolation: true}, end)
+	require.NoError(t, err)
+
+	// Raw changes are differences between samples, which are all multiples of 10
+	require.Len(t, raw, 1)
+	require.Len(t, extrapolated, 1)
+	assert.True(t, raw[0][0] > 0 && math.Mod(raw[0][0], 10) == 0, "raw change %v", raw[0][0])
+	assert.True(t, extrapolated[0][0] > raw[0][0], "extrapolated %v, raw %v", extrapolated[0][0], raw[0][0])
+}
+
+func TestExecuteExprWithResetTolerance(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	jittering := counterStorage(end, 10, 20, 19.9999, 30)
+	execute := func(store storage.Storage, opts *EngineOptions) [][]float64 {
+		_, values, err := executeInstant(t, store, "increase(requests[5m])", opts, end)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		return values
+	}
+
+	// Within the tolerance, the dip is not a reset, so the increase is that of a steady counter
+	steady := execute(counterStorage(end, 10, 20, 20, 30), &EngineOptions{})
+	assert.InDelta(t, steady[0][0], execute(jittering, &EngineOptions{ResetTolerance: 0.001})[0][0], 0.001)
+	assert.True(t, execute(jittering, &EngineOptions{})[0][0] > steady[0][0]+19, "the dip is a reset by default")
+
+	_, _, err := executeInstant(t, jittering, "increase(requests[5m])", &EngineOptions{ResetTolerance: -1}, end)
+	assert.EqualError(t, err, "reset tolerance cannot be negative: -1")
+}
+
+func TestExecuteExprWithNonNegative(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := counterStorage(end, 30, 20, 10)
+	_, values, err := executeInstant(t, store, "delta(requests[5m])", &EngineOptions{}, end)
+	require.NoError(t, err)
+	require.Len(t, values, 1)
+	assert.True(t, values[0][0] < 0)
+
+	_, values, err = executeInstant(t, store, "delta(requests[5m])", &EngineOptions{NonNegative: true}, end)
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{0}}, values)
+
+	// Counters never have negative changes, so they are unaffected
+	_, values, err = executeInstant(t, store, "increase(requests[5m])", &EngineOptions{NonNegative: true}, end)
+	require.NoError(t, err)
+	require.Len(t, values, 1)
+	assert.True(t, values[0][0] > 0)
+}
+
+func TestExecuteExprWithRegressionReference(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := counterStorage(end, 10, 20, 30, 40)
+	execute := func(query string, opts *EngineOptions) float64 {
+		_, values, err := executeInstant(t, store, query, opts, end)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		return values[0][0]
+	}
+
+	// Predicting 0s ahead gives the fitted value at the reference, which is earlier in the window
+	atEvaluation := execute("predict_linear(requests[5m], 0)", &EngineOptions{})
+	atStart := execute("predict_linear(requests[5m], 0)", &EngineOptions{RegressionReference: utils.ReferenceWindowStart})
+	assert.True(t, atStart < atEvaluation)
+
+	// The reference does not change the slope
+	assert.Equal(t, execute("deriv(requests[5m])", &EngineOptions{}),
+		execute("deriv(requests[5m])", &EngineOptions{RegressionReference: utils.ReferenceWindowStart}))
+
+	_, _, err := executeInstant(t, store, "deriv(requests[5m])", &EngineOptions{RegressionReference: 3}, end)
+	assert.EqualError(t, err, "unknown regression reference: 3")
+}
+
+func TestExecuteExprWithInterpolationMethod(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := fixtures.NewMockStorage(
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "latency", "host": "a"}, Datapoints: ts.Datapoints{{Timestamp: end, Value: 1}}},
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "latency", "host": "b"}, Datapoints: ts.Datapoints{{Timestamp: end, Value: 2}}},
+	)
+	execute := func(store storage.Storage, query string, opts *EngineOptions) [][]float64 {
+		_, values, err := executeInstant(t, store, query, opts, end)
+		require.NoError(t, err)
+		return values
+	}
+
+	assert.Equal(t, [][]float64{{1.5}}, execute(store, "quantile(0.5, latency)", &EngineOptions{}))
+	assert.Equal(t, [][]float64{{1}}, execute(store, "quantile(0.5, latency)", &EngineOptions{InterpolationMethod: utils.LowerInterpolation}))
+	assert.Equal(t, [][]float64{{2}}, execute(store, "quantile(0.5, latency)", &EngineOptions{InterpolationMethod: utils.HigherInterpolation}))
+
+	// quantile_over_time picks between the values of the window rather than of the series
+	counter := counterStorage(end, 10, 20, 30, 40)
+	linear := execute(counter, "quantile_over_time(0.5, requests[5m])", &EngineOptions{})
+	lower := execute(counter, "quantile_over_time(0.5, requests[5m])", &EngineOptions{InterpolationMethod: utils.LowerInterpolation})
+	higher := execute(counter, "quantile_over_time(0.5, requests[5m])", &EngineOptions{InterpolationMethod: utils.HigherInterpolation})
+	require.Len(t, linear, 1)
+	assert.True(t, lower[0][0] < linear[0][0] && linear[0][0] < higher[0][0])
+
+	_, _, err := executeInstant(t, store, "quantile(0.5, latency)", &EngineOptions{InterpolationMethod: 4}, end)
+	assert.EqualError(t, err, "unknown interpolation method: 4")
+}
+
+func TestExecuteExprWithUnanchoredLabelReplace(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags:       models.Tags{models.MetricName: "requests", "host": "web-01.prod"},
+		Datapoints: ts.Datapoints{{Timestamp: end, Value: 1}},
+	})
+	query := `label_replace(requests, "env", "$1", "host", "[.]([a-z]+)")`
+
+	metas, _, err := executeInstant(t, store, query, &EngineOptions{}, end)
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+	assert.NotContains(t, metas[0].Tags, "env")
+
+	metas, _, err = executeInstant(t, store, query, &EngineOptions{UnanchoredLabelReplace: true}, end)
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+	assert.Equal(t, "prod", metas[0].Tags["env"])
+}
+
+func TestExecuteExprWithIncludeGroupSize(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := fixtures.NewMockStorage(
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "latency", "job": "api", "host": "a"}, Datapoints: ts.Datapoints{{Timestamp: end, Value: 1}}},
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "latency", "job": "api", "host": "b"}, Datapoints: ts.Datapoints{{Timestamp: end, Value: 2}}},
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "up", "job": "api"}, Datapoints: ts.Datapoints{{Timestamp: end, Value: 1}}},
+	)
+	opts := &EngineOptions{IncludeGroupSize: true}
+
+	metas, values, err := executeInstant(t, store, "sum(latency)", opts, end)
+	require.NoError(t, err)
+	require.Len(t, metas, 2)
+	assert.Equal(t, models.Tags{aggregation.GroupSizeTag: "true"}, metas[1].Tags)
+	assert.Equal(t, [][]float64{{3}, {2}}, values)
+
+	// The group size counts the series kept by the set operation
+	_, values, err = executeInstant(t, store, "sum by (job) (latency and on(job) up)", opts, end)
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{3}, {2}}, values)
+
+	// Aggregations without group sizes are unaffected
+	metas, values, err = executeInstant(t, store, "max(latency)", opts, end)
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+	assert.Equal(t, [][]float64{{2}}, values)
+}
+
+func TestExecuteExprWithRollupShards(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	series := make([]fixtures.TestSeries, 0, 20)
+	for i := 0; i < 20; i++ {
+		series = append(series, fixtures.TestSeries{
+			Tags:       models.Tags{models.MetricName: "latency", "job": fmt.Sprint(i % 3), "host": fmt.Sprint(i)},
+			Datapoints: ts.Datapoints{{Timestamp: end, Value: float64(i)}},
+		})
+	}
+
+	store := fixtures.NewMockStorage(series...)
+	for _, query := range []string{"sum by (job) (latency)", "count by (job) (latency)", "avg by (job) (latency)"} {
+		_, expected, err := executeInstant(t, store, query, &EngineOptions{}, end)
+		require.NoError(t, err)
+		_, values, err := executeInstant(t, store, query, &EngineOptions{RollupShards: 4}, end)
+		require.NoError(t, err)
+		assert.Equal(t, expected, values, query)
+	}
+
+	_, _, err := executeInstant(t, store, "sum(latency)", &EngineOptions{RollupShards: -1}, end)
+	assert.EqualError(t, err, "rollup shards cannot be negative: -1")
+}
+
+func TestExecuteExprWithIncludeTies(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := fixtures.NewMockStorage(
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "latency", "host": "a"}, Datapoints: ts.Datapoints{{Timestamp: end, Value: 2}}},
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "latency", "host": "b"}, Datapoints: ts.Datapoints{{Timestamp: end, Value: 2}}},
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "latency", "host": "c"}, Datapoints: ts.Datapoints{{Timestamp: end, Value: 1}}},
+	)
+	kept := func(query string, opts *EngineOptions) int {
+		_, values, err := executeInstant(t, store, query, opts, end)
+		require.NoError(t, err)
+		count := 0
+		for _, series := range values {
+			if !math.IsNaN(series[0]) {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	assert.Equal(t, 1, kept("topk(1, latency)", &EngineOptions{}))
+	assert.Equal(t, 2, kept("topk(1, latency)", &EngineOptions{IncludeTies: true}))
+	assert.Equal(t, 1, kept("bottomk(1, latency)", &EngineOptions{IncludeTies: true}))
+}
+
+func TestExecuteExprWithStreamingAggregation(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	series := make([]fixtures.TestSeries, 0, 20)
+	for i := 0; i < 20; i++ {
+		series = append(series, fixtures.TestSeries{
+			Tags:       models.Tags{models.MetricName: "latency", "job": fmt.Sprint(i % 3), "host": fmt.Sprint(i)},
+			Datapoints: ts.Datapoints{{Timestamp: end, Value: float64(i)}},
+		})
+	}
+
+	store := fixtures.NewMockStorage(series...)
+	for _, query := range []string{"sum by (job) (latency)", "avg by (job) (latency)", "max by (job) (latency)", "quantile by (job) (0.5, latency)"} {
+		_, expected, err := executeInstant(t, store, query, &EngineOptions{}, end)
+		require.NoError(t, err)
+		_, values, err := executeInstant(t, store, query, &EngineOptions{StreamingAggregation: true}, end)
+		require.NoError(t, err)
+		assert.Equal(t, expected, values, query)
+	}
+
+	_, _, err := executeInstant(t, store, "sum(latency)", &EngineOptions{StreamingAggregation: true, RollupShards: 2}, end)
+	assert.EqualError(t, err, "rollup cannot be combined with streaming aggregation")
+}
+
+func TestExecuteExprWithLeftInclusive(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := counterStorage(end, 1, 2, 3, 4, 5, 6, 7, 8)
+	min := func(opts *EngineOptions) float64 {
+		_, values, err := executeInstant(t, store, "quantile_over_time(0, requests[5m])", opts, end)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		return values[0][0]
+	}
+
+	// The value exactly 5m before the evaluation time is only in a left inclusive window
+	assert.Equal(t, min(&EngineOptions{})-1, min(&EngineOptions{LeftInclusive: true}))
+}
+
+func TestExecuteExprWithSeriesPages(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	series := make([]fixtures.TestSeries, 0, 5)
+	for i := 0; i < 5; i++ {
+		series = append(series, fixtures.TestSeries{
+			Tags:       models.Tags{models.MetricName: "latency", "host": fmt.Sprint(i)},
+			Datapoints: ts.Datapoints{{Timestamp: end, Value: float64(i)}},
+		})
+	}
+
+	store := fixtures.NewMockStorage(series...)
+	metas, values, err := executeInstant(t, store, "latency", &EngineOptions{SeriesLimit: 2, SeriesOffset: 1}, end)
+	require.NoError(t, err)
+	require.Len(t, metas, 2)
+	assert.Equal(t, "1", metas[0].Tags["host"])
+	assert.Equal(t, "2", metas[1].Tags["host"])
+	assert.Equal(t, [][]float64{{1}, {2}}, values)
+
+	// The last page is short
+	_, values, err = executeInstant(t, store, "latency", &EngineOptions{SeriesLimit: 2, SeriesOffset: 4}, end)
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{4}}, values)
+
+	_, _, err = executeInstant(t, store, "latency", &EngineOptions{SeriesLimit: -1}, end)
+	assert.EqualError(t, err, "series limit cannot be negative: -1")
+	_, _, err = executeInstant(t, store, "latency", &EngineOptions{SeriesOffset: -1}, end)
+	assert.EqualError(t, err, "series offset cannot be negative: -1")
+}
+
+func TestExecuteExprWithSelectorLookbacks(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-10 * time.Minute)
+	datapoints := func(value float64) ts.Datapoints {
+		return ts.Datapoints{{Timestamp: start, Value: value}, {Timestamp: end, Value: value}}
+	}
+
+	store := fixtures.NewMockStorage(
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "sparse"}, Datapoints: datapoints(1)},
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "dense"}, Datapoints: datapoints(2)},
+	)
+	consolidation := ts.ConsolidationOptions{LookbackDuration: 5 * time.Minute}
+	opts := &EngineOptions{Consolidation: consolidation, SelectorLookbacks: map[string]time.Duration{"sparse": 15 * time.Minute}}
+	// gap returns the value at the step before the end, 9m after the previous sample
+	gap := func(query string, opts *EngineOptions) float64 {
+		_, values, err := executeRange(t, store, query, opts, start, end)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		return values[0][len(values[0])-2]
+	}
+
+	assert.True(t, math.IsNaN(gap("sparse", &EngineOptions{Consolidation: consolidation})))
+	assert.Equal(t, 1.0, gap("sparse", opts))
+	assert.Equal(t, 1.0, gap(`{__name__="sparse"}`, opts))
+	assert.True(t, math.IsNaN(gap("dense", opts)))
+
+	_, _, err := executeInstant(t, store, "sparse", &EngineOptions{SelectorLookbacks: map[string]time.Duration{"sparse": -time.Minute}}, end)
+	assert.EqualError(t, err, "lookback of sparse cannot be negative: -1m0s")
+}
+
+func TestEngineWithTagSanitizer(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	datapoints := ts.Datapoints{{Timestamp: end.Add(-30 * time.Second), Value: 1}}
+	store := fixtures.NewMockStorage(
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "http.requests", "host": "web-1"}, Datapoints: datapoints},
+		fixtures.TestSeries{Tags: models.Tags{models.MetricName: "limits", "host": "web.1"}, Datapoints: datapoints},
+	)
+
+	invalid := regexp.MustCompile("[^a-zA-Z0-9_]")
+	sanitizer := func(_, value string) string { return invalid.ReplaceAllString(value, "_") }
+	query := `{__name__="http.requests"} / limits`
+
+	metas, err := executeSeriesMetas(t, NewEngine(store), query, end)
+	require.NoError(t, err)
+	assert.Empty(t, metas, "series with different hosts do not match")
+
+	metas, err = executeSeriesMetas(t, NewEngine(store, WithTagSanitizer(sanitizer)), query, end)
+	require.NoError(t, err)
+	require.Len(t, metas, 1, "sanitized hosts match")
+	assert.Equal(t, `{host="web_1"}`, metas[0].SignatureString(false, false))
+
+	metas, err = executeSeriesMetas(t, NewEngine(store, WithTagSanitizer(sanitizer)), `{__name__="http.requests"}`, end)
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+	assert.Equal(t, models.Tags{models.MetricName: "http_requests", "host": "web_1"}, metas[0].Tags)
+}
+
+func TestExecuteExprWithScalars(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	tests := []struct {
+		query    string
+		expected func(step time.Time) float64
+	}{
+		{query: "(2 + 3) * 4", expected: func(time.Time) float64 { return 20 }},
+		{query: "2 ^ (1 + 2) - 1", expected: func(time.Time) float64 { return 7 }},
+		{query: "(time() + 60) - time()", expected: func(time.Time) float64 { return 60 }},
+		{query: "time() * 2", expected: func(step time.Time) float64 { return float64(step.Unix()) * 2 }},
+	}
+
+	for _, tt := range tests {
+		p, err := promql.Parse(tt.query)
+		require.NoError(t, err)
+
+		results := make(chan Query, 1)
+		go NewEngine(fixtures.NewMockStorage()).ExecuteExpr(context.TODO(), p, &EngineOptions{}, models.RequestParams{
+			Start: end.Add(-3 * time.Minute),
+			End:   end,
+			Now:   end,
+			Step:  time.Minute,
+		}, results)
+
+		r := <-results
+		require.NoError(t, r.Err, tt.query)
+		for res := range r.Result.ResultChan() {
+			require.NoError(t, res.Err, tt.query)
+			iter, err := res.Block.SeriesIter()
+			require.NoError(t, err)
+			require.True(t, iter.Next(), tt.query)
+			series, err := iter.Current()
+			require.NoError(t, err)
+			assert.Empty(t, series.Meta.Tags, "scalars have no tags: %s", tt.query)
+
+			bounds := iter.Meta().Bounds
+			require.Equal(t, 4, series.Len(), tt.query)
+			for i, value := range series.Values() {
+				assert.Equal(t, tt.expected(bounds.TimeForStep(i)), value, tt.query)
+			}
+
+			assert.False(t, iter.Next(), "scalars are a single series: %s", tt.query)
+		}
+	}
+}
+
+func TestExecuteExprWithVectorAndScalar(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-3 * time.Minute)
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags: models.Tags{models.MetricName: "up", "job": "api"},
+		Datapoints: ts.Datapoints{
+			{Timestamp: start, Value: 2},
+			{Timestamp: start.Add(time.Minute), Value: 2},
+			{Timestamp: start.Add(2 * time.Minute), Value: 2},
+		},
+	})
+
+	tests := []struct {
+		query    string
+		expected func(seconds float64) float64
+	}{
+		{query: "up * time()", expected: func(seconds float64) float64 { return 2 * seconds }},
+		{query: "time() * up", expected: func(seconds float64) float64 { return 2 * seconds }},
+		{query: "time() - up", expected: func(seconds float64) float64 { return seconds - 2 }},
+		{query: "time() > bool up", expected: func(float64) float64 { return 1 }},
+		{query: "up < time()", expected: func(float64) float64 { return 2 }},
+	}
+
+	for _, tt := range tests {
+		p, err := promql.Parse(tt.query)
+		require.NoError(t, err)
+
+		results := make(chan Query, 1)
+		go NewEngine(store).ExecuteExpr(context.TODO(), p, &EngineOptions{}, models.RequestParams{
+			Start: start,
+			End:   end,
+			Now:   end,
+			Step:  time.Minute,
+		}, results)
+
+		r := <-results
+		require.NoError(t, r.Err, tt.query)
+		var series int
+		for res := range r.Result.ResultChan() {
+			require.NoError(t, res.Err, tt.query)
+			iter, err := res.Block.SeriesIter()
+			require.NoError(t, err)
+			bounds := iter.Meta().Bounds
+			for iter.Next() {
+				s, err := iter.Current()
+				require.NoError(t, err)
+				assert.Equal(t, "api", s.Meta.Tags["job"], "the vector keeps its labels: %s", tt.query)
+				// The step at the end of the range has no sample of up
+				values := s.Values()
+				require.Len(t, values, 4, tt.query)
+				for i, value := range values[:3] {
+					seconds := float64(bounds.TimeForStep(i).Unix())
+					assert.Equal(t, tt.expected(seconds), value, tt.query)
+				}
+
+				series++
+			}
+		}
+
+		assert.Equal(t, 1, series, tt.query)
+	}
+}
+
+func TestUsesSampleTimes(t *testing.T) {
+	stepOp, err := functions.NewTimestampOp(nil, functions.TimestampOptions{})
+	require.NoError(t, err)
+	sampleOp, err := functions.NewTimestampOp(nil, functions.TimestampOptions{SampleTimes: true})
+	require.NoError(t, err)
+
+	assert.False(t, usesSampleTimes(parser.Nodes{{ID: parser.NodeID(0), Op: stepOp}}))
+	assert.True(t, usesSampleTimes(parser.Nodes{{ID: parser.NodeID(0), Op: stepOp}, {ID: parser.NodeID(1), Op: sampleOp}}))
+}
+
+func TestExecuteExprWithTimestampSampleTimes(t *testing.T) {
+	start := time.Now().Truncate(time.Minute).Add(-2 * time.Minute)
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags: models.Tags{models.MetricName: "up"},
+		Datapoints: ts.Datapoints{
+			{Timestamp: start, Value: 1},
+			{Timestamp: start.Add(90 * time.Second), Value: 2},
+		},
+	})
+
+	execute := func(sampleTimes bool) []float64 {
+		p, err := promql.Parse("timestamp(up)")
+		require.NoError(t, err)
+
+		results := make(chan Query, 1)
+		end := start.Add(2 * time.Minute)
+		go NewEngine(store).ExecuteExpr(context.TODO(), p, &EngineOptions{TimestampSampleTimes: sampleTimes},
+			models.RequestParams{Start: start, End: end, Now: end, Step: time.Minute}, results)
+
+		r := <-results
+		require.NoError(t, r.Err)
+
+		var values []float64
+		for res := range r.Result.ResultChan() {
+			require.NoError(t, res.Err)
+			iter, err := res.Block.SeriesIter()
+			require.NoError(t, err)
+			require.True(t, iter.Next())
+			series, err := iter.Current()
+			require.NoError(t, err)
+			values = append(values, series.Values()...)
+		}
+
+		require.True(t, len(values) >= 2)
+		return values[:2]
+	}
+
+	// The first sample is carried forward onto the second step
+	seconds := float64(start.Unix())
+	assert.Equal(t, []float64{seconds, seconds + 60}, execute(false))
+	assert.Equal(t, []float64{seconds, seconds}, execute(true))
+}
+
+// rangeStorage records the range of the block fetches
+type rangeStorage struct {
+	storage.Storage
+	start, end time.Time
+}
+
+func (s *rangeStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	s.start, s.end = query.Start, query.End
+	return s.Storage.FetchBlocks(ctx, query, options)
+}
+
+func TestExecuteExprWithAtModifier(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-5 * time.Minute)
+	at := end.Add(-20 * time.Minute)
+	values := make([]float64, 40)
+	for i := range values {
+		values[i] = float64(i)
+	}
+
+	for _, query := range []string{"requests %s", "rate(requests[5m] %s)"} {
+		var ranges [][2]time.Time
+		var results [][][]float64
+		for _, modifiers := range []string{"@ %d offset 10m", "offset 10m @ %d"} {
+			store := &rangeStorage{Storage: counterStorage(end, values...)}
+			q := fmt.Sprintf(query, fmt.Sprintf(modifiers, at.Unix()))
+			_, series, err := executeRange(t, store, q, &EngineOptions{}, start, end)
+			require.NoError(t, err, q)
+			require.Len(t, series, 1, q)
+			ranges = append(ranges, [2]time.Time{store.start, store.end})
+			results = append(results, series)
+
+			// The selector is evaluated at the instant, so every step has the same value
+			for _, value := range series[0] {
+				assert.Equal(t, series[0][0], value, q)
+			}
+		}
+
+		// Either order of the modifiers fetches the same range, ending at the offset instant
+		assert.Equal(t, ranges[0], ranges[1], query)
+		assert.Equal(t, at.Add(-10*time.Minute), ranges[0][1], query)
+		assert.Equal(t, results[0], results[1], query)
+	}
+
+	// The sample 30 minutes before the end of the query
+	_, series, err := executeRange(t, counterStorage(end, values...),
+		fmt.Sprintf("requests @ %d offset 10m", at.Unix()), &EngineOptions{}, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, float64(9), series[0][0])
+
+	_, err = promql.Parse(fmt.Sprintf("requests @ %d @ %d", at.Unix(), at.Unix()))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "@ <timestamp> may not be set multiple times")
+}
+
+func TestExecuteExprWithAtStartOrEnd(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-5 * time.Minute)
+	store := counterStorage(end, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	for query, expected := range map[string]float64{"requests @ start()": 5, "requests @ end()": 10} {
+		_, series, err := executeRange(t, store, query, &EngineOptions{}, start, end)
+		require.NoError(t, err, query)
+		require.Len(t, series, 1, query)
+		for _, value := range series[0] {
+			assert.Equal(t, expected, value, query)
+		}
+	}
+}
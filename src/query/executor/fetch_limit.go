@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/storage"
+)
+
+// WithMaxConcurrentFetches bounds how many storage fetches run in parallel for a single query,
+// e.g. when a selector on __name__ expands to many metrics. Fetches beyond the limit queue until
+// another fetch of the query completes. Limits which are not positive are ignored
+func WithMaxConcurrentFetches(n int) Option {
+	return func(e *Engine) {
+		e.maxConcurrentFetches = n
+	}
+}
+
+// limitedStorage is a storage which runs at most a fixed number of fetches at once
+type limitedStorage struct {
+	storage.Storage
+	slots chan struct{}
+}
+
+// limitFetches wraps the storage to run at most limit fetches at once, returning the storage
+// as is if the limit is not positive
+func limitFetches(store storage.Storage, limit int) storage.Storage {
+	if limit <= 0 {
+		return store
+	}
+
+	return &limitedStorage{Storage: store, slots: make(chan struct{}, limit)}
+}
+
+// acquire waits for a free slot, returning an error if the context is done first
+func (s *limitedStorage) acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *limitedStorage) release() {
+	<-s.slots
+}
+
+func (s *limitedStorage) Fetch(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (*storage.FetchResult, error) {
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	defer s.release()
+	return s.Storage.Fetch(ctx, query, options)
+}
+
+func (s *limitedStorage) FetchTags(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (*storage.SearchResults, error) {
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	defer s.release()
+	return s.Storage.FetchTags(ctx, query, options)
+}
+
+func (s *limitedStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	if err := s.acquire(ctx); err != nil {
+		return block.Result{}, err
+	}
+
+	defer s.release()
+	return s.Storage.FetchBlocks(ctx, query, options)
+}
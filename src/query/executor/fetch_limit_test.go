@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyStorage records the most fetches which were running at once
+type concurrencyStorage struct {
+	*metricStorage
+	sync.Mutex
+	running    int
+	maxRunning int
+	fetches    int
+}
+
+func (s *concurrencyStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	s.Lock()
+	s.running++
+	s.fetches++
+	if s.running > s.maxRunning {
+		s.maxRunning = s.running
+	}
+	s.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	s.Lock()
+	s.running--
+	s.Unlock()
+	return s.metricStorage.FetchBlocks(ctx, query, options)
+}
+
+func TestExecuteExprWithMaxConcurrentFetches(t *testing.T) {
+	store := &concurrencyStorage{metricStorage: newMetricStorage()}
+	p, err := promql.Parse("up + up + up + up + down + down + down + down")
+	require.NoError(t, err)
+
+	bounds := store.bounds
+	results := make(chan Query, 1)
+	engine := NewEngine(store, WithMaxConcurrentFetches(2))
+	go engine.ExecuteExpr(context.TODO(), p, &EngineOptions{}, models.RequestParams{
+		Start: bounds.Start,
+		End:   bounds.End,
+		Now:   bounds.End,
+		Step:  time.Minute,
+	}, results)
+
+	r := <-results
+	require.NoError(t, r.Err)
+	for res := range r.Result.ResultChan() {
+		require.NoError(t, res.Err)
+	}
+
+	assert.Equal(t, 8, store.fetches)
+	assert.True(t, store.maxRunning <= 2, "ran %d fetches at once", store.maxRunning)
+}
+
+func TestLimitFetchesIgnoresNonPositiveLimits(t *testing.T) {
+	store := newMetricStorage()
+	assert.Equal(t, storage.Storage(store), limitFetches(store, 0))
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/storage/mock"
+	"github.com/m3db/m3/src/query/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fusedQuery = "abs(round(ceil(clamp_max(up, 7.5)), 2))"
+
+// elementWiseStorage returns a storage serving a single block of the series
+func elementWiseStorage(numSeries, numSteps int) (mock.Storage, block.Bounds) {
+	now := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: now.Add(-time.Duration(numSteps-1) * time.Minute), End: now, StepSize: time.Minute}
+	values := make([][]float64, numSeries)
+	metas := make([]block.SeriesMeta, numSeries)
+	for i := range values {
+		values[i] = make([]float64, numSteps)
+		for j := range values[i] {
+			values[i][j] = float64(i+j)/3 - 5
+		}
+
+		metas[i] = block.SeriesMeta{Tags: models.Tags{models.MetricName: "up", "i": fmt.Sprint(i)}}
+	}
+
+	store := mock.NewMockStorage()
+	store.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)},
+	}, nil)
+
+	return store, bounds
+}
+
+func executeElementWise(t testing.TB, store mock.Storage, bounds block.Bounds, opts *EngineOptions) Result {
+	p, err := promql.Parse(fusedQuery)
+	require.NoError(t, err)
+
+	results := make(chan Query, 1)
+	go NewEngine(store).ExecuteExpr(context.TODO(), p, opts, models.RequestParams{
+		Start: bounds.Start,
+		End:   bounds.End,
+		Now:   bounds.End,
+		Step:  time.Minute,
+	}, results)
+
+	r := <-results
+	require.NoError(t, r.Err)
+	return r.Result
+}
+
+func TestFuseElementWiseOpsMatchesUnfused(t *testing.T) {
+	// Element-wise functions update values in place, so each query needs a fresh block
+	store, bounds := elementWiseStorage(10, 5)
+	unfused := resultValues(t, executeElementWise(t, store, bounds, &EngineOptions{}))
+	store, bounds = elementWiseStorage(10, 5)
+	fused := resultValues(t, executeElementWise(t, store, bounds, &EngineOptions{FuseElementWiseOps: true}))
+	require.Len(t, unfused, 10)
+	assert.Equal(t, unfused, fused)
+}
+
+func benchmarkElementWise(b *testing.B, opts *EngineOptions) {
+	store, bounds := elementWiseStorage(1000, 60)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result := executeElementWise(b, store, bounds, opts)
+		for r := range result.ResultChan() {
+			require.NoError(b, r.Err)
+			iter, err := r.Block.StepIter()
+			require.NoError(b, err)
+			for iter.Next() {
+				_, err := iter.Current()
+				require.NoError(b, err)
+			}
+		}
+	}
+}
+
+func BenchmarkElementWiseOps(b *testing.B) {
+	benchmarkElementWise(b, &EngineOptions{})
+}
+
+func BenchmarkFusedElementWiseOps(b *testing.B) {
+	benchmarkElementWise(b, &EngineOptions{FuseElementWiseOps: true})
+}
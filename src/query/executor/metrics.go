@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/parser"
+
+	"github.com/uber-go/tally"
+)
+
+// Option configures the engine
+type Option func(*Engine)
+
+// WithMetricsScope emits the number of series entering and leaving each node of a query, and
+// the number of series fetched, to the scope so that dashboards can show the shape of queries
+func WithMetricsScope(scope tally.Scope) Option {
+	return func(e *Engine) {
+		e.scope = scope
+	}
+}
+
+// seriesInNode counts the series of the blocks entering a node before processing them
+type seriesInNode struct {
+	node    transform.OpNode
+	counter tally.Counter
+}
+
+func (n *seriesInNode) Process(ID parser.NodeID, b block.Block) error {
+	if err := countSeries(b, n.counter); err != nil {
+		return err
+	}
+
+	return n.node.Process(ID, b)
+}
+
+// seriesOutNode counts the series of the blocks a node emits. It is added to the controller
+// of the node ahead of its downstream transforms
+type seriesOutNode struct {
+	counters []tally.Counter
+}
+
+func (n *seriesOutNode) Process(_ parser.NodeID, b block.Block) error {
+	for _, counter := range n.counters {
+		if err := countSeries(b, counter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func countSeries(b block.Block, counter tally.Counter) error {
+	iter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+	counter.Inc(int64(len(iter.SeriesMeta())))
+	return nil
+}
+
+// withSeriesMetrics records the series entering the node and leaving its controller
+func withSeriesMetrics(scope tally.Scope, opType string, node transform.OpNode, controller *transform.Controller) transform.OpNode {
+	if scope == nil {
+		return node
+	}
+
+	tagged := scope.Tagged(map[string]string{"type": opType})
+	controller.AddTransform(&seriesOutNode{counters: []tally.Counter{tagged.Counter("series.out")}})
+	return &seriesInNode{node: node, counter: tagged.Counter("series.in")}
+}
+
+// withFetchMetrics records the series a source fetches
+func withFetchMetrics(scope tally.Scope, opType string, controller *transform.Controller) {
+	if scope == nil {
+		return
+	}
+
+	controller.AddTransform(&seriesOutNode{counters: []tally.Counter{
+		scope.Tagged(map[string]string{"type": opType}).Counter("series.out"),
+		scope.Counter("fetched.series"),
+	}})
+}
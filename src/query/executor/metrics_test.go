@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/storage/mock"
+	"github.com/m3db/m3/src/query/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestEngineWithMetricsScope(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds([][]float64{
+		{1, 2, 3, 4, 5},
+		{5, 6, 7, 8, 9},
+		{0, 1, 0, 1, 0},
+	}, nil)
+	store := mock.NewMockStorage()
+	store.SetFetchBlocksResult(block.Result{Blocks: []block.Block{test.NewBlockFromValues(bounds, values)}}, nil)
+
+	now := time.Now()
+	params := models.RequestParams{
+		Start: now.Add(-4 * time.Minute),
+		End:   now,
+		Now:   now,
+		Step:  time.Minute,
+	}
+
+	p, err := promql.Parse("sum(up)")
+	require.NoError(t, err)
+
+	scope := tally.NewTestScope("", nil)
+	results := make(chan Query)
+	go NewEngine(store, WithMetricsScope(scope)).ExecuteExpr(context.TODO(), p, &EngineOptions{}, params, results)
+	for r := range results {
+		require.NoError(t, r.Err)
+		assert.Len(t, resultValues(t, r.Result), 1)
+	}
+
+	counters := make(map[string]int64)
+	for _, counter := range scope.Snapshot().Counters() {
+		counters[counter.Name()+"+"+counter.Tags()["type"]] = counter.Value()
+	}
+
+	assert.Equal(t, map[string]int64{
+		"fetched.series+":  3,
+		"series.out+fetch": 3,
+		"series.in+sum":    3,
+		"series.out+sum":   1,
+	}, counters)
+}
+
+// closeTrackingBlock counts the step iterators of the block left open
+type closeTrackingBlock struct {
+	block.Block
+	open int
+}
+
+func (b *closeTrackingBlock) StepIter() (block.StepIter, error) {
+	iter, err := b.Block.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	b.open++
+	return &closeTrackingStepIter{StepIter: iter, block: b}, nil
+}
+
+type closeTrackingStepIter struct {
+	block.StepIter
+	block *closeTrackingBlock
+}
+
+func (i *closeTrackingStepIter) Close() {
+	i.block.open--
+	i.StepIter.Close()
+}
+
+func TestCountSeriesClosesIterator(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := &closeTrackingBlock{Block: test.NewBlockFromValues(bounds, values)}
+	counter := tally.NewTestScope("", nil).Counter("series")
+	require.NoError(t, countSeries(b, counter))
+	assert.Equal(t, 0, b.open)
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/storage/mock"
+	"github.com/m3db/m3/src/query/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricStorage serves a fresh block of the series of the fetched metric name
+type metricStorage struct {
+	mock.Storage
+	bounds block.Bounds
+	series map[string][]block.SeriesMeta
+	values map[string][][]float64
+}
+
+func (s *metricStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	var name string
+	for _, matcher := range query.TagMatchers {
+		if matcher.Name == models.MetricName {
+			name = matcher.Value
+		}
+	}
+
+	return block.Result{
+		Blocks: []block.Block{test.NewBlockFromValuesWithSeriesMeta(s.bounds, s.series[name], s.values[name])},
+	}, nil
+}
+
+func newMetricStorage() *metricStorage {
+	now := time.Now().Truncate(time.Minute)
+	store := &metricStorage{
+		Storage: mock.NewMockStorage(),
+		bounds:  block.Bounds{Start: now.Add(-3 * time.Minute), End: now, StepSize: time.Minute},
+		series:  make(map[string][]block.SeriesMeta),
+		values:  make(map[string][][]float64),
+	}
+
+	add := func(name string, values []float64, tags ...string) {
+		meta := block.SeriesMeta{Tags: models.Tags{models.MetricName: name}}
+		for i := 0; i < len(tags); i += 2 {
+			meta.Tags[tags[i]] = tags[i+1]
+		}
+
+		store.series[name] = append(store.series[name], meta)
+		store.values[name] = append(store.values[name], values)
+	}
+
+	nan := math.NaN()
+	add("up", []float64{1, 2, 3, 4}, "job", "x", "instance", "1", "zone", "a")
+	add("up", []float64{5, nan, 7, 8}, "job", "x", "instance", "2", "zone", "b")
+	add("up", []float64{9, 10, 11, 12}, "job", "y", "instance", "1", "zone", "a")
+	add("up", []float64{13, 14, nan, 16}, "job", "y", "instance", "3", "zone", "a")
+	add("down", []float64{1, nan, 1, 1}, "job", "x", "instance", "9", "zone", "a")
+	add("down", []float64{nan, 1, 1, nan}, "job", "y", "instance", "1", "zone", "b")
+	add("down", []float64{1, 1, 1, 1}, "job", "z", "instance", "1", "zone", "a")
+	return store
+}
+
+func executeWithStorage(t *testing.T, store storage.Storage, query string, opts *EngineOptions) Result {
+	p, err := promql.Parse(query)
+	require.NoError(t, err)
+
+	bounds := newMetricStorage().bounds
+	results := make(chan Query, 1)
+	go NewEngine(store).ExecuteExpr(context.TODO(), p, opts, models.RequestParams{
+		Start: bounds.Start,
+		End:   bounds.End,
+		Now:   bounds.End,
+		Step:  time.Minute,
+	}, results)
+
+	r := <-results
+	require.NoError(t, r.Err)
+	return r.Result
+}
+
+// resultSeries returns the values of the result series by their tags
+func resultSeries(t *testing.T, result Result) map[string][]float64 {
+	series := make(map[string][]float64)
+	for r := range result.ResultChan() {
+		require.NoError(t, r.Err)
+		iter, err := r.Block.SeriesIter()
+		require.NoError(t, err)
+		for iter.Next() {
+			s, err := iter.Current()
+			require.NoError(t, err)
+			series[s.Meta.Tags.ID()] = s.Values()
+		}
+	}
+
+	return series
+}
+
+func TestAggregationPushDownMatchesOriginal(t *testing.T) {
+	queries := []string{
+		"sum by (job) (up and on(job) down)",
+		"max by (job, zone) (up and on(zone) down)",
+		"count without (instance) (up and on(job) down)",
+		"avg without (instance) (up and ignoring(instance) down)",
+		"min by (job) (up and on(job) down) and on(job) down",
+	}
+
+	for _, query := range queries {
+		original := resultSeries(t, executeWithStorage(t, newMetricStorage(), query,
+			&EngineOptions{DisableAggregationPushDown: true}))
+		pushed := resultSeries(t, executeWithStorage(t, newMetricStorage(), query, &EngineOptions{}))
+		require.NotEmpty(t, original, query)
+		require.Equal(t, len(original), len(pushed), query)
+		for id, values := range original {
+			require.Contains(t, pushed, id, query)
+			assert.Len(t, pushed[id], len(values), query)
+			test.EqualsWithNans(t, values, pushed[id])
+		}
+	}
+}
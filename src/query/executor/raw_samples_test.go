@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/test/fixtures"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// executeRawSamples runs the query against the series, returning the raw samples of the results
+func executeRawSamples(t *testing.T, store storage.Storage, end time.Time, opts *EngineOptions) ([]transform.RawSeries, bool) {
+	p, err := promql.Parse("up")
+	require.NoError(t, err)
+
+	results := make(chan Query, 1)
+	go NewEngine(store).ExecuteExpr(context.TODO(), p, opts, models.RequestParams{
+		Start: end.Add(-3 * time.Minute),
+		End:   end,
+		Now:   end,
+		Step:  time.Minute,
+	}, results)
+
+	r := <-results
+	require.NoError(t, r.Err)
+
+	var (
+		rawSamples []transform.RawSeries
+		truncated  bool
+	)
+
+	for res := range r.Result.ResultChan() {
+		require.NoError(t, res.Err)
+		rawSamples = append(rawSamples, res.RawSamples...)
+		truncated = truncated || res.RawSamplesTruncated
+	}
+
+	return rawSamples, truncated
+}
+
+func TestExecuteExprWithRawSamples(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	tags := models.Tags{models.MetricName: "up", "job": "api"}
+	datapoints := ts.Datapoints{
+		{Timestamp: end.Add(-150 * time.Second), Value: 1},
+		{Timestamp: end.Add(-150 * time.Second), Value: 2},
+		{Timestamp: end.Add(-30 * time.Second), Value: 3},
+	}
+
+	store := fixtures.NewMockStorage(fixtures.TestSeries{Tags: tags, Datapoints: datapoints})
+	rawSamples, _ := executeRawSamples(t, store, end, &EngineOptions{})
+	assert.Empty(t, rawSamples, "raw samples are only returned when requested")
+
+	rawSamples, truncated := executeRawSamples(t, store, end, &EngineOptions{IncludeRawSamples: true})
+	require.Len(t, rawSamples, 1)
+	assert.Equal(t, tags, rawSamples[0].Tags)
+	assert.Equal(t, datapoints, rawSamples[0].Datapoints, "duplicates are returned before consolidation")
+	assert.False(t, truncated)
+
+	rawSamples, truncated = executeRawSamples(t, store, end, &EngineOptions{IncludeRawSamples: true, MaxRawSamples: 2})
+	require.Len(t, rawSamples, 1)
+	assert.Equal(t, datapoints[:2], rawSamples[0].Datapoints)
+	assert.True(t, truncated)
+}
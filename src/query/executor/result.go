@@ -21,9 +21,11 @@
 package executor
 
 import (
+	"context"
 	"sync"
 
 	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
 	"github.com/m3db/m3/src/query/parser"
 
 	"github.com/pkg/errors"
@@ -48,19 +50,36 @@ type Result interface {
 // ResultNode is used to provide the results to the caller from the query execution
 type ResultNode struct {
 	mu         sync.Mutex
+	ctx        context.Context
 	resultChan chan ResultChan
 	aborted    bool
+	warnings   *transform.Warnings
+	rawSamples *transform.RawSamples
+	hooks      []ResultHook
 }
 
 // ResultChan has the result from a block
 type ResultChan struct {
 	Block block.Block
 	Err   error
+	// Warnings are advisory messages raised while computing the block
+	Warnings []string
+	// RawSamples, when requested, are the raw datapoints fetched from storage since the previous
+	// block, before consolidation
+	RawSamples []transform.RawSeries
+	// RawSamplesTruncated is set once raw datapoints have been dropped as the limit was reached
+	RawSamplesTruncated bool
 }
 
-func newResultNode() *ResultNode {
+// newResultNode creates a result node which stops waiting for the caller to read results once
+// the context is done, so that results which are never drained do not block the query forever
+func newResultNode(
+	ctx context.Context,
+	warnings *transform.Warnings,
+	rawSamples *transform.RawSamples,
+) *ResultNode {
 	blocks := make(chan ResultChan, channelSize)
-	return &ResultNode{resultChan: blocks}
+	return &ResultNode{ctx: ctx, resultChan: blocks, warnings: warnings, rawSamples: rawSamples}
 }
 
 // Process the block
@@ -69,11 +88,28 @@ func (r *ResultNode) Process(ID parser.NodeID, block block.Block) error {
 		return errAborted
 	}
 
-	r.resultChan <- ResultChan{
-		Block: block,
+	result, owned, err := applyResultHooks(r.hooks, block)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	rawSamples, truncated := r.rawSamples.Drain()
+	select {
+	case r.resultChan <- ResultChan{
+		Block:               result,
+		Warnings:            r.warnings.Drain(),
+		RawSamples:          rawSamples,
+		RawSamplesTruncated: truncated,
+	}:
+		return nil
+	case <-r.ctx.Done():
+		// A block from the hooks is not closed by the sender, and is never read
+		if owned {
+			result.Close()
+		}
+
+		return r.ctx.Err()
+	}
 }
 
 // ResultChan return a channel to stream back resultChan to the client
@@ -90,9 +126,13 @@ func (r *ResultNode) abort(err error) {
 	}
 
 	r.aborted = true
-	r.resultChan <- ResultChan{
+	select {
+	case r.resultChan <- ResultChan{
 		Err: err,
+	}:
+	case <-r.ctx.Done():
 	}
+
 	close(r.resultChan)
 }
 
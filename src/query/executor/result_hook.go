@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"github.com/m3db/m3/src/query/block"
+)
+
+// ResultHook transforms or inspects each final block of a query before it is returned, e.g. to
+// relabel, redact or sample results without changing the query graph
+type ResultHook interface {
+	// OnResult returns false when the given block is used as it is, and otherwise the block to
+	// use in its place, which then belongs to the hooks and is closed once replaced or read.
+	// Errors abort the query
+	OnResult(block.Block) (block.Block, bool, error)
+}
+
+// WithResultHooks registers hooks run on the results of every query, in registration order
+func WithResultHooks(hooks ...ResultHook) Option {
+	return func(e *Engine) {
+		e.resultHooks = append(e.resultHooks, hooks...)
+	}
+}
+
+// applyResultHooks runs the hooks in order, each on the block returned by the previous one. It
+// returns true if the result was returned by a hook, in which case it is not closed by the node
+// which sent the block the hooks were first run on
+func applyResultHooks(hooks []ResultHook, b block.Block) (block.Block, bool, error) {
+	owned := false
+	for _, hook := range hooks {
+		next, replaced, err := hook.OnResult(b)
+		if owned && (err != nil || replaced) {
+			b.Close()
+		}
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		if replaced {
+			b, owned = next, true
+		}
+	}
+
+	return b, owned, nil
+}
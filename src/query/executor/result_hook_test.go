@@ -0,0 +1,229 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/fixtures"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dropTagHook rebuilds each block without a tag, recording its name once run
+type dropTagHook struct {
+	tag   string
+	calls *[]string
+}
+
+func (h dropTagHook) OnResult(b block.Block) (block.Block, bool, error) {
+	*h.calls = append(*h.calls, h.tag)
+	iter, err := b.SeriesIter()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var (
+		metas  []block.SeriesMeta
+		values [][]float64
+	)
+
+	for iter.Next() {
+		series, err := iter.Current()
+		if err != nil {
+			return nil, false, err
+		}
+
+		meta := series.Meta
+		tags := make(models.Tags, len(meta.Tags))
+		for k, v := range meta.Tags {
+			if k != h.tag {
+				tags[k] = v
+			}
+		}
+
+		meta.Tags = tags
+		metas = append(metas, meta)
+		values = append(values, series.Values())
+	}
+
+	return test.NewBlockFromValuesWithSeriesMeta(iter.Meta().Bounds, metas, values), true, nil
+}
+
+type failingHook struct{}
+
+func (failingHook) OnResult(block.Block) (block.Block, bool, error) {
+	return nil, false, errors.New("hook failed")
+}
+
+// executeSeriesMetas runs the query against the series, returning the series metas of the results
+func executeSeriesMetas(t *testing.T, engine *Engine, query string, end time.Time) ([]block.SeriesMeta, error) {
+	p, err := promql.Parse(query)
+	require.NoError(t, err)
+
+	results := make(chan Query, 1)
+	go engine.ExecuteExpr(context.TODO(), p, &EngineOptions{}, models.RequestParams{
+		Start: end.Add(-3 * time.Minute),
+		End:   end,
+		Now:   end,
+		Step:  time.Minute,
+	}, results)
+
+	r := <-results
+	require.NoError(t, r.Err)
+
+	var metas []block.SeriesMeta
+	for res := range r.Result.ResultChan() {
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		iter, err := res.Block.SeriesIter()
+		require.NoError(t, err)
+		metas = append(metas, iter.SeriesMeta()...)
+	}
+
+	return metas, nil
+}
+
+func TestEngineWithResultHooks(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	tags := models.Tags{models.MetricName: "up", "job": "api", "token": "secret", "tenant": "a"}
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags:       tags,
+		Datapoints: ts.Datapoints{{Timestamp: end.Add(-30 * time.Second), Value: 1}},
+	})
+
+	var calls []string
+	engine := NewEngine(store,
+		WithResultHooks(dropTagHook{tag: "token", calls: &calls}),
+		WithResultHooks(dropTagHook{tag: "tenant", calls: &calls}))
+	metas, err := executeSeriesMetas(t, engine, "up", end)
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+	assert.Equal(t, models.Tags{models.MetricName: "up", "job": "api"}, metas[0].Tags)
+	assert.Equal(t, []string{"token", "tenant"}, calls, "hooks run in registration order")
+
+	_, err = executeSeriesMetas(t, NewEngine(store, WithResultHooks(failingHook{})), "up", end)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hook failed")
+}
+
+// recordingHook replaces each block with a block recording when it is closed
+type recordingHook struct {
+	closed chan struct{}
+}
+
+func (h recordingHook) OnResult(b block.Block) (block.Block, bool, error) {
+	return closeRecordingBlock{Block: b, closed: h.closed}, true, nil
+}
+
+func TestEngineWithResultHooksClosesReplacedBlocks(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags:       models.Tags{models.MetricName: "up", "token": "secret"},
+		Datapoints: ts.Datapoints{{Timestamp: end.Add(-30 * time.Second), Value: 1}},
+	})
+
+	var calls []string
+	closed := make(chan struct{})
+	engine := NewEngine(store,
+		WithResultHooks(recordingHook{closed: closed}),
+		WithResultHooks(dropTagHook{tag: "token", calls: &calls}))
+	metas, err := executeSeriesMetas(t, engine, "up", end)
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+
+	select {
+	case <-closed:
+	default:
+		t.Fatal("the block replaced by a later hook was not closed")
+	}
+}
+
+// countingBlock counts closes, and holds a slice so that it cannot be compared
+type countingBlock struct {
+	block.Block
+	closes []int
+}
+
+func newCountingBlock() countingBlock {
+	return countingBlock{closes: make([]int, 1)}
+}
+
+func (b countingBlock) Close() error {
+	b.closes[0]++
+	return nil
+}
+
+// replacingHook replaces each block with the next of its blocks
+type replacingHook struct {
+	blocks *[]countingBlock
+}
+
+func (h replacingHook) OnResult(block.Block) (block.Block, bool, error) {
+	next := (*h.blocks)[0]
+	*h.blocks = (*h.blocks)[1:]
+	return next, true, nil
+}
+
+type keepingHook struct{}
+
+func (keepingHook) OnResult(block.Block) (block.Block, bool, error) {
+	return nil, false, nil
+}
+
+func TestApplyResultHooksClosesOwnedBlocks(t *testing.T) {
+	input, first, second := newCountingBlock(), newCountingBlock(), newCountingBlock()
+	blocks := []countingBlock{first, second}
+	result, owned, err := applyResultHooks([]ResultHook{
+		replacingHook{blocks: &blocks},
+		keepingHook{},
+		replacingHook{blocks: &blocks},
+	}, input)
+	require.NoError(t, err)
+	assert.True(t, owned)
+	assert.Equal(t, second, result)
+	assert.Equal(t, 0, input.closes[0], "the input block is closed by its sender")
+	assert.Equal(t, 1, first.closes[0])
+	assert.Equal(t, 0, second.closes[0])
+
+	blocks = []countingBlock{first}
+	_, owned, err = applyResultHooks([]ResultHook{replacingHook{blocks: &blocks}, failingHook{}}, input)
+	require.Error(t, err)
+	assert.False(t, owned)
+	assert.Equal(t, 2, first.closes[0], "a replaced block is closed when a later hook fails")
+
+	result, owned, err = applyResultHooks([]ResultHook{keepingHook{}}, input)
+	require.NoError(t, err)
+	assert.False(t, owned)
+	assert.Equal(t, input, result)
+	assert.Equal(t, 0, input.closes[0])
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/m3db/m3/src/query/util/execution"
 
 	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
 )
 
 // ExecutionState represents the execution hierarchy
@@ -39,17 +40,18 @@ type ExecutionState struct {
 	sources    []parser.Source
 	resultNode Result
 	storage    storage.Storage
+	scope      tally.Scope
 }
 
 // CreateSource creates a source node
 func CreateSource(ID parser.NodeID, params SourceParams, storage storage.Storage, options transform.Options) (parser.Source, *transform.Controller) {
-	controller := &transform.Controller{ID: ID}
+	controller := &transform.Controller{ID: ID, Options: options}
 	return params.Node(controller, storage, options), controller
 }
 
 // CreateTransform creates a transform node which works on functions and contains state
-func CreateTransform(ID parser.NodeID, params transform.Params) (transform.OpNode, *transform.Controller) {
-	controller := &transform.Controller{ID: ID}
+func CreateTransform(ID parser.NodeID, params transform.Params, options transform.Options) (transform.OpNode, *transform.Controller) {
+	controller := &transform.Controller{ID: ID, Options: options}
 	node := params.Node(controller)
 
 	switch node.(type) {
@@ -72,10 +74,23 @@ type SourceParams interface {
 
 // GenerateExecutionState creates an execution state from the physical plan
 func GenerateExecutionState(pplan plan.PhysicalPlan, storage storage.Storage) (*ExecutionState, error) {
+	return generateExecutionState(context.Background(), pplan, storage, nil, nil)
+}
+
+// generateExecutionState creates an execution state which records series metrics to the scope if set,
+// running the hooks on each result block. The state stops sending results once the context is done
+func generateExecutionState(
+	ctx context.Context,
+	pplan plan.PhysicalPlan,
+	storage storage.Storage,
+	scope tally.Scope,
+	hooks []ResultHook,
+) (*ExecutionState, error) {
 	result := pplan.ResultStep
 	state := &ExecutionState{
 		plan:    pplan,
 		storage: storage,
+		scope:   scope,
 	}
 
 	step, ok := pplan.Step(result.Parent)
@@ -84,9 +99,40 @@ func GenerateExecutionState(pplan plan.PhysicalPlan, storage storage.Storage) (*
 	}
 
 	options := transform.Options{
-		TimeSpec: pplan.TimeSpec,
-		Debug:    pplan.Debug,
+		TimeSpec:                pplan.TimeSpec,
+		Debug:                   pplan.Debug,
+		AlignStepsToEpoch:       pplan.AlignStepsToEpoch,
+		WarnOnGaugeRates:        pplan.WarnOnGaugeRates,
+		MergeReplicas:           pplan.MergeReplicas,
+		RegressionDecayHalfLife: pplan.RegressionDecayHalfLife,
+		CounterMaxValue:         pplan.CounterMaxValue,
+		MinSamples:              pplan.MinSamples,
+		DisableExtrapolation:    pplan.DisableExtrapolation,
+		ResetTolerance:          pplan.ResetTolerance,
+		NonNegative:             pplan.NonNegative,
+		RegressionReference:     pplan.RegressionReference,
+		InterpolationMethod:     pplan.InterpolationMethod,
+		UnanchoredLabelReplace:  pplan.UnanchoredLabelReplace,
+		IncludeGroupSize:        pplan.IncludeGroupSize,
+		RollupShards:            pplan.RollupShards,
+		IncludeTies:             pplan.IncludeTies,
+		StreamingAggregation:    pplan.StreamingAggregation,
+		LeftInclusive:           pplan.LeftInclusive,
+		SeriesLimit:             pplan.SeriesLimit,
+		SeriesOffset:            pplan.SeriesOffset,
+		SelectorLookbacks:       pplan.SelectorLookbacks,
+		Warnings:                transform.NewWarnings(),
+		MaxSeriesPerNode:        pplan.MaxSeriesPerNode,
+		MaxBlockBytes:           pplan.MaxBlockBytes,
+		BlockBytes:              transform.NewBlockBytes(),
+		Consolidation:           pplan.Consolidation,
+		TagSanitizer:            pplan.TagSanitizer,
+	}
+
+	if pplan.IncludeRawSamples {
+		options.RawSamples = transform.NewRawSamples(pplan.MaxRawSamples)
 	}
+
 	controller, err := state.createNode(step, options)
 	if err != nil {
 		return nil, err
@@ -96,7 +142,8 @@ func GenerateExecutionState(pplan plan.PhysicalPlan, storage storage.Storage) (*
 		return nil, errors.New("empty sources for the execution state")
 	}
 
-	rNode := newResultNode()
+	rNode := newResultNode(ctx, options.Warnings, options.RawSamples)
+	rNode.hooks = hooks
 	state.resultNode = rNode
 	controller.AddTransform(rNode)
 
@@ -110,6 +157,7 @@ func (s *ExecutionState) createNode(step plan.LogicalStep, options transform.Opt
 	sourceParams, ok := step.Transform.Op.(SourceParams)
 	if ok {
 		source, controller := CreateSource(step.ID(), sourceParams, s.storage, options)
+		withFetchMetrics(s.scope, sourceParams.OpType(), controller)
 		s.sources = append(s.sources, source)
 		return controller, nil
 	}
@@ -119,7 +167,8 @@ func (s *ExecutionState) createNode(step plan.LogicalStep, options transform.Opt
 		return nil, fmt.Errorf("invalid transform step, %s", step)
 	}
 
-	transformNode, controller := CreateTransform(step.ID(), transformParams)
+	transformNode, controller := CreateTransform(step.ID(), transformParams, options)
+	transformNode = withSeriesMetrics(s.scope, transformParams.OpType(), transformNode, controller)
 	for _, parentID := range step.Parents {
 		parentStep, ok := s.plan.Step(parentID)
 		if !ok {
@@ -147,6 +196,15 @@ func (s *ExecutionState) Execute(ctx context.Context) error {
 	return execution.ExecuteParallel(ctx, requests)
 }
 
+// run executes the state, closing the results once done
+func (s *ExecutionState) run(ctx context.Context) {
+	if err := s.Execute(ctx); err != nil {
+		s.resultNode.abort(err)
+	} else {
+		s.resultNode.done()
+	}
+}
+
 // String representation of the state
 func (s *ExecutionState) String() string {
 	return fmt.Sprintf("plan: %s\nsources: %s\nresult: %s", s.plan, s.sources, s.resultNode)
@@ -0,0 +1,65 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"time"
+)
+
+const (
+	// defaultStepPoints is the number of steps the default step resolver aims for
+	defaultStepPoints = 250
+	// defaultMinStep is the smallest step the default step resolver chooses
+	defaultMinStep = 15 * time.Second
+)
+
+// DefaultStepResolver chooses the step of queries which do not set one
+type DefaultStepResolver func(start, end time.Time) time.Duration
+
+// NewDefaultStepResolver creates a resolver which splits the query range into the number of
+// points, rounded up to a whole second and to at least the minimum step. Ranges which are not
+// positive, such as those of instant queries, get the minimum step
+func NewDefaultStepResolver(points int, minStep time.Duration) DefaultStepResolver {
+	return func(start, end time.Time) time.Duration {
+		if points <= 0 || !end.After(start) {
+			return minStep
+		}
+
+		step := end.Sub(start) / time.Duration(points)
+		if rem := step % time.Second; rem != 0 {
+			step += time.Second - rem
+		}
+
+		if step < minStep {
+			return minStep
+		}
+
+		return step
+	}
+}
+
+// WithDefaultStepResolver sets the resolver choosing the step of queries without one, which
+// defaults to splitting the range into 250 steps of at least 15s
+func WithDefaultStepResolver(resolver DefaultStepResolver) Option {
+	return func(e *Engine) {
+		e.stepResolver = resolver
+	}
+}
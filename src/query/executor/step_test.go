@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser/promql"
+	"github.com/m3db/m3/src/query/test/fixtures"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultStepResolver(t *testing.T) {
+	resolver := NewDefaultStepResolver(250, 15*time.Second)
+	end := time.Unix(1000000, 0)
+	assert.Equal(t, 15*time.Second, resolver(end.Add(-time.Hour), end), "1h over 250 steps is below the minimum")
+	assert.Equal(t, 10368*time.Second, resolver(end.Add(-30*24*time.Hour), end))
+	assert.Equal(t, 41*time.Second, resolver(end.Add(-10001*time.Second), end), "steps are rounded up to a second")
+	assert.Equal(t, 15*time.Second, resolver(end, end), "instant queries get the minimum")
+}
+
+func TestExecuteExprWithoutStep(t *testing.T) {
+	end := time.Now().Truncate(time.Hour)
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags:       models.Tags{models.MetricName: "up"},
+		Datapoints: ts.Datapoints{{Timestamp: end.Add(-time.Minute), Value: 1}},
+	})
+
+	p, err := promql.Parse("up")
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		queryRange time.Duration
+		step       time.Duration
+	}{
+		{queryRange: time.Hour, step: 15 * time.Second},
+		{queryRange: 30 * 24 * time.Hour, step: 10368 * time.Second},
+	} {
+		params := models.RequestParams{Start: end.Add(-tt.queryRange), End: end, Now: end}
+		state, err := NewEngine(store).createState(context.TODO(), p, &EngineOptions{}, params, store)
+		require.NoError(t, err)
+		assert.Equal(t, tt.step, state.plan.TimeSpec.Step)
+
+		go state.run(context.TODO())
+		var steps []time.Duration
+		for res := range state.resultNode.ResultChan() {
+			require.NoError(t, res.Err)
+			iter, err := res.Block.StepIter()
+			require.NoError(t, err)
+			steps = append(steps, iter.Meta().Bounds.StepSize)
+		}
+
+		require.NotEmpty(t, steps)
+		for _, step := range steps {
+			assert.Equal(t, tt.step, step, "blocks are built with the default step")
+		}
+	}
+
+	// The resolver is configurable, and only used for queries without a step
+	resolver := func(start, end time.Time) time.Duration { return time.Minute }
+	params := models.RequestParams{Start: end.Add(-time.Hour), End: end, Now: end}
+	state, err := NewEngine(store, WithDefaultStepResolver(resolver)).createState(context.TODO(), p, &EngineOptions{}, params, store)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, state.plan.TimeSpec.Step)
+
+	params.Step = 30 * time.Second
+	state, err = NewEngine(store).createState(context.TODO(), p, &EngineOptions{}, params, store)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, state.plan.TimeSpec.Step, "explicit steps are kept")
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transform
+
+import (
+	"sync"
+)
+
+// BlockBytes accounts the estimated bytes of the blocks built and fetched by the nodes of a query,
+// so that the block limit applies to the query as a whole
+type BlockBytes struct {
+	mu    sync.Mutex
+	bytes int
+}
+
+// NewBlockBytes creates a new block bytes account
+func NewBlockBytes() *BlockBytes {
+	return &BlockBytes{}
+}
+
+// Add records the bytes of a block, returning the bytes of all blocks of the query. Without an
+// account, only the bytes of the block are returned
+func (b *BlockBytes) Add(bytes int) int {
+	if b == nil {
+		return bytes
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bytes += bytes
+	return b.bytes
+}
@@ -23,12 +23,24 @@ package transform
 import (
 	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/parser"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTooManySeries is returned when a node would emit more series than the per node limit
+	ErrTooManySeries = errors.New("too many series")
+
+	// ErrResourceExhausted is returned when a node would build a block larger than the block limit
+	ErrResourceExhausted = errors.New("resource exhausted")
 )
 
 // Controller controls the caching and forwarding the request to downstream.
 type Controller struct {
 	ID         parser.NodeID
 	transforms []OpNode
+	// Options are the options of the query the node belongs to
+	Options Options
 }
 
 // AddTransform adds a dependent transformation to the controller
@@ -36,10 +48,15 @@ func (t *Controller) AddTransform(node OpNode) {
 	t.transforms = append(t.transforms, node)
 }
 
-// Process performs processing on the underlying transforms
-func (t *Controller) Process(block block.Block) error {
+// Process performs processing on the underlying transforms. Blocks shared by several transforms
+// cache their rows, so that range functions over the same input only transpose it once
+func (t *Controller) Process(b block.Block) error {
+	if len(t.transforms) > 1 {
+		b = block.WithCachedRows(b)
+	}
+
 	for _, ts := range t.transforms {
-		err := ts.Process(t.ID, block)
+		err := ts.Process(t.ID, b)
 		if err != nil {
 			return err
 		}
@@ -48,7 +65,35 @@ func (t *Controller) Process(block block.Block) error {
 	return nil
 }
 
-// BlockBuilder returns a BlockBuilder instance with associated metadata
+// BlockBuilder returns a BlockBuilder instance with associated metadata. It fails fast
+// if the node would emit more series than allowed, e.g. for a join which fans out, or if
+// the blocks of the query would be larger than allowed, e.g. for a long range query
 func (t *Controller) BlockBuilder(blockMeta block.Metadata, seriesMeta []block.SeriesMeta) (block.Builder, error) {
+	if max := t.Options.MaxSeriesPerNode; max > 0 && len(seriesMeta) > max {
+		return nil, errors.Wrapf(ErrTooManySeries, "node %s would emit %d series, limit: %d", t.ID, len(seriesMeta), max)
+	}
+
+	if err := t.ChargeBlock(seriesMeta, blockMeta.Bounds.Steps()); err != nil {
+		return nil, err
+	}
+
 	return block.NewColumnBlockBuilder(blockMeta, seriesMeta), nil
 }
+
+// ChargeBlock accounts the bytes of a block with the series and number of steps towards the
+// blocks of the query, failing if they would together be larger than allowed. Blocks from the
+// BlockBuilder are charged when their builders are created, while blocks created otherwise, e.g.
+// by storage, are charged by their creator
+func (t *Controller) ChargeBlock(seriesMeta []block.SeriesMeta, steps int) error {
+	max := t.Options.MaxBlockBytes
+	if max <= 0 {
+		return nil
+	}
+
+	if bytes := t.Options.BlockBytes.Add(block.EstimateBytes(seriesMeta, steps)); bytes > max {
+		return errors.Wrapf(ErrResourceExhausted, "node %s would hold blocks of %d bytes, limit: %d",
+			t.ID, bytes, max)
+	}
+
+	return nil
+}
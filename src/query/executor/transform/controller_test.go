@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package transform
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transposingNode records the rows of the blocks it processes
+type transposingNode struct {
+	rows [][]float64
+}
+
+func (n *transposingNode) Process(_ parser.NodeID, b block.Block) error {
+	rows, err := block.Transpose(b)
+	n.rows = rows
+	return err
+}
+
+// seriesOnlyBlock hides the cached rows of the wrapped block, like blocks from storage
+type seriesOnlyBlock struct {
+	block.Block
+}
+
+func TestControllerCachesRowsOfSharedBlocks(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := seriesOnlyBlock{test.NewBlockFromValues(bounds, values)}
+
+	first, second := &transposingNode{}, &transposingNode{}
+	controller := &Controller{}
+	controller.AddTransform(first)
+	controller.AddTransform(second)
+	require.NoError(t, controller.Process(b))
+	assert.Equal(t, values, first.rows)
+	assert.True(t, &first.rows[0][0] == &second.rows[0][0], "the rows are only transposed once")
+}
+
+func TestControllerLimitsBlockBytesOfQuery(t *testing.T) {
+	metas := []block.SeriesMeta{{Name: "a"}, {Name: "b"}}
+	bytes := block.EstimateBytes(metas, 10)
+	bounds := block.Bounds{StepSize: 1}
+	bounds.End = bounds.Start.Add(9)
+
+	// Without an account for the query, only each block is limited
+	controller := &Controller{Options: Options{MaxBlockBytes: bytes}}
+	for i := 0; i < 3; i++ {
+		_, err := controller.BlockBuilder(block.Metadata{Bounds: bounds}, metas)
+		require.NoError(t, err)
+	}
+
+	// Controllers of the same query share their account
+	options := Options{MaxBlockBytes: 2 * bytes, BlockBytes: NewBlockBytes()}
+	first, second := &Controller{Options: options}, &Controller{Options: options}
+	_, err := first.BlockBuilder(block.Metadata{Bounds: bounds}, metas)
+	require.NoError(t, err)
+	require.NoError(t, second.ChargeBlock(metas, 10))
+	_, err = first.BlockBuilder(block.Metadata{Bounds: bounds}, metas)
+	assert.Equal(t, ErrResourceExhausted, errors.Cause(err))
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transform
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3/src/query/parser"
+
+	"github.com/pkg/errors"
+)
+
+// Error is an error raised by a node while processing blocks. It records the node
+// so that callers can report where deep in a query it failed, and wraps the cause so that
+// sentinel errors can still be checked with errors.Cause
+type Error struct {
+	OpType  string
+	NodeIDs []parser.NodeID
+	Err     error
+}
+
+// NewError wraps the error with the op type and the IDs of the nodes involved
+func NewError(opType string, err error, nodeIDs ...parser.NodeID) *Error {
+	return &Error{
+		OpType:  opType,
+		NodeIDs: nodeIDs,
+		Err:     err,
+	}
+}
+
+// Error returns the cause prefixed with the op and nodes
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %v: %v", e.OpType, e.NodeIDs, e.Err)
+}
+
+// Cause returns the underlying cause of the error
+func (e *Error) Cause() error {
+	return errors.Cause(e.Err)
+}
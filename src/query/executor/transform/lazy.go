@@ -45,7 +45,8 @@ type lazyNode struct {
 // NewLazyNode creates a new wrapper around a function fNode to make it support lazy initialization
 func NewLazyNode(node OpNode, controller *Controller) (OpNode, *Controller) {
 	c := &Controller{
-		ID: controller.ID,
+		ID:      controller.ID,
+		Options: controller.Options,
 	}
 
 	sink := &sinkNode{}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transform
+
+import (
+	"sync"
+
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+// DefaultMaxRawSamples is the number of raw datapoints kept for a query when no limit is set
+const DefaultMaxRawSamples = 10000
+
+// RawSeries are the datapoints of a series as fetched from storage, before consolidation
+type RawSeries struct {
+	Name       string
+	Tags       models.Tags
+	Datapoints ts.Datapoints
+}
+
+// RawSamples collects the raw datapoints fetched by sources for debugging consolidation.
+// At most a fixed number of datapoints are kept, after which series are truncated
+type RawSamples struct {
+	mu        sync.Mutex
+	remaining int
+	series    []RawSeries
+	truncated bool
+}
+
+// NewRawSamples creates a new raw samples collector keeping at most maxDatapoints datapoints,
+// or DefaultMaxRawSamples if the limit is not positive
+func NewRawSamples(maxDatapoints int) *RawSamples {
+	if maxDatapoints <= 0 {
+		maxDatapoints = DefaultMaxRawSamples
+	}
+
+	return &RawSamples{remaining: maxDatapoints}
+}
+
+// Add records the datapoints of the series, doing nothing if raw samples are not being collected
+func (r *RawSamples) Add(seriesList ts.SeriesList) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, series := range seriesList {
+		values := series.Values()
+		n := values.Len()
+		if n > r.remaining {
+			n = r.remaining
+			r.truncated = true
+		}
+
+		datapoints := make(ts.Datapoints, n)
+		for i := range datapoints {
+			datapoints[i] = values.DatapointAt(i)
+		}
+
+		r.remaining -= n
+		r.series = append(r.series, RawSeries{Name: series.Name(), Tags: series.Tags, Datapoints: datapoints})
+	}
+}
+
+// Drain returns the series added since the last drain, and whether any datapoints were dropped
+// as the limit was reached
+func (r *RawSamples) Drain() ([]RawSeries, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	series := r.series
+	r.series = nil
+	return series, r.truncated
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawSamples(t *testing.T) {
+	now := time.Now()
+	datapoints := ts.Datapoints{{Timestamp: now, Value: 1}, {Timestamp: now.Add(time.Second), Value: 2}}
+	seriesList := ts.SeriesList{
+		ts.NewSeries("a", datapoints, models.Tags{"a": "1"}),
+		ts.NewSeries("b", datapoints, models.Tags{"b": "1"}),
+	}
+
+	r := NewRawSamples(3)
+	r.Add(seriesList)
+	series, truncated := r.Drain()
+	assert.Equal(t, []RawSeries{
+		{Name: "a", Tags: models.Tags{"a": "1"}, Datapoints: datapoints},
+		{Name: "b", Tags: models.Tags{"b": "1"}, Datapoints: datapoints[:1]},
+	}, series)
+	assert.True(t, truncated)
+
+	r.Add(seriesList)
+	series, _ = r.Drain()
+	assert.Equal(t, []RawSeries{
+		{Name: "a", Tags: models.Tags{"a": "1"}, Datapoints: ts.Datapoints{}},
+		{Name: "b", Tags: models.Tags{"b": "1"}, Datapoints: ts.Datapoints{}},
+	}, series, "series are still listed once the limit is reached")
+
+	var disabled *RawSamples
+	disabled.Add(seriesList)
+	series, truncated = disabled.Drain()
+	assert.Empty(t, series)
+	assert.False(t, truncated)
+}
@@ -24,15 +24,91 @@ import (
 	"time"
 
 	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/functions/utils"
 	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/ts"
 )
 
 // Options to create transform nodes
 type Options struct {
 	TimeSpec TimeSpec
 	Debug    bool
+	// AlignStepsToEpoch aligns the step timestamps of sources to multiples of the step since the epoch
+	AlignStepsToEpoch bool
+	// WarnOnGaugeRates warns when rate or increase are applied to series which look like gauges
+	WarnOnGaugeRates bool
+	// MergeReplicas merges the replicas of counters for rate and increase, as with
+	// temporal.CounterOptions
+	MergeReplicas bool
+	// RegressionDecayHalfLife weights the samples fit by deriv and predict_linear, as with
+	// temporal.LinearRegressionOptions, when the op does not set its own
+	RegressionDecayHalfLife time.Duration
+	// CounterMaxValue is the value at which counters wrap for rate and increase, as with
+	// temporal.CounterOptions, when the op does not set its own
+	CounterMaxValue float64
+	// MinSamples is the number of samples a window of the counter and regression functions needs,
+	// as with temporal.CounterOptions, when the op does not set its own
+	MinSamples int
+	// DisableExtrapolation returns the raw changes of the counter functions, as with
+	// temporal.CounterOptions
+	DisableExtrapolation bool
+	// ResetTolerance is the largest decrease of a counter which is not a reset, as with
+	// temporal.CounterOptions, when the op does not set its own
+	ResetTolerance float64
+	// NonNegative clamps the negative changes of delta to 0, as with temporal.CounterOptions
+	NonNegative bool
+	// RegressionReference is the time predict_linear predicts from, as with
+	// temporal.LinearRegressionOptions
+	RegressionReference utils.RegressionReference
+	// InterpolationMethod determines how quantiles pick values between ranks, as with
+	// aggregation.NodeParams and temporal.QuantileOptions
+	InterpolationMethod utils.InterpolationMethod
+	// UnanchoredLabelReplace matches the regexes of label_replace anywhere in the source value, as
+	// with tag.LabelReplaceOptions
+	UnanchoredLabelReplace bool
+	// IncludeGroupSize adds the size of each group to sums, averages and counts, as with
+	// aggregation.NodeParams
+	IncludeGroupSize bool
+	// RollupShards aggregates sums and counts in two phases over this many shards, as with
+	// aggregation.NodeParams
+	RollupShards int
+	// IncludeTies makes topk, bottomk and range_topk take the ties of the k-th element, as with
+	// aggregation.NodeParams
+	IncludeTies bool
+	// StreamingAggregation aggregates sums, counts, averages, minimums and maximums one series at a
+	// time, as with aggregation.NodeParams
+	StreamingAggregation bool
+	// LeftInclusive closes the left edge of range windows, as with temporal.BaseOp
+	LeftInclusive bool
+	// SeriesLimit caps the number of series each selector returns, as with functions.FetchOp
+	SeriesLimit int
+	// SeriesOffset skips the first series each selector matches, as with functions.FetchOp
+	SeriesOffset int
+	// SelectorLookbacks overrides the lookback duration for the selectors of each metric name, as
+	// with functions.FetchOp
+	SelectorLookbacks map[string]time.Duration
+	// Warnings collects the warnings raised by nodes for the query
+	Warnings *Warnings
+	// MaxSeriesPerNode, when positive, fails the query if any node would emit more series
+	MaxSeriesPerNode int
+	// MaxBlockBytes, when positive, fails the query if the blocks built and fetched by its nodes
+	// would together be larger
+	MaxBlockBytes int
+	// BlockBytes accounts the bytes of the blocks of the query towards MaxBlockBytes. Without it,
+	// the limit applies to each block
+	BlockBytes *BlockBytes
+	// Consolidation configures how sources consolidate raw datapoints onto the query steps
+	Consolidation ts.ConsolidationOptions
+	// RawSamples, when set, collects the raw datapoints fetched by sources for debugging
+	RawSamples *RawSamples
+	// TagSanitizer, when set, rewrites the tag values of fetched series before any other node sees them
+	TagSanitizer TagSanitizer
 }
 
+// TagSanitizer returns the value to use in place of the value of the named tag, including the
+// metric name, e.g. replacing characters which are invalid in strict downstream systems
+type TagSanitizer func(name, value string) string
+
 // OpNode represents the execution node
 type OpNode interface {
 	Process(ID parser.NodeID, block block.Block) error
@@ -47,6 +123,23 @@ type TimeSpec struct {
 	Step time.Duration
 }
 
+// Bounds returns the bounds of the query steps, starting at a multiple of the step since the epoch
+// when aligned
+func (t TimeSpec) Bounds(alignToEpoch bool) block.Bounds {
+	start := t.Start
+	if alignToEpoch && t.Step > 0 {
+		nanos := start.UnixNano()
+		remainder := nanos % int64(t.Step)
+		if remainder < 0 {
+			remainder += int64(t.Step)
+		}
+
+		start = time.Unix(0, nanos-remainder).In(start.Location())
+	}
+
+	return block.Bounds{Start: start, End: t.End, StepSize: t.Step}
+}
+
 // Params are defined by transforms
 type Params interface {
 	parser.Params
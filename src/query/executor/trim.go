@@ -0,0 +1,80 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"math"
+
+	"github.com/m3db/m3/src/query/block"
+)
+
+// nanStepTrimmer is a result hook removing the leading and trailing steps without any values,
+// so that graphs of data starting late or ending early in the range are not padded
+type nanStepTrimmer struct{}
+
+// OnResult trims the block, keeping interior steps without values. Blocks without any values
+// are kept unchanged
+func (nanStepTrimmer) OnResult(b block.Block) (block.Block, bool, error) {
+	iter, err := b.StepIter()
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer iter.Close()
+	first, last := -1, -1
+	for idx := 0; iter.Next(); idx++ {
+		step, err := iter.Current()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if hasValue(step.Values()) {
+			if first < 0 {
+				first = idx
+			}
+
+			last = idx
+		}
+	}
+
+	bounds := iter.Meta().Bounds
+	if first < 0 || (first == 0 && last == iter.StepCount()-1) {
+		return nil, false, nil
+	}
+
+	// The end of the slice is exclusive. Trimming only drops steps, so it needs no limits
+	trimmed, err := block.Slice(block.UnlimitedBuilder, b, bounds.TimeForStep(first), bounds.TimeForStep(last+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return trimmed, true, nil
+}
+
+func hasValue(values []float64) bool {
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			return true
+		}
+	}
+
+	return false
+}
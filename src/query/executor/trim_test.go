@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package executor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stepValues returns the values of each step of the block
+func stepValues(t *testing.T, b block.Block) [][]float64 {
+	iter, err := b.StepIter()
+	require.NoError(t, err)
+	defer iter.Close()
+
+	var values [][]float64
+	for iter.Next() {
+		step, err := iter.Current()
+		require.NoError(t, err)
+		values = append(values, step.Values())
+	}
+
+	return values
+}
+
+func TestTrimNaNSteps(t *testing.T) {
+	nan := math.NaN()
+	start := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: start, End: start.Add(4 * time.Minute), StepSize: time.Minute}
+	b := test.NewBlockFromValues(bounds, [][]float64{
+		{nan, 1, nan, 3, nan},
+		{nan, nan, nan, 4, nan},
+	})
+
+	trimmed, replaced, err := nanStepTrimmer{}.OnResult(b)
+	require.NoError(t, err)
+	require.True(t, replaced)
+	iter, err := trimmed.StepIter()
+	require.NoError(t, err)
+	assert.Equal(t, block.Bounds{Start: start.Add(time.Minute), End: start.Add(3 * time.Minute), StepSize: time.Minute},
+		iter.Meta().Bounds)
+	assert.Len(t, iter.SeriesMeta(), 2)
+	iter.Close()
+
+	// Interior steps without values are kept
+	test.EqualsWithNans(t, [][]float64{{1, nan}, {nan, nan}, {3, 4}}, stepValues(t, trimmed))
+}
+
+func TestTrimNaNStepsKeepsBlocksWithoutPadding(t *testing.T) {
+	nan := math.NaN()
+	start := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: start, End: start.Add(2 * time.Minute), StepSize: time.Minute}
+	for _, values := range [][][]float64{{{1, nan, 3}}, {{nan, nan, nan}}} {
+		b := test.NewBlockFromValues(bounds, values)
+		_, replaced, err := nanStepTrimmer{}.OnResult(b)
+		require.NoError(t, err)
+		assert.False(t, replaced)
+	}
+}
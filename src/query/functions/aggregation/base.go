@@ -0,0 +1,329 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// NodeParams contains additional parameters required for aggregation ops
+type NodeParams struct {
+	// MatchingTags is the set of tags by which the aggregation groups output series
+	MatchingTags []string
+	// Without indicates if series should use only the MatchingTags or if MatchingTags
+	// should be excluded from grouping
+	Without bool
+	// Parameter is the param value for the aggregation op when appropriate
+	Parameter float64
+	// InterpolationMethod determines how quantile picks values between ranks
+	InterpolationMethod utils.InterpolationMethod
+	// RangeAggregation is the aggregation over all steps by which range_topk ranks
+	// series, defaulting to sum
+	RangeAggregation string
+	// IncludeGroupSize adds a sibling series for each group, marked with the GroupSizeTag,
+	// holding the number of non nan series contributing to the group at each step
+	IncludeGroupSize bool
+	// RollupShards, when above one, aggregates wide sums and counts in two phases: series
+	// are split into this many shards which are aggregated concurrently, and the partial
+	// aggregations are then combined
+	RollupShards int
+	// Streaming aggregates sums, counts, averages, minimums and maximums one series at a time,
+	// keeping only running accumulators for each group rather than every series, for very wide
+	// aggregations. Other aggregations need every value of a group and are unaffected
+	Streaming bool
+	// ValueLabel is the label count_values writes each distinct value into
+	ValueLabel string
+	// IncludeTies, for topk, bottomk and range_topk, also takes every element tied with the
+	// k-th, so more than k elements may be taken. Prometheus takes exactly k, picking among
+	// ties arbitrarily
+	IncludeTies bool
+}
+
+// aggregationFn aggregates the values of a single group at a step
+type aggregationFn func(values []float64, bucket []int) float64
+
+var aggregationFunctions = map[string]aggregationFn{
+	SumType:    sumFn,
+	AvgType:    avgFn,
+	MinType:    minFn,
+	MaxType:    maxFn,
+	CountType:  countFn,
+	ExistsType: existsFn,
+}
+
+// pushableAggregations are the aggregations which may be applied before a set operation
+var pushableAggregations = map[string]bool{
+	SumType:    true,
+	AvgType:    true,
+	MinType:    true,
+	MaxType:    true,
+	CountType:  true,
+	ExistsType: true,
+}
+
+// GroupSizeTag marks the sibling series holding the number of series contributing to a group
+const GroupSizeTag = "__group_size__"
+
+// groupSizeFunctions are the aggregations which can include the size of each group
+var groupSizeFunctions = map[string]bool{
+	SumType:   true,
+	AvgType:   true,
+	CountType: true,
+}
+
+// BaseOp stores required properties for aggregation operations
+type BaseOp struct {
+	params NodeParams
+	opType string
+	aggFn  aggregationFn
+}
+
+// NewAggregationOp creates a new aggregation op based on the type
+func NewAggregationOp(opType string, params NodeParams) (BaseOp, error) {
+	fn, ok := aggregationFunctions[opType]
+	if opType == QuantileType {
+		fn, ok = makeQuantileFn(params.Parameter, params.InterpolationMethod), true
+	}
+
+	if !ok {
+		return BaseOp{}, fmt.Errorf("operator not supported: %s", opType)
+	}
+
+	if params.IncludeGroupSize && !groupSizeFunctions[opType] {
+		return BaseOp{}, fmt.Errorf("group size is not supported for %s", opType)
+	}
+
+	if params.RollupShards < 0 {
+		return BaseOp{}, fmt.Errorf("rollup shards cannot be negative: %d", params.RollupShards)
+	}
+
+	if params.RollupShards > 1 && !rollupFunctions[opType] {
+		return BaseOp{}, fmt.Errorf("rollup is not supported for %s", opType)
+	}
+
+	if params.RollupShards > 1 && params.Streaming {
+		return BaseOp{}, fmt.Errorf("rollup cannot be combined with streaming for %s", opType)
+	}
+
+	return BaseOp{
+		params: params,
+		opType: opType,
+		aggFn:  fn,
+	}, nil
+}
+
+// OpType for the operator
+func (o BaseOp) OpType() string {
+	return o.opType
+}
+
+// String representation
+func (o BaseOp) String() string {
+	return fmt.Sprintf("type: %s, matching: %v, without: %t", o.OpType(), o.params.MatchingTags, o.params.Without)
+}
+
+// FormatExpr renders the aggregation of its input along with the grouping clause
+func (o BaseOp) FormatExpr(inputs []string) string {
+	if o.opType == QuantileType {
+		inputs = append([]string{parser.FormatLiteral(o.params.Parameter)}, inputs...)
+	}
+
+	return formatAggregation(o.opType, o.params, inputs)
+}
+
+// formatAggregation renders an aggregation of the inputs, which include any parameter, with its grouping clause
+func formatAggregation(opType string, params NodeParams, inputs []string) string {
+	if len(params.MatchingTags) == 0 && !params.Without {
+		return parser.FormatFunction(opType, inputs...)
+	}
+
+	grouping := "by"
+	if params.Without {
+		grouping = "without"
+	}
+
+	return fmt.Sprintf("%s %s (%s) (%s)", opType, grouping, strings.Join(params.MatchingTags, ", "), strings.Join(inputs, ", "))
+}
+
+// Grouping returns how series are grouped. Only aggregations which are NaN for groups without any
+// values can be pushed down, and not with group sizes, as their series are matched separately
+func (o BaseOp) Grouping() ([]string, bool, bool) {
+	if !pushableAggregations[o.opType] || o.params.IncludeGroupSize {
+		return nil, false, false
+	}
+
+	return o.params.MatchingTags, o.params.Without, true
+}
+
+// Node creates an execution node
+func (o BaseOp) Node(controller *transform.Controller) transform.OpNode {
+	return &baseNode{
+		op:         o,
+		controller: controller,
+		params:     o.queryParams(controller.Options),
+	}
+}
+
+// queryParams returns the params of the op with the aggregation options of the query applied,
+// where the op supports them
+func (o BaseOp) queryParams(opts transform.Options) NodeParams {
+	params := o.params
+	if opts.IncludeGroupSize && groupSizeFunctions[o.opType] {
+		params.IncludeGroupSize = true
+	}
+
+	if opts.StreamingAggregation && streamingFunctions[o.opType] && params.RollupShards <= 1 {
+		params.Streaming = true
+	}
+
+	// Streaming already keeps a single accumulator per group, so there is nothing to shard
+	if params.RollupShards == 0 && opts.RollupShards > 1 && rollupFunctions[o.opType] && !params.Streaming {
+		params.RollupShards = opts.RollupShards
+	}
+
+	return params
+}
+
+type baseNode struct {
+	op         BaseOp
+	controller *transform.Controller
+	// params are the params of the op as applied to the query
+	params NodeParams
+}
+
+// aggFn returns the aggregation of the op, with quantiles interpolating as the query does
+// where the op does not set its own method
+func (n *baseNode) aggFn() aggregationFn {
+	method := n.controller.Options.InterpolationMethod
+	if n.op.opType != QuantileType || n.op.params.InterpolationMethod != utils.LinearInterpolation ||
+		method == utils.LinearInterpolation {
+		return n.op.aggFn
+	}
+
+	return makeQuantileFn(n.op.params.Parameter, method)
+}
+
+// Process the block
+func (n *baseNode) Process(ID parser.NodeID, b block.Block) error {
+	if n.params.Streaming && streamingFunctions[n.op.opType] {
+		return n.processStreaming(b)
+	}
+
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	params := n.params
+	buckets, metas := utils.GroupSeries(params.MatchingTags, params.Without, n.op.opType, stepIter.SeriesMeta())
+	if params.IncludeGroupSize {
+		metas = append(metas, groupSizeMetas(metas)...)
+	}
+
+	builder, err := n.controller.BlockBuilder(stepIter.Meta(), metas)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(stepIter.StepCount()); err != nil {
+		return err
+	}
+
+	if params.RollupShards > 1 {
+		results, err := n.processRollup(stepIter, buckets)
+		if err != nil {
+			return err
+		}
+
+		for index, values := range results {
+			for _, value := range values {
+				if err := builder.AppendValue(index, value); err != nil {
+					return err
+				}
+			}
+		}
+
+		nextBlock := builder.Build()
+		defer nextBlock.Close()
+		return n.controller.Process(nextBlock)
+	}
+
+	aggFn := n.aggFn()
+	for index := 0; stepIter.Next(); index++ {
+		step, err := stepIter.Current()
+		if err != nil {
+			return err
+		}
+
+		values := step.Values()
+		for _, bucket := range buckets {
+			if err := builder.AppendValue(index, aggFn(values, bucket)); err != nil {
+				return err
+			}
+		}
+
+		if !params.IncludeGroupSize {
+			continue
+		}
+
+		for _, bucket := range buckets {
+			size := countFn(values, bucket)
+			if math.IsNaN(size) {
+				size = 0
+			}
+
+			if err := builder.AppendValue(index, size); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
+
+// groupSizeMetas returns the metadata for the group size sibling of each group
+func groupSizeMetas(metas []block.SeriesMeta) []block.SeriesMeta {
+	sizeMetas := make([]block.SeriesMeta, len(metas))
+	for i, meta := range metas {
+		tags := make(models.Tags, len(meta.Tags)+1)
+		for k, v := range meta.Tags {
+			tags[k] = v
+		}
+
+		tags[GroupSizeTag] = "true"
+		sizeMetas[i] = block.SeriesMeta{
+			Tags: tags,
+			Name: meta.Name,
+		}
+	}
+
+	return sizeMetas
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/util"
+)
+
+const (
+	// CountValuesType counts the elements of each group with each distinct value, writing the
+	// value into a label
+	CountValuesType = "count_values"
+)
+
+var labelNameRegex = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+type countValuesOp struct {
+	params NodeParams
+}
+
+// NewCountValuesOp creates a new count_values op, writing each value into the params' ValueLabel
+func NewCountValuesOp(params NodeParams) (transform.Params, error) {
+	if !labelNameRegex.MatchString(params.ValueLabel) {
+		return nil, fmt.Errorf("invalid label name in count_values: %s", params.ValueLabel)
+	}
+
+	return countValuesOp{params: params}, nil
+}
+
+// OpType for the operator
+func (o countValuesOp) OpType() string {
+	return CountValuesType
+}
+
+// String representation
+func (o countValuesOp) String() string {
+	return fmt.Sprintf("type: %s, label: %s, matching: %v, without: %t", o.OpType(), o.params.ValueLabel, o.params.MatchingTags, o.params.Without)
+}
+
+// FormatExpr renders the count of values of its input along with the grouping clause
+func (o countValuesOp) FormatExpr(inputs []string) string {
+	inputs = append([]string{parser.FormatLiteral(o.params.ValueLabel)}, inputs...)
+	return formatAggregation(CountValuesType, o.params, inputs)
+}
+
+// Node creates an execution node
+func (o countValuesOp) Node(controller *transform.Controller) transform.OpNode {
+	return &countValuesNode{
+		op:         o,
+		controller: controller,
+	}
+}
+
+type countValuesNode struct {
+	op         countValuesOp
+	controller *transform.Controller
+}
+
+// countedValue is a distinct value within a group, keyed by its label value as in Prometheus,
+// so that -0 and 0 are counted separately
+type countedValue struct {
+	group int
+	label string
+}
+
+// Process the block, emitting a series for each distinct value of each group. The series are
+// ordered by value, then by group, so that the output does not depend on map iteration order
+func (n *countValuesNode) Process(ID parser.NodeID, b block.Block) error {
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	params := n.op.params
+	buckets, groupMetas := utils.GroupSeries(params.MatchingTags, params.Without, CountValuesType, stepIter.SeriesMeta())
+	stepCount := stepIter.StepCount()
+
+	var (
+		counts = make(map[countedValue][]float64)
+		values = make(map[countedValue]float64)
+	)
+
+	for index := 0; stepIter.Next(); index++ {
+		step, err := stepIter.Current()
+		if err != nil {
+			return err
+		}
+
+		stepValues := step.Values()
+		for group, bucket := range buckets {
+			for _, idx := range bucket {
+				v := stepValues[idx]
+				if math.IsNaN(v) {
+					continue
+				}
+
+				key := countedValue{group: group, label: util.FormatValue(v)}
+				stepCounts, ok := counts[key]
+				if !ok {
+					stepCounts = make([]float64, stepCount)
+					for i := range stepCounts {
+						stepCounts[i] = math.NaN()
+					}
+
+					counts[key] = stepCounts
+					values[key] = v
+				}
+
+				if math.IsNaN(stepCounts[index]) {
+					stepCounts[index] = 0
+				}
+
+				stepCounts[index]++
+			}
+		}
+	}
+
+	keys := make([]countedValue, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		vi, vj := values[keys[i]], values[keys[j]]
+		if vi != vj {
+			return vi < vj
+		}
+
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+
+		// Only -0 and 0 compare equal with different labels
+		return keys[i].label < keys[j].label
+	})
+
+	metas := make([]block.SeriesMeta, len(keys))
+	for i, key := range keys {
+		groupMeta := groupMetas[key.group]
+		tags := make(models.Tags, len(groupMeta.Tags)+1)
+		for k, v := range groupMeta.Tags {
+			tags[k] = v
+		}
+
+		tags[params.ValueLabel] = key.label
+		metas[i] = block.SeriesMeta{Tags: tags, Name: groupMeta.Name}
+	}
+
+	builder, err := n.controller.BlockBuilder(stepIter.Meta(), metas)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(stepCount); err != nil {
+		return err
+	}
+
+	for index := 0; index < stepCount; index++ {
+		for _, key := range keys {
+			if err := builder.AppendValue(index, counts[key][index]); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processCountValuesOp(t *testing.T, params NodeParams, metas []block.SeriesMeta, values [][]float64) *executor.SinkNode {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)
+	op, err := NewCountValuesOp(params)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	return sink
+}
+
+func TestCountValues(t *testing.T) {
+	nan := math.NaN()
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "build", "job": "a", "instance": "1"}},
+		{Tags: models.Tags{models.MetricName: "build", "job": "b", "instance": "2"}},
+		{Tags: models.Tags{models.MetricName: "build", "job": "a", "instance": "3"}},
+		{Tags: models.Tags{models.MetricName: "build", "job": "b", "instance": "4"}},
+	}
+	values := [][]float64{
+		{10, 10, 2.5, 2.5, 2.5},
+		{-1, 10, 10, 10, nan},
+		{10, 100, 2.5, 2.5, nan},
+		{2.5, 2.5, 2.5, 100, nan},
+	}
+
+	expectedTags := []models.Tags{
+		{"job": "b", "version": "-1"},
+		{"job": "a", "version": "2.5"},
+		{"job": "b", "version": "2.5"},
+		{"job": "a", "version": "10"},
+		{"job": "b", "version": "10"},
+		{"job": "a", "version": "100"},
+		{"job": "b", "version": "100"},
+	}
+	expected := [][]float64{
+		{1, nan, nan, nan, nan},
+		{nan, nan, 2, 2, 1},
+		{1, 1, 1, nan, nan},
+		{2, 1, nan, nan, nan},
+		{nan, 1, 1, 1, nan},
+		{nan, 1, nan, nan, nan},
+		{nan, nan, nan, 1, nan},
+	}
+
+	params := NodeParams{MatchingTags: []string{"job"}, ValueLabel: "version"}
+	// Series are ordered by value and then group, so the order is the same on every run
+	for i := 0; i < 10; i++ {
+		sink := processCountValuesOp(t, params, metas, values)
+		require.Len(t, sink.Metas, len(expectedTags))
+		for j, meta := range sink.Metas {
+			assert.Equal(t, expectedTags[j], meta.Tags)
+		}
+
+		test.EqualsWithNans(t, expected, sink.Values)
+	}
+}
+
+func TestCountValuesOverwritesGroupingLabel(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "up", "job": "a"}},
+		{Tags: models.Tags{models.MetricName: "up", "job": "b"}},
+	}
+	values := [][]float64{{1, 1, 1, 1, 1}, {1, 1, 1, 1, 1}}
+
+	sink := processCountValuesOp(t, NodeParams{ValueLabel: "job"}, metas, values)
+	require.Len(t, sink.Metas, 1)
+	assert.Equal(t, models.Tags{"job": "1"}, sink.Metas[0].Tags)
+	assert.Equal(t, [][]float64{{2, 2, 2, 2, 2}}, sink.Values)
+}
+
+func TestNewCountValuesOpWithInvalidLabel(t *testing.T) {
+	_, err := NewCountValuesOp(NodeParams{ValueLabel: "1version"})
+	assert.Error(t, err)
+
+	_, err = NewCountValuesOp(NodeParams{})
+	assert.Error(t, err)
+}
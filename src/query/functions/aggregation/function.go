@@ -0,0 +1,144 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"math"
+	"sort"
+
+	"github.com/m3db/m3/src/query/functions/internal/quantile"
+	"github.com/m3db/m3/src/query/functions/utils"
+)
+
+const (
+	// SumType adds all non nan elements in a list of series
+	SumType = "sum"
+
+	// AvgType averages all non nan elements in a list of series
+	AvgType = "avg"
+
+	// MinType takes the minimum of all non nan elements in a list of series
+	MinType = "min"
+
+	// MaxType takes the maximum of all non nan elements in a list of series
+	MaxType = "max"
+
+	// CountType counts all non nan elements in a list of series
+	CountType = "count"
+
+	// ExistsType is 1 at each step where any series of a group has a value, and nothing otherwise,
+	// e.g. for up style existence alerts in recording rules. Unlike the other aggregations it has no
+	// PromQL syntax, and without grouping labels it collapses every series into a single one
+	ExistsType = "exists"
+
+	// QuantileType calculates the φ-quantile (0 ≤ φ ≤ 1) over the non nan elements in a list of series
+	QuantileType = "quantile"
+)
+
+// sumFn relies on IEEE 754 addition for infinities, so a group holding both +Inf
+// and -Inf sums to NaN as it does in Prometheus
+func sumFn(values []float64, bucket []int) float64 {
+	sum := 0.0
+	count := 0
+	for _, idx := range bucket {
+		v := values[idx]
+		if !math.IsNaN(v) {
+			sum += v
+			count++
+		}
+	}
+
+	if count == 0 {
+		return math.NaN()
+	}
+
+	return sum
+}
+
+func avgFn(values []float64, bucket []int) float64 {
+	sum := sumFn(values, bucket)
+	if math.IsNaN(sum) {
+		return sum
+	}
+
+	return sum / countFn(values, bucket)
+}
+
+func minFn(values []float64, bucket []int) float64 {
+	min := math.NaN()
+	for _, idx := range bucket {
+		if v := values[idx]; !math.IsNaN(v) && (math.IsNaN(min) || v < min) {
+			min = v
+		}
+	}
+
+	return min
+}
+
+func maxFn(values []float64, bucket []int) float64 {
+	max := math.NaN()
+	for _, idx := range bucket {
+		if v := values[idx]; !math.IsNaN(v) && (math.IsNaN(max) || v > max) {
+			max = v
+		}
+	}
+
+	return max
+}
+
+func countFn(values []float64, bucket []int) float64 {
+	count := 0.0
+	for _, idx := range bucket {
+		if !math.IsNaN(values[idx]) {
+			count++
+		}
+	}
+
+	if count == 0 {
+		return math.NaN()
+	}
+
+	return count
+}
+
+func existsFn(values []float64, bucket []int) float64 {
+	for _, idx := range bucket {
+		if !math.IsNaN(values[idx]) {
+			return 1
+		}
+	}
+
+	return math.NaN()
+}
+
+func makeQuantileFn(q float64, method utils.InterpolationMethod) aggregationFn {
+	return func(values []float64, bucket []int) float64 {
+		sorted := make([]float64, 0, len(bucket))
+		for _, idx := range bucket {
+			if v := values[idx]; !math.IsNaN(v) {
+				sorted = append(sorted, v)
+			}
+		}
+
+		sort.Float64s(sorted)
+		return quantile.Interpolate(sorted, q, method)
+	}
+}
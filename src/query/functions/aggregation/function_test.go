@@ -0,0 +1,271 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var seriesMetas = []block.SeriesMeta{
+	{Tags: models.Tags{models.MetricName: "up", "a": "1", "b": "1"}, Source: "zone-a"},
+	{Tags: models.Tags{models.MetricName: "up", "a": "1", "b": "2"}, Source: "zone-b"},
+	{Tags: models.Tags{models.MetricName: "up", "a": "2", "b": "1"}, Source: "zone-a"},
+}
+
+func processAggregationOp(t *testing.T, opType string, params NodeParams, values [][]float64) *executor.SinkNode {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, seriesMetas, values)
+	op, err := NewAggregationOp(opType, params)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	return sink
+}
+
+func TestSum(t *testing.T) {
+	values := [][]float64{
+		{0, math.NaN(), 2, 3, math.NaN()},
+		{5, 6, 7, 8, math.NaN()},
+		{10, 11, 12, 13, 14},
+	}
+
+	sink := processAggregationOp(t, SumType, NodeParams{MatchingTags: []string{"a"}}, values)
+	expected := [][]float64{
+		{5, 6, 9, 11, math.NaN()},
+		{10, 11, 12, 13, 14},
+	}
+
+	test.EqualsWithNans(t, expected, sink.Values)
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{"a": "1"}, sink.Metas[0].Tags)
+	assert.Equal(t, models.Tags{"a": "2"}, sink.Metas[1].Tags)
+}
+
+func TestSumWithout(t *testing.T) {
+	values := [][]float64{
+		{0, 1, 2, 3, 4},
+		{5, 6, 7, 8, 9},
+		{10, 11, 12, 13, 14},
+	}
+
+	sink := processAggregationOp(t, SumType, NodeParams{MatchingTags: []string{"a"}, Without: true}, values)
+	expected := [][]float64{
+		{10, 12, 14, 16, 18},
+		{5, 6, 7, 8, 9},
+	}
+
+	assert.Equal(t, expected, sink.Values)
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{"b": "1"}, sink.Metas[0].Tags)
+	assert.Equal(t, models.Tags{"b": "2"}, sink.Metas[1].Tags)
+}
+
+func TestSumClearsSource(t *testing.T) {
+	values, _ := test.GenerateValuesAndBounds([][]float64{{0}, {1}, {2}}, nil)
+	sink := processAggregationOp(t, SumType, NodeParams{MatchingTags: []string{"b"}}, values)
+	require.Len(t, sink.Metas, 2)
+	for _, meta := range sink.Metas {
+		assert.Empty(t, meta.Source, "merged series should not keep a source")
+	}
+}
+
+func TestUnknownAggregation(t *testing.T) {
+	_, err := NewAggregationOp("unknown", NodeParams{})
+	assert.Error(t, err)
+}
+
+func TestQuantileWithInterpolationMethods(t *testing.T) {
+	values := [][]float64{
+		{1, 4, math.NaN()},
+		{2, 1, math.NaN()},
+		{4, 2, math.NaN()},
+	}
+
+	tests := []struct {
+		method   utils.InterpolationMethod
+		expected []float64
+	}{
+		{method: utils.LinearInterpolation, expected: []float64{2.4, 2.4, math.NaN()}},
+		{method: utils.LowerInterpolation, expected: []float64{2, 2, math.NaN()}},
+		{method: utils.HigherInterpolation, expected: []float64{4, 4, math.NaN()}},
+		{method: utils.NearestInterpolation, expected: []float64{2, 2, math.NaN()}},
+	}
+
+	for _, tt := range tests {
+		sink := processAggregationOp(t, QuantileType, NodeParams{Parameter: 0.6, InterpolationMethod: tt.method}, values)
+		require.Len(t, sink.Values, 1)
+		require.Len(t, sink.Values[0], 3)
+		assert.InDelta(t, tt.expected[0], sink.Values[0][0], 1e-9, "method: %d", tt.method)
+		assert.InDelta(t, tt.expected[1], sink.Values[0][1], 1e-9, "method: %d", tt.method)
+		assert.True(t, math.IsNaN(sink.Values[0][2]))
+	}
+}
+
+func TestQuantileDefaultsToLinear(t *testing.T) {
+	values := [][]float64{{1}, {2}, {4}}
+	sink := processAggregationOp(t, QuantileType, NodeParams{Parameter: 0.9}, values)
+	assert.InDelta(t, 3.6, sink.Values[0][0], 1e-9)
+}
+
+func TestQuantileByKeepsGroupingTags(t *testing.T) {
+	values := [][]float64{{1}, {3}, {4}}
+	sink := processAggregationOp(t, QuantileType, NodeParams{MatchingTags: []string{"a"}, Parameter: 0.5}, values)
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{"a": "1"}, sink.Metas[0].Tags)
+	assert.Equal(t, models.Tags{"a": "2"}, sink.Metas[1].Tags)
+	assert.Equal(t, [][]float64{{2}, {4}}, sink.Values)
+}
+
+func TestSumWithEmptyBlocks(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	for _, metas := range [][]block.SeriesMeta{nil, seriesMetas} {
+		values := make([][]float64, len(metas))
+		op, err := NewAggregationOp(SumType, NodeParams{MatchingTags: []string{"a"}})
+		require.NoError(t, err)
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		node := op.Node(c)
+		err = node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+		require.NoError(t, err)
+		assert.Equal(t, bounds, sink.Meta.Bounds)
+		for _, series := range sink.Values {
+			assert.Empty(t, series)
+		}
+	}
+}
+
+func TestAvgAndCount(t *testing.T) {
+	values := [][]float64{
+		{0, math.NaN(), 2, 3, math.NaN()},
+		{5, 6, 7, 8, math.NaN()},
+		{10, 11, 12, 13, 14},
+	}
+
+	sink := processAggregationOp(t, AvgType, NodeParams{MatchingTags: []string{"a"}}, values)
+	expected := [][]float64{
+		{2.5, 6, 4.5, 5.5, math.NaN()},
+		{10, 11, 12, 13, 14},
+	}
+
+	test.EqualsWithNans(t, expected, sink.Values)
+
+	sink = processAggregationOp(t, CountType, NodeParams{MatchingTags: []string{"a"}}, values)
+	expected = [][]float64{
+		{2, 1, 2, 2, math.NaN()},
+		{1, 1, 1, 1, 1},
+	}
+
+	test.EqualsWithNans(t, expected, sink.Values)
+}
+
+func TestGroupSize(t *testing.T) {
+	values := [][]float64{
+		{0, math.NaN(), 2, 3, math.NaN()},
+		{5, 6, 7, 8, math.NaN()},
+		{10, 11, 12, 13, math.NaN()},
+	}
+
+	for _, opType := range []string{SumType, AvgType, CountType} {
+		sink := processAggregationOp(t, opType, NodeParams{MatchingTags: []string{"a"}, IncludeGroupSize: true}, values)
+		require.Len(t, sink.Values, 4, opType)
+		// The siblings follow the groups, and count the non nan contributors at each step
+		assert.Equal(t, []float64{2, 1, 2, 2, 0}, sink.Values[2], opType)
+		assert.Equal(t, []float64{1, 1, 1, 1, 0}, sink.Values[3], opType)
+		assert.Equal(t, models.Tags{"a": "1", GroupSizeTag: "true"}, sink.Metas[2].Tags, opType)
+		assert.Equal(t, models.Tags{"a": "2", GroupSizeTag: "true"}, sink.Metas[3].Tags, opType)
+	}
+
+	sink := processAggregationOp(t, SumType, NodeParams{MatchingTags: []string{"a"}}, values)
+	assert.Len(t, sink.Values, 2, "group sizes are off by default")
+
+	_, err := NewAggregationOp(QuantileType, NodeParams{Parameter: 0.5, IncludeGroupSize: true})
+	assert.Error(t, err)
+}
+
+func TestAggregationsWithInfinities(t *testing.T) {
+	inf, ninf, nan := math.Inf(1), math.Inf(-1), math.NaN()
+	values := [][]float64{
+		{inf, inf, ninf, inf, 1},
+		{1, ninf, ninf, nan, 2},
+		{ninf, 3, nan, inf, inf},
+	}
+
+	tests := []struct {
+		opType   string
+		expected [][]float64
+	}{
+		{opType: SumType, expected: [][]float64{{inf, nan, ninf, inf, 3}, {ninf, 3, nan, inf, inf}}},
+		{opType: AvgType, expected: [][]float64{{inf, nan, ninf, inf, 1.5}, {ninf, 3, nan, inf, inf}}},
+		{opType: MinType, expected: [][]float64{{1, ninf, ninf, inf, 1}, {ninf, 3, nan, inf, inf}}},
+		{opType: MaxType, expected: [][]float64{{inf, inf, ninf, inf, 2}, {ninf, 3, nan, inf, inf}}},
+	}
+
+	for _, tt := range tests {
+		sink := processAggregationOp(t, tt.opType, NodeParams{MatchingTags: []string{"a"}}, values)
+		test.EqualsWithNans(t, tt.expected, sink.Values)
+	}
+}
+
+func TestMinAndMax(t *testing.T) {
+	values := [][]float64{
+		{-1, math.NaN(), 2, 3, math.NaN()},
+		{5, 6, -7, 8, math.NaN()},
+		{10, 11, 12, 13, 14},
+	}
+
+	sink := processAggregationOp(t, MinType, NodeParams{MatchingTags: []string{"a"}}, values)
+	test.EqualsWithNans(t, [][]float64{{-1, 6, -7, 3, math.NaN()}, {10, 11, 12, 13, 14}}, sink.Values)
+
+	sink = processAggregationOp(t, MaxType, NodeParams{MatchingTags: []string{"a"}}, values)
+	test.EqualsWithNans(t, [][]float64{{5, 6, 2, 8, math.NaN()}, {10, 11, 12, 13, 14}}, sink.Values)
+}
+
+func TestExists(t *testing.T) {
+	nan := math.NaN()
+	// Series appear and disappear across the steps, and none exist at the last step
+	values := [][]float64{
+		{0, nan, nan, 3, nan},
+		{nan, 6, nan, nan, nan},
+		{nan, nan, nan, 13, nan},
+	}
+
+	sink := processAggregationOp(t, ExistsType, NodeParams{}, values)
+	test.EqualsWithNans(t, [][]float64{{1, 1, nan, 1, nan}}, sink.Values)
+	require.Len(t, sink.Metas, 1, "series are collapsed into one without grouping labels")
+	assert.Empty(t, sink.Metas[0].Tags)
+
+	sink = processAggregationOp(t, ExistsType, NodeParams{MatchingTags: []string{"a"}}, values)
+	test.EqualsWithNans(t, [][]float64{{1, 1, nan, 1, nan}, {nan, nan, nan, 1, nan}}, sink.Values)
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{"a": "2"}, sink.Metas[1].Tags)
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"math"
+	"sync"
+
+	"github.com/m3db/m3/src/query/block"
+)
+
+// rollupFunctions are the aggregations which can be combined from partial aggregations
+var rollupFunctions = map[string]bool{
+	SumType:   true,
+	CountType: true,
+}
+
+// partialAggregation holds the sum and count of the non nan values of each group at each step
+type partialAggregation struct {
+	sums   [][]float64
+	counts [][]float64
+}
+
+func newPartialAggregation(groups, steps int) partialAggregation {
+	p := partialAggregation{
+		sums:   make([][]float64, groups),
+		counts: make([][]float64, groups),
+	}
+
+	for i := range p.sums {
+		p.sums[i] = make([]float64, steps)
+		p.counts[i] = make([]float64, steps)
+	}
+
+	return p
+}
+
+// rollup aggregates the steps in two phases. Series are partitioned into shards by their
+// index, which is cheaper than hashing their tags and spreads wide groups evenly, and each
+// shard is aggregated concurrently. The partial aggregations of the shards are then combined
+// by group
+func rollup(steps [][]float64, buckets [][]int, shards int) partialAggregation {
+	// shardBuckets holds the indices of the series of each group which belong to each shard
+	shardBuckets := make([][][]int, shards)
+	for s := range shardBuckets {
+		shardBuckets[s] = make([][]int, len(buckets))
+	}
+
+	for g, bucket := range buckets {
+		for _, idx := range bucket {
+			s := idx % shards
+			shardBuckets[s][g] = append(shardBuckets[s][g], idx)
+		}
+	}
+
+	partials := make([]partialAggregation, shards)
+	var wg sync.WaitGroup
+	wg.Add(shards)
+	for s := 0; s < shards; s++ {
+		go func(s int) {
+			defer wg.Done()
+			partial := newPartialAggregation(len(buckets), len(steps))
+			for g, bucket := range shardBuckets[s] {
+				for i, values := range steps {
+					for _, idx := range bucket {
+						if v := values[idx]; !math.IsNaN(v) {
+							partial.sums[g][i] += v
+							partial.counts[g][i]++
+						}
+					}
+				}
+			}
+
+			partials[s] = partial
+		}(s)
+	}
+
+	wg.Wait()
+	combined := partials[0]
+	for _, partial := range partials[1:] {
+		for g := range buckets {
+			for i := range steps {
+				combined.sums[g][i] += partial.sums[g][i]
+				combined.counts[g][i] += partial.counts[g][i]
+			}
+		}
+	}
+
+	return combined
+}
+
+// processRollup aggregates the block with rollup, which needs all the steps up front
+func (n *baseNode) processRollup(stepIter block.StepIter, buckets [][]int) ([][]float64, error) {
+	steps := make([][]float64, 0, stepIter.StepCount())
+	for stepIter.Next() {
+		step, err := stepIter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, step.Values())
+	}
+
+	params := n.params
+	combined := rollup(steps, buckets, params.RollupShards)
+	outputs := len(buckets)
+	if params.IncludeGroupSize {
+		outputs *= 2
+	}
+
+	results := make([][]float64, len(steps))
+	for i := range steps {
+		results[i] = make([]float64, 0, outputs)
+		for g := range buckets {
+			count := combined.counts[g][i]
+			switch {
+			case count == 0:
+				results[i] = append(results[i], math.NaN())
+			case n.op.opType == CountType:
+				results[i] = append(results[i], count)
+			default:
+				results[i] = append(results[i], combined.sums[g][i])
+			}
+		}
+
+		if params.IncludeGroupSize {
+			results[i] = append(results[i], countsAt(combined.counts, i)...)
+		}
+	}
+
+	return results, nil
+}
+
+// countsAt returns the count of each group at the step
+func countsAt(counts [][]float64, step int) []float64 {
+	values := make([]float64, len(counts))
+	for g := range counts {
+		values[g] = counts[g][step]
+	}
+
+	return values
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wideBlock generates a block with the number of series spread over the groups, with some NaNs
+func wideBlock(numSeries, groups, steps int) block.Block {
+	r := rand.New(rand.NewSource(1))
+	metas := make([]block.SeriesMeta, numSeries)
+	values := make([][]float64, numSeries)
+	for i := range metas {
+		metas[i] = block.SeriesMeta{Tags: models.Tags{
+			"group":    fmt.Sprint(i % groups),
+			"instance": fmt.Sprint(i),
+		}}
+
+		values[i] = make([]float64, steps)
+		for j := range values[i] {
+			values[i][j] = r.Float64() * 100
+			// The first group is empty at the last step
+			if r.Intn(10) == 0 || (i%groups == 0 && j == steps-1) {
+				values[i][j] = math.NaN()
+			}
+		}
+	}
+
+	now := time.Now()
+	bounds := block.Bounds{
+		Start:    now,
+		End:      now.Add(time.Duration(steps-1) * time.Minute),
+		StepSize: time.Minute,
+	}
+
+	return test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)
+}
+
+func processWide(t testing.TB, opType string, params NodeParams, b block.Block) *executor.SinkNode {
+	op, err := NewAggregationOp(opType, params)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	err = op.Node(c).Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	return sink
+}
+
+func TestRollupMatchesSingleThreadedAggregation(t *testing.T) {
+	b := wideBlock(1000, 7, 5)
+	for _, opType := range []string{SumType, CountType} {
+		for _, includeGroupSize := range []bool{false, true} {
+			params := NodeParams{MatchingTags: []string{"group"}, IncludeGroupSize: includeGroupSize}
+			expected := processWide(t, opType, params, b)
+
+			params.RollupShards = 4
+			actual := processWide(t, opType, params, b)
+			assert.Equal(t, expected.Metas, actual.Metas)
+			require.Len(t, actual.Values, len(expected.Values))
+			for i := range expected.Values {
+				require.Len(t, actual.Values[i], len(expected.Values[i]))
+				for j, v := range expected.Values[i] {
+					if math.IsNaN(v) {
+						assert.True(t, math.IsNaN(actual.Values[i][j]), "%s series %d step %d", opType, i, j)
+						continue
+					}
+
+					assert.InDelta(t, v, actual.Values[i][j], 1e-6, "%s series %d step %d", opType, i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestRollupWithInvalidParams(t *testing.T) {
+	_, err := NewAggregationOp(QuantileType, NodeParams{RollupShards: 4})
+	assert.Error(t, err)
+
+	_, err = NewAggregationOp(SumType, NodeParams{RollupShards: -1})
+	assert.Error(t, err)
+
+	_, err = NewAggregationOp(AvgType, NodeParams{RollupShards: 1})
+	assert.NoError(t, err, "a single shard is the regular aggregation")
+}
+
+func benchmarkSum(b *testing.B, shards int) {
+	block := wideBlock(100000, 100, 10)
+	params := NodeParams{MatchingTags: []string{"group"}, RollupShards: shards}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		processWide(b, SumType, params, block)
+	}
+}
+
+func BenchmarkSum(b *testing.B) {
+	benchmarkSum(b, 1)
+}
+
+func BenchmarkSumWithRollup(b *testing.B) {
+	benchmarkSum(b, 8)
+}
+
+func TestRollupShardsOfQuery(t *testing.T) {
+	opts := transform.Options{RollupShards: 4}
+	for opType, shards := range map[string]int{SumType: 4, CountType: 4, AvgType: 0, MaxType: 0} {
+		op, err := NewAggregationOp(opType, NodeParams{})
+		require.NoError(t, err)
+		assert.Equal(t, shards, op.queryParams(opts).RollupShards, opType)
+	}
+
+	// The op's own sharding and streaming take precedence
+	op, err := NewAggregationOp(SumType, NodeParams{RollupShards: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, op.queryParams(opts).RollupShards)
+
+	op, err = NewAggregationOp(SumType, NodeParams{Streaming: true})
+	require.NoError(t, err)
+	assert.Equal(t, 0, op.queryParams(opts).RollupShards)
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"math"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/functions/utils"
+)
+
+// streamingFunctions are the aggregations which can be accumulated one series at a time
+var streamingFunctions = map[string]bool{
+	SumType:   true,
+	AvgType:   true,
+	MinType:   true,
+	MaxType:   true,
+	CountType: true,
+}
+
+// accumulator holds the running value and number of non nan values of each group at each step
+type accumulator struct {
+	opType string
+	values [][]float64
+	counts [][]float64
+}
+
+func newAccumulator(opType string, groups, steps int) *accumulator {
+	a := &accumulator{
+		opType: opType,
+		values: make([][]float64, groups),
+		counts: make([][]float64, groups),
+	}
+
+	for g := range a.values {
+		a.values[g] = make([]float64, steps)
+		a.counts[g] = make([]float64, steps)
+	}
+
+	return a
+}
+
+// add accumulates the values of a series into its group
+func (a *accumulator) add(group int, values []float64) {
+	accumulated, counts := a.values[group], a.counts[group]
+	for i, v := range values {
+		if math.IsNaN(v) || i >= len(accumulated) {
+			continue
+		}
+
+		counts[i]++
+		switch {
+		case counts[i] == 1:
+			accumulated[i] = v
+		case a.opType == MinType:
+			if v < accumulated[i] {
+				accumulated[i] = v
+			}
+		case a.opType == MaxType:
+			if v > accumulated[i] {
+				accumulated[i] = v
+			}
+		default:
+			accumulated[i] += v
+		}
+	}
+}
+
+// value returns the aggregated value of the group at the step
+func (a *accumulator) value(group, step int) float64 {
+	count := a.counts[group][step]
+	switch {
+	case count == 0:
+		return math.NaN()
+	case a.opType == CountType:
+		return count
+	case a.opType == AvgType:
+		return a.values[group][step] / count
+	default:
+		return a.values[group][step]
+	}
+}
+
+// processStreaming aggregates the block one series at a time, only keeping the running
+// accumulators of each group. Memory is then bounded by the number of groups and steps, rather
+// than by the number of series
+func (n *baseNode) processStreaming(b block.Block) error {
+	seriesIter, err := b.SeriesIter()
+	if err != nil {
+		return err
+	}
+
+	defer seriesIter.Close()
+	params := n.params
+	buckets, metas := utils.GroupSeries(params.MatchingTags, params.Without, n.op.opType, seriesIter.SeriesMeta())
+	groups := make([]int, len(seriesIter.SeriesMeta()))
+	for g, bucket := range buckets {
+		for _, idx := range bucket {
+			groups[idx] = g
+		}
+	}
+
+	if params.IncludeGroupSize {
+		metas = append(metas, groupSizeMetas(metas)...)
+	}
+
+	meta := seriesIter.Meta()
+	steps := meta.Bounds.Steps()
+	acc := newAccumulator(n.op.opType, len(buckets), steps)
+	for idx := 0; seriesIter.Next(); idx++ {
+		series, err := seriesIter.Current()
+		if err != nil {
+			return err
+		}
+
+		acc.add(groups[idx], series.Values())
+	}
+
+	builder, err := n.controller.BlockBuilder(meta, metas)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return err
+	}
+
+	for i := 0; i < steps; i++ {
+		for g := range buckets {
+			if err := builder.AppendValue(i, acc.value(g, i)); err != nil {
+				return err
+			}
+		}
+
+		if params.IncludeGroupSize {
+			for _, count := range countsAt(acc.counts, i) {
+				if err := builder.AppendValue(i, count); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamingMatchesBufferedAggregation(t *testing.T) {
+	b := wideBlock(1000, 7, 5)
+	for _, opType := range []string{SumType, AvgType, MinType, MaxType, CountType, QuantileType} {
+		for _, includeGroupSize := range []bool{false, true} {
+			if includeGroupSize && (opType == MinType || opType == MaxType || opType == QuantileType) {
+				continue
+			}
+
+			params := NodeParams{MatchingTags: []string{"group"}, IncludeGroupSize: includeGroupSize, Parameter: 0.5}
+			expected := processWide(t, opType, params, b)
+
+			params.Streaming = true
+			actual := processWide(t, opType, params, b)
+			assert.Equal(t, expected.Metas, actual.Metas, opType)
+			require.Len(t, actual.Values, len(expected.Values))
+			for i := range expected.Values {
+				require.Len(t, actual.Values[i], len(expected.Values[i]))
+				for j, v := range expected.Values[i] {
+					if math.IsNaN(v) {
+						assert.True(t, math.IsNaN(actual.Values[i][j]), "%s series %d step %d", opType, i, j)
+						continue
+					}
+
+					assert.Equal(t, v, actual.Values[i][j], "%s series %d step %d", opType, i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamingWithRollup(t *testing.T) {
+	_, err := NewAggregationOp(SumType, NodeParams{RollupShards: 4, Streaming: true})
+	assert.Error(t, err)
+}
+
+func benchmarkWideAggregation(b *testing.B, streaming bool) {
+	block := wideBlock(100000, 100, 10)
+	params := NodeParams{MatchingTags: []string{"group"}, Streaming: streaming}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		processWide(b, AvgType, params, block)
+	}
+}
+
+func TestStreamingOfQuery(t *testing.T) {
+	opts := transform.Options{StreamingAggregation: true}
+	for opType, streaming := range map[string]bool{SumType: true, MaxType: true, QuantileType: false} {
+		op, err := NewAggregationOp(opType, NodeParams{})
+		require.NoError(t, err)
+		assert.Equal(t, streaming, op.queryParams(opts).Streaming, opType)
+	}
+
+	// The op's own sharding takes precedence
+	op, err := NewAggregationOp(SumType, NodeParams{RollupShards: 2})
+	require.NoError(t, err)
+	assert.False(t, op.queryParams(opts).Streaming)
+}
+
+func BenchmarkBufferedAvg(b *testing.B) {
+	benchmarkWideAggregation(b, false)
+}
+
+func BenchmarkStreamingAvg(b *testing.B) {
+	benchmarkWideAggregation(b, true)
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+const (
+	// TopKType keeps the largest k elements of each group at each step
+	TopKType = "topk"
+
+	// BottomKType keeps the smallest k elements of each group at each step
+	BottomKType = "bottomk"
+
+	// RangeTopKType keeps, in full, the k series of each group with the largest aggregate
+	// over the whole range, so that membership does not change from step to step
+	RangeTopKType = "range_topk"
+)
+
+type takeOp struct {
+	params  NodeParams
+	opType  string
+	rangeFn aggregationFn
+}
+
+// NewTakeOp creates a new take op based on the type, with the parameter as k
+func NewTakeOp(opType string, params NodeParams) (transform.Params, error) {
+	op := takeOp{
+		params: params,
+		opType: opType,
+	}
+
+	switch opType {
+	case TopKType, BottomKType:
+	case RangeTopKType:
+		aggregation := params.RangeAggregation
+		if aggregation == "" {
+			aggregation = SumType
+		}
+
+		fn, ok := aggregationFunctions[aggregation]
+		if !ok {
+			return nil, fmt.Errorf("unsupported range aggregation for %s: %s", opType, aggregation)
+		}
+
+		op.rangeFn = fn
+	default:
+		return nil, fmt.Errorf("operator not supported: %s", opType)
+	}
+
+	return op, nil
+}
+
+// OpType for the operator
+func (o takeOp) OpType() string {
+	return o.opType
+}
+
+// String representation
+func (o takeOp) String() string {
+	return fmt.Sprintf("type: %s, k: %v, matching: %v, without: %t", o.OpType(), o.params.Parameter, o.params.MatchingTags, o.params.Without)
+}
+
+// FormatExpr renders the take of its input along with the grouping clause
+func (o takeOp) FormatExpr(inputs []string) string {
+	inputs = append([]string{parser.FormatLiteral(o.params.Parameter)}, inputs...)
+	return formatAggregation(o.opType, o.params, inputs)
+}
+
+// Node creates an execution node
+func (o takeOp) Node(controller *transform.Controller) transform.OpNode {
+	return &takeNode{
+		op:          o,
+		controller:  controller,
+		includeTies: o.params.IncludeTies || controller.Options.IncludeTies,
+	}
+}
+
+type takeNode struct {
+	op         takeOp
+	controller *transform.Controller
+	// includeTies takes the ties of the k-th element, if the op or the query asks for them
+	includeTies bool
+}
+
+// Process the block
+func (n *takeNode) Process(ID parser.NodeID, b block.Block) error {
+	if n.op.opType == RangeTopKType {
+		return n.processRange(b)
+	}
+
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	params := n.op.params
+	seriesMetas := stepIter.SeriesMeta()
+	if clampK(params.Parameter, len(seriesMetas)) == 0 {
+		return n.processEmpty(stepIter.Meta(), stepIter.StepCount())
+	}
+
+	buckets, _ := utils.GroupSeries(params.MatchingTags, params.Without, n.op.opType, seriesMetas)
+	// Unlike other aggregations, the selected series keep their own metadata
+	builder, err := n.controller.BlockBuilder(stepIter.Meta(), seriesMetas)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(stepIter.StepCount()); err != nil {
+		return err
+	}
+
+	taken := make([]float64, len(seriesMetas))
+	for index := 0; stepIter.Next(); index++ {
+		step, err := stepIter.Current()
+		if err != nil {
+			return err
+		}
+
+		values := step.Values()
+		for i := range taken {
+			taken[i] = math.NaN()
+		}
+
+		for _, bucket := range buckets {
+			for _, idx := range takeIndices(values, bucket, params.Parameter, n.op.opType == TopKType, n.includeTies) {
+				taken[idx] = values[idx]
+			}
+		}
+
+		for _, value := range taken {
+			if err := builder.AppendValue(index, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
+
+// processRange keeps the k series of each group with the largest aggregate over all steps
+func (n *takeNode) processRange(b block.Block) error {
+	seriesIter, err := b.SeriesIter()
+	if err != nil {
+		return err
+	}
+
+	params := n.op.params
+	seriesMetas := seriesIter.SeriesMeta()
+	if clampK(params.Parameter, len(seriesMetas)) == 0 {
+		meta := seriesIter.Meta()
+		return n.processEmpty(meta, meta.Bounds.Steps())
+	}
+
+	buckets, _ := utils.GroupSeries(params.MatchingTags, params.Without, n.op.opType, seriesMetas)
+
+	allSeries := make([][]float64, 0, len(seriesMetas))
+	for seriesIter.Next() {
+		series, err := seriesIter.Current()
+		if err != nil {
+			return err
+		}
+
+		allSeries = append(allSeries, series.Values())
+	}
+
+	aggregates := make([]float64, len(allSeries))
+	for i, values := range allSeries {
+		all := make([]int, len(values))
+		for j := range all {
+			all[j] = j
+		}
+
+		aggregates[i] = n.op.rangeFn(values, all)
+	}
+
+	var kept []int
+	for _, bucket := range buckets {
+		kept = append(kept, takeIndices(aggregates, bucket, params.Parameter, true, n.includeTies)...)
+	}
+
+	keptMetas := make([]block.SeriesMeta, len(kept))
+	for i, idx := range kept {
+		keptMetas[i] = seriesMetas[idx]
+	}
+
+	builder, err := n.controller.BlockBuilder(seriesIter.Meta(), keptMetas)
+	if err != nil {
+		return err
+	}
+
+	steps := 0
+	if len(allSeries) > 0 {
+		steps = len(allSeries[0])
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return err
+	}
+
+	for _, idx := range kept {
+		for step, value := range allSeries[idx] {
+			if err := builder.AppendValue(step, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
+
+// processEmpty sends a block without any series, as taking no elements gives an empty vector
+func (n *takeNode) processEmpty(meta block.Metadata, steps int) error {
+	builder, err := n.controller.BlockBuilder(meta, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return err
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
+
+// clampK returns the number of elements to take from a group of the size, which is k clamped
+// to the group. It is clamped before converting, since huge floats do not convert to ints
+func clampK(k float64, size int) int {
+	if math.IsNaN(k) || k < 1 {
+		return 0
+	}
+
+	if k >= float64(size) {
+		return size
+	}
+
+	return int(k)
+}
+
+// takeIndices returns the indices in the bucket of the k largest, or smallest, non nan values
+// ordered from first to last taken. Ties are broken by the order in the bucket, unless includeTies
+// is set, in which case every value equal to the k-th is taken too
+func takeIndices(values []float64, bucket []int, param float64, largest, includeTies bool) []int {
+	k := clampK(param, len(bucket))
+	if k == 0 {
+		return nil
+	}
+
+	indices := make([]int, 0, len(bucket))
+	for _, idx := range bucket {
+		if !math.IsNaN(values[idx]) {
+			indices = append(indices, idx)
+		}
+	}
+
+	sort.SliceStable(indices, func(i, j int) bool {
+		if largest {
+			return values[indices[i]] > values[indices[j]]
+		}
+
+		return values[indices[i]] < values[indices[j]]
+	})
+
+	if includeTies && len(indices) > k {
+		cutoff := values[indices[k-1]]
+		for k < len(indices) && values[indices[k]] == cutoff {
+			k++
+		}
+	}
+
+	if len(indices) > k {
+		indices = indices[:k]
+	}
+
+	return indices
+}
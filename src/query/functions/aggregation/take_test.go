@@ -0,0 +1,209 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aggregation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processTakeOp(t *testing.T, opType string, params NodeParams, values [][]float64) *executor.SinkNode {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, seriesMetas, values)
+	op, err := NewTakeOp(opType, params)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	return sink
+}
+
+var takeValues = [][]float64{
+	{10, 1, 10, 1, 10},
+	{1, 5, 1, 5, math.NaN()},
+	{4, 4, 4, 4, 4},
+}
+
+func TestTopK(t *testing.T) {
+	sink := processTakeOp(t, TopKType, NodeParams{Parameter: 1}, takeValues)
+	nan := math.NaN()
+	expected := [][]float64{
+		{10, nan, 10, nan, 10},
+		{nan, 5, nan, 5, nan},
+		{nan, nan, nan, nan, nan},
+	}
+
+	test.EqualsWithNans(t, expected, sink.Values)
+	assert.Equal(t, seriesMetas, sink.Metas, "series keep their metadata")
+}
+
+func TestBottomKByGroup(t *testing.T) {
+	sink := processTakeOp(t, BottomKType, NodeParams{Parameter: 1, MatchingTags: []string{"a"}}, takeValues)
+	nan := math.NaN()
+	expected := [][]float64{
+		{nan, 1, nan, 1, 10},
+		{1, nan, 1, nan, nan},
+		{4, 4, 4, 4, 4},
+	}
+
+	test.EqualsWithNans(t, expected, sink.Values)
+}
+
+func TestTopKByRegion(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	var metas []block.SeriesMeta
+	for _, region := range []string{"east", "west"} {
+		for _, host := range []string{"a", "b", "c", "d"} {
+			metas = append(metas, block.SeriesMeta{Tags: models.Tags{"region": region, "host": host}})
+		}
+	}
+
+	values := [][]float64{
+		{1, 8, 1}, {2, 7, 2}, {3, 6, 3}, {4, 5, 4},
+		{40, 1, 40}, {30, 2, 30}, {20, 3, 20}, {10, 4, 10},
+	}
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)
+	op, err := NewTakeOp(TopKType, NodeParams{Parameter: 3, MatchingTags: []string{"region"}})
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	require.NoError(t, op.Node(c).Process(parser.NodeID(0), b))
+
+	nan := math.NaN()
+	expected := [][]float64{
+		{nan, 8, nan}, {2, 7, 2}, {3, 6, 3}, {4, nan, 4},
+		{40, nan, 40}, {30, 2, 30}, {20, 3, 20}, {nan, 4, nan},
+	}
+
+	test.EqualsWithNans(t, expected, sink.Values)
+	assert.Equal(t, metas, sink.Metas, "series keep all of their labels")
+}
+
+func TestTakeWithIncludeTies(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"a": "1"}},
+		{Tags: models.Tags{"a": "2"}},
+		{Tags: models.Tags{"a": "3"}},
+		{Tags: models.Tags{"a": "4"}},
+	}
+	// Three series tie at the cutoff for k = 2 at every step but the last
+	values := [][]float64{
+		{9, 9, 9, 9, 1},
+		{5, 5, 5, 5, 2},
+		{5, 5, 5, 5, 3},
+		{5, 5, 5, 5, 4},
+	}
+
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	nan := math.NaN()
+	process := func(opType string, includeTies bool) [][]float64 {
+		op, err := NewTakeOp(opType, NodeParams{Parameter: 2, IncludeTies: includeTies})
+		require.NoError(t, err)
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+		require.NoError(t, err)
+		return sink.Values
+	}
+
+	test.EqualsWithNans(t, [][]float64{
+		{9, 9, 9, 9, nan},
+		{5, 5, 5, 5, nan},
+		{nan, nan, nan, nan, 3},
+		{nan, nan, nan, nan, 4},
+	}, process(TopKType, false))
+
+	test.EqualsWithNans(t, [][]float64{
+		{9, 9, 9, 9, nan},
+		{5, 5, 5, 5, nan},
+		{5, 5, 5, 5, 3},
+		{5, 5, 5, 5, 4},
+	}, process(TopKType, true))
+
+	// Ties at the cutoff are all taken by bottomk too
+	test.EqualsWithNans(t, [][]float64{
+		{nan, nan, nan, nan, 1},
+		{5, 5, 5, 5, 2},
+		{5, 5, 5, 5, nan},
+		{5, 5, 5, 5, nan},
+	}, process(BottomKType, true))
+}
+
+func TestRangeTopKHasStableMembership(t *testing.T) {
+	// Per step topk churns between the first two series, while range_topk keeps the
+	// series with the largest sum over the whole range in full
+	nan := math.NaN()
+	sink := processTakeOp(t, TopKType, NodeParams{Parameter: 1}, takeValues)
+	test.EqualsWithNans(t, [][]float64{
+		{10, nan, 10, nan, 10},
+		{nan, 5, nan, 5, nan},
+		{nan, nan, nan, nan, nan},
+	}, sink.Values)
+
+	sink = processTakeOp(t, RangeTopKType, NodeParams{Parameter: 1}, takeValues)
+	assert.Equal(t, [][]float64{takeValues[0]}, sink.Values)
+	assert.Equal(t, []block.SeriesMeta{seriesMetas[0]}, sink.Metas)
+
+	sink = processTakeOp(t, RangeTopKType, NodeParams{Parameter: 2}, takeValues)
+	assert.Equal(t, [][]float64{takeValues[0], takeValues[2]}, sink.Values, "series are ordered by rank")
+}
+
+func TestTakeWithEdgeCaseK(t *testing.T) {
+	for _, opType := range []string{TopKType, BottomKType, RangeTopKType} {
+		for _, k := range []float64{0, -1, math.NaN()} {
+			sink := processTakeOp(t, opType, NodeParams{Parameter: k}, takeValues)
+			assert.Empty(t, sink.Values, "%s with k %v is an empty vector", opType, k)
+			assert.Empty(t, sink.Metas)
+		}
+
+		// A k of at least the group size takes every non NaN value, including a k too large for an int
+		for _, k := range []float64{3, 1000, 1e20} {
+			sink := processTakeOp(t, opType, NodeParams{Parameter: k}, takeValues)
+			if opType == RangeTopKType {
+				// Series are ordered by rank
+				test.EqualsWithNans(t, [][]float64{takeValues[0], takeValues[2], takeValues[1]}, sink.Values)
+				continue
+			}
+
+			test.EqualsWithNans(t, takeValues, sink.Values)
+			assert.Equal(t, seriesMetas, sink.Metas, "%s with k %v", opType, k)
+		}
+	}
+
+	sink := processTakeOp(t, TopKType, NodeParams{Parameter: 1000, MatchingTags: []string{"a"}}, takeValues)
+	test.EqualsWithNans(t, takeValues, sink.Values)
+}
+
+func TestTakeWithInvalidParams(t *testing.T) {
+	_, err := NewTakeOp(SumType, NodeParams{Parameter: 1})
+	assert.Error(t, err)
+
+	_, err = NewTakeOp(RangeTopKType, NodeParams{Parameter: 1, RangeAggregation: "stddev"})
+	assert.Error(t, err)
+}
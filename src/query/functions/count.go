@@ -46,6 +46,11 @@ func (o CountOp) String() string {
 	return fmt.Sprintf("type: %s", o.OpType())
 }
 
+// FormatExpr renders the aggregation of its input
+func (o CountOp) FormatExpr(inputs []string) string {
+	return parser.FormatFunction(CountType, inputs...)
+}
+
 // Node creates an execution node
 func (o CountOp) Node(controller *transform.Controller) transform.OpNode {
 	return &CountNode{op: o, controller: controller}
@@ -60,8 +65,14 @@ type CountNode struct {
 // Process the block
 func (c *CountNode) Process(ID parser.NodeID, b block.Block) error {
 	// TODO: Figure out a good name and tags after an aggregation operation
+	return processCount(c.controller, b, CountType)
+}
+
+// processCount sends a single series without tags holding the number of non nan values of the
+// block at each step
+func processCount(controller *transform.Controller, b block.Block, name string) error {
 	meta := block.SeriesMeta{
-		Name: CountType,
+		Name: name,
 	}
 
 	stepIter, err := b.StepIter()
@@ -69,7 +80,7 @@ func (c *CountNode) Process(ID parser.NodeID, b block.Block) error {
 		return err
 	}
 
-	builder, err := c.controller.BlockBuilder(stepIter.Meta(), []block.SeriesMeta{meta})
+	builder, err := controller.BlockBuilder(stepIter.Meta(), []block.SeriesMeta{meta})
 	if err != nil {
 		return err
 	}
@@ -92,10 +103,12 @@ func (c *CountNode) Process(ID parser.NodeID, b block.Block) error {
 			}
 		}
 
-		builder.AppendValue(index, count)
+		if err := builder.AppendValue(index, count); err != nil {
+			return err
+		}
 	}
 
 	nextBlock := builder.Build()
 	defer nextBlock.Close()
-	return c.controller.Process(nextBlock)
+	return controller.Process(nextBlock)
 }
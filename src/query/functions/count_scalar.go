@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// CountScalarType returns the number of series with a value at each step as a scalar, as the
+// legacy count_scalar function did. Unlike count, it is zero rather than empty without series
+const CountScalarType = "count_scalar"
+
+// CountScalarOp stores required properties for count_scalar
+type CountScalarOp struct {
+}
+
+// NewCountScalarOp creates a new count_scalar op
+func NewCountScalarOp(args []interface{}) (CountScalarOp, error) {
+	if len(args) != 0 {
+		return CountScalarOp{}, fmt.Errorf("invalid number of args for count_scalar: %d", len(args))
+	}
+
+	return CountScalarOp{}, nil
+}
+
+// OpType for the operator
+func (o CountScalarOp) OpType() string {
+	return CountScalarType
+}
+
+// String representation
+func (o CountScalarOp) String() string {
+	return fmt.Sprintf("type: %s", o.OpType())
+}
+
+// FormatExpr renders the function call on its input
+func (o CountScalarOp) FormatExpr(inputs []string) string {
+	return parser.FormatFunction(CountScalarType, inputs...)
+}
+
+// Node creates an execution node
+func (o CountScalarOp) Node(controller *transform.Controller) transform.OpNode {
+	return &CountScalarNode{op: o, controller: controller}
+}
+
+// CountScalarNode is an execution node
+type CountScalarNode struct {
+	op         CountScalarOp
+	controller *transform.Controller
+}
+
+// Process the block
+func (c *CountScalarNode) Process(ID parser.NodeID, b block.Block) error {
+	return processCount(c.controller, b, CountScalarType)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountScalar(t *testing.T) {
+	nan := math.NaN()
+	v := [][]float64{
+		{0, nan, 2, nan, nan},
+		{nan, 6, 7, 8, nan},
+		{1, 1, 1, nan, nan},
+	}
+
+	values, bounds := test.GenerateValuesAndBounds(v, nil)
+	op, err := NewCountScalarOp(nil)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValues(bounds, values))
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{2, 2, 3, 1, 0}}, sink.Values)
+	require.Len(t, sink.Metas, 1)
+	assert.Empty(t, sink.Metas[0].Tags)
+}
+
+func TestCountScalarWithoutSeries(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	builder := block.NewColumnBlockBuilder(block.Metadata{Bounds: bounds}, nil)
+	require.NoError(t, builder.AddCols(bounds.Steps()))
+
+	op, err := NewCountScalarOp(nil)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	require.NoError(t, op.Node(c).Process(parser.NodeID(0), builder.Build()))
+	require.Len(t, sink.Values, 1)
+	assert.Equal(t, make([]float64, bounds.Steps()), sink.Values[0])
+}
+
+func TestCountScalarWithArgs(t *testing.T) {
+	_, err := NewCountScalarOp([]interface{}{1.0})
+	assert.Error(t, err)
+}
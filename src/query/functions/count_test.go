@@ -23,10 +23,12 @@ package functions
 import (
 	"math"
 	"testing"
+	"time"
 
 	"github.com/m3db/m3/src/query/parser"
 	"github.com/m3db/m3/src/query/test"
 	"github.com/m3db/m3/src/query/test/executor"
+	"github.com/m3db/m3/src/query/test/fixtures"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -53,11 +55,12 @@ func TestCountWithSomeValues(t *testing.T) {
 		{math.NaN(), 6, 7, 8, 9},
 	}
 
-	values, bounds := test.GenerateValuesAndBounds(v, nil)
-	block := test.NewBlockFromValues(bounds, values)
+	bounds := fixtures.NewBounds(time.Now(), time.Minute, 5)
+	block, err := fixtures.NewBlockFromValues(bounds, v, nil)
+	require.NoError(t, err)
 	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
 	countNode := (&CountOp{}).Node(c)
-	err := countNode.Process(parser.NodeID(0), block)
+	err = countNode.Process(parser.NodeID(0), block)
 	require.NoError(t, err)
 	expected := []float64{1, 1, 2, 2, 2}
 	assert.Len(t, sink.Values, 1)
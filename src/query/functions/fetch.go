@@ -23,32 +23,60 @@ package functions
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/executor/transform"
 	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/parser"
 	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/ts"
+	"github.com/m3db/m3/src/query/util"
 )
 
 // FetchType gets the series from storage
 const FetchType = "fetch"
 
+// The bounds of the query which the @ modifier may pin a selector to
+const (
+	AtStart = "start"
+	AtEnd   = "end"
+)
+
 // FetchOp stores required properties for fetch
 type FetchOp struct {
 	Name     string
 	Range    time.Duration
 	Offset   time.Duration
 	Matchers models.Matchers
+	// SeriesLimit, when positive, caps the number of series returned, for paging through the matched series
+	SeriesLimit int
+	// SeriesOffset skips the first matched series, ordered by their tags so pages are stable across requests
+	SeriesOffset int
+	// Lookback, when set, overrides the lookback duration of the query for this selector, e.g. so
+	// that sparse series carry their values forward for longer than dense ones
+	Lookback *time.Duration
+	// At, when set by the @ modifier, pins the selector to an instant, so that every step of the
+	// query has the values at that instant. The offset is applied after the instant is pinned
+	At *time.Time
+	// StartOrEnd is set to AtStart or AtEnd by the @ start() and @ end() modifiers, which pin the
+	// selector to that bound of the query once it is resolved by WithQueryBounds
+	StartOrEnd string
 }
 
 // FetchNode is the execution node
 type FetchNode struct {
-	op         FetchOp
-	controller *transform.Controller
-	storage    storage.Storage
-	timespec   transform.TimeSpec
-	debug      bool
+	op            FetchOp
+	controller    *transform.Controller
+	storage       storage.Storage
+	timespec      transform.TimeSpec
+	debug         bool
+	alignSteps    bool
+	consolidation ts.ConsolidationOptions
+	rawSamples    *transform.RawSamples
+	sanitizer     transform.TagSanitizer
 }
 
 // OpType for the operator
@@ -58,38 +86,530 @@ func (o FetchOp) OpType() string {
 
 // String representation
 func (o FetchOp) String() string {
+	if o.StartOrEnd != "" {
+		return fmt.Sprintf("type: %s. name: %s, range: %v, at: %s(), offset: %v, matchers: %v", o.OpType(), o.Name, o.Range, o.StartOrEnd, o.Offset, o.Matchers)
+	}
+
+	if o.At != nil {
+		return fmt.Sprintf("type: %s. name: %s, range: %v, at: %s, offset: %v, matchers: %v", o.OpType(), o.Name, o.Range, o.At.UTC().Format(time.RFC3339Nano), o.Offset, o.Matchers)
+	}
+
 	return fmt.Sprintf("type: %s. name: %s, range: %v, offset: %v, matchers: %v", o.OpType(), o.Name, o.Range, o.Offset, o.Matchers)
 }
 
-// Node creates an execution node
+// RangeWindow returns the range of a range selector, which is zero for instant selectors
+func (o FetchOp) RangeWindow() time.Duration {
+	return o.Range
+}
+
+// WithQueryBounds returns the op with an @ start() or @ end() modifier resolved to the instant of
+// that bound of the query, so that the op is pinned to an absolute instant like any other @ modifier
+func (o FetchOp) WithQueryBounds(start, end time.Time) parser.Params {
+	return o.withQueryBounds(start, end)
+}
+
+func (o FetchOp) withQueryBounds(start, end time.Time) FetchOp {
+	switch o.StartOrEnd {
+	case AtStart:
+		o.At = &start
+	case AtEnd:
+		o.At = &end
+	default:
+		return o
+	}
+
+	o.StartOrEnd = ""
+	return o
+}
+
+// metricName returns the name of the metrics the selector matches, which may be set by an equality
+// name matcher rather than by the name
+func (o FetchOp) metricName() string {
+	if o.Name != "" {
+		return o.Name
+	}
+
+	for _, m := range o.Matchers {
+		if m.Name == models.MetricName && m.Type == models.MatchEqual {
+			return m.Value
+		}
+	}
+
+	return ""
+}
+
+// FormatExpr renders the selector, using the metric name in place of an equality name matcher
+func (o FetchOp) FormatExpr(_ []string) string {
+	name := o.Name
+	matchers := make([]string, 0, len(o.Matchers))
+	for _, m := range o.Matchers {
+		if m.Name == models.MetricName && m.Type == models.MatchEqual && (name == "" || m.Value == name) {
+			name = m.Value
+			continue
+		}
+
+		matchers = append(matchers, m.String())
+	}
+
+	expr := name
+	if len(matchers) > 0 || expr == "" {
+		expr += "{" + strings.Join(matchers, ", ") + "}"
+	}
+
+	if o.Range > 0 {
+		expr += "[" + util.FormatDuration(o.Range) + "]"
+	}
+
+	if o.StartOrEnd != "" {
+		expr += " @ " + o.StartOrEnd + "()"
+	} else if o.At != nil {
+		expr += " @ " + parser.FormatLiteral(float64(o.At.UnixNano())/float64(time.Second))
+	}
+
+	if o.Offset != 0 {
+		expr += " offset " + util.FormatDuration(o.Offset)
+	}
+
+	return expr
+}
+
+// Node creates an execution node. Selectors which do not page themselves or set their own lookback
+// are paged and looked back as the query is
 func (o FetchOp) Node(controller *transform.Controller, storage storage.Storage, options transform.Options) parser.Source {
-	return &FetchNode{op: o, controller: controller, storage: storage, timespec: options.TimeSpec, debug: options.Debug}
+	o = o.withQueryBounds(options.TimeSpec.Start, options.TimeSpec.End)
+	if o.SeriesLimit <= 0 && o.SeriesOffset == 0 {
+		o.SeriesLimit, o.SeriesOffset = options.SeriesLimit, options.SeriesOffset
+	}
+
+	if lookback, ok := options.SelectorLookbacks[o.metricName()]; ok && o.Lookback == nil {
+		o.Lookback = &lookback
+	}
+
+	return &FetchNode{
+		op:            o,
+		controller:    controller,
+		storage:       storage,
+		timespec:      options.TimeSpec,
+		debug:         options.Debug,
+		alignSteps:    options.AlignStepsToEpoch,
+		consolidation: options.Consolidation,
+		rawSamples:    options.RawSamples,
+		sanitizer:     options.TagSanitizer,
+	}
 }
 
 // Execute runs the fetch node operation
 func (n *FetchNode) Execute(ctx context.Context) error {
 	timeSpec := n.timespec
-	startTime := timeSpec.Start.Add(-1 * n.op.Offset)
-	endTime := timeSpec.End
-	blockResult, err := n.storage.FetchBlocks(ctx, &storage.FetchQuery{
+	queryBounds := timeSpec.Bounds(n.alignSteps)
+	queryStart := queryBounds.Start
+
+	// Range selectors need an extra window of data before the query start. With an offset, the
+	// data is fetched from the offset timeline and shifted back onto the query timeline, so that
+	// range windows end at the offset adjusted instant of each step. With the @ modifier, every step
+	// is evaluated at the pinned instant, which the offset then shifts
+	fetchStart, fetchEnd := queryStart, timeSpec.End
+	if n.op.At != nil {
+		fetchStart, fetchEnd = *n.op.At, *n.op.At
+	}
+
+	startTime := fetchStart.Add(-1 * (n.op.Offset + n.op.rangeLookback(timeSpec.Step)))
+	endTime := fetchEnd.Add(-1 * n.op.Offset)
+	consolidation := n.consolidation
+	if n.op.Lookback != nil {
+		consolidation.LookbackDuration = *n.op.Lookback
+	}
+
+	blockResult, err := n.fetchBlocks(ctx, &storage.FetchQuery{
 		Start:       startTime,
 		End:         endTime,
 		TagMatchers: n.op.Matchers,
 		Interval:    timeSpec.Step,
-	}, &storage.FetchOptions{})
+	}, &storage.FetchOptions{
+		Consolidation: consolidation,
+		BlockBuilder:  n.controller.BlockBuilder,
+		ChargeBlock:   n.controller.ChargeBlock,
+	})
 	if err != nil {
 		return err
 	}
 
-	for _, block := range blockResult.Blocks {
-		if err := n.controller.Process(block); err != nil {
-			block.Close()
+	for _, warning := range blockResult.Warnings {
+		n.controller.Options.Warnings.Add(warning)
+	}
+
+	blockResult.Blocks, err = n.op.paginate(n.controller.BlockBuilder, blockResult.Blocks)
+	if err != nil {
+		return err
+	}
+
+	empty, err := hasNoSeries(blockResult.Blocks)
+	if err != nil || empty {
+		for _, b := range blockResult.Blocks {
+			b.Close()
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if empty {
+
+		// Clients still get the query bounds when nothing matches
+		return n.processEmpty(queryBounds)
+	}
+
+	for _, b := range blockResult.Blocks {
+		if n.sanitizer != nil {
+			b = &sanitizedBlock{Block: b, sanitizer: n.sanitizer}
+		}
+
+		if blockResult.Source != "" {
+			b = block.NewSourceBlock(b, blockResult.Source)
+		}
+
+		switch {
+		case n.op.At != nil:
+			if b, err = n.pin(b, queryBounds); err != nil {
+				return err
+			}
+		case n.op.Offset != 0:
+			b = &offsetBlock{Block: b, offset: n.op.Offset}
+		}
+
+		if err := n.controller.Process(b); err != nil {
+			b.Close()
 			// Fail on first error
 			return err
 		}
 
-		block.Close()
+		b.Close()
 	}
 
 	return nil
 }
+
+// fetchBlocks fetches the blocks for the query. When collecting raw samples, the raw series are
+// recorded by the storage before they are consolidated into blocks
+func (n *FetchNode) fetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	if n.rawSamples != nil {
+		options.RawSeries = n.rawSamples.Add
+	}
+
+	return n.storage.FetchBlocks(ctx, query, options)
+}
+
+// processEmpty sends a block without any series for the bounds
+func (n *FetchNode) processEmpty(bounds block.Bounds) error {
+	builder, err := n.controller.BlockBuilder(block.Metadata{Bounds: bounds}, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(bounds.Steps()); err != nil {
+		return err
+	}
+
+	emptyBlock := builder.Build()
+	defer emptyBlock.Close()
+	return n.controller.Process(emptyBlock)
+}
+
+// hasNoSeries returns true if none of the blocks have any series
+func hasNoSeries(blocks []block.Block) (bool, error) {
+	for _, b := range blocks {
+		iter, err := b.SeriesIter()
+		if err != nil {
+			return false, err
+		}
+
+		count := iter.SeriesCount()
+		iter.Close()
+		if count > 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// paginate keeps the page of series selected by the series offset and limit. Storage does not
+// guarantee an order, so series are ordered by their tags to keep pages stable across requests
+func (o FetchOp) paginate(newBuilder block.BuilderFn, blocks []block.Block) ([]block.Block, error) {
+	if o.SeriesLimit <= 0 && o.SeriesOffset == 0 {
+		return blocks, nil
+	}
+
+	defer func() {
+		for _, b := range blocks {
+			b.Close()
+		}
+	}()
+
+	if o.SeriesOffset < 0 {
+		return nil, fmt.Errorf("series offset cannot be negative: %d", o.SeriesOffset)
+	}
+
+	// The same series can be in multiple blocks, e.g. when they cover different time ranges
+	unique := make(map[string]struct{})
+	for _, b := range blocks {
+		iter, err := b.StepIter()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, meta := range iter.SeriesMeta() {
+			unique[meta.Tags.ID()] = struct{}{}
+		}
+
+		iter.Close()
+	}
+
+	ids := make([]string, 0, len(unique))
+	for id := range unique {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	start := o.SeriesOffset
+	if start > len(ids) {
+		start = len(ids)
+	}
+
+	end := len(ids)
+	if o.SeriesLimit > 0 && start+o.SeriesLimit < end {
+		end = start + o.SeriesLimit
+	}
+
+	page := make(map[string]struct{}, end-start)
+	for _, id := range ids[start:end] {
+		page[id] = struct{}{}
+	}
+
+	paged := make([]block.Block, 0, len(blocks))
+	for _, b := range blocks {
+		pagedBlock, err := selectSeries(newBuilder, b, page)
+		if err != nil {
+			for _, pagedBlock := range paged {
+				pagedBlock.Close()
+			}
+
+			return nil, err
+		}
+
+		paged = append(paged, pagedBlock)
+	}
+
+	return paged, nil
+}
+
+// selectSeries copies the series in the page into a new block, ordered by their tags
+func selectSeries(newBuilder block.BuilderFn, b block.Block, page map[string]struct{}) (block.Block, error) {
+	iter, err := b.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+	metas := iter.SeriesMeta()
+	ids := make([]string, len(metas))
+	indices := make([]int, 0, len(page))
+	for i, meta := range metas {
+		ids[i] = meta.Tags.ID()
+		if _, ok := page[ids[i]]; ok {
+			indices = append(indices, i)
+		}
+	}
+
+	sort.Slice(indices, func(i, j int) bool { return ids[indices[i]] < ids[indices[j]] })
+	pagedMetas := make([]block.SeriesMeta, len(indices))
+	for i, idx := range indices {
+		pagedMetas[i] = metas[idx]
+	}
+
+	builder, err := newBuilder(iter.Meta(), pagedMetas)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := builder.AddCols(iter.StepCount()); err != nil {
+		return nil, err
+	}
+
+	for i := 0; iter.Next(); i++ {
+		step, err := iter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		values := step.Values()
+		for _, idx := range indices {
+			if err := builder.AppendValue(i, values[idx]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// rangeLookback rounds the range up to a multiple of the step to keep the fetched steps aligned
+func (o FetchOp) rangeLookback(step time.Duration) time.Duration {
+	if step <= 0 || o.Range%step == 0 {
+		return o.Range
+	}
+
+	return (o.Range/step + 1) * step
+}
+
+// offsetBlock shifts the steps of a block fetched for an offset selector forward by the offset,
+// onto the timeline of the query
+type offsetBlock struct {
+	block.Block
+	offset time.Duration
+}
+
+func (b *offsetBlock) StepIter() (block.StepIter, error) {
+	iter, err := b.Block.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &offsetStepIter{StepIter: iter, offset: b.offset}, nil
+}
+
+func (b *offsetBlock) SeriesIter() (block.SeriesIter, error) {
+	iter, err := b.Block.SeriesIter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &offsetSeriesIter{SeriesIter: iter, offset: b.offset}, nil
+}
+
+// SampleTime returns the sample times of the underlying block, if it has them, without shifting
+// them by the offset, since they are the times at which the samples were taken
+func (b *offsetBlock) SampleTime(series, step int) (time.Time, bool) {
+	sampled, ok := b.Block.(block.SampleTimesBlock)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return sampled.SampleTime(series, step)
+}
+
+func shiftMeta(meta block.Metadata, offset time.Duration) block.Metadata {
+	meta.Bounds.Start = meta.Bounds.Start.Add(offset)
+	meta.Bounds.End = meta.Bounds.End.Add(offset)
+	return meta
+}
+
+type offsetStepIter struct {
+	block.StepIter
+	offset time.Duration
+}
+
+func (i *offsetStepIter) Meta() block.Metadata {
+	return shiftMeta(i.StepIter.Meta(), i.offset)
+}
+
+func (i *offsetStepIter) Current() (block.Step, error) {
+	step, err := i.StepIter.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	return block.NewColStep(step.Time().Add(i.offset), step.Values()), nil
+}
+
+type offsetSeriesIter struct {
+	block.SeriesIter
+	offset time.Duration
+}
+
+func (i *offsetSeriesIter) Meta() block.Metadata {
+	return shiftMeta(i.SeriesIter.Meta(), i.offset)
+}
+
+// sanitizedBlock rewrites the tag values of the series of the block with the sanitizer
+type sanitizedBlock struct {
+	block.Block
+	sanitizer transform.TagSanitizer
+}
+
+func (b *sanitizedBlock) StepIter() (block.StepIter, error) {
+	iter, err := b.Block.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sanitizedStepIter{StepIter: iter, sanitizer: b.sanitizer}, nil
+}
+
+func (b *sanitizedBlock) SeriesIter() (block.SeriesIter, error) {
+	iter, err := b.Block.SeriesIter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sanitizedSeriesIter{SeriesIter: iter, sanitizer: b.sanitizer}, nil
+}
+
+// SampleTime returns the sample times of the underlying block, if it has them
+func (b *sanitizedBlock) SampleTime(series, step int) (time.Time, bool) {
+	sampled, ok := b.Block.(block.SampleTimesBlock)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return sampled.SampleTime(series, step)
+}
+
+type sanitizedStepIter struct {
+	block.StepIter
+	sanitizer transform.TagSanitizer
+}
+
+func (i *sanitizedStepIter) SeriesMeta() []block.SeriesMeta {
+	return sanitizeMetas(i.StepIter.SeriesMeta(), i.sanitizer)
+}
+
+type sanitizedSeriesIter struct {
+	block.SeriesIter
+	sanitizer transform.TagSanitizer
+}
+
+func (i *sanitizedSeriesIter) SeriesMeta() []block.SeriesMeta {
+	return sanitizeMetas(i.SeriesIter.SeriesMeta(), i.sanitizer)
+}
+
+func (i *sanitizedSeriesIter) Current() (block.Series, error) {
+	series, err := i.SeriesIter.Current()
+	if err != nil {
+		return block.Series{}, err
+	}
+
+	series.Meta.Tags = sanitizeTags(series.Meta.Tags, i.sanitizer)
+	return series, nil
+}
+
+func sanitizeMetas(metas []block.SeriesMeta, sanitizer transform.TagSanitizer) []block.SeriesMeta {
+	updated := make([]block.SeriesMeta, len(metas))
+	for i, meta := range metas {
+		meta.Tags = sanitizeTags(meta.Tags, sanitizer)
+		updated[i] = meta
+	}
+
+	return updated
+}
+
+func sanitizeTags(tags models.Tags, sanitizer transform.TagSanitizer) models.Tags {
+	sanitized := make(models.Tags, len(tags))
+	for name, value := range tags {
+		sanitized[name] = sanitizer(name, value)
+	}
+
+	return sanitized
+}
@@ -22,14 +22,23 @@ package functions
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/temporal"
+	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/storage"
 	"github.com/m3db/m3/src/query/storage/mock"
 	"github.com/m3db/m3/src/query/test"
 	"github.com/m3db/m3/src/query/test/executor"
+	"github.com/m3db/m3/src/query/test/fixtures"
+	"github.com/m3db/m3/src/query/ts"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -48,3 +57,292 @@ func TestFetch(t *testing.T) {
 	assert.Len(t, sink.Values, 2)
 	assert.Equal(t, expected, sink.Values)
 }
+
+func TestFetchWithSource(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValues(bounds, values)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	mockStorage := mock.NewMockStorage()
+	mockStorage.SetFetchBlocksResult(block.Result{Blocks: []block.Block{b}, Source: "zone-a"}, nil)
+	source := (&FetchOp{}).Node(c, mockStorage, transform.Options{})
+	err := source.Execute(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, values, sink.Values)
+	require.Len(t, sink.Metas, 2)
+	for _, meta := range sink.Metas {
+		assert.Equal(t, "zone-a", meta.Source)
+	}
+}
+
+func TestFetchWithRawSamplesKeepsBlockResult(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValues(bounds, values)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	mockStorage := mock.NewMockStorage()
+	mockStorage.SetFetchBlocksResult(block.Result{
+		Blocks:   []block.Block{b},
+		Source:   "zone-a",
+		Warnings: []string{"partial results"},
+	}, nil)
+
+	warnings := transform.NewWarnings()
+	c.Options.Warnings = warnings
+	source := (&FetchOp{}).Node(c, mockStorage, transform.Options{RawSamples: transform.NewRawSamples(0)})
+	require.NoError(t, source.Execute(context.TODO()))
+	assert.Equal(t, values, sink.Values)
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, "zone-a", sink.Metas[0].Source)
+	assert.Equal(t, []string{"partial results"}, warnings.Drain())
+}
+
+func TestFetchRangeLookback(t *testing.T) {
+	op := FetchOp{Range: 5 * time.Minute}
+	assert.Equal(t, 5*time.Minute, op.rangeLookback(time.Minute))
+	assert.Equal(t, 6*time.Minute, op.rangeLookback(2*time.Minute))
+	assert.Equal(t, 5*time.Minute, op.rangeLookback(0))
+}
+
+// queryBoundsStorage returns a block with the bounds of the query
+type queryBoundsStorage struct {
+	mock.Storage
+}
+
+func (s *queryBoundsStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	bounds := block.Bounds{Start: query.Start, End: query.End, StepSize: query.Interval}
+	values := make([]float64, bounds.Steps())
+	return block.Result{Blocks: []block.Block{test.NewBlockFromValues(bounds, [][]float64{values})}}, nil
+}
+
+func fetchStepTimes(t *testing.T, start time.Time, alignSteps bool) map[time.Time]bool {
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	source := (&FetchOp{}).Node(c, &queryBoundsStorage{Storage: mock.NewMockStorage()}, transform.Options{
+		TimeSpec: transform.TimeSpec{
+			Start: start,
+			End:   start.Add(10 * time.Minute),
+			Step:  time.Minute,
+		},
+		AlignStepsToEpoch: alignSteps,
+	})
+	require.NoError(t, source.Execute(context.TODO()))
+
+	times := make(map[time.Time]bool)
+	bounds := sink.Meta.Bounds
+	for i := 0; i < bounds.Steps(); i++ {
+		stepTime, err := bounds.TimeForIndex(i)
+		require.NoError(t, err)
+		times[stepTime.UTC()] = true
+	}
+
+	return times
+}
+
+// squareStorage returns a series whose value at each step is the square of its unix time,
+// so that the rate of the series depends on when it is sampled
+type squareStorage struct {
+	mock.Storage
+}
+
+func (s *squareStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	bounds := block.Bounds{Start: query.Start, End: query.End, StepSize: query.Interval}
+	values := make([]float64, bounds.Steps())
+	for i := range values {
+		seconds := float64(bounds.TimeForStep(i).Unix())
+		values[i] = seconds * seconds
+	}
+
+	return block.Result{Blocks: []block.Block{test.NewBlockFromValues(bounds, [][]float64{values})}}, nil
+}
+
+func TestFetchRangeWithOffset(t *testing.T) {
+	start := time.Unix(7200, 0)
+	timeSpec := transform.TimeSpec{Start: start, End: start.Add(10 * time.Minute), Step: time.Minute}
+	rate, err := temporal.NewRateOp([]interface{}{5 * time.Minute}, temporal.RateType,
+		temporal.CounterOptions{DisableExtrapolation: true})
+	require.NoError(t, err)
+
+	rateController, sink := executor.NewControllerWithSink(parser.NodeID(2))
+	c := &transform.Controller{ID: parser.NodeID(1)}
+	c.AddTransform(rate.Node(rateController))
+	op := &FetchOp{Range: 5 * time.Minute, Offset: time.Hour}
+	source := op.Node(c, &squareStorage{Storage: mock.NewMockStorage()}, transform.Options{TimeSpec: timeSpec})
+	require.NoError(t, source.Execute(context.TODO()))
+
+	assert.Equal(t, timeSpec.Start, sink.Meta.Bounds.Start, "steps are on the query timeline")
+	assert.Equal(t, timeSpec.End, sink.Meta.Bounds.End)
+	require.Len(t, sink.Values, 1)
+	require.Len(t, sink.Values[0], 11)
+	for i, value := range sink.Values[0] {
+		// The window ends an hour before the step, and its first sample is 4m after its start
+		end := float64(timeSpec.Start.Add(time.Duration(i)*time.Minute - time.Hour).Unix())
+		first := end - 240
+		assert.InDelta(t, (end*end-first*first)/240, value, 1e-6, "step %d", i)
+	}
+}
+
+func TestFetchAlignStepsToEpoch(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+	first := fetchStepTimes(t, base.Add(17*time.Second), true)
+	second := fetchStepTimes(t, base.Add(3*time.Minute+43*time.Second), true)
+
+	shared := 0
+	for stepTime := range second {
+		assert.Equal(t, int64(0), stepTime.UnixNano()%int64(time.Minute), "steps should be aligned to the epoch")
+		if first[stepTime] {
+			shared++
+		}
+	}
+
+	assert.Equal(t, 8, shared, "overlapping steps should have identical timestamps")
+
+	first = fetchStepTimes(t, base.Add(17*time.Second), false)
+	second = fetchStepTimes(t, base.Add(3*time.Minute+43*time.Second), false)
+	for stepTime := range second {
+		assert.False(t, first[stepTime], "unaligned steps should not line up")
+	}
+}
+
+func TestFetchWithNoData(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	timeSpec := transform.TimeSpec{
+		Start: start,
+		End:   start.Add(10 * time.Minute),
+		Step:  time.Minute,
+	}
+
+	mockStorage := mock.NewMockStorage()
+	mockStorage.SetFetchBlocksResult(block.Result{Blocks: []block.Block{test.NewBlockFromValues(block.Bounds{}, nil)}}, nil)
+	for _, op := range []FetchOp{{}, {Range: 5 * time.Minute}} {
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		source := op.Node(c, mockStorage, transform.Options{TimeSpec: timeSpec})
+		require.NoError(t, source.Execute(context.TODO()))
+		assert.Empty(t, sink.Values)
+		assert.Equal(t, block.Bounds{Start: start, End: timeSpec.End, StepSize: time.Minute}, sink.Meta.Bounds,
+			"the bounds do not include the range")
+	}
+}
+
+func TestFetchSeriesPagination(t *testing.T) {
+	// Series are returned out of order, and their values identify them
+	order := []int{7, 2, 9, 0, 5, 3, 8, 1, 6, 4}
+	metas := make([]block.SeriesMeta, 0, len(order))
+	values := make([][]float64, 0, len(order))
+	for _, i := range order {
+		metas = append(metas, block.SeriesMeta{Tags: models.Tags{"id": fmt.Sprintf("series_%d", i)}})
+		values = append(values, []float64{float64(i), float64(i)})
+	}
+
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	mockStorage := mock.NewMockStorage()
+	mockStorage.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)},
+	}, nil)
+
+	var seen []float64
+	for offset, pageSize := range map[int]int{0: 3, 3: 3, 6: 3, 9: 1, 12: 0} {
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		source := (&FetchOp{SeriesLimit: 3, SeriesOffset: offset}).Node(c, mockStorage, transform.Options{})
+		require.NoError(t, source.Execute(context.TODO()))
+		require.Len(t, sink.Values, pageSize, "offset: %d", offset)
+		for i, series := range sink.Values {
+			assert.Equal(t, float64(offset+i), series[0], "pages are ordered by tags")
+			assert.Equal(t, fmt.Sprintf("series_%d", offset+i), sink.Metas[i].Tags["id"])
+			seen = append(seen, series[0])
+		}
+	}
+
+	assert.Len(t, seen, len(order), "every series is in exactly one page")
+}
+
+func TestFetchSeriesPaginationWithNegativeOffset(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	mockStorage := mock.NewMockStorage()
+	mockStorage.SetFetchBlocksResult(block.Result{Blocks: []block.Block{test.NewBlockFromValues(bounds, values)}}, nil)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(1))
+	source := (&FetchOp{SeriesOffset: -1}).Node(c, mockStorage, transform.Options{})
+	assert.Error(t, source.Execute(context.TODO()))
+}
+
+// closeTrackingBlock records whether the block was closed
+type closeTrackingBlock struct {
+	block.Block
+	closed bool
+}
+
+func (b *closeTrackingBlock) Close() error {
+	b.closed = true
+	return b.Block.Close()
+}
+
+// trackingBuilder records the blocks it builds
+type trackingBuilder struct {
+	block.Builder
+	built *[]*closeTrackingBlock
+}
+
+func (b trackingBuilder) Build() block.Block {
+	built := &closeTrackingBlock{Block: b.Builder.Build()}
+	*b.built = append(*b.built, built)
+	return built
+}
+
+func TestFetchSeriesPaginationClosesPagedBlocksOnError(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	blocks := []block.Block{test.NewBlockFromValues(bounds, values), test.NewBlockFromValues(bounds, values)}
+
+	// The builder fails once a block was built, e.g. as the blocks of the query exceed their limit
+	var built []*closeTrackingBlock
+	newBuilder := func(meta block.Metadata, seriesMeta []block.SeriesMeta) (block.Builder, error) {
+		if len(built) > 0 {
+			return nil, errors.New("limit exceeded")
+		}
+
+		builder, err := block.UnlimitedBuilder(meta, seriesMeta)
+		if err != nil {
+			return nil, err
+		}
+
+		return trackingBuilder{Builder: builder, built: &built}, nil
+	}
+
+	_, err := FetchOp{SeriesLimit: 1}.paginate(newBuilder, blocks)
+	require.Error(t, err)
+	require.Len(t, built, 1)
+	assert.True(t, built[0].closed, "the blocks paged before the error are closed")
+}
+
+func TestFetchWithLookbackOverride(t *testing.T) {
+	start := time.Unix(7200, 0)
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags: models.Tags{models.MetricName: "sparse"},
+		Datapoints: ts.Datapoints{
+			{Timestamp: start, Value: 1},
+			{Timestamp: start.Add(8 * time.Minute), Value: 2},
+		},
+	})
+
+	options := transform.Options{
+		TimeSpec:      transform.TimeSpec{Start: start, End: start.Add(8 * time.Minute), Step: time.Minute},
+		Consolidation: ts.ConsolidationOptions{LookbackDuration: 5 * time.Minute},
+	}
+
+	fetch := func(lookback *time.Duration) []float64 {
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		op := FetchOp{
+			Matchers: models.Matchers{{Type: models.MatchEqual, Name: models.MetricName, Value: "sparse"}},
+			Lookback: lookback,
+		}
+		require.NoError(t, op.Node(c, store, options).Execute(context.TODO()))
+		require.Len(t, sink.Values, 1)
+		return sink.Values[0]
+	}
+
+	values := fetch(nil)
+	require.True(t, len(values) > 7)
+	assert.True(t, math.IsNaN(values[7]), "the sample is not carried forward beyond the query lookback")
+
+	override := 10 * time.Minute
+	values = fetch(&override)
+	assert.Equal(t, 1.0, values[7], "the sample is carried forward within the selector lookback")
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package quantile computes φ-quantiles for the quantile functions, so that they share the
+// same behavior for φ at and outside of the [0, 1] bounds.
+package quantile
+
+import (
+	"math"
+	"sort"
+
+	"github.com/m3db/m3/src/query/functions/utils"
+)
+
+// Bucket is a single cumulative classic histogram bucket
+type Bucket struct {
+	UpperBound float64
+	Count      float64
+}
+
+// edge returns the quantile for a φ which does not need to be interpolated
+func edge(phi float64) (float64, bool) {
+	switch {
+	case math.IsNaN(phi):
+		return math.NaN(), true
+	case phi < 0:
+		return math.Inf(-1), true
+	case phi > 1:
+		return math.Inf(+1), true
+	default:
+		return 0, false
+	}
+}
+
+// Interpolate returns the φ-quantile of the sorted values, which must not contain NaNs.
+// A φ below 0 returns -Inf, above 1 returns +Inf and a NaN φ or no values return NaN
+func Interpolate(sorted []float64, phi float64, method utils.InterpolationMethod) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+
+	if v, ok := edge(phi); ok {
+		return v
+	}
+
+	n := float64(len(sorted))
+	rank := phi * (n - 1)
+	lowerIndex := math.Max(0, math.Floor(rank))
+	upperIndex := math.Min(n-1, math.Ceil(rank))
+
+	switch method {
+	case utils.LowerInterpolation:
+		return sorted[int(lowerIndex)]
+	case utils.HigherInterpolation:
+		return sorted[int(upperIndex)]
+	case utils.NearestInterpolation:
+		return sorted[int(math.Min(n-1, math.Floor(rank+0.5)))]
+	default:
+		weight := rank - math.Floor(rank)
+		return sorted[int(lowerIndex)]*(1-weight) + sorted[int(upperIndex)]*weight
+	}
+}
+
+// BucketQuantile estimates the φ-quantile of a classic histogram from its buckets, which must be
+// sorted by upper bound with monotonic counts and end with the +Inf bucket. Observations are
+// assumed to be uniformly distributed within each bucket, as Prometheus does. The edges behave
+// as for Interpolate, and histograms without a +Inf bucket or observations return NaN
+func BucketQuantile(buckets []Bucket, phi float64) float64 {
+	if len(buckets) < 2 || !math.IsInf(buckets[len(buckets)-1].UpperBound, 1) {
+		return math.NaN()
+	}
+
+	if v, ok := edge(phi); ok {
+		return v
+	}
+
+	observations := buckets[len(buckets)-1].Count
+	if observations == 0 {
+		return math.NaN()
+	}
+
+	rank := phi * observations
+	b := sort.Search(len(buckets)-1, func(i int) bool { return buckets[i].Count >= rank })
+	if b == len(buckets)-1 {
+		// The quantile falls in the +Inf bucket, so the best estimate is the highest finite bound
+		return buckets[len(buckets)-2].UpperBound
+	}
+
+	if b == 0 && buckets[0].UpperBound <= 0 {
+		return buckets[0].UpperBound
+	}
+
+	var (
+		bucketStart float64
+		bucketEnd   = buckets[b].UpperBound
+		count       = buckets[b].Count
+	)
+
+	if b > 0 {
+		bucketStart = buckets[b-1].UpperBound
+		count -= buckets[b-1].Count
+		rank -= buckets[b-1].Count
+	}
+
+	return bucketStart + (bucketEnd-bucketStart)*(rank/count)
+}
+
+// Sample is a value with the weight it contributes to a weighted quantile
+type Sample struct {
+	Value  float64
+	Weight float64
+}
+
+// Weighted returns the weighted φ-quantile of the samples, the smallest value whose cumulative
+// weight reaches φ of the total weight. The samples are sorted in place and must not contain NaNs.
+// Samples without a positive weight are ignored, and the edges behave as for Interpolate
+func Weighted(samples []Sample, phi float64) float64 {
+	var total float64
+	for _, s := range samples {
+		if s.Weight > 0 {
+			total += s.Weight
+		}
+	}
+
+	if total == 0 {
+		return math.NaN()
+	}
+
+	if v, ok := edge(phi); ok {
+		return v
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Value < samples[j].Value })
+	var (
+		target     = phi * total
+		cumulative float64
+		last       float64
+	)
+
+	for _, s := range samples {
+		if s.Weight <= 0 {
+			continue
+		}
+
+		cumulative += s.Weight
+		last = s.Value
+		if cumulative >= target {
+			return s.Value
+		}
+	}
+
+	// Rounding can leave the cumulative weight just short of the target for φ of 1
+	return last
+}
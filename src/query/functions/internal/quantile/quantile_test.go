@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package quantile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/functions/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var methods = []utils.InterpolationMethod{
+	utils.LinearInterpolation,
+	utils.LowerInterpolation,
+	utils.HigherInterpolation,
+	utils.NearestInterpolation,
+}
+
+func TestInterpolateMethods(t *testing.T) {
+	values := []float64{1, 2, 3, 4}
+	tests := []struct {
+		method   utils.InterpolationMethod
+		phi      float64
+		expected float64
+	}{
+		{method: utils.LinearInterpolation, phi: 0.4, expected: 2.2},
+		{method: utils.LowerInterpolation, phi: 0.4, expected: 2},
+		{method: utils.HigherInterpolation, phi: 0.4, expected: 3},
+		{method: utils.NearestInterpolation, phi: 0.4, expected: 2},
+		{method: utils.LinearInterpolation, phi: 0.6, expected: 2.8},
+		{method: utils.LowerInterpolation, phi: 0.6, expected: 2},
+		{method: utils.HigherInterpolation, phi: 0.6, expected: 3},
+		{method: utils.NearestInterpolation, phi: 0.6, expected: 3},
+	}
+
+	for _, tt := range tests {
+		assert.InDelta(t, tt.expected, Interpolate(values, tt.phi, tt.method), 1e-9, "method: %d, phi: %v", tt.method, tt.phi)
+	}
+}
+
+func TestInterpolateEdges(t *testing.T) {
+	values := []float64{1, 2, 3, 4}
+	for _, method := range methods {
+		assert.Equal(t, 1.0, Interpolate(values, 0, method))
+		assert.Equal(t, 4.0, Interpolate(values, 1, method))
+		assert.Equal(t, 5.0, Interpolate([]float64{5}, 0.5, method))
+		assert.True(t, math.IsInf(Interpolate(values, -1, method), -1))
+		assert.True(t, math.IsInf(Interpolate(values, 2, method), 1))
+		assert.True(t, math.IsNaN(Interpolate(nil, 0.5, method)))
+		assert.True(t, math.IsNaN(Interpolate(values, math.NaN(), method)))
+	}
+}
+
+func TestBucketQuantile(t *testing.T) {
+	buckets := []Bucket{
+		{UpperBound: 1, Count: 10},
+		{UpperBound: 2, Count: 30},
+		{UpperBound: 4, Count: 40},
+		{UpperBound: math.Inf(1), Count: 40},
+	}
+
+	tests := []struct {
+		phi      float64
+		expected float64
+	}{
+		{phi: 0, expected: 0},
+		{phi: 0.125, expected: 0.5},
+		{phi: 0.25, expected: 1},
+		{phi: 0.5, expected: 1.5},
+		{phi: 0.875, expected: 3},
+		{phi: 1, expected: 4},
+	}
+
+	for _, tt := range tests {
+		assert.InDelta(t, tt.expected, BucketQuantile(buckets, tt.phi), 1e-9, "phi: %v", tt.phi)
+	}
+}
+
+func TestBucketQuantileEdges(t *testing.T) {
+	buckets := []Bucket{
+		{UpperBound: 1, Count: 10},
+		{UpperBound: math.Inf(1), Count: 20},
+	}
+
+	// Quantiles in the +Inf bucket return the highest finite bound
+	assert.Equal(t, 1.0, BucketQuantile(buckets, 0.9))
+	assert.True(t, math.IsInf(BucketQuantile(buckets, -1), -1))
+	assert.True(t, math.IsInf(BucketQuantile(buckets, 2), 1))
+	assert.True(t, math.IsNaN(BucketQuantile(buckets, math.NaN())))
+	assert.True(t, math.IsNaN(BucketQuantile(buckets[:1], 0.5)), "no +Inf bucket")
+	assert.True(t, math.IsNaN(BucketQuantile(nil, 0.5)))
+
+	empty := []Bucket{{UpperBound: 1}, {UpperBound: math.Inf(1)}}
+	assert.True(t, math.IsNaN(BucketQuantile(empty, 0.5)), "no observations")
+
+	negative := []Bucket{{UpperBound: -1, Count: 10}, {UpperBound: math.Inf(1), Count: 10}}
+	assert.Equal(t, -1.0, BucketQuantile(negative, 0.5))
+}
+
+func TestWeighted(t *testing.T) {
+	// 1 is weighted 1, 2 is weighted 6 and 3 is weighted 3, out of a total of 10
+	samples := func() []Sample {
+		return []Sample{{Value: 3, Weight: 3}, {Value: 1, Weight: 1}, {Value: 2, Weight: 6}, {Value: 4, Weight: 0}}
+	}
+
+	tests := []struct {
+		phi      float64
+		expected float64
+	}{
+		{phi: 0, expected: 1},
+		{phi: 0.1, expected: 1},
+		{phi: 0.5, expected: 2},
+		{phi: 0.7, expected: 2},
+		{phi: 0.71, expected: 3},
+		{phi: 1, expected: 3},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Weighted(samples(), tt.phi), "phi: %v", tt.phi)
+	}
+
+	assert.True(t, math.IsInf(Weighted(samples(), -1), -1))
+	assert.True(t, math.IsInf(Weighted(samples(), 2), 1))
+	assert.True(t, math.IsNaN(Weighted(samples(), math.NaN())))
+	assert.True(t, math.IsNaN(Weighted([]Sample{{Value: 1}}, 0.5)), "no weight")
+	assert.True(t, math.IsNaN(Weighted(nil, 0.5)))
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
 	"github.com/m3db/m3/src/query/parser"
 )
 
@@ -34,6 +35,14 @@ var emptyOp = BaseOp{}
 type BaseOp struct {
 	operatorType string
 	processorFn  makeProcessor
+	// args are the scalar arguments following the series argument
+	args []interface{}
+	// KeepMetricNames preserves the metric name, which is otherwise dropped from the output
+	KeepMetricNames bool
+	// elementWise ops apply a pure function to each value independently, so they can be fused
+	elementWise bool
+	// fused are the ops applied, in order, before this op in the same pass
+	fused []BaseOp
 }
 
 // OpType for the operator
@@ -46,13 +55,38 @@ func (o BaseOp) String() string {
 	return fmt.Sprintf("type: %s", o.OpType())
 }
 
+// FormatExpr renders the function call on its input, nesting the calls of any fused ops
+func (o BaseOp) FormatExpr(inputs []string) string {
+	if len(o.fused) > 0 && len(inputs) == 1 {
+		expr := inputs[0]
+		for _, op := range o.chain() {
+			expr = op.FormatExpr([]string{expr})
+		}
+
+		return expr
+	}
+
+	args := append([]string{}, inputs...)
+	for _, arg := range o.args {
+		args = append(args, parser.FormatLiteral(arg))
+	}
+
+	return parser.FormatKeepMetricNames(parser.FormatFunction(o.operatorType, args...), o.KeepMetricNames)
+}
+
+// WithKeepMetricNames returns the op keeping the metric names of its input
+func (o BaseOp) WithKeepMetricNames() parser.Params {
+	o.KeepMetricNames = true
+	return o
+}
+
 // Node creates an execution node
 func (o BaseOp) Node(controller *transform.Controller) transform.OpNode {
 	return &baseNode{
 		controller: controller,
 		cache:      transform.NewBlockCache(),
 		op:         o,
-		processor:  o.processorFn(o, controller),
+		processor:  o.processor(controller),
 	}
 }
 
@@ -67,16 +101,18 @@ type baseNode struct {
 var _ transform.StepNode = (*baseNode)(nil)
 var _ transform.SeriesNode = (*baseNode)(nil)
 
-// ProcessStep allows step iteration
+// ProcessStep allows step iteration. Processors work in place, and steps share their values with
+// the block, so the values are copied to keep the block the same for its other iterations
 func (c *baseNode) ProcessStep(step block.Step) (block.Step, error) {
-	processedValue := c.processor.Process(step.Values())
-	return block.NewColStep(step.Time(), processedValue), nil
+	values := make([]float64, len(step.Values()))
+	copy(values, step.Values())
+	return block.NewColStep(step.Time(), c.processor.Process(values)), nil
 }
 
 // ProcessSeries allows series iteration
 func (c *baseNode) ProcessSeries(series block.Series) (block.Series, error) {
 	processedValue := c.processor.Process(series.Values())
-	return block.NewSeries(processedValue, series.Meta), nil
+	return block.NewSeries(processedValue, c.seriesMeta(series.Meta)), nil
 }
 
 // Process the block
@@ -86,7 +122,7 @@ func (c *baseNode) Process(ID parser.NodeID, b block.Block) error {
 		return err
 	}
 
-	builder, err := c.controller.BlockBuilder(stepIter.Meta(), stepIter.SeriesMeta())
+	builder, err := c.controller.BlockBuilder(stepIter.Meta(), c.SeriesMeta(stepIter.SeriesMeta()))
 	if err != nil {
 		return err
 	}
@@ -95,15 +131,20 @@ func (c *baseNode) Process(ID parser.NodeID, b block.Block) error {
 		return err
 	}
 
+	// The input block may be iterated again by other nodes, so its steps are processed in a copy
+	var values []float64
 	for index := 0; stepIter.Next(); index++ {
 		step, err := stepIter.Current()
 		if err != nil {
 			return err
 		}
 
-		values := c.processor.Process(step.Values())
+		values = append(values[:0], step.Values()...)
+		values = c.processor.Process(values)
 		for _, value := range values {
-			builder.AppendValue(index, value)
+			if err := builder.AppendValue(index, value); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -119,7 +160,19 @@ func (c *baseNode) Meta(meta block.Metadata) block.Metadata {
 
 // SeriesMeta returns the metadata for each series in the block
 func (c *baseNode) SeriesMeta(metas []block.SeriesMeta) []block.SeriesMeta {
-	return metas
+	if c.op.keepMetricNames() {
+		return metas
+	}
+
+	return utils.DropMetricNames(metas)
+}
+
+func (c *baseNode) seriesMeta(meta block.SeriesMeta) block.SeriesMeta {
+	if c.op.keepMetricNames() {
+		return meta
+	}
+
+	return utils.DropMetricName(meta)
 }
 
 // makeProcessor is a way to create a transform
@@ -63,6 +63,8 @@ func NewClampOp(args []interface{}, optype string) (BaseOp, error) {
 	return BaseOp{
 		operatorType: optype,
 		processorFn:  makeClampProcessor(spec),
+		args:         args,
+		elementWise:  true,
 	}, nil
 }
 
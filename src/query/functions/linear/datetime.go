@@ -80,6 +80,7 @@ func NewDateOp(optype string) (BaseOp, error) {
 	return BaseOp{
 		operatorType: optype,
 		processorFn:  newDateNode,
+		elementWise:  true,
 	}, nil
 }
 
@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package linear
+
+import (
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// Fuse returns an op which applies the input op and then this op in a single pass over each
+// block, and false if either op is not element-wise, e.g. absent which depends on all values
+func (o BaseOp) Fuse(input parser.Params) (parser.Params, bool) {
+	in, ok := input.(BaseOp)
+	if !ok || !o.elementWise || !in.elementWise {
+		return nil, false
+	}
+
+	chain := append(in.chain(), o.chain()...)
+	fused := chain[len(chain)-1]
+	fused.fused = chain[:len(chain)-1]
+	return fused, true
+}
+
+// processor creates the processor of the op, applying any fused ops first
+func (o BaseOp) processor(controller *transform.Controller) Processor {
+	if len(o.fused) == 0 {
+		return o.processorFn(o, controller)
+	}
+
+	chain := o.chain()
+	processors := make(fusedProcessor, len(chain))
+	for i, op := range chain {
+		processors[i] = op.processorFn(op, controller)
+	}
+
+	return processors
+}
+
+// chain returns the ops applied by this op in order, without any fusing
+func (o BaseOp) chain() []BaseOp {
+	last := o
+	last.fused = nil
+	return append(append([]BaseOp{}, o.fused...), last)
+}
+
+// keepMetricNames returns true if the metric name survives every op applied
+func (o BaseOp) keepMetricNames() bool {
+	for _, op := range o.fused {
+		if !op.KeepMetricNames {
+			return false
+		}
+	}
+
+	return o.KeepMetricNames
+}
+
+// fusedProcessor applies each processor in turn to the same values
+type fusedProcessor []Processor
+
+func (f fusedProcessor) Process(values []float64) []float64 {
+	for _, processor := range f {
+		values = processor.Process(values)
+	}
+
+	return values
+}
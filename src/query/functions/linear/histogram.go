@@ -0,0 +1,239 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package linear
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/internal/quantile"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// BucketTag is the tag holding the upper bound of a classic histogram bucket
+const BucketTag = "le"
+
+// buckets are the cumulative histogram buckets at a step, sorted by upper bound
+type buckets []quantile.Bucket
+
+// indexedBucket is the upper bound of the bucket held by the series at idx
+type indexedBucket struct {
+	upperBound float64
+	idx        int
+}
+
+// histogramFn computes the value for a single histogram at a step from its sorted, monotonic buckets
+type histogramFn func(buckets buckets) float64
+
+// histogramOp stores required properties for ops on classic histograms
+type histogramOp struct {
+	opType string
+	fn     histogramFn
+	// args are the scalar arguments preceding the series argument
+	args []interface{}
+	// argWarning is raised when the node runs if the arguments are valid but suspicious
+	argWarning string
+}
+
+// OpType for the operator
+func (o histogramOp) OpType() string {
+	return o.opType
+}
+
+// String representation
+func (o histogramOp) String() string {
+	return fmt.Sprintf("type: %s", o.OpType())
+}
+
+// FormatExpr renders the function call on its input
+func (o histogramOp) FormatExpr(inputs []string) string {
+	args := make([]string, 0, len(o.args)+len(inputs))
+	for _, arg := range o.args {
+		args = append(args, parser.FormatLiteral(arg))
+	}
+
+	return parser.FormatFunction(o.opType, append(args, inputs...)...)
+}
+
+// Node creates an execution node
+func (o histogramOp) Node(controller *transform.Controller) transform.OpNode {
+	return &histogramNode{
+		op:         o,
+		controller: controller,
+	}
+}
+
+type histogramNode struct {
+	op         histogramOp
+	controller *transform.Controller
+}
+
+// Process the block
+func (n *histogramNode) Process(ID parser.NodeID, b block.Block) error {
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	if n.op.argWarning != "" {
+		n.controller.Options.Warnings.Add(n.op.argWarning)
+	}
+
+	indexedBuckets, metas := gatherSeriesToBuckets(stepIter.SeriesMeta(), n.op.opType)
+	builder, err := n.controller.BlockBuilder(stepIter.Meta(), metas)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(stepIter.StepCount()); err != nil {
+		return err
+	}
+
+	nonMonotonic := false
+	for index := 0; stepIter.Next(); index++ {
+		step, err := stepIter.Current()
+		if err != nil {
+			return err
+		}
+
+		values := step.Values()
+		for _, group := range indexedBuckets {
+			stepBuckets, ok := bucketsAtStep(group, values)
+			if !ok {
+				// Interpolating without a bucket, e.g. one gone stale, would misplace its observations
+				if err := builder.AppendValue(index, math.NaN()); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if ensureMonotonic(stepBuckets) {
+				nonMonotonic = true
+			}
+
+			if err := builder.AppendValue(index, n.op.fn(stepBuckets)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if nonMonotonic {
+		n.controller.Options.Warnings.Add(fmt.Sprintf(
+			"%s found bucket counts which decrease as le increases, counts were raised to be monotonic",
+			n.op.opType))
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
+
+// bucketsAtStep returns the buckets of the group with their counts at the step, and false if
+// the count of any bucket is NaN at the step
+func bucketsAtStep(group []indexedBucket, values []float64) (buckets, bool) {
+	stepBuckets := make(buckets, 0, len(group))
+	for _, b := range group {
+		v := values[b.idx]
+		if math.IsNaN(v) {
+			return nil, false
+		}
+
+		stepBuckets = append(stepBuckets, quantile.Bucket{UpperBound: b.upperBound, Count: v})
+	}
+
+	return stepBuckets, true
+}
+
+// gatherSeriesToBuckets groups series by all tags except the bucket tag, sorting each group by
+// its bucket upper bounds. Series without a valid bucket tag are dropped
+func gatherSeriesToBuckets(metas []block.SeriesMeta, opName string) ([][]indexedBucket, []block.SeriesMeta) {
+	groups, groupMetas := utils.GroupSeries([]string{BucketTag}, true, opName, metas)
+	indexedBuckets := make([][]indexedBucket, 0, len(groups))
+	validMetas := make([]block.SeriesMeta, 0, len(groupMetas))
+	for i, group := range groups {
+		grouped := make([]indexedBucket, 0, len(group))
+		for _, idx := range group {
+			le, ok := metas[idx].Tags[BucketTag]
+			if !ok {
+				continue
+			}
+
+			upperBound, err := strconv.ParseFloat(le, 64)
+			if err != nil {
+				continue
+			}
+
+			grouped = append(grouped, indexedBucket{upperBound: upperBound, idx: idx})
+		}
+
+		if len(grouped) == 0 {
+			continue
+		}
+
+		sort.Slice(grouped, func(i, j int) bool { return grouped[i].upperBound < grouped[j].upperBound })
+		indexedBuckets = append(indexedBuckets, grouped)
+		validMetas = append(validMetas, groupMetas[i])
+	}
+
+	return indexedBuckets, validMetas
+}
+
+// ensureMonotonic raises bucket counts which are lower than a preceding bucket, as can happen
+// when buckets are scraped at slightly different times, and returns true if any count was raised
+func ensureMonotonic(b buckets) bool {
+	raised := false
+	max := math.Inf(-1)
+	for i := range b {
+		if b[i].Count >= max {
+			max = b[i].Count
+		} else {
+			b[i].Count = max
+			raised = true
+		}
+	}
+
+	return raised
+}
+
+// bucketLowerBound returns the lower bound of a bucket. The first bucket is assumed to start
+// at zero if its upper bound is positive, as observations are usually non negative
+func bucketLowerBound(b buckets, i int) float64 {
+	if i > 0 {
+		return b[i-1].UpperBound
+	}
+
+	if b[0].UpperBound > 0 {
+		return 0
+	}
+
+	return math.Inf(-1)
+}
+
+// hasInfBucket returns true if the highest bucket is the +Inf bucket holding the total count
+func hasInfBucket(b buckets) bool {
+	return len(b) > 0 && math.IsInf(b[len(b)-1].UpperBound, 1)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package linear
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+)
+
+// HistogramFractionType estimates the fraction of observations of a classic histogram
+// which fall between a lower and an upper value
+const HistogramFractionType = "histogram_fraction"
+
+// NewHistogramFractionOp creates a new histogram_fraction op based on the arguments
+func NewHistogramFractionOp(args []interface{}) (transform.Params, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("invalid number of args for histogram_fraction: %d", len(args))
+	}
+
+	lower, ok := args[0].(float64)
+	if !ok {
+		return nil, fmt.Errorf("unable to cast to scalar argument: %v", args[0])
+	}
+
+	upper, ok := args[1].(float64)
+	if !ok {
+		return nil, fmt.Errorf("unable to cast to scalar argument: %v", args[1])
+	}
+
+	return histogramOp{
+		opType: HistogramFractionType,
+		args:   args,
+		fn: func(b buckets) float64 {
+			return bucketFraction(lower, upper, b)
+		},
+	}, nil
+}
+
+// bucketFraction estimates the fraction of observations between lower and upper, assuming
+// observations are uniformly distributed within each bucket as histogram_quantile does
+func bucketFraction(lower, upper float64, b buckets) float64 {
+	if !hasInfBucket(b) || math.IsNaN(lower) || math.IsNaN(upper) {
+		return math.NaN()
+	}
+
+	count := b[len(b)-1].Count
+	if count == 0 {
+		return math.NaN()
+	}
+
+	if lower >= upper {
+		return 0
+	}
+
+	fraction := (bucketRank(upper, b) - bucketRank(lower, b)) / count
+	return math.Max(0, math.Min(1, fraction))
+}
+
+// bucketRank estimates the number of observations less than or equal to v
+func bucketRank(v float64, b buckets) float64 {
+	rank := 0.0
+	for i, bucket := range b {
+		if v >= bucket.UpperBound {
+			rank = bucket.Count
+			continue
+		}
+
+		lowerBound := bucketLowerBound(b, i)
+		// Observations in buckets without a finite width cannot be interpolated
+		if v <= lowerBound || math.IsInf(lowerBound, -1) || math.IsInf(bucket.UpperBound, 1) {
+			return rank
+		}
+
+		return rank + (bucket.Count-rank)*(v-lowerBound)/(bucket.UpperBound-lowerBound)
+	}
+
+	return rank
+}
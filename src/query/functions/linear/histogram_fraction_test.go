@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package linear
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func histogramMetas() []block.SeriesMeta {
+	metas := make([]block.SeriesMeta, 0, 8)
+	for _, job := range []string{"a", "b"} {
+		for _, le := range []string{"4", "1", "+Inf", "2"} {
+			metas = append(metas, block.SeriesMeta{
+				Tags: models.Tags{models.MetricName: "latency_bucket", "job": job, BucketTag: le},
+			})
+		}
+	}
+
+	return metas
+}
+
+func processHistogramFraction(t *testing.T, lower, upper float64) *executor.SinkNode {
+	// Buckets are unsorted to make sure they are ordered by upper bound
+	values := [][]float64{
+		{40, 40}, {10, 20}, {40, 40}, {20, 20},
+		{8, math.NaN()}, {2, math.NaN()}, {8, 0}, {4, math.NaN()},
+	}
+
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, histogramMetas(), values)
+	op, err := NewHistogramFractionOp([]interface{}{lower, upper})
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	return sink
+}
+
+func TestHistogramFractionWithinBucket(t *testing.T) {
+	sink := processHistogramFraction(t, 1.25, 1.75)
+	require.Len(t, sink.Values, 2)
+	assert.InDelta(t, 0.125, sink.Values[0][0], 1e-9)
+	// The first bucket is wider in the second step, leaving nothing between 1 and 2
+	assert.InDelta(t, 0.0, sink.Values[0][1], 1e-9)
+	assert.InDelta(t, 0.125, sink.Values[1][0], 1e-9)
+	assert.True(t, math.IsNaN(sink.Values[1][1]), "empty histograms have no fraction")
+
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{"job": "a"}, sink.Metas[0].Tags)
+	assert.Equal(t, models.Tags{"job": "b"}, sink.Metas[1].Tags)
+}
+
+func TestHistogramFractionSpanningBuckets(t *testing.T) {
+	sink := processHistogramFraction(t, 0.5, 3)
+	require.Len(t, sink.Values, 2)
+	assert.InDelta(t, 0.625, sink.Values[0][0], 1e-9)
+	assert.InDelta(t, 0.5, sink.Values[0][1], 1e-9)
+	assert.InDelta(t, 0.625, sink.Values[1][0], 1e-9)
+}
+
+func TestHistogramFractionClampsOutsideBuckets(t *testing.T) {
+	sink := processHistogramFraction(t, -10, 100)
+	assert.Equal(t, 1.0, sink.Values[0][0])
+	assert.Equal(t, 1.0, sink.Values[1][0])
+
+	sink = processHistogramFraction(t, math.Inf(-1), math.Inf(1))
+	assert.Equal(t, 1.0, sink.Values[0][0])
+
+	sink = processHistogramFraction(t, 10, 100)
+	assert.Equal(t, 0.0, sink.Values[0][0])
+
+	sink = processHistogramFraction(t, 3, 2)
+	assert.Equal(t, 0.0, sink.Values[0][0])
+}
+
+func TestHistogramFractionWithInvalidArgs(t *testing.T) {
+	_, err := NewHistogramFractionOp([]interface{}{1.0})
+	assert.Error(t, err)
+
+	_, err = NewHistogramFractionOp([]interface{}{1.0, "2"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package linear
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/internal/quantile"
+)
+
+// HistogramQuantileType calculates the φ-quantile (0 ≤ φ ≤ 1) of a classic histogram
+const HistogramQuantileType = "histogram_quantile"
+
+// NewHistogramQuantileOp creates a new histogram_quantile op based on the arguments
+func NewHistogramQuantileOp(args []interface{}) (transform.Params, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of args for histogram_quantile: %d", len(args))
+	}
+
+	phi, ok := args[0].(float64)
+	if !ok {
+		return nil, fmt.Errorf("unable to cast to scalar argument: %v", args[0])
+	}
+
+	var argWarning string
+	if phi < 0 || phi > 1 {
+		argWarning = fmt.Sprintf("histogram_quantile quantile %v is outside [0, 1]", phi)
+	}
+
+	return histogramOp{
+		opType: HistogramQuantileType,
+		args:   args,
+		fn: func(b buckets) float64 {
+			return quantile.BucketQuantile(b, phi)
+		},
+		argWarning: argWarning,
+	}, nil
+}
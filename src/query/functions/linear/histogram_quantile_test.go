@@ -0,0 +1,162 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package linear
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processHistogramQuantile(t *testing.T, phi float64) *executor.SinkNode {
+	// Buckets are unsorted to make sure they are ordered by upper bound
+	values := [][]float64{
+		{40, 40}, {10, 20}, {40, 40}, {30, 10},
+		{8, math.NaN()}, {2, math.NaN()}, {8, 0}, {4, math.NaN()},
+	}
+
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, histogramMetas(), values)
+	op, err := NewHistogramQuantileOp([]interface{}{phi})
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	return sink
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	sink := processHistogramQuantile(t, 0.5)
+	require.Len(t, sink.Values, 2)
+	assert.InDelta(t, 1.5, sink.Values[0][0], 1e-9)
+	// The 2 bucket is lower than the 1 bucket in the second step, so it is raised to match
+	assert.InDelta(t, 1, sink.Values[0][1], 1e-9)
+	assert.InDelta(t, 2, sink.Values[1][0], 1e-9)
+	assert.True(t, math.IsNaN(sink.Values[1][1]), "empty histograms have no quantile")
+
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{"job": "a"}, sink.Metas[0].Tags)
+	assert.Equal(t, models.Tags{"job": "b"}, sink.Metas[1].Tags)
+}
+
+func TestHistogramQuantileEdges(t *testing.T) {
+	sink := processHistogramQuantile(t, 0)
+	assert.Equal(t, 0.0, sink.Values[0][0])
+
+	sink = processHistogramQuantile(t, 1)
+	assert.Equal(t, 4.0, sink.Values[0][0])
+
+	sink = processHistogramQuantile(t, -1)
+	assert.True(t, math.IsInf(sink.Values[0][0], -1))
+
+	sink = processHistogramQuantile(t, 2)
+	assert.True(t, math.IsInf(sink.Values[0][0], 1))
+}
+
+func TestHistogramQuantileWithInvalidArgs(t *testing.T) {
+	_, err := NewHistogramQuantileOp(nil)
+	assert.Error(t, err)
+
+	_, err = NewHistogramQuantileOp([]interface{}{"0.5"})
+	assert.Error(t, err)
+}
+
+func TestHistogramQuantileWarnings(t *testing.T) {
+	// Buckets are ordered 4, 1, +Inf, 2 and the 2 bucket is lower than the 1 bucket in both
+	// steps, as is the 4 bucket in the second step
+	nonMonotonic := [][]float64{{30, 6}, {10, 10}, {40, 8}, {5, 4}}
+	monotonic := [][]float64{{30, 6}, {10, 1}, {40, 8}, {20, 4}}
+	tests := []struct {
+		name     string
+		phi      float64
+		values   [][]float64
+		expected []float64
+		warnings []string
+	}{
+		{
+			name:     "non monotonic buckets",
+			phi:      0.5,
+			values:   nonMonotonic,
+			expected: []float64{3, 0.5},
+			warnings: []string{
+				"histogram_quantile found bucket counts which decrease as le increases, counts were raised to be monotonic",
+			},
+		},
+		{
+			name:     "monotonic buckets",
+			phi:      0.5,
+			values:   monotonic,
+			expected: []float64{2, 2},
+		},
+		{
+			name:     "quantile out of range",
+			phi:      2,
+			values:   monotonic,
+			expected: []float64{math.Inf(1), math.Inf(1)},
+			warnings: []string{"histogram_quantile quantile 2 is outside [0, 1]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, bounds := test.GenerateValuesAndBounds(nil, nil)
+			bounds.End = bounds.Start.Add(bounds.StepSize)
+			b := test.NewBlockFromValuesWithSeriesMeta(bounds, histogramMetas()[:4], tt.values)
+			op, err := NewHistogramQuantileOp([]interface{}{tt.phi})
+			require.NoError(t, err)
+
+			warnings := transform.NewWarnings()
+			c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+			c.Options = transform.Options{Warnings: warnings}
+			require.NoError(t, op.Node(c).Process(parser.NodeID(0), b))
+
+			require.Len(t, sink.Values, 1)
+			assert.Equal(t, tt.expected, sink.Values[0])
+			assert.Equal(t, tt.warnings, warnings.Drain())
+		})
+	}
+}
+
+func TestHistogramQuantileWithNaNBucket(t *testing.T) {
+	// Buckets are ordered 1, 2, 4, +Inf, and the 2 bucket has no count at the second step
+	values := [][]float64{{30, 30, 30}, {10, 10, 10}, {40, 40, 40}, {20, math.NaN(), 20}}
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	bounds.End = bounds.Start.Add(2 * bounds.StepSize)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, histogramMetas()[:4], values)
+	op, err := NewHistogramQuantileOp([]interface{}{0.5})
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	require.NoError(t, op.Node(c).Process(parser.NodeID(0), b))
+
+	require.Len(t, sink.Values, 1)
+	assert.InDelta(t, 2, sink.Values[0][0], 1e-9)
+	assert.True(t, math.IsNaN(sink.Values[0][1]), "steps with a NaN bucket have no quantile")
+	assert.InDelta(t, 2, sink.Values[0][2], 1e-9)
+}
@@ -82,6 +82,7 @@ func NewMathOp(optype string) (BaseOp, error) {
 	return BaseOp{
 		operatorType: optype,
 		processorFn:  newMathNode,
+		elementWise:  true,
 	}, nil
 }
 
@@ -24,6 +24,9 @@ import (
 	"math"
 	"testing"
 
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/parser"
 	"github.com/m3db/m3/src/query/test"
 	"github.com/m3db/m3/src/query/test/executor"
@@ -62,6 +65,89 @@ func TestAbsWithAllValues(t *testing.T) {
 	assert.Equal(t, expected, sink.Values)
 }
 
+func TestAbsOfScalar(t *testing.T) {
+	// Scalars, such as the output of count_scalar, are a single series without tags
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	scalar := []block.SeriesMeta{{Name: "count_scalar"}}
+	values := [][]float64{{-1, 2, -3, math.NaN(), 5, -6}}
+	op, err := NewMathOp(AbsType)
+	require.NoError(t, err)
+
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, scalar, values))
+	require.NoError(t, err)
+	test.EqualsWithNans(t, [][]float64{{1, 2, 3, math.NaN(), 5, 6}}, sink.Values)
+	assert.Equal(t, scalar, sink.Metas, "the output is still a scalar")
+
+	// Lazy evaluation keeps the scalar as well
+	c = &transform.Controller{ID: parser.NodeID(1)}
+	node, downstream := transform.NewLazyNode(op.Node(c), c)
+	sink = &executor.SinkNode{}
+	downstream.AddTransform(sink)
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, scalar, values))
+	require.NoError(t, err)
+	test.EqualsWithNans(t, [][]float64{{1, 2, 3, math.NaN(), 5, 6}}, sink.Values)
+	assert.Equal(t, scalar, sink.Metas)
+}
+
+func TestMathKeepMetricNames(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	metas := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "requests", "job": "api"}}}
+	values := [][]float64{{-1, 2, -3, 4, -5, 6}}
+	for _, keep := range []bool{false, true} {
+		op, err := NewMathOp(AbsType)
+		require.NoError(t, err)
+		op.KeepMetricNames = keep
+
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+		require.NoError(t, err)
+		require.Len(t, sink.Metas, 1)
+		_, hasName := sink.Metas[0].Tags[models.MetricName]
+		assert.Equal(t, keep, hasName)
+		assert.Equal(t, "api", sink.Metas[0].Tags["job"])
+	}
+}
+
+func stepValues(t *testing.T, b block.Block) [][]float64 {
+	iter, err := b.StepIter()
+	require.NoError(t, err)
+	var values [][]float64
+	for iter.Next() {
+		step, err := iter.Current()
+		require.NoError(t, err)
+		values = append(values, append([]float64(nil), step.Values()...))
+	}
+
+	return values
+}
+
+func TestMathIteratesBlocksMoreThanOnce(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{0, 1, 2, 3, 4, 5}}
+	input := test.NewBlockFromValues(bounds, values)
+	original := stepValues(t, input)
+	op, err := NewMathOp(ExpType)
+	require.NoError(t, err)
+
+	// Eager processing leaves the input unchanged for other consumers of it
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	require.NoError(t, op.Node(c).Process(parser.NodeID(0), input))
+	assert.Equal(t, expectedMathVals(values, math.Exp), sink.Values)
+	assert.Equal(t, original, stepValues(t, input))
+
+	// Lazy blocks apply the function to each iteration of the input, not to the last one
+	c = &transform.Controller{ID: parser.NodeID(1)}
+	node, downstream := transform.NewLazyNode(op.Node(c), c)
+	lazySink := &executor.BlockSinkNode{}
+	downstream.AddTransform(lazySink)
+	require.NoError(t, node.Process(parser.NodeID(0), input))
+	first := stepValues(t, lazySink.Block)
+	assert.Equal(t, first, stepValues(t, lazySink.Block))
+	assert.InDelta(t, math.Exp(1), first[1][0], 1e-9)
+	assert.Equal(t, original, stepValues(t, input))
+}
+
 func TestAbsWithSomeValues(t *testing.T) {
 	v := [][]float64{
 		{0, math.NaN(), 2, 3, 4},
@@ -213,3 +299,20 @@ func TestNonExistentFunc(t *testing.T) {
 	_, err := NewMathOp("nonexistent_func")
 	require.Error(t, err)
 }
+
+func TestAbsWithEmptyBlocks(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	for _, values := range [][][]float64{{}, {{}, {}}} {
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		op, err := NewMathOp(AbsType)
+		require.NoError(t, err)
+		node := op.Node(c)
+		err = node.Process(parser.NodeID(0), test.NewBlockFromValues(bounds, values))
+		require.NoError(t, err)
+		assert.Equal(t, bounds, sink.Meta.Bounds)
+		assert.Len(t, sink.Values, len(values))
+		for _, series := range sink.Values {
+			assert.Empty(t, series)
+		}
+	}
+}
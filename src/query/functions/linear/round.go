@@ -61,6 +61,8 @@ func NewRoundOp(args []interface{}) (BaseOp, error) {
 	return BaseOp{
 		operatorType: RoundType,
 		processorFn:  makeRoundProcessor(spec),
+		args:         args,
+		elementWise:  true,
 	}, nil
 
 }
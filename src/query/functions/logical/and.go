@@ -25,7 +25,9 @@ import (
 
 	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/util"
 )
 
 const (
@@ -71,8 +73,12 @@ func (c *AndNode) Process(lhs, rhs block.Block) (block.Block, error) {
 		return nil, err
 	}
 
+	if err := validateSteps(lIter, rIter); err != nil {
+		return nil, err
+	}
+
 	intersection := c.intersect(lIter.SeriesMeta(), rIter.SeriesMeta())
-	builder, err := c.controller.BlockBuilder(lIter.Meta(), rIter.SeriesMeta())
+	builder, err := c.controller.BlockBuilder(lIter.Meta(), lIter.SeriesMeta())
 	if err != nil {
 		return nil, err
 	}
@@ -99,11 +105,12 @@ func (c *AndNode) Process(lhs, rhs block.Block) (block.Block, error) {
 		for idx, value := range lValues {
 			rIdx := intersection[idx]
 			if rIdx < 0 || math.IsNaN(rValues[rIdx]) {
-				builder.AppendValue(index, math.NaN())
-				continue
+				value = math.NaN()
 			}
 
-			builder.AppendValue(index, value)
+			if err := builder.AppendValue(index, value); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -112,7 +119,8 @@ func (c *AndNode) Process(lhs, rhs block.Block) (block.Block, error) {
 
 // intersect returns the slice of rhs indices if there is a match with a corresponding lhs index. If no match is found, it returns -1
 func (c *AndNode) intersect(lhs, rhs []block.SeriesMeta) []int {
-	idFunction := hashFunc(c.op.Matching.On, c.op.Matching.MatchingLabels...)
+	matching := c.op.Matching
+	idFunction := matching.signatureFunc()
 	// The set of signatures for the right-hand side.
 	rightSigs := make(map[uint64]int, len(rhs))
 	for idx, meta := range rhs {
@@ -131,3 +139,60 @@ func (c *AndNode) intersect(lhs, rhs []block.SeriesMeta) []int {
 
 	return matches
 }
+
+// RHS returns the node the rhs is read from
+func (o BaseOp) RHS() parser.NodeID {
+	return o.RNode
+}
+
+// WithAggregatedLHS returns the and op reading its lhs from the aggregation instead, which is
+// only equivalent when every series of a group has the same matching signature
+func (o BaseOp) WithAggregatedLHS(labels []string, without bool, aggregation parser.NodeID) (parser.Params, bool) {
+	if o.OperatorType != AndType || o.LNode == o.RNode {
+		return nil, false
+	}
+
+	matching := o.Matching
+	if matching == nil {
+		matching = &VectorMatching{}
+	}
+
+	if matching.MatchName || !groupsMatchTogether(matching, labels, without) {
+		return nil, false
+	}
+
+	o.LNode = aggregation
+	return o, true
+}
+
+// groupsMatchTogether returns true if series which are in the same group, by or without the labels,
+// have the same matching signature. Grouping drops the metric name, so matching on it is never safe
+func groupsMatchTogether(matching *VectorMatching, labels []string, without bool) bool {
+	if matching.On {
+		if util.ContainsString(matching.MatchingLabels, models.MetricName) {
+			return false
+		}
+
+		for _, label := range matching.MatchingLabels {
+			if util.ContainsString(labels, label) == without {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	// Without on labels, series in a group only have the same signature when the labels they can
+	// differ by are ignored
+	if !without {
+		return false
+	}
+
+	for _, label := range labels {
+		if !util.ContainsString(matching.MatchingLabels, label) {
+			return false
+		}
+	}
+
+	return true
+}
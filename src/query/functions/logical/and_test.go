@@ -24,10 +24,14 @@ import (
 	"math"
 	"testing"
 
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/parser"
 	"github.com/m3db/m3/src/query/test"
 	"github.com/m3db/m3/src/query/test/executor"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -57,8 +61,8 @@ func TestAndWithSomeValues(t *testing.T) {
 		{math.NaN(), 6, 7, 8, 9},
 	}
 
-	values2, bounds2 := test.GenerateValuesAndBounds(v, nil)
-	block2 := test.NewBlockFromValues(bounds2, values2)
+	values2, _ := test.GenerateValuesAndBounds(v, nil)
+	block2 := test.NewBlockFromValues(bounds1, values2)
 
 	op := NewAndOp(parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
 	c, sink := executor.NewControllerWithSink(parser.NodeID(2))
@@ -74,3 +78,99 @@ func TestAndWithSomeValues(t *testing.T) {
 	expected[1][0] = math.NaN()
 	test.EqualsWithNans(t, expected, sink.Values)
 }
+
+func TestAndWithMatchName(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	lhsMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "a", "job": "x"}},
+		{Tags: models.Tags{models.MetricName: "b", "job": "y"}},
+	}
+	rhsMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "b", "job": "x"}},
+		{Tags: models.Tags{models.MetricName: "b", "job": "y"}},
+	}
+
+	process := func(matching *VectorMatching) [][]float64 {
+		op := NewAndOp(parser.NodeID(0), parser.NodeID(1), matching)
+		c, sink := executor.NewControllerWithSink(parser.NodeID(2))
+		node := op.Node(c)
+		err := node.Process(parser.NodeID(1), test.NewBlockFromValuesWithSeriesMeta(bounds, rhsMetas, values))
+		require.NoError(t, err)
+		err = node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, lhsMetas, values))
+		require.NoError(t, err)
+		return sink.Values
+	}
+
+	// By default the name is ignored, so both series match
+	assert.Equal(t, values, process(&VectorMatching{}))
+
+	nans := []float64{math.NaN(), math.NaN(), math.NaN(), math.NaN(), math.NaN()}
+	test.EqualsWithNans(t, [][]float64{nans, values[1]}, process(&VectorMatching{MatchName: true}))
+	test.EqualsWithNans(t, [][]float64{nans, values[1]}, process(&VectorMatching{
+		On:             true,
+		MatchingLabels: []string{"job"},
+		MatchName:      true,
+	}))
+	assert.Equal(t, values, process(&VectorMatching{On: true, MatchingLabels: []string{"job"}}))
+}
+
+func TestAndWithEmptyBlocks(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	emptySteps := [][]float64{{}, {}}
+	tests := []struct {
+		name     string
+		lhs, rhs [][]float64
+	}{
+		{name: "no steps", lhs: emptySteps, rhs: emptySteps},
+		{name: "no series", lhs: [][]float64{}, rhs: [][]float64{}},
+		{name: "no lhs series", lhs: [][]float64{}, rhs: emptySteps},
+	}
+
+	for _, tt := range tests {
+		op := NewAndOp(parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+		c, sink := executor.NewControllerWithSink(parser.NodeID(2))
+		node := op.Node(c)
+		err := node.Process(parser.NodeID(1), test.NewBlockFromValues(bounds, tt.rhs))
+		require.NoError(t, err, tt.name)
+		err = node.Process(parser.NodeID(0), test.NewBlockFromValues(bounds, tt.lhs))
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, bounds, sink.Meta.Bounds, tt.name)
+		assert.Len(t, sink.Values, len(tt.lhs), tt.name)
+		assert.Len(t, sink.Metas, len(tt.lhs), tt.name)
+		for _, series := range sink.Values {
+			assert.Empty(t, series, tt.name)
+		}
+	}
+}
+
+func TestAndWithMismatchedSteps(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	op := NewAndOp(parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	err := node.Process(parser.NodeID(1), test.NewBlockFromValues(bounds, [][]float64{{}, {}}))
+	require.NoError(t, err)
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValues(bounds, values))
+	require.Error(t, err)
+	assert.Equal(t, errMismatchedStepCounts, errors.Cause(err))
+	assert.Contains(t, err.Error(), AndType)
+
+	transformErr, ok := err.(*transform.Error)
+	require.True(t, ok)
+	assert.Equal(t, AndType, transformErr.OpType)
+	assert.Equal(t, []parser.NodeID{parser.NodeID(0), parser.NodeID(1)}, transformErr.NodeIDs)
+}
+
+func TestAndWithSeriesLimit(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	op := NewAndOp(parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	c, _ := executor.NewControllerWithSink(parser.NodeID("join"))
+	c.Options.MaxSeriesPerNode = 1
+	node := op.Node(c)
+	err := node.Process(parser.NodeID(1), test.NewBlockFromValues(bounds, values))
+	require.NoError(t, err)
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValues(bounds, values))
+	require.Error(t, err)
+	assert.Equal(t, transform.ErrTooManySeries, errors.Cause(err))
+	assert.Contains(t, err.Error(), "node join would emit 2 series")
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+const (
+	// PlusType adds datapoints in both series
+	PlusType = "+"
+
+	// MinusType subtracts rhs from lhs
+	MinusType = "-"
+
+	// MultiplyType multiplies datapoints by series
+	MultiplyType = "*"
+
+	// DivType divides datapoints by series
+	DivType = "/"
+
+	// ExpType raises lhs to the power of rhs
+	ExpType = "^"
+
+	// ModType takes the modulo of lhs by rhs
+	ModType = "%"
+
+	// ElemMaxType takes the larger of the lhs and rhs datapoints. There is no PromQL syntax for it,
+	// so it is only available to plans built directly, e.g. the worse of two regions' error ratios
+	ElemMaxType = "elem_max"
+
+	// ElemMinType takes the smaller of the lhs and rhs datapoints, with the same caveats as ElemMaxType
+	ElemMinType = "elem_min"
+)
+
+type arithmeticFn func(x, y float64) float64
+
+var arithmeticFns = map[string]arithmeticFn{
+	PlusType:     func(x, y float64) float64 { return x + y },
+	MinusType:    func(x, y float64) float64 { return x - y },
+	MultiplyType: func(x, y float64) float64 { return x * y },
+	DivType:      func(x, y float64) float64 { return x / y },
+	ExpType:      math.Pow,
+	ModType:      math.Mod,
+	ElemMaxType:  math.Max,
+	ElemMinType:  math.Min,
+}
+
+// nanSkippingTypes set SkipNaNs by default, returning the other side when one side is NaN
+var nanSkippingTypes = map[string]bool{
+	ElemMaxType: true,
+	ElemMinType: true,
+}
+
+// NewArithmeticOp creates a new arithmetic operation
+func NewArithmeticOp(opType string, lNode parser.NodeID, rNode parser.NodeID, matching *VectorMatching) (BaseOp, error) {
+	fn, ok := arithmeticFns[opType]
+	if !ok {
+		return BaseOp{}, fmt.Errorf("unknown arithmetic type: %s", opType)
+	}
+
+	return BaseOp{
+		OperatorType: opType,
+		LNode:        lNode,
+		RNode:        rNode,
+		Matching:     matching,
+		SkipNaNs:     nanSkippingTypes[opType],
+		ProcessorFn: func(op BaseOp, controller *transform.Controller) Processor {
+			return &ArithmeticNode{
+				op:         op,
+				fn:         fn,
+				controller: controller,
+			}
+		},
+	}, nil
+}
+
+// ArithmeticNode is a node for arithmetic operations
+type ArithmeticNode struct {
+	op         BaseOp
+	fn         arithmeticFn
+	controller *transform.Controller
+}
+
+// Process processes two logical blocks, applying the arithmetic operation to each one to one match
+func (c *ArithmeticNode) Process(lhs, rhs block.Block) (block.Block, error) {
+	lIter, err := lhs.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	rIter, err := rhs.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateSteps(lIter, rIter); err != nil {
+		return nil, err
+	}
+
+	lIndices, rIndices, err := matchPairs(c.op, lIter.SeriesMeta(), rIter.SeriesMeta())
+	if err != nil {
+		return nil, err
+	}
+
+	// The metric name is dropped since the result is no longer that metric
+	seriesMeta, err := resultMetas(c.op, lIter.SeriesMeta(), rIter.SeriesMeta(), lIndices, rIndices, !c.op.KeepMetricNames)
+	if err != nil {
+		return nil, err
+	}
+
+	builder, err := c.controller.BlockBuilder(lIter.Meta(), seriesMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := builder.AddCols(lIter.StepCount()); err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, len(lIndices))
+	for index := 0; lIter.Next() && rIter.Next(); index++ {
+		lStep, err := lIter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		rStep, err := rIter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		lValues, rValues := lStep.Values(), rStep.Values()
+		omitted := false
+		for i, lIdx := range lIndices {
+			lValue, rValue := lValues[lIdx], rValues[rIndices[i]]
+			omitted = omitted || c.op.NaNStrict && (math.IsNaN(lValue) || math.IsNaN(rValue))
+			values[i] = c.apply(lValue, rValue)
+		}
+
+		for _, value := range values {
+			if omitted {
+				value = math.NaN()
+			}
+
+			if err := builder.AppendValue(index, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// apply applies the operation to a pair of values. Either side being NaN gives NaN for every
+// operation, including those which do not propagate NaNs in IEEE arithmetic, e.g. NaN ^ 0 is 1,
+// unless SkipNaNs is set, in which case the other side is returned
+func (c *ArithmeticNode) apply(lValue, rValue float64) float64 {
+	lNaN, rNaN := math.IsNaN(lValue), math.IsNaN(rValue)
+	switch {
+	case c.op.SkipNaNs && lNaN:
+		return rValue
+	case c.op.SkipNaNs && rNaN:
+		return lValue
+	case lNaN || rNaN:
+		return math.NaN()
+	default:
+		return c.fn(lValue, rValue)
+	}
+}
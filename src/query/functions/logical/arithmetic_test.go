@@ -0,0 +1,595 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processArithmetic(t *testing.T, op BaseOp, lhs, rhs block.Block) *executor.SinkNode {
+	c, sink := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	err := node.Process(parser.NodeID(1), rhs)
+	require.NoError(t, err)
+	err = node.Process(parser.NodeID(0), lhs)
+	require.NoError(t, err)
+	return sink
+}
+
+func TestArithmeticOps(t *testing.T) {
+	lhs := [][]float64{{1, 2, 3, 4, 5}}
+	rhs := [][]float64{{2, 2, 2, 2, 2}}
+	tests := []struct {
+		opType   string
+		expected []float64
+	}{
+		{opType: PlusType, expected: []float64{3, 4, 5, 6, 7}},
+		{opType: MinusType, expected: []float64{-1, 0, 1, 2, 3}},
+		{opType: MultiplyType, expected: []float64{2, 4, 6, 8, 10}},
+		{opType: DivType, expected: []float64{0.5, 1, 1.5, 2, 2.5}},
+		{opType: ExpType, expected: []float64{1, 4, 9, 16, 25}},
+		{opType: ModType, expected: []float64{1, 0, 1, 0, 1}},
+		{opType: ElemMaxType, expected: []float64{2, 2, 3, 4, 5}},
+		{opType: ElemMinType, expected: []float64{1, 2, 2, 2, 2}},
+	}
+
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	for _, tt := range tests {
+		op, err := NewArithmeticOp(tt.opType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+		require.NoError(t, err)
+		sink := processArithmetic(t, op, test.NewBlockFromValues(bounds, lhs), test.NewBlockFromValues(bounds, rhs))
+		assert.Equal(t, [][]float64{tt.expected}, sink.Values, tt.opType)
+	}
+}
+
+func TestArithmeticDropsUnmatchedSeriesAndName(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, 3, 4, 5}, {6, 7, 8, 9, 10}}
+	lhsMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "a", "job": "x"}},
+		{Tags: models.Tags{models.MetricName: "a", "job": "y"}},
+	}
+	rhsMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "b", "job": "y"}},
+		{Tags: models.Tags{models.MetricName: "b", "job": "z"}},
+	}
+
+	op, err := NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhsMetas, values),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, rhsMetas, values))
+	assert.Equal(t, [][]float64{{7, 9, 11, 13, 15}}, sink.Values)
+	require.Len(t, sink.Metas, 1)
+	assert.Equal(t, models.Tags{"job": "y"}, sink.Metas[0].Tags)
+}
+
+func TestArithmeticWithDuplicateMatches(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, 3, 4, 5}, {6, 7, 8, 9, 10}}
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"job": "x", "instance": "a"}},
+		{Tags: models.Tags{"job": "x", "instance": "b"}},
+	}
+
+	op, err := NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{
+		On:             true,
+		MatchingLabels: []string{"job"},
+	})
+	require.NoError(t, err)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(1), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+	require.NoError(t, err)
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+	assert.Error(t, err)
+}
+
+func TestArithmeticWithEmptyMatchingLabels(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, 3, 4, 5}}
+	lhs := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "a", "job": "x"}}}
+	rhs := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "b", "instance": "1"}}}
+
+	// on() puts every series in the same match group, so single series always match
+	on := &VectorMatching{On: true, MatchingLabels: []string{}}
+	op, err := NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), on)
+	require.NoError(t, err)
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhs, values),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, rhs, values))
+	assert.Equal(t, [][]float64{{2, 4, 6, 8, 10}}, sink.Values)
+	require.Len(t, sink.Metas, 1)
+	assert.Equal(t, models.Tags{"job": "x"}, sink.Metas[0].Tags)
+
+	// ignoring() ignores nothing, which is the default matching on all labels but the name
+	ignoring := &VectorMatching{MatchingLabels: []string{}}
+	op, err = NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), ignoring)
+	require.NoError(t, err)
+	sink = processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhs, values),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, rhs, values))
+	assert.Empty(t, sink.Metas)
+
+	// With more series on a side, on() is ambiguous without a group modifier
+	many := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "b", "instance": "1"}},
+		{Tags: models.Tags{models.MetricName: "b", "instance": "2"}},
+	}
+
+	op, err = NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), on)
+	require.NoError(t, err)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(1), test.NewBlockFromValuesWithSeriesMeta(bounds, many, [][]float64{values[0], values[0]}))
+	require.NoError(t, err)
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, lhs, values))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "match group {} on the right hand side")
+
+	op, err = NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1),
+		&VectorMatching{On: true, Card: CardOneToMany})
+	require.NoError(t, err)
+	sink = processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhs, values),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, many, [][]float64{values[0], values[0]}))
+	assert.Equal(t, [][]float64{{2, 4, 6, 8, 10}, {2, 4, 6, 8, 10}}, sink.Values, "group_right allows many rhs series")
+}
+
+func TestArithmeticNaNs(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	lhs := [][]float64{{1, math.NaN(), 2, math.NaN(), 3}}
+	rhs := [][]float64{{2, 0, math.NaN(), math.NaN(), 1}}
+	nan := math.NaN()
+
+	tests := []struct {
+		opType   string
+		expected []float64
+		skipped  []float64
+	}{
+		{opType: PlusType, expected: []float64{3, nan, nan, nan, 4}, skipped: []float64{3, 0, 2, nan, 4}},
+		{opType: MinusType, expected: []float64{-1, nan, nan, nan, 2}, skipped: []float64{-1, 0, 2, nan, 2}},
+		{opType: MultiplyType, expected: []float64{2, nan, nan, nan, 3}, skipped: []float64{2, 0, 2, nan, 3}},
+		{opType: DivType, expected: []float64{0.5, nan, nan, nan, 3}, skipped: []float64{0.5, 0, 2, nan, 3}},
+		// NaN ^ 0 and 2 ^ NaN would be 1 in IEEE arithmetic
+		{opType: ExpType, expected: []float64{1, nan, nan, nan, 3}, skipped: []float64{1, 0, 2, nan, 3}},
+		{opType: ModType, expected: []float64{1, nan, nan, nan, 0}, skipped: []float64{1, 0, 2, nan, 0}},
+	}
+
+	for _, tt := range tests {
+		op, err := NewArithmeticOp(tt.opType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+		require.NoError(t, err)
+		assert.False(t, op.SkipNaNs, "NaNs propagate by default for %s", tt.opType)
+		sink := processArithmetic(t, op, test.NewBlockFromValues(bounds, lhs), test.NewBlockFromValues(bounds, rhs))
+		test.EqualsWithNans(t, [][]float64{tt.expected}, sink.Values)
+		assert.Equal(t, bounds, sink.Meta.Bounds)
+
+		op.SkipNaNs = true
+		sink = processArithmetic(t, op, test.NewBlockFromValues(bounds, lhs), test.NewBlockFromValues(bounds, rhs))
+		test.EqualsWithNans(t, [][]float64{tt.skipped}, sink.Values)
+	}
+}
+
+func TestArithmeticNaNStrict(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"a": "1"}, Name: "a=1"},
+		{Tags: models.Tags{"a": "2"}, Name: "a=2"},
+	}
+	lhs := [][]float64{{1, math.NaN(), 3, 4, 5}, {1, 2, 3, 4, 5}}
+	rhs := [][]float64{{1, 1, 1, 1, 1}, {1, 1, 1, math.NaN(), 1}}
+	nan := math.NaN()
+
+	op, err := NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	assert.False(t, op.NaNStrict)
+
+	// By default only the series the NaN is matched into is NaN at the step
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, metas, lhs),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, metas, rhs))
+	test.EqualsWithNans(t, [][]float64{{2, nan, 4, 5, 6}, {2, 3, 4, nan, 6}}, sink.Values)
+
+	// Strict mode omits the steps from every series, keeping the bounds of the inputs
+	op.NaNStrict = true
+	sink = processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, metas, lhs),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, metas, rhs))
+	test.EqualsWithNans(t, [][]float64{{2, nan, 4, nan, 6}, {2, nan, 4, nan, 6}}, sink.Values)
+	assert.Equal(t, bounds, sink.Meta.Bounds)
+
+	// Strict mode takes precedence over skipping NaNs
+	op.SkipNaNs = true
+	sink = processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, metas, lhs),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, metas, rhs))
+	test.EqualsWithNans(t, [][]float64{{2, nan, 4, nan, 6}, {2, nan, 4, nan, 6}}, sink.Values)
+}
+
+func TestElemMaxMinSkipNaNs(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	lhs := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "errors", "region": "us"}},
+		{Tags: models.Tags{models.MetricName: "errors", "region": "eu"}},
+	}
+	rhs := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "errors", "region": "eu"}},
+		{Tags: models.Tags{models.MetricName: "errors", "region": "ap"}},
+	}
+	lValues := [][]float64{{1, 1, 1, 1, 1}, {1, math.NaN(), 5, math.NaN(), 2}}
+	rValues := [][]float64{{3, 4, math.NaN(), math.NaN(), 1}, {9, 9, 9, 9, 9}}
+
+	op, err := NewArithmeticOp(ElemMaxType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhs, lValues),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, rhs, rValues))
+	require.Len(t, sink.Metas, 1, "only matched series are compared")
+	assert.Equal(t, models.Tags{"region": "eu"}, sink.Metas[0].Tags)
+	test.EqualsWithNans(t, [][]float64{{3, 4, 5, math.NaN(), 2}}, sink.Values)
+
+	op, err = NewArithmeticOp(ElemMinType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	sink = processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhs, lValues),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, rhs, rValues))
+	test.EqualsWithNans(t, [][]float64{{1, 4, 5, math.NaN(), 1}}, sink.Values)
+
+	// Without SkipNaNs the NaNs propagate as for other operations
+	op.SkipNaNs = false
+	sink = processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhs, lValues),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, rhs, rValues))
+	test.EqualsWithNans(t, [][]float64{{1, math.NaN(), math.NaN(), math.NaN(), 1}}, sink.Values)
+}
+
+func TestNewArithmeticOpWithUnknownType(t *testing.T) {
+	_, err := NewArithmeticOp("and", parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	assert.Error(t, err)
+}
+
+func TestArithmeticKeepMetricNames(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, 3, 4, 5}}
+	lhsMetas := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "a", "job": "x"}}}
+	rhsMetas := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "b", "job": "x"}}}
+
+	op, err := NewArithmeticOp(MultiplyType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	op.KeepMetricNames = true
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhsMetas, values),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, rhsMetas, values))
+	require.Len(t, sink.Metas, 1)
+	assert.Equal(t, lhsMetas[0].Tags, sink.Metas[0].Tags, "the lhs name survives")
+	assert.Equal(t, "(a * b) keep_metric_names", op.FormatExpr([]string{"a", "b"}))
+}
+
+func TestArithmeticWithNormalizedNumericLabels(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, 3, 4, 5}}
+	lhsMetas := []block.SeriesMeta{{Tags: models.Tags{"job": "x", "quantile": "1.0"}}}
+	rhsMetas := []block.SeriesMeta{{Tags: models.Tags{"job": "x", "quantile": "1"}}}
+
+	process := func(matching *VectorMatching) *executor.SinkNode {
+		op, err := NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), matching)
+		require.NoError(t, err)
+		return processArithmetic(t, op,
+			test.NewBlockFromValuesWithSeriesMeta(bounds, lhsMetas, values),
+			test.NewBlockFromValuesWithSeriesMeta(bounds, rhsMetas, values))
+	}
+
+	sink := process(&VectorMatching{})
+	assert.Empty(t, sink.Values, "labels match exactly by default")
+
+	sink = process(&VectorMatching{NormalizeNumericLabels: []string{"quantile"}})
+	assert.Equal(t, [][]float64{{2, 4, 6, 8, 10}}, sink.Values)
+	require.Len(t, sink.Metas, 1)
+	assert.Equal(t, models.Tags{"job": "x", "quantile": "1.0"}, sink.Metas[0].Tags, "output keeps the original value")
+}
+
+func TestArithmeticWithCaseInsensitiveMatching(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, 3, 4, 5}}
+	lhsMetas := []block.SeriesMeta{{Tags: models.Tags{"instance": "Host1"}}}
+	rhsMetas := []block.SeriesMeta{{Tags: models.Tags{"instance": "host1"}}}
+
+	process := func(matching *VectorMatching) *executor.SinkNode {
+		op, err := NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), matching)
+		require.NoError(t, err)
+		return processArithmetic(t, op,
+			test.NewBlockFromValuesWithSeriesMeta(bounds, lhsMetas, values),
+			test.NewBlockFromValuesWithSeriesMeta(bounds, rhsMetas, values))
+	}
+
+	sink := process(&VectorMatching{On: true, MatchingLabels: []string{"instance"}})
+	assert.Empty(t, sink.Values, "label values are case sensitive by default")
+
+	sink = process(&VectorMatching{On: true, MatchingLabels: []string{"instance"}, CaseInsensitive: true})
+	assert.Equal(t, [][]float64{{2, 4, 6, 8, 10}}, sink.Values)
+	require.Len(t, sink.Metas, 1)
+	assert.Equal(t, models.Tags{"instance": "Host1"}, sink.Metas[0].Tags, "output keeps the original case")
+}
+
+func TestNormalizeNumericLabels(t *testing.T) {
+	tags := models.Tags{"a": "1.50", "b": "NaN", "c": "nan", "d": "x", "e": "1e3"}
+	normalized := normalizeNumericLabels(tags, []string{"a", "c", "d", "e", "missing"})
+	assert.Equal(t, models.Tags{"a": "1.5", "b": "NaN", "c": "NaN", "d": "x", "e": "1000"}, normalized)
+	assert.Equal(t, "1.50", tags["a"], "the input is not modified")
+
+	unchanged := models.Tags{"a": "1.5"}
+	assert.Equal(t, unchanged, normalizeNumericLabels(unchanged, []string{"a"}))
+}
+
+func TestSignatureString(t *testing.T) {
+	requests := block.SeriesMeta{Tags: models.Tags{models.MetricName: "requests", "instance": "a:1", "job": "api"}}
+	limits := block.SeriesMeta{Tags: models.Tags{models.MetricName: "limits", "instance": "a:1", "zone": "z"}}
+	other := block.SeriesMeta{Tags: models.Tags{models.MetricName: "limits", "instance": "b:1", "zone": "z"}}
+
+	matching := &VectorMatching{On: true, MatchingLabels: []string{"instance"}}
+	assert.Equal(t, `{instance="a:1"}`, matching.SignatureString(requests))
+	assert.Equal(t, matching.SignatureString(requests), matching.SignatureString(limits))
+	assert.NotEqual(t, matching.SignatureString(requests), matching.SignatureString(other))
+
+	signature := matching.signatureFunc()
+	assert.Equal(t, signature(requests.Tags), signature(limits.Tags), "strings match when hashes match")
+
+	ignoring := &VectorMatching{MatchingLabels: []string{"job"}, NormalizeNumericLabels: []string{"quantile"}}
+	normalized := block.SeriesMeta{Tags: models.Tags{"instance": "a:1", "job": "api", "quantile": "0.50"}}
+	assert.Equal(t, `{instance="a:1",quantile="0.5"}`, ignoring.SignatureString(normalized))
+}
+
+func TestArithmeticWithMismatchedBounds(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	op, err := NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	shorter := bounds
+	shorter.End = bounds.End.Add(-bounds.StepSize)
+	err = node.Process(parser.NodeID(1), test.NewBlockFromValues(shorter, values))
+	require.NoError(t, err)
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValues(bounds, values))
+	require.Error(t, err)
+	assert.Equal(t, errMismatchedBounds, errors.Cause(err))
+	assert.Contains(t, err.Error(), PlusType)
+
+	// Sides with the same step size and number of steps must also start at the same time
+	shifted := bounds
+	shifted.Start = bounds.Start.Add(-time.Hour)
+	shifted.End = bounds.End.Add(-time.Hour)
+	c, _ = executor.NewControllerWithSink(parser.NodeID(2))
+	node = op.Node(c)
+	err = node.Process(parser.NodeID(1), test.NewBlockFromValues(shifted, values))
+	require.NoError(t, err)
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValues(bounds, values))
+	require.Error(t, err)
+	assert.Equal(t, errMismatchedBounds, errors.Cause(err))
+}
+
+func TestArithmeticWithResample(t *testing.T) {
+	now := time.Now().Truncate(time.Hour)
+	fine := block.Bounds{Start: now, End: now.Add(10 * time.Minute), StepSize: time.Minute}
+	coarse := block.Bounds{Start: now, End: now.Add(10 * time.Minute), StepSize: 5 * time.Minute}
+	fineValues := [][]float64{{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}
+	coarseValues := [][]float64{{100, 200, 300}}
+
+	op, err := NewArithmeticOp(PlusType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	require.NoError(t, node.Process(parser.NodeID(1), test.NewBlockFromValues(coarse, coarseValues)))
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValues(fine, fineValues))
+	assert.Equal(t, errMismatchedBounds, errors.Cause(err), "sides with different steps fail by default")
+
+	op.Resample = block.ResamplePrevious
+	sink := processArithmetic(t, op, test.NewBlockFromValues(fine, fineValues), test.NewBlockFromValues(coarse, coarseValues))
+	test.EqualsWithNans(t, [][]float64{{100, 101, 102, 103, 104, 205, 206, 207, 208, 209, 310}}, sink.Values)
+	assert.Equal(t, fine, sink.Meta.Bounds, "the coarser side is aligned to the finer steps")
+
+	op.Resample = block.ResampleLinear
+	sink = processArithmetic(t, op, test.NewBlockFromValues(coarse, coarseValues), test.NewBlockFromValues(fine, fineValues))
+	test.EqualsWithNans(t, [][]float64{{100, 121, 142, 163, 184, 205, 226, 247, 268, 289, 310}}, sink.Values)
+}
+
+func TestArithmeticGroupLeftWithoutInclude(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	lhsMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "requests", "instance": "a", "code": "200"}},
+		{Tags: models.Tags{models.MetricName: "requests", "instance": "a", "code": "500"}},
+		{Tags: models.Tags{models.MetricName: "requests", "instance": "b", "code": "200"}},
+	}
+	rhsMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "weight", "instance": "a", "zone": "east"}},
+		{Tags: models.Tags{models.MetricName: "weight", "instance": "c", "zone": "west"}},
+	}
+
+	op, err := NewArithmeticOp(MultiplyType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{
+		Card:           CardManyToOne,
+		On:             true,
+		MatchingLabels: []string{"instance"},
+	})
+	require.NoError(t, err)
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, lhsMetas, [][]float64{{1, 2, 3, 4, 5}, {2, 2, 2, 2, 2}, {9, 9, 9, 9, 9}}),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, rhsMetas, [][]float64{{10, 10, 10, 10, 10}, {7, 7, 7, 7, 7}}))
+
+	assert.Equal(t, [][]float64{{10, 20, 30, 40, 50}, {20, 20, 20, 20, 20}}, sink.Values)
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{"instance": "a", "code": "200"}, sink.Metas[0].Tags, "no rhs labels are copied")
+	assert.Equal(t, models.Tags{"instance": "a", "code": "500"}, sink.Metas[1].Tags)
+}
+
+func TestArithmeticGroupMatching(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	manyMetas := []block.SeriesMeta{
+		{Tags: models.Tags{"instance": "a", "code": "200"}},
+		{Tags: models.Tags{"instance": "a", "code": "500"}},
+	}
+	oneMetas := []block.SeriesMeta{{Tags: models.Tags{"instance": "a", "zone": "east"}}}
+	many := [][]float64{{4, 4, 4, 4, 4}, {8, 8, 8, 8, 8}}
+	one := [][]float64{{2, 2, 2, 2, 2}}
+
+	// group_left copies the include labels from the rhs
+	op, err := NewArithmeticOp(DivType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{
+		Card:           CardManyToOne,
+		On:             true,
+		MatchingLabels: []string{"instance"},
+		Include:        []string{"zone"},
+	})
+	require.NoError(t, err)
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, manyMetas, many),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, oneMetas, one))
+	assert.Equal(t, [][]float64{{2, 2, 2, 2, 2}, {4, 4, 4, 4, 4}}, sink.Values)
+	assert.Equal(t, models.Tags{"instance": "a", "code": "200", "zone": "east"}, sink.Metas[0].Tags)
+	assert.Equal(t, models.Tags{"instance": "a", "code": "200"}, manyMetas[0].Tags, "input tags are not modified")
+
+	// group_right keeps the rhs labels while the operands keep their sides
+	op, err = NewArithmeticOp(DivType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{
+		Card:           CardOneToMany,
+		On:             true,
+		MatchingLabels: []string{"instance"},
+	})
+	require.NoError(t, err)
+	sink = processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, oneMetas, one),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, manyMetas, many))
+	assert.Equal(t, [][]float64{{0.5, 0.5, 0.5, 0.5, 0.5}, {0.25, 0.25, 0.25, 0.25, 0.25}}, sink.Values)
+	assert.Equal(t, models.Tags{"instance": "a", "code": "500"}, sink.Metas[1].Tags)
+
+	// The one side must be unique for each match group
+	op, err = NewArithmeticOp(DivType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{
+		Card:           CardManyToOne,
+		On:             true,
+		MatchingLabels: []string{"instance"},
+	})
+	require.NoError(t, err)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	require.NoError(t, node.Process(parser.NodeID(1), test.NewBlockFromValuesWithSeriesMeta(bounds, manyMetas, many)))
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, manyMetas, many))
+	assert.Error(t, err)
+}
+
+func TestScalarArithmetic(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, math.NaN(), 4, 5}}
+	metas := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "up", "job": "api"}}}
+	nan := math.NaN()
+
+	op, err := NewScalarArithmeticOp(MinusType, 1, false)
+	require.NoError(t, err)
+	assert.Equal(t, "up - 1", op.FormatExpr([]string{"up"}))
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	require.NoError(t, op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)))
+	test.EqualsWithNans(t, [][]float64{{0, 1, nan, 3, 4}}, sink.Values)
+	require.Len(t, sink.Metas, 1)
+	assert.Equal(t, models.Tags{"job": "api"}, sink.Metas[0].Tags, "the metric name is dropped")
+
+	op, err = NewScalarArithmeticOp(MinusType, 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, "1 - up", op.FormatExpr([]string{"up"}))
+	c, sink = executor.NewControllerWithSink(parser.NodeID(1))
+	require.NoError(t, op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)))
+	test.EqualsWithNans(t, [][]float64{{0, -1, nan, -3, -4}}, sink.Values)
+
+	_, err = NewScalarArithmeticOp(GreaterType, 1, false)
+	assert.Error(t, err)
+}
+
+func TestApplyScalarArithmetic(t *testing.T) {
+	value, err := ApplyScalarArithmetic(MultiplyType, 60, 60)
+	require.NoError(t, err)
+	assert.Equal(t, 3600.0, value)
+
+	value, err = ApplyScalarArithmetic(DivType, 1, 0)
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(value, 1))
+
+	_, err = ApplyScalarArithmetic(GreaterType, 1, 0)
+	assert.Error(t, err)
+}
+
+func TestArithmeticBetweenScalars(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	op, err := NewArithmeticOp(MultiplyType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	op.LScalar, op.RScalar = true, true
+
+	scalarMetas := []block.SeriesMeta{{}}
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, scalarMetas, [][]float64{{1, 2, 3, math.NaN(), 5}}),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, scalarMetas, [][]float64{{4, 4, 4, 4, 4}}))
+	test.EqualsWithNans(t, [][]float64{{4, 8, 12, math.NaN(), 20}}, sink.Values)
+	require.Len(t, sink.Metas, 1)
+	assert.Empty(t, sink.Metas[0].Tags)
+
+	values, _ := test.GenerateValuesAndBounds(nil, nil)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	require.NoError(t, node.Process(parser.NodeID(1), test.NewBlockFromValues(bounds, values)))
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, scalarMetas, [][]float64{{1, 2, 3, 4, 5}}))
+	assert.Error(t, err, "both sides must be scalars")
+}
+
+func TestArithmeticBetweenVectorAndScalar(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "up", "job": "a"}},
+		{Tags: models.Tags{models.MetricName: "up", "job": "b"}},
+	}
+	vector := [][]float64{{1, 2, 3, 4, 5}, {10, 20, 30, 40, 50}}
+	scalar := [][]float64{{2, 2, 2, math.NaN(), 2}}
+	expectedMetas := []block.SeriesMeta{
+		{Tags: models.Tags{"job": "a"}, Name: models.Tags{"job": "a"}.ID()},
+		{Tags: models.Tags{"job": "b"}, Name: models.Tags{"job": "b"}.ID()},
+	}
+
+	op, err := NewArithmeticOp(MinusType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{})
+	require.NoError(t, err)
+	op.RScalar = true
+	sink := processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, metas, vector),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, []block.SeriesMeta{{}}, scalar))
+	test.EqualsWithNans(t, [][]float64{{-1, 0, 1, math.NaN(), 3}, {8, 18, 28, math.NaN(), 48}}, sink.Values)
+	assert.Equal(t, expectedMetas, sink.Metas)
+
+	// The scalar on the lhs is combined with every series of the rhs, which keeps its labels
+	op.LScalar, op.RScalar = true, false
+	sink = processArithmetic(t, op,
+		test.NewBlockFromValuesWithSeriesMeta(bounds, []block.SeriesMeta{{}}, scalar),
+		test.NewBlockFromValuesWithSeriesMeta(bounds, metas, vector))
+	test.EqualsWithNans(t, [][]float64{{1, 0, -1, math.NaN(), -3}, {-8, -18, -28, math.NaN(), -48}}, sink.Values)
+	assert.Equal(t, expectedMetas, sink.Metas)
+}
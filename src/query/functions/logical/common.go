@@ -21,9 +21,21 @@
 package logical
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/executor/transform"
 	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/util"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errMismatchedBounds     = errors.New("mismatch in bounds")
+	errMismatchedStepCounts = errors.New("mismatch in number of steps")
 )
 
 // VectorMatchCardinality describes the cardinality relationship
@@ -55,17 +67,213 @@ type VectorMatching struct {
 	// Include contains additional labels that should be included in
 	// the result from the side with the lower cardinality.
 	Include []string
+	// MatchName includes the metric name in the matching signature. By default,
+	// the name is ignored unless it is one of the on labels, as in Prometheus.
+	MatchName bool
+	// NormalizeNumericLabels are labels holding numbers which are canonicalized before
+	// matching, so that e.g. "1.0" matches "1". Other labels must match exactly.
+	NormalizeNumericLabels []string
+	// CaseInsensitive lowercases label values before matching, so that e.g. "Host1" matches
+	// "host1", for systems which disagree on case. Values match exactly by default, as in Prometheus.
+	CaseInsensitive bool
+}
+
+// format renders the matching and grouping clauses of a binary expression
+func (m *VectorMatching) format() string {
+	if m == nil {
+		return ""
+	}
+
+	var clauses []string
+	if m.On {
+		clauses = append(clauses, fmt.Sprintf("on(%s)", strings.Join(m.MatchingLabels, ", ")))
+	} else if len(m.MatchingLabels) > 0 {
+		clauses = append(clauses, fmt.Sprintf("ignoring(%s)", strings.Join(m.MatchingLabels, ", ")))
+	}
+
+	switch m.Card {
+	case CardManyToOne:
+		clauses = append(clauses, fmt.Sprintf("group_left(%s)", strings.Join(m.Include, ", ")))
+	case CardOneToMany:
+		clauses = append(clauses, fmt.Sprintf("group_right(%s)", strings.Join(m.Include, ", ")))
+	}
+
+	return strings.Join(clauses, " ")
 }
 
 // hashFunc returns a function that calculates the signature for a metric
 // ignoring the provided labels. If on, then the given labels are only used instead.
-func hashFunc(on bool, names ...string) func(models.Tags) uint64 {
+// If includeName, the metric name is part of the signature in either case.
+func hashFunc(on, includeName bool, names ...string) func(models.Tags) uint64 {
 	if on {
+		if includeName {
+			names = append([]string{models.MetricName}, names...)
+		}
+
 		return func(tags models.Tags) uint64 { return tags.IDWithKeys(names...) }
 	}
+
+	if includeName {
+		return func(tags models.Tags) uint64 { return tags.IDWithExcludesKeepingName(names...) }
+	}
+
 	return func(tags models.Tags) uint64 { return tags.IDWithExcludes(names...) }
 }
 
+// signatureFunc returns a function that calculates the matching signature for a metric
+func (m *VectorMatching) signatureFunc() func(models.Tags) uint64 {
+	hash := hashFunc(m.On, m.MatchName, m.MatchingLabels...)
+	if len(m.NormalizeNumericLabels) == 0 && !m.CaseInsensitive {
+		return hash
+	}
+
+	return func(tags models.Tags) uint64 { return hash(m.signatureTags(tags)) }
+}
+
+// SignatureString renders the labels forming the matching signature of the series, which
+// series must share to be matched, as a stable {k="v",...} string for debugging joins
+func (m *VectorMatching) SignatureString(meta block.SeriesMeta) string {
+	meta.Tags = m.signatureTags(meta.Tags)
+	return meta.SignatureString(m.On, m.MatchName, m.MatchingLabels...)
+}
+
+// signatureTags returns the tags with their values canonicalized as configured for matching
+func (m *VectorMatching) signatureTags(tags models.Tags) models.Tags {
+	if len(m.NormalizeNumericLabels) > 0 {
+		tags = normalizeNumericLabels(tags, m.NormalizeNumericLabels)
+	}
+
+	if m.CaseInsensitive {
+		tags = lowercaseValues(tags)
+	}
+
+	return tags
+}
+
+// lowercaseValues lowercases the values of the tags. Tags are only copied if a value changes
+func lowercaseValues(tags models.Tags) models.Tags {
+	var lowered models.Tags
+	for name, value := range tags {
+		lower := strings.ToLower(value)
+		if lower == value {
+			continue
+		}
+
+		if lowered == nil {
+			lowered = make(models.Tags, len(tags))
+			for k, v := range tags {
+				lowered[k] = v
+			}
+		}
+
+		lowered[name] = lower
+	}
+
+	if lowered == nil {
+		return tags
+	}
+
+	return lowered
+}
+
+// normalizeNumericLabels formats the values of the given labels which parse as numbers in their
+// shortest form. Tags are only copied if a value changes
+func normalizeNumericLabels(tags models.Tags, names []string) models.Tags {
+	var normalized models.Tags
+	for _, name := range names {
+		value, ok := tags[name]
+		if !ok {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		canonical := util.FormatValue(f)
+		if canonical == value {
+			continue
+		}
+
+		if normalized == nil {
+			normalized = make(models.Tags, len(tags))
+			for k, v := range tags {
+				normalized[k] = v
+			}
+		}
+
+		normalized[name] = canonical
+	}
+
+	if normalized == nil {
+		return tags
+	}
+
+	return normalized
+}
+
+// validateSteps ensures both sides of a binary operation have the same steps. Blocks fetched for
+// an offset selector are shifted onto the steps of the query before reaching the operation, so
+// the sides must also start at the same time
+func validateSteps(lIter, rIter block.StepIter) error {
+	lBounds, rBounds := lIter.Meta().Bounds, rIter.Meta().Bounds
+	if !lBounds.Equal(rBounds) {
+		return errors.Wrapf(errMismatchedBounds, "lhs: %v, rhs: %v", lBounds, rBounds)
+	}
+
+	if l, r := lIter.StepCount(), rIter.StepCount(); l != r {
+		return errors.Wrapf(errMismatchedStepCounts, "lhs: %d, rhs: %d", l, r)
+	}
+
+	return nil
+}
+
+// alignBlocks resamples the side with the coarser step size onto the steps of the other side,
+// building the resampled block with the builder. The resampled block is also returned on its own
+// for the caller to close, and is nil when both sides have the same step size and are unchanged
+func alignBlocks(
+	newBuilder block.BuilderFn,
+	lhs, rhs block.Block,
+	method block.ResampleMethod,
+) (block.Block, block.Block, block.Block, error) {
+	lBounds, err := blockBounds(lhs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rBounds, err := blockBounds(rhs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var resampled block.Block
+	switch {
+	case lBounds.StepSize > rBounds.StepSize:
+		resampled, err = block.Resample(newBuilder, lhs, rBounds, method)
+		lhs = resampled
+	case rBounds.StepSize > lBounds.StepSize:
+		resampled, err = block.Resample(newBuilder, rhs, lBounds, method)
+		rhs = resampled
+	}
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return lhs, rhs, resampled, nil
+}
+
+func blockBounds(b block.Block) (block.Bounds, error) {
+	iter, err := b.StepIter()
+	if err != nil {
+		return block.Bounds{}, err
+	}
+
+	defer iter.Close()
+	return iter.Meta().Bounds, nil
+}
+
 // Processor is implemented by each logical transform
 type Processor interface {
 	Process(lhs block.Block, rhs block.Block) (block.Block, error)
@@ -0,0 +1,230 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+const (
+	// EqType keeps lhs datapoints equal to the rhs
+	EqType = "=="
+
+	// NotEqType keeps lhs datapoints not equal to the rhs
+	NotEqType = "!="
+
+	// GreaterType keeps lhs datapoints greater than the rhs
+	GreaterType = ">"
+
+	// LesserType keeps lhs datapoints less than the rhs
+	LesserType = "<"
+
+	// GreaterEqType keeps lhs datapoints greater than or equal to the rhs
+	GreaterEqType = ">="
+
+	// LesserEqType keeps lhs datapoints less than or equal to the rhs
+	LesserEqType = "<="
+)
+
+type comparisonFn func(x, y float64) bool
+
+// comparisonFns use IEEE 754 comparisons, so -0 and +0 are equal. NaNs never reach them
+var comparisonFns = map[string]comparisonFn{
+	EqType:        func(x, y float64) bool { return x == y },
+	NotEqType:     func(x, y float64) bool { return x != y },
+	GreaterType:   func(x, y float64) bool { return x > y },
+	LesserType:    func(x, y float64) bool { return x < y },
+	GreaterEqType: func(x, y float64) bool { return x >= y },
+	LesserEqType:  func(x, y float64) bool { return x <= y },
+}
+
+// NewComparisonOp creates a new comparison operation. By default it filters the lhs to the
+// datapoints which pass, while with returnBool it returns 1 or 0 for each match instead
+func NewComparisonOp(opType string, lNode parser.NodeID, rNode parser.NodeID, matching *VectorMatching, returnBool bool) (BaseOp, error) {
+	fn, ok := comparisonFns[opType]
+	if !ok {
+		return BaseOp{}, fmt.Errorf("unknown comparison type: %s", opType)
+	}
+
+	return BaseOp{
+		OperatorType: opType,
+		LNode:        lNode,
+		RNode:        rNode,
+		Matching:     matching,
+		ReturnBool:   returnBool,
+		ProcessorFn: func(op BaseOp, controller *transform.Controller) Processor {
+			return &ComparisonNode{
+				op:         op,
+				fn:         fn,
+				controller: controller,
+			}
+		},
+	}, nil
+}
+
+// ComparisonNode is a node for comparison operations
+type ComparisonNode struct {
+	op         BaseOp
+	fn         comparisonFn
+	controller *transform.Controller
+}
+
+// Process processes two logical blocks, comparing each one to one match
+func (c *ComparisonNode) Process(lhs, rhs block.Block) (block.Block, error) {
+	lIter, err := lhs.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	rIter, err := rhs.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateSteps(lIter, rIter); err != nil {
+		return nil, err
+	}
+
+	lIndices, rIndices, err := matchPairs(c.op, lIter.SeriesMeta(), rIter.SeriesMeta())
+	if err != nil {
+		return nil, err
+	}
+
+	// Filtered values are still the lhs metric, unlike the 1 or 0 of bool comparisons
+	dropName := c.op.ReturnBool && !c.op.KeepMetricNames
+	seriesMeta, err := resultMetas(c.op, lIter.SeriesMeta(), rIter.SeriesMeta(), lIndices, rIndices, dropName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]float64, len(lIndices))
+	for i := range rows {
+		rows[i] = make([]float64, 0, lIter.StepCount())
+	}
+
+	for lIter.Next() && rIter.Next() {
+		lStep, err := lIter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		rStep, err := rIter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		lValues, rValues := lStep.Values(), rStep.Values()
+		for i, lIdx := range lIndices {
+			lValue, rValue := lValues[lIdx], rValues[rIndices[i]]
+			// Filtering against a scalar keeps the samples of the vector, whichever side it is on
+			sample := lValue
+			if c.op.LScalar && !c.op.RScalar {
+				sample = rValue
+			}
+
+			rows[i] = append(rows[i], compare(c.fn, c.op.ReturnBool, lValue, rValue, sample))
+		}
+	}
+
+	return comparisonBlock(c.controller, lIter.Meta(), seriesMeta, rows, lIter.StepCount(), !c.op.ReturnBool)
+}
+
+// compare returns the output for a pair of datapoints, where sample is the vector value kept
+// when filtering. Any comparison with a NaN fails, even NaN != x, so the datapoint is dropped
+// in both modes as there is no sample to compare
+func compare(fn comparisonFn, returnBool bool, lValue, rValue, sample float64) float64 {
+	if math.IsNaN(lValue) || math.IsNaN(rValue) {
+		return math.NaN()
+	}
+
+	pass := fn(lValue, rValue)
+	if returnBool {
+		if pass {
+			return 1
+		}
+
+		return 0
+	}
+
+	if !pass {
+		return math.NaN()
+	}
+
+	return sample
+}
+
+// comparisonBlock builds the block for the compared rows of each series. When filtering, series
+// which fail at every step have no datapoints left, so they are dropped from the output
+func comparisonBlock(
+	controller *transform.Controller,
+	meta block.Metadata,
+	seriesMeta []block.SeriesMeta,
+	rows [][]float64,
+	steps int,
+	filter bool,
+) (block.Block, error) {
+	if filter {
+		keptRows := make([][]float64, 0, len(rows))
+		keptMeta := make([]block.SeriesMeta, 0, len(seriesMeta))
+		for i, row := range rows {
+			if !allNaN(row) {
+				keptRows = append(keptRows, row)
+				keptMeta = append(keptMeta, seriesMeta[i])
+			}
+		}
+
+		rows, seriesMeta = keptRows, keptMeta
+	}
+
+	builder, err := controller.BlockBuilder(meta, seriesMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return nil, err
+	}
+
+	for index := 0; index < steps; index++ {
+		for _, row := range rows {
+			if err := builder.AppendValue(index, row[index]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+func allNaN(values []float64) bool {
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			return false
+		}
+	}
+
+	return true
+}
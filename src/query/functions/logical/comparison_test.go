@@ -0,0 +1,183 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparisonOps(t *testing.T) {
+	lhs := [][]float64{{1, 2, 3, 4, 5}}
+	rhs := [][]float64{{3, 3, 3, 3, 3}}
+	nan := math.NaN()
+	tests := []struct {
+		opType   string
+		filtered []float64
+		bools    []float64
+	}{
+		{opType: EqType, filtered: []float64{nan, nan, 3, nan, nan}, bools: []float64{0, 0, 1, 0, 0}},
+		{opType: NotEqType, filtered: []float64{1, 2, nan, 4, 5}, bools: []float64{1, 1, 0, 1, 1}},
+		{opType: GreaterType, filtered: []float64{nan, nan, nan, 4, 5}, bools: []float64{0, 0, 0, 1, 1}},
+		{opType: LesserType, filtered: []float64{1, 2, nan, nan, nan}, bools: []float64{1, 1, 0, 0, 0}},
+		{opType: GreaterEqType, filtered: []float64{nan, nan, 3, 4, 5}, bools: []float64{0, 0, 1, 1, 1}},
+		{opType: LesserEqType, filtered: []float64{1, 2, 3, nan, nan}, bools: []float64{1, 1, 1, 0, 0}},
+	}
+
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	for _, tt := range tests {
+		for _, returnBool := range []bool{false, true} {
+			op, err := NewComparisonOp(tt.opType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{}, returnBool)
+			require.NoError(t, err)
+			sink := processArithmetic(t, op, test.NewBlockFromValues(bounds, lhs), test.NewBlockFromValues(bounds, rhs))
+			expected := tt.filtered
+			if returnBool {
+				expected = tt.bools
+			}
+
+			test.EqualsWithNans(t, [][]float64{expected}, sink.Values)
+		}
+	}
+}
+
+func TestComparisonWithZerosAndNaNs(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	lhs := [][]float64{{negZero, math.NaN(), math.NaN(), 1, math.NaN()}}
+	rhs := [][]float64{{0, math.NaN(), 1, math.NaN(), 1}}
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+
+	tests := []struct {
+		opType   string
+		expected []float64
+	}{
+		// -0 == 0 passes while NaN == NaN and NaN != 1 are both dropped
+		{opType: EqType, expected: []float64{negZero, math.NaN(), math.NaN(), math.NaN(), math.NaN()}},
+		{opType: NotEqType},
+		{opType: GreaterEqType, expected: []float64{negZero, math.NaN(), math.NaN(), math.NaN(), math.NaN()}},
+	}
+
+	for _, tt := range tests {
+		op, err := NewComparisonOp(tt.opType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{}, false)
+		require.NoError(t, err)
+		sink := processArithmetic(t, op, test.NewBlockFromValues(bounds, lhs), test.NewBlockFromValues(bounds, rhs))
+		if tt.expected == nil {
+			assert.Empty(t, sink.Values, "series with no passing datapoints are dropped, for %s", tt.opType)
+			continue
+		}
+
+		test.EqualsWithNans(t, [][]float64{tt.expected}, sink.Values)
+	}
+
+	op, err := NewComparisonOp(NotEqType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{}, true)
+	require.NoError(t, err)
+	sink := processArithmetic(t, op, test.NewBlockFromValues(bounds, lhs), test.NewBlockFromValues(bounds, rhs))
+	test.EqualsWithNans(t, [][]float64{{0, math.NaN(), math.NaN(), math.NaN(), math.NaN()}}, sink.Values)
+}
+
+func TestComparisonNames(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, 3, 4, 5}}
+	metas := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "up", "job": "x"}}}
+
+	for _, returnBool := range []bool{false, true} {
+		op, err := NewComparisonOp(GreaterEqType, parser.NodeID(0), parser.NodeID(1), &VectorMatching{}, returnBool)
+		require.NoError(t, err)
+		sink := processArithmetic(t, op,
+			test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values),
+			test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+		require.Len(t, sink.Metas, 1)
+		if returnBool {
+			assert.Equal(t, models.Tags{"job": "x"}, sink.Metas[0].Tags, "bool comparisons drop the name")
+		} else {
+			assert.Equal(t, metas[0].Tags, sink.Metas[0].Tags, "filters keep the name")
+		}
+	}
+}
+
+func TestUnknownComparison(t *testing.T) {
+	_, err := NewComparisonOp("=~", parser.NodeID(0), parser.NodeID(1), &VectorMatching{}, false)
+	assert.Error(t, err)
+}
+
+func processScalarComparison(t *testing.T, op ScalarComparisonOp, b block.Block) *executor.SinkNode {
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	require.NoError(t, op.Node(c).Process(parser.NodeID(0), b))
+	return sink
+}
+
+func TestScalarComparison(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{
+		{1, 6, 3, 8, math.NaN()},
+		{1, 2, 3, 4, 5},
+		{7, 7, 7, 7, 7},
+	}
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "up", "job": "mixed"}},
+		{Tags: models.Tags{models.MetricName: "up", "job": "failing"}},
+		{Tags: models.Tags{models.MetricName: "up", "job": "passing"}},
+	}
+	nan := math.NaN()
+
+	op, err := NewScalarComparisonOp(GreaterType, 5, false, false)
+	require.NoError(t, err)
+	sink := processScalarComparison(t, op, test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+	test.EqualsWithNans(t, [][]float64{{nan, 6, nan, 8, nan}, {7, 7, 7, 7, 7}}, sink.Values)
+	require.Len(t, sink.Metas, 2, "series failing at every step are dropped")
+	assert.Equal(t, metas[0].Tags, sink.Metas[0].Tags)
+	assert.Equal(t, metas[2].Tags, sink.Metas[1].Tags)
+
+	op, err = NewScalarComparisonOp(GreaterType, 5, false, true)
+	require.NoError(t, err)
+	sink = processScalarComparison(t, op, test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+	test.EqualsWithNans(t, [][]float64{{0, 1, 0, 1, nan}, {0, 0, 0, 0, 0}, {1, 1, 1, 1, 1}}, sink.Values)
+	require.Len(t, sink.Metas, 3, "bool comparisons keep every series")
+	assert.Equal(t, models.Tags{"job": "failing"}, sink.Metas[1].Tags)
+}
+
+func TestScalarComparisonWithScalarLeft(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 6, 3, 8, 5}}
+
+	op, err := NewScalarComparisonOp(LesserType, 5, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, "5 < up", op.FormatExpr([]string{"up"}))
+	sink := processScalarComparison(t, op, test.NewBlockFromValues(bounds, values))
+	// Filtering keeps the vector value when the scalar is the lhs
+	test.EqualsWithNans(t, [][]float64{{math.NaN(), 6, math.NaN(), 8, math.NaN()}}, sink.Values)
+
+	op, err = NewScalarComparisonOp(LesserType, 5, true, true)
+	require.NoError(t, err)
+	sink = processScalarComparison(t, op, test.NewBlockFromValues(bounds, values))
+	test.EqualsWithNans(t, [][]float64{{0, 1, 0, 1, 0}}, sink.Values)
+
+	_, err = NewScalarComparisonOp("=~", 5, true, true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/util"
+)
+
+const (
+	// InfoType copies labels from a companion info metric, e.g. node_info, onto the lhs series it
+	// matches. Values are carried over from the lhs unchanged
+	InfoType = "info"
+)
+
+// defaultInfoLabels identify the target an info metric describes when no matching is given
+var defaultInfoLabels = []string{"instance", "job"}
+
+// InfoParams configures which labels are copied from info series
+type InfoParams struct {
+	// Labels are the labels copied from the matching info series. When empty, every label of the
+	// info series except its metric name and matching labels is copied
+	Labels []string
+}
+
+// NewInfoOp creates a new info op which enriches the lhs series with the labels of the rhs info
+// series they match, matching on instance and job by default
+func NewInfoOp(lNode parser.NodeID, rNode parser.NodeID, matching *VectorMatching, params InfoParams) BaseOp {
+	if matching == nil {
+		matching = &VectorMatching{On: true, MatchingLabels: defaultInfoLabels}
+	}
+
+	return BaseOp{
+		OperatorType: InfoType,
+		LNode:        lNode,
+		RNode:        rNode,
+		Matching:     matching,
+		ProcessorFn: func(op BaseOp, controller *transform.Controller) Processor {
+			return &InfoNode{
+				op:         op,
+				params:     params,
+				controller: controller,
+			}
+		},
+	}
+}
+
+// InfoNode is a node for the info op
+type InfoNode struct {
+	op         BaseOp
+	params     InfoParams
+	controller *transform.Controller
+}
+
+// Process copies the labels of the matching info series onto each lhs series. Series without a
+// matching info series are kept as they are
+func (c *InfoNode) Process(lhs, rhs block.Block) (block.Block, error) {
+	lIter, err := lhs.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	rIter, err := rhs.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the labels of the info series are used, so its steps do not need to line up
+	idFunction := c.op.Matching.signatureFunc()
+	infoSigs, err := uniqueSignatures(c.op.Matching, rIter.SeriesMeta(), "right")
+	if err != nil {
+		return nil, err
+	}
+
+	infoMetas := rIter.SeriesMeta()
+	metas := make([]block.SeriesMeta, len(lIter.SeriesMeta()))
+	for idx, meta := range lIter.SeriesMeta() {
+		metas[idx] = meta
+		if rIdx, ok := infoSigs[idFunction(meta.Tags)]; ok {
+			metas[idx].Tags = c.copyLabels(meta.Tags, infoMetas[rIdx].Tags)
+		}
+	}
+
+	builder, err := c.controller.BlockBuilder(lIter.Meta(), metas)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := builder.AddCols(lIter.StepCount()); err != nil {
+		return nil, err
+	}
+
+	for index := 0; lIter.Next(); index++ {
+		step, err := lIter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, value := range step.Values() {
+			if err := builder.AppendValue(index, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// copyLabels returns the series tags with the labels of the info series added. Labels the series
+// already has are not overwritten
+func (c *InfoNode) copyLabels(tags, info models.Tags) models.Tags {
+	copied := make(models.Tags, len(tags)+len(info))
+	for k, v := range tags {
+		copied[k] = v
+	}
+
+	add := func(name string) {
+		if _, ok := copied[name]; ok {
+			return
+		}
+
+		if value, ok := info[name]; ok {
+			copied[name] = value
+		}
+	}
+
+	if len(c.params.Labels) > 0 {
+		for _, name := range c.params.Labels {
+			add(name)
+		}
+
+		return copied
+	}
+
+	for name := range info {
+		if name == models.MetricName || (c.op.Matching.On && util.ContainsString(c.op.Matching.MatchingLabels, name)) {
+			continue
+		}
+
+		add(name)
+	}
+
+	return copied
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfo(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	cpuMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "node_cpu", "job": "node", "instance": "a", "cpu": "0"}},
+		{Tags: models.Tags{models.MetricName: "node_cpu", "job": "node", "instance": "a", "cpu": "1"}},
+		{Tags: models.Tags{models.MetricName: "node_cpu", "job": "node", "instance": "b", "cpu": "0"}},
+		{Tags: models.Tags{models.MetricName: "node_cpu", "job": "node", "instance": "c", "cpu": "0"}},
+	}
+	cpu := [][]float64{
+		{1, 2, 3, 4, 5},
+		{2, 3, 4, 5, 6},
+		{3, 4, 5, 6, 7},
+		{4, 5, 6, 7, 8},
+	}
+
+	// Instance c has no info series
+	infoMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "node_info", "job": "node", "instance": "a",
+			"version": "1.2", "os": "linux", "cpu": "all"}},
+		{Tags: models.Tags{models.MetricName: "node_info", "job": "node", "instance": "b",
+			"version": "1.3", "os": "darwin"}},
+	}
+	info := [][]float64{{1, 1, 1, 1, 1}, {1, 1, 1, 1, 1}}
+
+	tests := []struct {
+		name     string
+		matching *VectorMatching
+		params   InfoParams
+		expected []models.Tags
+	}{
+		{
+			name: "all labels",
+			expected: []models.Tags{
+				{models.MetricName: "node_cpu", "job": "node", "instance": "a", "cpu": "0", "version": "1.2", "os": "linux"},
+				{models.MetricName: "node_cpu", "job": "node", "instance": "a", "cpu": "1", "version": "1.2", "os": "linux"},
+				{models.MetricName: "node_cpu", "job": "node", "instance": "b", "cpu": "0", "version": "1.3", "os": "darwin"},
+				{models.MetricName: "node_cpu", "job": "node", "instance": "c", "cpu": "0"},
+			},
+		},
+		{
+			name:     "selected labels on instance",
+			matching: &VectorMatching{On: true, MatchingLabels: []string{"instance"}},
+			params:   InfoParams{Labels: []string{"version", "missing"}},
+			expected: []models.Tags{
+				{models.MetricName: "node_cpu", "job": "node", "instance": "a", "cpu": "0", "version": "1.2"},
+				{models.MetricName: "node_cpu", "job": "node", "instance": "a", "cpu": "1", "version": "1.2"},
+				{models.MetricName: "node_cpu", "job": "node", "instance": "b", "cpu": "0", "version": "1.3"},
+				{models.MetricName: "node_cpu", "job": "node", "instance": "c", "cpu": "0"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		op := NewInfoOp(parser.NodeID(0), parser.NodeID(1), tt.matching, tt.params)
+		sink := processArithmetic(t, op,
+			test.NewBlockFromValuesWithSeriesMeta(bounds, cpuMetas, cpu),
+			test.NewBlockFromValuesWithSeriesMeta(bounds, infoMetas, info))
+		assert.Equal(t, cpu, sink.Values, tt.name)
+		require.Len(t, sink.Metas, len(tt.expected), tt.name)
+		for i, meta := range sink.Metas {
+			assert.Equal(t, tt.expected[i], meta.Tags, tt.name)
+		}
+	}
+}
+
+func TestInfoWithDuplicateInfoSeries(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 1, 1, 1, 1}, {1, 1, 1, 1, 1}}
+	lhsMetas := []block.SeriesMeta{{Tags: models.Tags{"job": "node", "instance": "a"}}}
+	infoMetas := []block.SeriesMeta{
+		{Tags: models.Tags{"job": "node", "instance": "a", "version": "1"}},
+		{Tags: models.Tags{"job": "node", "instance": "a", "version": "2"}},
+	}
+
+	op := NewInfoOp(parser.NodeID(0), parser.NodeID(1), nil, InfoParams{})
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	require.NoError(t, node.Process(parser.NodeID(1), test.NewBlockFromValuesWithSeriesMeta(bounds, infoMetas, values)))
+	err := node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, lhsMetas, values[:1]))
+	assert.Error(t, err)
+}
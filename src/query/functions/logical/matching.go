@@ -0,0 +1,224 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+)
+
+// matchPairs returns the indices of each matching pair of lhs and rhs series. With group_left
+// many lhs series can match the same rhs series, and with group_right many rhs series can match
+// the same lhs series, but the series on the other side must be unique for each match group
+func matchPairs(op BaseOp, lhs, rhs []block.SeriesMeta) ([]int, []int, error) {
+	if op.LScalar || op.RScalar {
+		return matchScalars(op, lhs, rhs)
+	}
+
+	matching := op.Matching
+	if matching == nil {
+		matching = &VectorMatching{}
+	}
+
+	switch matching.Card {
+	case CardOneToOne:
+		return matchOneToOne(matching, lhs, rhs)
+	case CardManyToOne:
+		return matchManyToOne(matching, lhs, rhs, "right")
+	case CardOneToMany:
+		rIndices, lIndices, err := matchManyToOne(matching, rhs, lhs, "left")
+		return lIndices, rIndices, err
+	default:
+		return nil, nil, fmt.Errorf("many to many matching is not supported for %s", op.OperatorType)
+	}
+}
+
+// matchScalars pairs the single series of a scalar side with each series of the other side
+func matchScalars(op BaseOp, lhs, rhs []block.SeriesMeta) ([]int, []int, error) {
+	if (op.LScalar && len(lhs) != 1) || (op.RScalar && len(rhs) != 1) {
+		return nil, nil, fmt.Errorf("expected a single series for the scalars of %s, found %d and %d series",
+			op.OperatorType, len(lhs), len(rhs))
+	}
+
+	vector := len(lhs)
+	if op.LScalar {
+		vector = len(rhs)
+	}
+
+	lIndices, rIndices := make([]int, vector), make([]int, vector)
+	for i := 0; i < vector; i++ {
+		if !op.LScalar {
+			lIndices[i] = i
+		}
+
+		if !op.RScalar {
+			rIndices[i] = i
+		}
+	}
+
+	return lIndices, rIndices, nil
+}
+
+// uniqueSignatures returns the index of each series by its signature, failing on duplicates
+func uniqueSignatures(matching *VectorMatching, metas []block.SeriesMeta, side string) (map[uint64]int, error) {
+	idFunction := matching.signatureFunc()
+	sigs := make(map[uint64]int, len(metas))
+	for idx, meta := range metas {
+		id := idFunction(meta.Tags)
+		if _, ok := sigs[id]; ok {
+			return nil, duplicateSeriesError(matching, meta, side)
+		}
+
+		sigs[id] = idx
+	}
+
+	return sigs, nil
+}
+
+// duplicateSeriesError describes a series whose match group already has a series on its side,
+// e.g. for on() which puts every series in the same match group
+func duplicateSeriesError(matching *VectorMatching, meta block.SeriesMeta, side string) error {
+	return fmt.Errorf(
+		"found duplicate series for the match group %s on the %s hand side: %s, matching labels must be "+
+			"unique on one side, or use group_left or group_right",
+		matching.SignatureString(meta), side, meta.Tags.ID())
+}
+
+func matchOneToOne(matching *VectorMatching, lhs, rhs []block.SeriesMeta) ([]int, []int, error) {
+	rightSigs, err := uniqueSignatures(matching, rhs, "right")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idFunction := matching.signatureFunc()
+	var (
+		lIndices, rIndices []int
+		matched            = make(map[uint64]struct{}, len(lhs))
+	)
+
+	for idx, meta := range lhs {
+		id := idFunction(meta.Tags)
+		rIdx, ok := rightSigs[id]
+		if !ok {
+			continue
+		}
+
+		if _, ok := matched[id]; ok {
+			return nil, nil, duplicateSeriesError(matching, meta, "left")
+		}
+
+		matched[id] = struct{}{}
+		lIndices = append(lIndices, idx)
+		rIndices = append(rIndices, rIdx)
+	}
+
+	return lIndices, rIndices, nil
+}
+
+// matchManyToOne pairs each of the many series with the unique series of the one side it matches
+func matchManyToOne(matching *VectorMatching, many, one []block.SeriesMeta, oneSide string) ([]int, []int, error) {
+	oneSigs, err := uniqueSignatures(matching, one, oneSide)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idFunction := matching.signatureFunc()
+	var manyIndices, oneIndices []int
+	for idx, meta := range many {
+		if oneIdx, ok := oneSigs[idFunction(meta.Tags)]; ok {
+			manyIndices = append(manyIndices, idx)
+			oneIndices = append(oneIndices, oneIdx)
+		}
+	}
+
+	return manyIndices, oneIndices, nil
+}
+
+// resultMetas returns the metadata for each matched pair. The tags come from the lhs, or the rhs
+// for group_right, with the include labels copied from the other side. Labels are only copied
+// when there are include labels, so an empty include list just allows the many side
+func resultMetas(
+	op BaseOp,
+	lhs, rhs []block.SeriesMeta,
+	lIndices, rIndices []int,
+	dropName bool,
+) ([]block.SeriesMeta, error) {
+	matching := op.Matching
+	if matching == nil {
+		matching = &VectorMatching{}
+	}
+
+	metas := make([]block.SeriesMeta, len(lIndices))
+	ids := make(map[string]struct{}, len(lIndices))
+	for i, lIdx := range lIndices {
+		tags, other := lhs[lIdx].Tags, rhs[rIndices[i]].Tags
+		// The result of a scalar and a vector takes the labels of the vector
+		if matching.Card == CardOneToMany || (op.LScalar && !op.RScalar) {
+			tags, other = other, tags
+		}
+
+		if matching.Card != CardOneToOne && len(matching.Include) > 0 {
+			tags = includeLabels(tags, other, matching.Include)
+		}
+
+		if dropName {
+			tags = tags.WithoutName()
+		}
+
+		id := tags.ID()
+		if matching.Card != CardOneToOne {
+			// Many series could otherwise end up with the same labels
+			if _, ok := ids[id]; ok {
+				return nil, fmt.Errorf("multiple matches for labels %s, the grouping labels must ensure unique matches", id)
+			}
+
+			ids[id] = struct{}{}
+		}
+
+		metas[i] = block.SeriesMeta{
+			Tags: tags,
+			Name: id,
+		}
+	}
+
+	return metas, nil
+}
+
+// includeLabels returns a copy of the tags with the include labels set from the other tags,
+// removing those which the other tags do not have
+func includeLabels(tags, other models.Tags, include []string) models.Tags {
+	updated := make(models.Tags, len(tags)+len(include))
+	for k, v := range tags {
+		updated[k] = v
+	}
+
+	for _, name := range include {
+		if v, ok := other[name]; ok {
+			updated[name] = v
+		} else {
+			delete(updated, name)
+		}
+	}
+
+	return updated
+}
@@ -22,6 +22,7 @@ package logical
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/m3db/m3/src/query/block"
@@ -36,7 +37,25 @@ type BaseOp struct {
 	RNode        parser.NodeID
 	Matching     *VectorMatching
 	ReturnBool   bool
-	ProcessorFn  MakeProcessor
+	// SkipNaNs opts out of NaN propagation, so that the output at a step where one side is NaN is
+	// the other side. By default it is only set for elem_max and elem_min
+	SkipNaNs bool
+	// NaNStrict omits the steps at which either side of any matched pair is NaN, so that every
+	// output series is NaN at those steps rather than only the series the NaN was matched into,
+	// e.g. for NaN free graphs. The output keeps the steps of the inputs, and it takes precedence
+	// over SkipNaNs
+	NaNStrict bool
+	// KeepMetricNames preserves the metric name of the lhs in the output of arithmetic
+	KeepMetricNames bool
+	// Resample aligns sides with different step sizes by resampling the coarser side onto the
+	// steps of the finer side. By default, both sides must have the same steps
+	Resample block.ResampleMethod
+	// LScalar and RScalar are set for sides which are scalars, e.g. time(), whose single value at
+	// each step is combined with every series of the other side without matching series. Between
+	// two scalars the result is a scalar
+	LScalar     bool
+	RScalar     bool
+	ProcessorFn MakeProcessor
 }
 
 // OpType for the operator
@@ -49,6 +68,50 @@ func (o BaseOp) String() string {
 	return fmt.Sprintf("type: %s, lnode: %s, rnode: %s", o.OpType(), o.LNode, o.RNode)
 }
 
+// FormatExpr renders the binary expression along with its matching clauses
+func (o BaseOp) FormatExpr(inputs []string) string {
+	if len(inputs) != 2 {
+		return fmt.Sprintf("%s(%s)", o.OperatorType, strings.Join(inputs, ", "))
+	}
+
+	op := o.OperatorType
+	if o.ReturnBool {
+		op += " bool"
+	}
+
+	if matching := o.Matching.format(); matching != "" {
+		op += " " + matching
+	}
+
+	expr := fmt.Sprintf("%s %s %s", inputs[0], op, inputs[1])
+	if o.KeepMetricNames {
+		return parser.FormatKeepMetricNames("("+expr+")", true)
+	}
+
+	return expr
+}
+
+// Precedence returns the precedence of the operator. Expressions keeping their metric names are
+// already parenthesized
+func (o BaseOp) Precedence() int {
+	if o.KeepMetricNames {
+		return parser.MaxPrecedence
+	}
+
+	return parser.BinaryPrecedence(o.OperatorType)
+}
+
+// OperandPrecedence returns the lowest precedence of the lhs or rhs which needs no parentheses
+func (o BaseOp) OperandPrecedence(input int) int {
+	return parser.OperandPrecedence(o.OperatorType, input == 1)
+}
+
+// WithKeepMetricNames returns the op keeping the metric names of the lhs
+func (o BaseOp) WithKeepMetricNames() parser.Params {
+	o.KeepMetricNames = true
+	return o
+}
+
 // Node creates an execution node
 func (o BaseOp) Node(controller *transform.Controller) transform.OpNode {
 	return &BaseNode{
@@ -83,9 +146,22 @@ func (c *BaseNode) Process(ID parser.NodeID, b block.Block) error {
 	}
 
 	c.cleanup()
+	if c.op.Resample != block.ResampleNone {
+		var resampled block.Block
+		lhs, rhs, resampled, err = alignBlocks(c.controller.BlockBuilder, lhs, rhs, c.op.Resample)
+		if err != nil {
+			return transform.NewError(c.op.OperatorType, err, c.op.LNode, c.op.RNode)
+		}
+
+		// The inputs are closed by their senders, but the resampled block belongs to the node
+		if resampled != nil {
+			defer resampled.Close()
+		}
+	}
+
 	nextBlock, err := c.processor.Process(lhs, rhs)
 	if err != nil {
-		return err
+		return transform.NewError(c.op.OperatorType, err, c.op.LNode, c.op.RNode)
 	}
 
 	defer nextBlock.Close()
@@ -0,0 +1,178 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/util"
+)
+
+// ScalarArithmeticOp applies an arithmetic operation between each datapoint of a vector and a
+// scalar, e.g. rate(x[5m]) * 60
+type ScalarArithmeticOp struct {
+	OperatorType string
+	Scalar       float64
+	// ScalarLeft is set when the scalar is the lhs of the operation, e.g. 1 - up
+	ScalarLeft bool
+	// KeepMetricNames preserves the metric names of the vector, which are otherwise dropped
+	KeepMetricNames bool
+	fn              arithmeticFn
+}
+
+// NewScalarArithmeticOp creates a new arithmetic operation between a vector and a scalar
+func NewScalarArithmeticOp(opType string, scalar float64, scalarLeft bool) (ScalarArithmeticOp, error) {
+	fn, ok := arithmeticFns[opType]
+	if !ok {
+		return ScalarArithmeticOp{}, fmt.Errorf("unknown arithmetic type: %s", opType)
+	}
+
+	return ScalarArithmeticOp{
+		OperatorType: opType,
+		Scalar:       scalar,
+		ScalarLeft:   scalarLeft,
+		fn:           fn,
+	}, nil
+}
+
+// ApplyScalarArithmetic applies the arithmetic operation to two scalars, e.g. to fold constant
+// expressions such as 60 * 60 before a query runs
+func ApplyScalarArithmetic(opType string, lhs, rhs float64) (float64, error) {
+	fn, ok := arithmeticFns[opType]
+	if !ok {
+		return 0, fmt.Errorf("unknown arithmetic type: %s", opType)
+	}
+
+	return fn(lhs, rhs), nil
+}
+
+// OpType for the operator
+func (o ScalarArithmeticOp) OpType() string {
+	return o.OperatorType
+}
+
+// String representation
+func (o ScalarArithmeticOp) String() string {
+	return fmt.Sprintf("type: %s, scalar: %v", o.OpType(), o.Scalar)
+}
+
+// FormatExpr renders the operation with the scalar on its side of the vector
+func (o ScalarArithmeticOp) FormatExpr(inputs []string) string {
+	vector, scalar := inputs[0], util.FormatValue(o.Scalar)
+	expr := fmt.Sprintf("%s %s %s", vector, o.OperatorType, scalar)
+	if o.ScalarLeft {
+		expr = fmt.Sprintf("%s %s %s", scalar, o.OperatorType, vector)
+	}
+
+	if o.KeepMetricNames {
+		return parser.FormatKeepMetricNames("("+expr+")", true)
+	}
+
+	return expr
+}
+
+// Precedence returns the precedence of the operator. Expressions keeping their metric names are
+// already parenthesized
+func (o ScalarArithmeticOp) Precedence() int {
+	if o.KeepMetricNames {
+		return parser.MaxPrecedence
+	}
+
+	return parser.BinaryPrecedence(o.OperatorType)
+}
+
+// OperandPrecedence returns the lowest precedence of the vector which needs no parentheses
+func (o ScalarArithmeticOp) OperandPrecedence(int) int {
+	return parser.OperandPrecedence(o.OperatorType, o.ScalarLeft)
+}
+
+// WithKeepMetricNames returns the op keeping the metric names of the vector
+func (o ScalarArithmeticOp) WithKeepMetricNames() parser.Params {
+	o.KeepMetricNames = true
+	return o
+}
+
+// Node creates an execution node
+func (o ScalarArithmeticOp) Node(controller *transform.Controller) transform.OpNode {
+	return &scalarArithmeticNode{op: o, controller: controller}
+}
+
+type scalarArithmeticNode struct {
+	op         ScalarArithmeticOp
+	controller *transform.Controller
+}
+
+// Process applies the operation to each datapoint of the block, dropping the metric names unless
+// they are kept since the results are no longer those metrics
+func (c *scalarArithmeticNode) Process(ID parser.NodeID, b block.Block) error {
+	iter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+	seriesMeta := iter.SeriesMeta()
+	if !c.op.KeepMetricNames {
+		seriesMeta = utils.DropMetricNames(seriesMeta)
+	}
+
+	builder, err := c.controller.BlockBuilder(iter.Meta(), seriesMeta)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(iter.StepCount()); err != nil {
+		return err
+	}
+
+	for index := 0; iter.Next(); index++ {
+		step, err := iter.Current()
+		if err != nil {
+			return err
+		}
+
+		for _, value := range step.Values() {
+			// Not all operations propagate NaNs, e.g. NaN ^ 0 is 1
+			result := math.NaN()
+			if !math.IsNaN(value) {
+				l, r := value, c.op.Scalar
+				if c.op.ScalarLeft {
+					l, r = r, l
+				}
+
+				result = c.op.fn(l, r)
+			}
+
+			if err := builder.AppendValue(index, result); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return c.controller.Process(nextBlock)
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/util"
+)
+
+// ScalarComparisonOp compares each datapoint of a vector with a scalar, e.g. up > 5
+type ScalarComparisonOp struct {
+	OperatorType string
+	Scalar       float64
+	// ScalarLeft is set when the scalar is the lhs of the comparison, e.g. 5 < up
+	ScalarLeft bool
+	ReturnBool bool
+	// KeepMetricNames preserves the metric names of the vector, which are otherwise dropped
+	// when returning bools
+	KeepMetricNames bool
+	fn              comparisonFn
+}
+
+// NewScalarComparisonOp creates a new comparison between a vector and a scalar. By default it
+// filters the vector to the datapoints which pass, while with returnBool it returns 1 or 0 instead
+func NewScalarComparisonOp(opType string, scalar float64, scalarLeft, returnBool bool) (ScalarComparisonOp, error) {
+	fn, ok := comparisonFns[opType]
+	if !ok {
+		return ScalarComparisonOp{}, fmt.Errorf("unknown comparison type: %s", opType)
+	}
+
+	return ScalarComparisonOp{
+		OperatorType: opType,
+		Scalar:       scalar,
+		ScalarLeft:   scalarLeft,
+		ReturnBool:   returnBool,
+		fn:           fn,
+	}, nil
+}
+
+// OpType for the operator
+func (o ScalarComparisonOp) OpType() string {
+	return o.OperatorType
+}
+
+// String representation
+func (o ScalarComparisonOp) String() string {
+	return fmt.Sprintf("type: %s, scalar: %v", o.OpType(), o.Scalar)
+}
+
+// FormatExpr renders the comparison with the scalar on its side of the vector
+func (o ScalarComparisonOp) FormatExpr(inputs []string) string {
+	op := o.OperatorType
+	if o.ReturnBool {
+		op += " bool"
+	}
+
+	vector, scalar := inputs[0], util.FormatValue(o.Scalar)
+	expr := fmt.Sprintf("%s %s %s", vector, op, scalar)
+	if o.ScalarLeft {
+		expr = fmt.Sprintf("%s %s %s", scalar, op, vector)
+	}
+
+	if o.KeepMetricNames {
+		return parser.FormatKeepMetricNames("("+expr+")", true)
+	}
+
+	return expr
+}
+
+// Precedence returns the precedence of the operator. Expressions keeping their metric names are
+// already parenthesized
+func (o ScalarComparisonOp) Precedence() int {
+	if o.KeepMetricNames {
+		return parser.MaxPrecedence
+	}
+
+	return parser.BinaryPrecedence(o.OperatorType)
+}
+
+// OperandPrecedence returns the lowest precedence of the vector which needs no parentheses
+func (o ScalarComparisonOp) OperandPrecedence(int) int {
+	return parser.OperandPrecedence(o.OperatorType, o.ScalarLeft)
+}
+
+// WithKeepMetricNames returns the op keeping the metric names of the vector
+func (o ScalarComparisonOp) WithKeepMetricNames() parser.Params {
+	o.KeepMetricNames = true
+	return o
+}
+
+// Node creates an execution node
+func (o ScalarComparisonOp) Node(controller *transform.Controller) transform.OpNode {
+	return &scalarComparisonNode{op: o, controller: controller}
+}
+
+type scalarComparisonNode struct {
+	op         ScalarComparisonOp
+	controller *transform.Controller
+}
+
+// Process compares each datapoint of the block with the scalar
+func (c *scalarComparisonNode) Process(ID parser.NodeID, b block.Block) error {
+	iter, err := b.SeriesIter()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+	meta := iter.Meta()
+	seriesMeta := iter.SeriesMeta()
+	if c.op.ReturnBool && !c.op.KeepMetricNames {
+		seriesMeta = utils.DropMetricNames(seriesMeta)
+	}
+
+	steps := meta.Bounds.Steps()
+	rows := make([][]float64, 0, iter.SeriesCount())
+	for iter.Next() {
+		series, err := iter.Current()
+		if err != nil {
+			return err
+		}
+
+		values := series.Values()
+		row := make([]float64, len(values))
+		for i, value := range values {
+			l, r := value, c.op.Scalar
+			if c.op.ScalarLeft {
+				l, r = r, l
+			}
+
+			row[i] = compare(c.op.fn, c.op.ReturnBool, l, r, value)
+		}
+
+		steps = len(row)
+		rows = append(rows, row)
+	}
+
+	nextBlock, err := comparisonBlock(c.controller, meta, seriesMeta, rows, steps, !c.op.ReturnBool)
+	if err != nil {
+		return err
+	}
+
+	defer nextBlock.Close()
+	return c.controller.Process(nextBlock)
+}
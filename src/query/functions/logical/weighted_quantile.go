@@ -0,0 +1,190 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/internal/quantile"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+const (
+	// WeightedQuantileType calculates the φ-quantile (0 ≤ φ ≤ 1) over groups of the lhs, with each
+	// lhs element weighted by its matching rhs element, e.g. latencies weighted by request counts
+	WeightedQuantileType = "weighted_quantile"
+)
+
+// WeightedQuantileParams configures a weighted quantile
+type WeightedQuantileParams struct {
+	// Quantile is the φ-quantile to calculate
+	Quantile float64
+	// MatchingTags and Without group the lhs series as for aggregations
+	MatchingTags []string
+	Without      bool
+	// DropUnweighted drops lhs elements without a matching weight, rather than weighting them 1
+	DropUnweighted bool
+}
+
+// NewWeightedQuantileOp creates a new weighted quantile of the values of the lhs, weighted by the
+// rhs series they match
+func NewWeightedQuantileOp(lNode parser.NodeID, rNode parser.NodeID, matching *VectorMatching, params WeightedQuantileParams) BaseOp {
+	return BaseOp{
+		OperatorType: WeightedQuantileType,
+		LNode:        lNode,
+		RNode:        rNode,
+		Matching:     matching,
+		ProcessorFn: func(op BaseOp, controller *transform.Controller) Processor {
+			return &WeightedQuantileNode{
+				op:         op,
+				params:     params,
+				controller: controller,
+			}
+		},
+	}
+}
+
+// WeightedQuantileNode is a node for weighted quantiles
+type WeightedQuantileNode struct {
+	op         BaseOp
+	params     WeightedQuantileParams
+	controller *transform.Controller
+}
+
+// Process groups the lhs series and calculates the weighted quantile of each group at each step
+func (c *WeightedQuantileNode) Process(lhs, rhs block.Block) (block.Block, error) {
+	lIter, err := lhs.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	rIter, err := rhs.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateSteps(lIter, rIter); err != nil {
+		return nil, err
+	}
+
+	weights, err := c.weightIndices(lIter.SeriesMeta(), rIter.SeriesMeta())
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, metas := utils.GroupSeries(c.params.MatchingTags, c.params.Without, WeightedQuantileType, lIter.SeriesMeta())
+	builder, err := c.controller.BlockBuilder(lIter.Meta(), metas)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := builder.AddCols(lIter.StepCount()); err != nil {
+		return nil, err
+	}
+
+	var samples []quantile.Sample
+	for index := 0; lIter.Next() && rIter.Next(); index++ {
+		lStep, err := lIter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		rStep, err := rIter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		lValues, rValues := lStep.Values(), rStep.Values()
+		for _, bucket := range buckets {
+			samples = samples[:0]
+			for _, idx := range bucket {
+				value := lValues[idx]
+				if math.IsNaN(value) {
+					continue
+				}
+
+				weight, ok := c.weight(rValues, weights[idx])
+				if !ok {
+					continue
+				}
+
+				samples = append(samples, quantile.Sample{Value: value, Weight: weight})
+			}
+
+			if err := builder.AppendValue(index, quantile.Weighted(samples, c.params.Quantile)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// weight returns the weight of an lhs element from the rhs index it matches, which is -1 for
+// unmatched elements, and false if the element should be dropped
+func (c *WeightedQuantileNode) weight(rValues []float64, rIdx int) (float64, bool) {
+	if rIdx >= 0 {
+		if weight := rValues[rIdx]; !math.IsNaN(weight) {
+			return weight, true
+		}
+	}
+
+	if c.params.DropUnweighted {
+		return 0, false
+	}
+
+	return 1, true
+}
+
+// weightIndices returns the index of the rhs series matching each lhs series, or -1 if none do
+func (c *WeightedQuantileNode) weightIndices(lhs, rhs []block.SeriesMeta) ([]int, error) {
+	matching := c.op.Matching
+	if matching == nil {
+		matching = &VectorMatching{}
+	}
+
+	idFunction := matching.signatureFunc()
+	rightSigs := make(map[uint64]int, len(rhs))
+	for idx, meta := range rhs {
+		id := idFunction(meta.Tags)
+		if _, ok := rightSigs[id]; ok {
+			return nil, fmt.Errorf("found duplicate weights for the match group on the right hand side: %s", meta.Tags.ID())
+		}
+
+		rightSigs[id] = idx
+	}
+
+	indices := make([]int, len(lhs))
+	for idx, meta := range lhs {
+		rIdx, ok := rightSigs[idFunction(meta.Tags)]
+		if !ok {
+			rIdx = -1
+		}
+
+		indices[idx] = rIdx
+	}
+
+	return indices, nil
+}
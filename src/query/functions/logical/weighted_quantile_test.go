@@ -0,0 +1,120 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logical
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedQuantile(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	latencyMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "latency", "job": "x", "instance": "a"}},
+		{Tags: models.Tags{models.MetricName: "latency", "job": "x", "instance": "b"}},
+		{Tags: models.Tags{models.MetricName: "latency", "job": "x", "instance": "c"}},
+		{Tags: models.Tags{models.MetricName: "latency", "job": "x", "instance": "d"}},
+		{Tags: models.Tags{models.MetricName: "latency", "job": "y", "instance": "a"}},
+	}
+	latencies := [][]float64{
+		{1, 1, 1, 1, 1},
+		{2, 2, 2, 2, 2},
+		{3, 3, 3, 3, 3},
+		{10, 10, 10, 10, 10},
+		{5, 5, 5, 5, math.NaN()},
+	}
+
+	// The instance d latencies have no weight series
+	countMetas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "requests", "job": "x", "instance": "a"}},
+		{Tags: models.Tags{models.MetricName: "requests", "job": "x", "instance": "b"}},
+		{Tags: models.Tags{models.MetricName: "requests", "job": "x", "instance": "c"}},
+		{Tags: models.Tags{models.MetricName: "requests", "job": "y", "instance": "a"}},
+	}
+	counts := [][]float64{
+		{1, 1, 1, 1, 1},
+		{6, 6, 6, 0, 6},
+		{3, 3, 3, 3, 3},
+		{2, math.NaN(), 0, 2, 2},
+	}
+
+	tests := []struct {
+		name     string
+		params   WeightedQuantileParams
+		expected [][]float64
+	}{
+		{
+			name:   "median",
+			params: WeightedQuantileParams{Quantile: 0.5, MatchingTags: []string{"job"}},
+			// Instance b has no weight at the fourth step, so the median moves up to instance c
+			expected: [][]float64{{2, 2, 2, 3, 2}, {5, 5, math.NaN(), 5, math.NaN()}},
+		},
+		{
+			name:     "unmatched weighted 1",
+			params:   WeightedQuantileParams{Quantile: 0.95, MatchingTags: []string{"job"}},
+			expected: [][]float64{{10, 10, 10, 10, 10}, {5, 5, math.NaN(), 5, math.NaN()}},
+		},
+		{
+			name:     "unmatched dropped",
+			params:   WeightedQuantileParams{Quantile: 0.95, MatchingTags: []string{"job"}, DropUnweighted: true},
+			expected: [][]float64{{3, 3, 3, 3, 3}, {5, math.NaN(), math.NaN(), 5, math.NaN()}},
+		},
+	}
+
+	for _, tt := range tests {
+		op := NewWeightedQuantileOp(parser.NodeID(0), parser.NodeID(1), &VectorMatching{}, tt.params)
+		sink := processArithmetic(t, op,
+			test.NewBlockFromValuesWithSeriesMeta(bounds, latencyMetas, latencies),
+			test.NewBlockFromValuesWithSeriesMeta(bounds, countMetas, counts))
+		test.EqualsWithNans(t, tt.expected, sink.Values)
+		require.Len(t, sink.Metas, 2, tt.name)
+		assert.Equal(t, models.Tags{"job": "x"}, sink.Metas[0].Tags, tt.name)
+		assert.Equal(t, models.Tags{"job": "y"}, sink.Metas[1].Tags, tt.name)
+	}
+}
+
+func TestWeightedQuantileWithDuplicateWeights(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	values := [][]float64{{1, 2, 3, 4, 5}, {1, 2, 3, 4, 5}}
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"job": "x", "instance": "a"}},
+		{Tags: models.Tags{"job": "x", "instance": "b"}},
+	}
+
+	op := NewWeightedQuantileOp(parser.NodeID(0), parser.NodeID(1), &VectorMatching{
+		On:             true,
+		MatchingLabels: []string{"job"},
+	}, WeightedQuantileParams{Quantile: 0.5})
+	c, _ := executor.NewControllerWithSink(parser.NodeID(2))
+	node := op.Node(c)
+	require.NoError(t, node.Process(parser.NodeID(1), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)))
+	err := node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+	assert.Error(t, err)
+}
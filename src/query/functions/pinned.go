@@ -0,0 +1,137 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"math"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+// pin moves a block fetched at the instant of a selector pinned by the @ modifier onto the bounds
+// of the query, taking ownership of it. The values of instant selectors are repeated at every
+// step, while range selectors keep the window fetched at the instant for range functions to
+// evaluate once
+func (n *FetchNode) pin(b block.Block, bounds block.Bounds) (block.Block, error) {
+	if n.op.Range > 0 {
+		return &pinnedBlock{Block: b, bounds: bounds}, nil
+	}
+
+	defer b.Close()
+	iter, err := b.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+	meta, seriesMeta := iter.Meta(), iter.SeriesMeta()
+	values := make([]float64, len(seriesMeta))
+	ts.Memset(values, math.NaN())
+	for iter.Next() {
+		step, err := iter.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		copy(values, step.Values())
+	}
+
+	meta.Bounds = bounds
+	builder, err := n.controller.BlockBuilder(meta, seriesMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := bounds.Steps()
+	if err := builder.AddCols(steps); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < steps; i++ {
+		for _, value := range values {
+			if err := builder.AppendValue(i, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// pinnedBlock marks the window fetched for a range selector pinned by the @ modifier with the
+// bounds of the query it is evaluated over
+type pinnedBlock struct {
+	block.Block
+	bounds block.Bounds
+}
+
+func (b *pinnedBlock) StepIter() (block.StepIter, error) {
+	iter, err := b.Block.StepIter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pinnedStepIter{StepIter: iter, bounds: b.bounds}, nil
+}
+
+func (b *pinnedBlock) SeriesIter() (block.SeriesIter, error) {
+	iter, err := b.Block.SeriesIter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pinnedSeriesIter{SeriesIter: iter, bounds: b.bounds}, nil
+}
+
+// SampleTime returns the sample times of the underlying block, if it has them
+func (b *pinnedBlock) SampleTime(series, step int) (time.Time, bool) {
+	sampled, ok := b.Block.(block.SampleTimesBlock)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return sampled.SampleTime(series, step)
+}
+
+func pinMeta(meta block.Metadata, bounds block.Bounds) block.Metadata {
+	meta.PinnedBounds = &bounds
+	return meta
+}
+
+type pinnedStepIter struct {
+	block.StepIter
+	bounds block.Bounds
+}
+
+func (i *pinnedStepIter) Meta() block.Metadata {
+	return pinMeta(i.StepIter.Meta(), i.bounds)
+}
+
+type pinnedSeriesIter struct {
+	block.SeriesIter
+	bounds block.Bounds
+}
+
+func (i *pinnedSeriesIter) Meta() block.Metadata {
+	return pinMeta(i.SeriesIter.Meta(), i.bounds)
+}
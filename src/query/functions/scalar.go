@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/util"
+)
+
+const (
+	// NumberLiteralType is a number literal, e.g. 20 for (2 + 3) * 4 once folded. It is distinct from
+	// the name of the PromQL scalar() function so allow and deny lists of functions do not match literals
+	NumberLiteralType = "number_literal"
+
+	// TimeType returns the time of each step as seconds since the epoch
+	TimeType = "time"
+)
+
+// ScalarOp is a source of a scalar, a single series without any tags holding a value at every step
+type ScalarOp struct {
+	opType string
+	value  float64
+}
+
+// NewScalarOp creates a new op for a number literal
+func NewScalarOp(value float64) ScalarOp {
+	return ScalarOp{opType: NumberLiteralType, value: value}
+}
+
+// NewTimeOp creates a new time op
+func NewTimeOp(args []interface{}) (ScalarOp, error) {
+	if len(args) != 0 {
+		return ScalarOp{}, fmt.Errorf("invalid number of args for time: %d", len(args))
+	}
+
+	return ScalarOp{opType: TimeType}, nil
+}
+
+// OpType for the operator
+func (o ScalarOp) OpType() string {
+	return o.opType
+}
+
+// String representation
+func (o ScalarOp) String() string {
+	if o.opType == TimeType {
+		return fmt.Sprintf("type: %s", o.OpType())
+	}
+
+	return fmt.Sprintf("type: %s, value: %v", o.OpType(), o.value)
+}
+
+// FormatExpr renders the literal or function call
+func (o ScalarOp) FormatExpr(_ []string) string {
+	if o.opType == TimeType {
+		return parser.FormatFunction(TimeType)
+	}
+
+	return util.FormatValue(o.value)
+}
+
+// Node creates an execution node
+func (o ScalarOp) Node(controller *transform.Controller, _ storage.Storage, options transform.Options) parser.Source {
+	return &scalarNode{
+		op:         o,
+		controller: controller,
+		timespec:   options.TimeSpec,
+		alignSteps: options.AlignStepsToEpoch,
+	}
+}
+
+type scalarNode struct {
+	op         ScalarOp
+	controller *transform.Controller
+	timespec   transform.TimeSpec
+	alignSteps bool
+}
+
+// Execute builds the block of the scalar over the query steps, as selectors would fetch them
+func (n *scalarNode) Execute(_ context.Context) error {
+	bounds := n.timespec.Bounds(n.alignSteps)
+	builder, err := n.controller.BlockBuilder(block.Metadata{Bounds: bounds}, []block.SeriesMeta{{}})
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(bounds.Steps()); err != nil {
+		return err
+	}
+
+	for i := 0; i < bounds.Steps(); i++ {
+		value := n.op.value
+		if n.op.opType == TimeType {
+			value = timestampSeconds(bounds.TimeForStep(i))
+		}
+
+		if err := builder.AppendValue(i, value); err != nil {
+			return err
+		}
+	}
+
+	scalarBlock := builder.Build()
+	defer scalarBlock.Close()
+	return n.controller.Process(scalarBlock)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func executeScalar(t *testing.T, op ScalarOp, timeSpec transform.TimeSpec) *executor.SinkNode {
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	require.NoError(t, op.Node(c, nil, transform.Options{TimeSpec: timeSpec}).Execute(context.TODO()))
+	return sink
+}
+
+func TestScalar(t *testing.T) {
+	start := time.Unix(1500, 0)
+	timeSpec := transform.TimeSpec{Start: start, End: start.Add(2 * time.Minute), Step: time.Minute}
+
+	op := NewScalarOp(1.5)
+	assert.Equal(t, "1.5", op.FormatExpr(nil))
+	sink := executeScalar(t, op, timeSpec)
+	assert.Equal(t, [][]float64{{1.5, 1.5, 1.5}}, sink.Values)
+	require.Len(t, sink.Metas, 1)
+	assert.Empty(t, sink.Metas[0].Tags)
+
+	op, err := NewTimeOp(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "time()", op.FormatExpr(nil))
+	sink = executeScalar(t, op, timeSpec)
+	assert.Equal(t, [][]float64{{1500, 1560, 1620}}, sink.Values)
+
+	_, err = NewTimeOp([]interface{}{1.0})
+	assert.Error(t, err)
+}
+
+func TestScalarWithBlockLimit(t *testing.T) {
+	start := time.Unix(1500, 0)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(1))
+	c.Options.MaxBlockBytes = 1
+	timeSpec := transform.TimeSpec{Start: start, End: start.Add(2 * time.Minute), Step: time.Minute}
+	err := NewScalarOp(1.5).Node(c, nil, transform.Options{TimeSpec: timeSpec}).Execute(context.TODO())
+	assert.Equal(t, transform.ErrResourceExhausted, errors.Cause(err))
+}
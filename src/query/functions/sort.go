@@ -0,0 +1,161 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+const (
+	// SortType orders series by ascending value
+	SortType = "sort"
+
+	// SortDescType orders series by descending value
+	SortDescType = "sort_desc"
+)
+
+// SortOp stores required properties for sort and sort_desc
+type SortOp struct {
+	opType string
+}
+
+// NewSortOp creates a new sort op based on the type
+func NewSortOp(args []interface{}, opType string) (SortOp, error) {
+	if opType != SortType && opType != SortDescType {
+		return SortOp{}, fmt.Errorf("unknown sort type: %s", opType)
+	}
+
+	if len(args) != 0 {
+		return SortOp{}, fmt.Errorf("invalid number of args for %s: %d", opType, len(args))
+	}
+
+	return SortOp{opType: opType}, nil
+}
+
+// OpType for the operator
+func (o SortOp) OpType() string {
+	return o.opType
+}
+
+// String representation
+func (o SortOp) String() string {
+	return fmt.Sprintf("type: %s", o.OpType())
+}
+
+// FormatExpr renders the function call on its input
+func (o SortOp) FormatExpr(inputs []string) string {
+	return parser.FormatFunction(o.opType, inputs...)
+}
+
+// Node creates an execution node
+func (o SortOp) Node(controller *transform.Controller) transform.OpNode {
+	return &SortNode{op: o, controller: controller}
+}
+
+// SortNode is an execution node
+type SortNode struct {
+	op         SortOp
+	controller *transform.Controller
+}
+
+// Process orders the series by their value at the last step, which is the only step of
+// instant queries, with series without a value there last. Series keep their metric names,
+// and blocks with at most one series, such as scalars, are passed on as they are
+func (n *SortNode) Process(ID parser.NodeID, b block.Block) error {
+	seriesIter, err := b.SeriesIter()
+	if err != nil {
+		return err
+	}
+
+	defer seriesIter.Close()
+	if seriesIter.SeriesCount() <= 1 {
+		return n.controller.Process(b)
+	}
+
+	var values [][]float64
+	for seriesIter.Next() {
+		series, err := seriesIter.Current()
+		if err != nil {
+			return err
+		}
+
+		values = append(values, series.Values())
+	}
+
+	meta := seriesIter.Meta()
+	steps := meta.Bounds.Steps()
+	indices := make([]int, len(values))
+	last := make([]float64, len(values))
+	for i, series := range values {
+		indices[i] = i
+		last[i] = math.NaN()
+		if steps > 0 && len(series) >= steps {
+			last[i] = series[steps-1]
+		}
+	}
+
+	descending := n.op.opType == SortDescType
+	sort.SliceStable(indices, func(i, j int) bool {
+		vi, vj := last[indices[i]], last[indices[j]]
+		if math.IsNaN(vi) || math.IsNaN(vj) {
+			return !math.IsNaN(vi) && math.IsNaN(vj)
+		}
+
+		if descending {
+			return vi > vj
+		}
+
+		return vi < vj
+	})
+
+	metas := seriesIter.SeriesMeta()
+	sortedMetas := make([]block.SeriesMeta, len(indices))
+	for i, idx := range indices {
+		sortedMetas[i] = metas[idx]
+	}
+
+	builder, err := n.controller.BlockBuilder(meta, sortedMetas)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return err
+	}
+
+	for step := 0; step < steps; step++ {
+		for _, idx := range indices {
+			if err := builder.AppendValue(step, values[idx][step]); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
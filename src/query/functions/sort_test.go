@@ -0,0 +1,100 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortPassesThroughSingleSeries(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	metas := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "up", "job": "api"}}}
+	values := [][]float64{{3, 1, 2, math.NaN(), 5, 4}}
+
+	for _, opType := range []string{SortType, SortDescType} {
+		op, err := NewSortOp(nil, opType)
+		require.NoError(t, err)
+
+		input := test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)
+		c, sink := executor.NewControllerWithBlockSink(parser.NodeID(1))
+		require.NoError(t, op.Node(c).Process(parser.NodeID(0), input))
+		assert.True(t, input == sink.Block, "%s passes on the input block", opType)
+
+		c, valuesSink := executor.NewControllerWithSink(parser.NodeID(1))
+		require.NoError(t, op.Node(c).Process(parser.NodeID(0), input))
+		test.EqualsWithNans(t, values, valuesSink.Values)
+		assert.Equal(t, metas, valuesSink.Metas, "sort keeps the metric name")
+	}
+}
+
+func TestSort(t *testing.T) {
+	_, bounds := test.GenerateValuesAndBounds(nil, nil)
+	nan := math.NaN()
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "up", "a": "1"}},
+		{Tags: models.Tags{models.MetricName: "up", "a": "2"}},
+		{Tags: models.Tags{models.MetricName: "up", "a": "3"}},
+		{Tags: models.Tags{models.MetricName: "up", "a": "4"}},
+	}
+	values := [][]float64{
+		{1, 1, 1, 1, 1, 2},
+		{1, 1, 1, 1, 1, nan},
+		{1, 1, 1, 1, 1, 9},
+		{1, 1, 1, 1, 1, 5},
+	}
+
+	tests := []struct {
+		opType   string
+		expected []string
+	}{
+		{opType: SortType, expected: []string{"1", "4", "3", "2"}},
+		{opType: SortDescType, expected: []string{"3", "4", "1", "2"}},
+	}
+
+	for _, tt := range tests {
+		op, err := NewSortOp(nil, tt.opType)
+		require.NoError(t, err)
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+		require.NoError(t, err)
+
+		var order []string
+		for _, meta := range sink.Metas {
+			assert.Equal(t, "up", meta.Tags[models.MetricName])
+			order = append(order, meta.Tags["a"])
+		}
+
+		assert.Equal(t, tt.expected, order, "%s orders by the last value, without values last", tt.opType)
+	}
+
+	_, err := NewSortOp([]interface{}{1.0}, SortType)
+	assert.Error(t, err)
+}
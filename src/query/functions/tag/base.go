@@ -0,0 +1,157 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+var emptyOp = BaseOp{}
+
+// tagTransformFunc returns the updated tags for a series. The input tags must not be modified
+type tagTransformFunc func(tags models.Tags) models.Tags
+
+// makeTagFn creates the tag transform of a node, for ops which depend on the options of the query
+type makeTagFn func(controller *transform.Controller) tagTransformFunc
+
+// staticTagFn makes the same tag transform for every node
+func staticTagFn(fn tagTransformFunc) makeTagFn {
+	return func(*transform.Controller) tagTransformFunc {
+		return fn
+	}
+}
+
+// BaseOp stores required properties for tag operations
+type BaseOp struct {
+	operatorType string
+	tagFn        makeTagFn
+	// args are the string arguments following the series argument
+	args []string
+}
+
+// OpType for the operator
+func (o BaseOp) OpType() string {
+	return o.operatorType
+}
+
+// String representation
+func (o BaseOp) String() string {
+	return fmt.Sprintf("type: %s", o.OpType())
+}
+
+// FormatExpr renders the function call on its input
+func (o BaseOp) FormatExpr(inputs []string) string {
+	args := append([]string{}, inputs...)
+	for _, arg := range o.args {
+		args = append(args, parser.FormatLiteral(arg))
+	}
+
+	return parser.FormatFunction(o.operatorType, args...)
+}
+
+// Node creates an execution node
+func (o BaseOp) Node(controller *transform.Controller) transform.OpNode {
+	return &baseNode{
+		op:         o,
+		controller: controller,
+		tagFn:      o.tagFn(controller),
+	}
+}
+
+type baseNode struct {
+	op         BaseOp
+	controller *transform.Controller
+	tagFn      tagTransformFunc
+}
+
+// Ensure baseNode implements the types for lazy evaluation
+var _ transform.StepNode = (*baseNode)(nil)
+var _ transform.SeriesNode = (*baseNode)(nil)
+
+// ProcessStep allows step iteration
+func (n *baseNode) ProcessStep(step block.Step) (block.Step, error) {
+	return step, nil
+}
+
+// ProcessSeries allows series iteration
+func (n *baseNode) ProcessSeries(series block.Series) (block.Series, error) {
+	return block.NewSeries(series.Values(), n.seriesMeta(series.Meta)), nil
+}
+
+// Process the block
+func (n *baseNode) Process(ID parser.NodeID, b block.Block) error {
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	builder, err := n.controller.BlockBuilder(stepIter.Meta(), n.SeriesMeta(stepIter.SeriesMeta()))
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(stepIter.StepCount()); err != nil {
+		return err
+	}
+
+	for index := 0; stepIter.Next(); index++ {
+		step, err := stepIter.Current()
+		if err != nil {
+			return err
+		}
+
+		for _, value := range step.Values() {
+			if err := builder.AppendValue(index, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
+
+// Meta returns the metadata for the block
+func (n *baseNode) Meta(meta block.Metadata) block.Metadata {
+	return meta
+}
+
+// SeriesMeta returns the metadata for each series in the block
+func (n *baseNode) SeriesMeta(metas []block.SeriesMeta) []block.SeriesMeta {
+	updated := make([]block.SeriesMeta, len(metas))
+	for i, meta := range metas {
+		updated[i] = n.seriesMeta(meta)
+	}
+
+	return updated
+}
+
+// seriesMeta updates the tags of a series, leaving the rest of the metadata intact
+func (n *baseNode) seriesMeta(meta block.SeriesMeta) block.SeriesMeta {
+	meta.Tags = n.tagFn(meta.Tags)
+	return meta
+}
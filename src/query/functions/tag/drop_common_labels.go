@@ -0,0 +1,162 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// DropCommonLabelsType removes the labels which every series has with the same value
+const DropCommonLabelsType = "drop_common_labels"
+
+// NewDropCommonLabelsOp creates a new drop_common_labels op
+func NewDropCommonLabelsOp() transform.Params {
+	return dropCommonLabelsOp{}
+}
+
+type dropCommonLabelsOp struct{}
+
+// OpType for the operator
+func (o dropCommonLabelsOp) OpType() string {
+	return DropCommonLabelsType
+}
+
+// String representation
+func (o dropCommonLabelsOp) String() string {
+	return fmt.Sprintf("type: %s", o.OpType())
+}
+
+// FormatExpr renders the function call on its input
+func (o dropCommonLabelsOp) FormatExpr(inputs []string) string {
+	return parser.FormatFunction(DropCommonLabelsType, inputs...)
+}
+
+// Node creates an execution node
+func (o dropCommonLabelsOp) Node(controller *transform.Controller) transform.OpNode {
+	return &dropCommonLabelsNode{controller: controller}
+}
+
+// dropCommonLabelsNode needs every series of a block to find the common labels, so it
+// does not support lazy evaluation
+type dropCommonLabelsNode struct {
+	controller *transform.Controller
+}
+
+// Process the block
+func (n *dropCommonLabelsNode) Process(ID parser.NodeID, b block.Block) error {
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	metas, err := dropCommonLabels(stepIter.SeriesMeta())
+	if err != nil {
+		return err
+	}
+
+	builder, err := n.controller.BlockBuilder(stepIter.Meta(), metas)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(stepIter.StepCount()); err != nil {
+		return err
+	}
+
+	for index := 0; stepIter.Next(); index++ {
+		step, err := stepIter.Current()
+		if err != nil {
+			return err
+		}
+
+		for _, value := range step.Values() {
+			if err := builder.AppendValue(index, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
+
+// dropCommonLabels removes the labels shared by all series, other than the metric name,
+// and errors if series can no longer be told apart
+func dropCommonLabels(metas []block.SeriesMeta) ([]block.SeriesMeta, error) {
+	if len(metas) == 0 {
+		return metas, nil
+	}
+
+	common := make([]string, 0, len(metas[0].Tags))
+	for name, value := range metas[0].Tags {
+		if name == models.MetricName {
+			continue
+		}
+
+		shared := true
+		for _, meta := range metas[1:] {
+			if v, ok := meta.Tags[name]; !ok || v != value {
+				shared = false
+				break
+			}
+		}
+
+		if shared {
+			common = append(common, name)
+		}
+	}
+
+	if len(common) == 0 {
+		return metas, nil
+	}
+
+	sort.Strings(common)
+	updated := make([]block.SeriesMeta, len(metas))
+	seen := make(map[string]struct{}, len(metas))
+	for i, meta := range metas {
+		tags := make(models.Tags, len(meta.Tags)-len(common))
+		for name, value := range meta.Tags {
+			tags[name] = value
+		}
+
+		for _, name := range common {
+			delete(tags, name)
+		}
+
+		meta.Tags = tags
+		id := tags.ID()
+		if _, ok := seen[id]; ok {
+			return nil, fmt.Errorf("dropping common labels %v leaves duplicate series: %s", common, id)
+		}
+
+		seen[id] = struct{}{}
+		updated[i] = meta
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processDropCommonLabels(metas []block.SeriesMeta) (*executor.SinkNode, [][]float64, error) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	node := NewDropCommonLabelsOp().Node(c)
+	return sink, values, node.Process(parser.NodeID(0), b)
+}
+
+func TestDropCommonLabels(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "up", "job": "api", "env": "prod", "instance": "a"}},
+		{Tags: models.Tags{models.MetricName: "up", "job": "api", "env": "prod", "instance": "b", "zone": "z1"}},
+	}
+
+	sink, values, err := processDropCommonLabels(metas)
+	require.NoError(t, err)
+	assert.Equal(t, values, sink.Values)
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{models.MetricName: "up", "instance": "a"}, sink.Metas[0].Tags, "the name is kept")
+	assert.Equal(t, models.Tags{models.MetricName: "up", "instance": "b", "zone": "z1"}, sink.Metas[1].Tags)
+	assert.Equal(t, "api", metas[0].Tags["job"], "input tags are not modified")
+}
+
+func TestDropCommonLabelsWithDifferentValues(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"job": "api", "instance": "a"}},
+		{Tags: models.Tags{"job": "db", "instance": "a"}},
+	}
+
+	sink, _, err := processDropCommonLabels(metas)
+	require.NoError(t, err)
+	assert.Equal(t, models.Tags{"job": "api"}, sink.Metas[0].Tags)
+	assert.Equal(t, models.Tags{"job": "db"}, sink.Metas[1].Tags)
+}
+
+func TestDropCommonLabelsWithCollision(t *testing.T) {
+	// The same series from two sources can not be told apart once the common labels are gone
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"job": "api", "instance": "a"}, Source: "zone-a"},
+		{Tags: models.Tags{"job": "api", "instance": "a"}, Source: "zone-b"},
+	}
+
+	_, _, err := processDropCommonLabels(metas)
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/util"
+)
+
+// LabelFromValueType sets the destination label to the value of each series, e.g. to show
+// values as a column in table panels. Labels belong to series rather than steps, so it only
+// supports instant vectors
+const LabelFromValueType = "label_from_value"
+
+// NewLabelFromValueOp creates a new label_from_value op based on the arguments
+func NewLabelFromValueOp(args []interface{}) (transform.Params, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of args for label_from_value: %d", len(args))
+	}
+
+	dst, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("unable to cast to string argument: %v", args[0])
+	}
+
+	if !labelNameRegex.MatchString(dst) {
+		return nil, fmt.Errorf("invalid destination label name in label_from_value: %s", dst)
+	}
+
+	return labelFromValueOp{dst: dst}, nil
+}
+
+type labelFromValueOp struct {
+	dst string
+}
+
+// OpType for the operator
+func (o labelFromValueOp) OpType() string {
+	return LabelFromValueType
+}
+
+// String representation
+func (o labelFromValueOp) String() string {
+	return fmt.Sprintf("type: %s, destination: %s", o.OpType(), o.dst)
+}
+
+// FormatExpr renders the function call on its input
+func (o labelFromValueOp) FormatExpr(inputs []string) string {
+	return parser.FormatFunction(LabelFromValueType, append(inputs, parser.FormatLiteral(o.dst))...)
+}
+
+// Node creates an execution node
+func (o labelFromValueOp) Node(controller *transform.Controller) transform.OpNode {
+	return &labelFromValueNode{op: o, controller: controller}
+}
+
+// labelFromValueNode needs the values of a block to build its series metadata, so it does not
+// support lazy evaluation
+type labelFromValueNode struct {
+	op         labelFromValueOp
+	controller *transform.Controller
+}
+
+// Process the block, leaving the label unset for series without a value
+func (n *labelFromValueNode) Process(ID parser.NodeID, b block.Block) error {
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	defer stepIter.Close()
+	if steps := stepIter.StepCount(); steps != 1 {
+		return fmt.Errorf("label_from_value only supports instant vectors, found %d steps", steps)
+	}
+
+	if !stepIter.Next() {
+		return fmt.Errorf("label_from_value found a block without steps")
+	}
+
+	step, err := stepIter.Current()
+	if err != nil {
+		return err
+	}
+
+	values := step.Values()
+	metas := stepIter.SeriesMeta()
+	updated := make([]block.SeriesMeta, len(metas))
+	for i, meta := range metas {
+		updated[i] = meta
+		if math.IsNaN(values[i]) {
+			continue
+		}
+
+		tags := make(models.Tags, len(meta.Tags)+1)
+		for k, v := range meta.Tags {
+			tags[k] = v
+		}
+
+		tags[n.op.dst] = util.FormatValue(values[i])
+		updated[i].Tags = tags
+	}
+
+	builder, err := n.controller.BlockBuilder(stepIter.Meta(), updated)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(1); err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		if err := builder.AppendValue(0, value); err != nil {
+			return err
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
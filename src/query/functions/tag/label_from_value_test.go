@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelFromValue(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: now, End: now, StepSize: time.Minute}
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "up", "instance": "a"}},
+		{Tags: models.Tags{models.MetricName: "up", "instance": "b"}},
+		{Tags: models.Tags{models.MetricName: "up", "instance": "c"}},
+	}
+
+	values := [][]float64{{1.5}, {100}, {math.NaN()}}
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	op, err := NewLabelFromValueOp([]interface{}{"value"})
+	require.NoError(t, err)
+	require.NoError(t, op.Node(c).Process(parser.NodeID(0), b))
+
+	test.EqualsWithNans(t, values, sink.Values)
+	require.Len(t, sink.Metas, 3)
+	assert.Equal(t, models.Tags{models.MetricName: "up", "instance": "a", "value": "1.5"}, sink.Metas[0].Tags)
+	assert.Equal(t, models.Tags{models.MetricName: "up", "instance": "b", "value": "100"}, sink.Metas[1].Tags)
+	assert.Equal(t, metas[2].Tags, sink.Metas[2].Tags, "series without a value are not labelled")
+	assert.NotContains(t, metas[0].Tags, "value", "input tags are not modified")
+}
+
+func TestLabelFromValueRequiresInstantVectors(t *testing.T) {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValues(bounds, values)
+	c, _ := executor.NewControllerWithSink(parser.NodeID(1))
+	op, err := NewLabelFromValueOp([]interface{}{"value"})
+	require.NoError(t, err)
+	assert.Error(t, op.Node(c).Process(parser.NodeID(0), b))
+}
+
+func TestLabelFromValueInvalidArgs(t *testing.T) {
+	_, err := NewLabelFromValueOp([]interface{}{"1value"})
+	assert.Error(t, err)
+
+	_, err = NewLabelFromValueOp(nil)
+	assert.Error(t, err)
+}
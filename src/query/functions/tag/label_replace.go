@@ -0,0 +1,115 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+)
+
+// LabelReplaceType matches the regex against the value of the source label, and if it matches,
+// sets the destination label to the replacement with its capture groups expanded. The metric
+// name is an ordinary label here, so __name__ can be the source or the destination
+const LabelReplaceType = "label_replace"
+
+var labelNameRegex = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// LabelReplaceOptions configures label_replace
+type LabelReplaceOptions struct {
+	// Unanchored matches the regex anywhere in the source value, with the leftmost match driving
+	// the capture groups. The destination is still set to the expanded replacement alone, not to
+	// the source value with the match replaced. Prometheus anchors the regex to the whole value,
+	// which is the default, so queries relying on this option are not portable. When unset,
+	// the option of the query applies
+	Unanchored bool
+}
+
+// NewLabelReplaceOp creates a new label_replace op based on the arguments
+func NewLabelReplaceOp(args []interface{}, opts LabelReplaceOptions) (BaseOp, error) {
+	if len(args) != 4 {
+		return emptyOp, fmt.Errorf("invalid number of args for label_replace: %d", len(args))
+	}
+
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(string)
+		if !ok {
+			return emptyOp, fmt.Errorf("unable to cast to string argument: %v", arg)
+		}
+
+		strArgs[i] = str
+	}
+
+	dst, replacement, src, regex := strArgs[0], strArgs[1], strArgs[2], strArgs[3]
+	if !labelNameRegex.MatchString(dst) {
+		return emptyOp, fmt.Errorf("invalid destination label name in label_replace: %s", dst)
+	}
+
+	// Either anchoring may be picked by the query, so the regex must be valid for both
+	anchored, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return emptyOp, fmt.Errorf("invalid regular expression in label_replace: %s", regex)
+	}
+
+	unanchored, err := regexp.Compile(regex)
+	if err != nil {
+		return emptyOp, fmt.Errorf("invalid regular expression in label_replace: %s", regex)
+	}
+
+	return BaseOp{
+		operatorType: LabelReplaceType,
+		tagFn: func(controller *transform.Controller) tagTransformFunc {
+			if opts.Unanchored || controller.Options.UnanchoredLabelReplace {
+				return makeLabelReplaceFn(unanchored, dst, replacement, src)
+			}
+
+			return makeLabelReplaceFn(anchored, dst, replacement, src)
+		},
+		args: strArgs,
+	}, nil
+}
+
+func makeLabelReplaceFn(re *regexp.Regexp, dst, replacement, src string) tagTransformFunc {
+	return func(tags models.Tags) models.Tags {
+		srcVal := tags[src]
+		indices := re.FindStringSubmatchIndex(srcVal)
+		if indices == nil {
+			return tags
+		}
+
+		res := re.ExpandString([]byte{}, replacement, srcVal, indices)
+		updated := make(models.Tags, len(tags)+1)
+		for k, v := range tags {
+			updated[k] = v
+		}
+
+		if len(res) == 0 {
+			delete(updated, dst)
+		} else {
+			updated[dst] = string(res)
+		}
+
+		return updated
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processLabelReplace(t *testing.T, args []interface{}, metas []block.SeriesMeta) []block.SeriesMeta {
+	return processLabelReplaceWithOptions(t, args, LabelReplaceOptions{}, metas)
+}
+
+func processLabelReplaceWithOptions(
+	t *testing.T, args []interface{}, opts LabelReplaceOptions, metas []block.SeriesMeta) []block.SeriesMeta {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)
+	op, err := NewLabelReplaceOp(args, opts)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	assert.Equal(t, values, sink.Values)
+	return sink.Metas
+}
+
+func TestLabelReplace(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"instance": "host1:9090"}},
+		{Tags: models.Tags{"instance": "host2"}},
+	}
+
+	actual := processLabelReplace(t, []interface{}{"host", "$1", "instance", "(.*):.*"}, metas)
+	require.Len(t, actual, 2)
+	assert.Equal(t, models.Tags{"instance": "host1:9090", "host": "host1"}, actual[0].Tags)
+	assert.Equal(t, models.Tags{"instance": "host2"}, actual[1].Tags, "non matching series are unchanged")
+	assert.Equal(t, models.Tags{"instance": "host1:9090"}, metas[0].Tags, "input tags are not modified")
+}
+
+func TestLabelReplaceUnanchored(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"path": "/api/v2/users"}},
+		{Tags: models.Tags{"path": "/health"}},
+	}
+
+	args := []interface{}{"version", "$1", "path", "v([0-9]+)"}
+	actual := processLabelReplace(t, args, metas)
+	assert.Equal(t, models.Tags{"path": "/api/v2/users"}, actual[0].Tags, "partial matches fail when anchored")
+
+	actual = processLabelReplaceWithOptions(t, args, LabelReplaceOptions{Unanchored: true}, metas)
+	assert.Equal(t, models.Tags{"path": "/api/v2/users", "version": "2"}, actual[0].Tags)
+	assert.Equal(t, models.Tags{"path": "/health"}, actual[1].Tags, "non matching series are unchanged")
+}
+
+func TestLabelReplaceWithEmptyReplacement(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"instance": "host1", "host": "old"}},
+		{Tags: models.Tags{"instance": "host2", "host": "old"}},
+	}
+
+	actual := processLabelReplace(t, []interface{}{"host", "", "instance", "host1"}, metas)
+	assert.Equal(t, models.Tags{"instance": "host1"}, actual[0].Tags)
+	assert.Equal(t, models.Tags{"instance": "host2", "host": "old"}, actual[1].Tags)
+}
+
+func TestLabelReplacePreservesSource(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"instance": "host1"}, Source: "zone-a"},
+		{Tags: models.Tags{"instance": "host2"}, Source: "zone-b"},
+	}
+
+	actual := processLabelReplace(t, []interface{}{"host", "$1", "instance", "(.*)"}, metas)
+	assert.Equal(t, "zone-a", actual[0].Source)
+	assert.Equal(t, "zone-b", actual[1].Source)
+}
+
+func TestLabelReplaceWithMetricName(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "up", "job": "api"}},
+		{Tags: models.Tags{models.MetricName: "down", "job": "db"}},
+	}
+
+	// An empty source label matches the empty regex for every series
+	actual := processLabelReplace(t, []interface{}{models.MetricName, "up_renamed", "", ""}, metas)
+	assert.Equal(t, models.Tags{models.MetricName: "up_renamed", "job": "api"}, actual[0].Tags)
+	assert.Equal(t, models.Tags{models.MetricName: "up_renamed", "job": "db"}, actual[1].Tags)
+
+	actual = processLabelReplace(t, []interface{}{"metric", "${1}_total", models.MetricName, "(.*)"}, metas)
+	assert.Equal(t, models.Tags{models.MetricName: "up", "metric": "up_total", "job": "api"}, actual[0].Tags)
+	assert.Equal(t, models.Tags{models.MetricName: "down", "metric": "down_total", "job": "db"}, actual[1].Tags)
+
+	actual = processLabelReplace(t, []interface{}{models.MetricName, "", models.MetricName, "up"}, metas)
+	assert.Equal(t, models.Tags{"job": "api"}, actual[0].Tags, "an empty name removes it")
+	assert.Equal(t, metas[1].Tags, actual[1].Tags)
+}
+
+func TestLabelReplaceWithInvalidArgs(t *testing.T) {
+	_, err := NewLabelReplaceOp([]interface{}{"host", "$1", "instance"}, LabelReplaceOptions{})
+	assert.Error(t, err)
+
+	_, err = NewLabelReplaceOp([]interface{}{"1host", "$1", "instance", "(.*)"}, LabelReplaceOptions{})
+	assert.Error(t, err)
+
+	_, err = NewLabelReplaceOp([]interface{}{"host", "$1", "instance", "(.*"}, LabelReplaceOptions{})
+	assert.Error(t, err)
+
+	_, err = NewLabelReplaceOp([]interface{}{"host", 1.0, "instance", "(.*)"}, LabelReplaceOptions{})
+	assert.Error(t, err)
+}
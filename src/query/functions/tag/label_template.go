@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/m3db/m3/src/query/models"
+)
+
+// LabelTemplateType sets the destination label to a template rendered with the labels of the series,
+// e.g. {{.instance}}:{{.port}}, allowing a label to be built from several source labels
+const LabelTemplateType = "label_template"
+
+// NewLabelTemplateOp creates a new label_template op based on the arguments
+func NewLabelTemplateOp(args []interface{}) (BaseOp, error) {
+	if len(args) != 2 {
+		return emptyOp, fmt.Errorf("invalid number of args for label_template: %d", len(args))
+	}
+
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(string)
+		if !ok {
+			return emptyOp, fmt.Errorf("unable to cast to string argument: %v", arg)
+		}
+
+		strArgs[i] = str
+	}
+
+	dst, text := strArgs[0], strArgs[1]
+	if !labelNameRegex.MatchString(dst) {
+		return emptyOp, fmt.Errorf("invalid destination label name in label_template: %s", dst)
+	}
+
+	// Labels missing from a series render as empty strings
+	tmpl, err := template.New(LabelTemplateType).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return emptyOp, fmt.Errorf("invalid template in label_template: %s", err)
+	}
+
+	return BaseOp{
+		operatorType: LabelTemplateType,
+		tagFn:        staticTagFn(makeLabelTemplateFn(tmpl, dst)),
+		args:         strArgs,
+	}, nil
+}
+
+func makeLabelTemplateFn(tmpl *template.Template, dst string) tagTransformFunc {
+	return func(tags models.Tags) models.Tags {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, map[string]string(tags)); err != nil {
+			return tags
+		}
+
+		updated := make(models.Tags, len(tags)+1)
+		for k, v := range tags {
+			updated[k] = v
+		}
+
+		if buf.Len() == 0 {
+			delete(updated, dst)
+		} else {
+			updated[dst] = buf.String()
+		}
+
+		return updated
+	}
+}
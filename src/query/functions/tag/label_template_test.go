@@ -0,0 +1,86 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tag
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processLabelTemplate(t *testing.T, args []interface{}, metas []block.SeriesMeta) []block.SeriesMeta {
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	b := test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)
+	op, err := NewLabelTemplateOp(args)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	assert.Equal(t, values, sink.Values)
+	return sink.Metas
+}
+
+func TestLabelTemplate(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"instance": "host1", "port": "9090"}},
+		{Tags: models.Tags{"instance": "host2", "port": "9091"}},
+	}
+
+	actual := processLabelTemplate(t, []interface{}{"addr", "{{.instance}}:{{.port}}"}, metas)
+	require.Len(t, actual, 2)
+	assert.Equal(t, models.Tags{"instance": "host1", "port": "9090", "addr": "host1:9090"}, actual[0].Tags)
+	assert.Equal(t, models.Tags{"instance": "host2", "port": "9091", "addr": "host2:9091"}, actual[1].Tags)
+	assert.Equal(t, models.Tags{"instance": "host1", "port": "9090"}, metas[0].Tags, "input tags are not modified")
+}
+
+func TestLabelTemplateWithMissingLabel(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{"instance": "host1"}},
+		{Tags: models.Tags{"job": "api", "addr": "old"}},
+	}
+
+	actual := processLabelTemplate(t, []interface{}{"addr", "{{.instance}}"}, metas)
+	assert.Equal(t, models.Tags{"instance": "host1", "addr": "host1"}, actual[0].Tags)
+	assert.Equal(t, models.Tags{"job": "api"}, actual[1].Tags, "an empty render removes the label")
+
+	actual = processLabelTemplate(t, []interface{}{"addr", "{{.instance}}:{{.port}}"}, metas)
+	assert.Equal(t, models.Tags{"instance": "host1", "addr": "host1:"}, actual[0].Tags)
+	assert.Equal(t, models.Tags{"job": "api", "addr": ":"}, actual[1].Tags)
+}
+
+func TestLabelTemplateWithInvalidArgs(t *testing.T) {
+	_, err := NewLabelTemplateOp([]interface{}{"addr"})
+	assert.Error(t, err)
+
+	_, err = NewLabelTemplateOp([]interface{}{"1addr", "{{.instance}}"})
+	assert.Error(t, err)
+
+	_, err = NewLabelTemplateOp([]interface{}{"addr", "{{.instance"})
+	assert.Error(t, err)
+}
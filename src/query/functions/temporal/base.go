@@ -0,0 +1,302 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+var emptyOp = BaseOp{}
+
+// BaseOp stores required properties for temporal operations
+type BaseOp struct {
+	operatorType string
+	duration     time.Duration
+	processorFn  makeProcessor
+	// args are the scalar arguments following the range argument
+	args []interface{}
+	// leadingArgs are the scalar arguments preceding the range argument
+	leadingArgs []interface{}
+	// KeepMetricNames preserves the metric name, which is otherwise dropped from the output
+	KeepMetricNames bool
+	// preservesName is set for functions which do not change the meaning of the values, so that
+	// the metric name is kept regardless of KeepMetricNames, e.g. last_over_time
+	preservesName bool
+	// LeftInclusive closes the left edge of the window, so that a window of duration d evaluated
+	// at t covers [t-d, t] rather than the Prometheus default of (t-d, t]. When unset, the
+	// option of the query applies
+	LeftInclusive bool
+}
+
+// OpType for the operator
+func (o BaseOp) OpType() string {
+	return o.operatorType
+}
+
+// String representation
+func (o BaseOp) String() string {
+	return fmt.Sprintf("type: %s, duration: %v", o.OpType(), o.duration)
+}
+
+// FormatExpr renders the function call on its input, which already includes the range
+func (o BaseOp) FormatExpr(inputs []string) string {
+	return parser.FormatKeepMetricNames(o.formatCall(inputs), o.KeepMetricNames)
+}
+
+func (o BaseOp) formatCall(inputs []string) string {
+	args := make([]string, 0, len(o.leadingArgs)+len(inputs)+len(o.args))
+	for _, arg := range o.leadingArgs {
+		args = append(args, parser.FormatLiteral(arg))
+	}
+
+	args = append(args, inputs...)
+	for _, arg := range o.args {
+		args = append(args, parser.FormatLiteral(arg))
+	}
+
+	return parser.FormatFunction(o.operatorType, args...)
+}
+
+// WithKeepMetricNames returns the op keeping the metric names of its input
+func (o BaseOp) WithKeepMetricNames() parser.Params {
+	o.KeepMetricNames = true
+	return o
+}
+
+// Node creates an execution node
+func (o BaseOp) Node(controller *transform.Controller) transform.OpNode {
+	return &baseNode{
+		controller: controller,
+		op:         o,
+		processor:  o.processorFn(o, controller),
+	}
+}
+
+type baseNode struct {
+	op         BaseOp
+	controller *transform.Controller
+	processor  Processor
+}
+
+// Process the block. The incoming block is expected to start one range duration before
+// the query start, so the leading steps are only used as lookback and are not emitted
+func (c *baseNode) Process(ID parser.NodeID, b block.Block) error {
+	if preprocessor, ok := c.processor.(blockPreprocessor); ok {
+		preprocessed, rewritten, err := preprocessor.preprocess(b)
+		if err != nil {
+			return err
+		}
+
+		// The incoming block is closed by its sender, but a rewritten block belongs to the node
+		if rewritten {
+			defer preprocessed.Close()
+			b = preprocessed
+		}
+	}
+
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	seriesIter, err := b.SeriesIter()
+	if err != nil {
+		return err
+	}
+
+	meta := seriesIter.Meta()
+	bounds := meta.Bounds
+	lookback := lookbackSteps(c.op.duration, bounds.StepSize)
+	steps := stepIter.StepCount() - lookback
+	if steps < 0 {
+		steps = 0
+	}
+
+	meta.Bounds = block.Bounds{
+		Start:    bounds.TimeForStep(lookback),
+		End:      bounds.End,
+		StepSize: bounds.StepSize,
+	}
+
+	// A selector pinned to an instant by the @ modifier only has the window fetched at the instant,
+	// which is evaluated once and repeated at every step of the query
+	pinned := meta.PinnedBounds != nil
+	if pinned {
+		meta.Bounds, meta.PinnedBounds = *meta.PinnedBounds, nil
+		steps = meta.Bounds.Steps()
+	}
+
+	seriesMeta := seriesIter.SeriesMeta()
+	if !c.op.KeepMetricNames && !c.op.preservesName {
+		seriesMeta = utils.DropMetricNames(seriesMeta)
+	}
+
+	builder, err := c.controller.BlockBuilder(meta, seriesMeta)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return err
+	}
+
+	datapoints := make(ts.Datapoints, 0, lookback+1)
+	// Inputs shared by several nodes cache their rows, so they are only transposed once
+	rows, err := block.Transpose(b)
+	if err != nil {
+		return err
+	}
+
+	validator, validate := c.processor.(seriesValidator)
+	for _, series := range rows {
+		if validate {
+			validator.validateSeries(series)
+		}
+
+		var value float64
+		for i := 0; i < steps; i++ {
+			if pinned && i > 0 {
+				if err := builder.AppendValue(i, value); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			idx := i + lookback
+			if pinned {
+				idx = len(series) - 1
+			}
+
+			evaluationTime := bounds.TimeForStep(idx)
+			windowStart := evaluationTime.Add(-1 * c.op.duration)
+			datapoints = datapoints[:0]
+			for j := idx - lookback; j <= idx; j++ {
+				if j < 0 {
+					continue
+				}
+
+				t := bounds.TimeForStep(j)
+				value := series[j]
+				// Missing samples are represented as NaNs and are skipped
+				if !c.inWindow(t, windowStart) || math.IsNaN(value) {
+					continue
+				}
+
+				datapoints = append(datapoints, ts.Datapoint{Timestamp: t, Value: value})
+			}
+
+			value = c.processor.Process(datapoints, evaluationTime)
+			if err := builder.AppendValue(i, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return c.controller.Process(nextBlock)
+}
+
+// inWindow returns whether a sample at or before the evaluation time falls within the window
+// starting at windowStart, which is only included when the op or the query is left inclusive
+func (c *baseNode) inWindow(t, windowStart time.Time) bool {
+	if c.op.LeftInclusive || c.controller.Options.LeftInclusive {
+		return !t.Before(windowStart)
+	}
+
+	return t.After(windowStart)
+}
+
+// rangeArg checks the number of arguments of the function and returns its range argument
+func rangeArg(args []interface{}, optype string, numArgs, index int) (time.Duration, error) {
+	if len(args) != numArgs {
+		return 0, fmt.Errorf("invalid number of args for %s: %d", optype, len(args))
+	}
+
+	duration, ok := args[index].(time.Duration)
+	if !ok {
+		return 0, fmt.Errorf("unable to cast to duration argument: %v", args[index])
+	}
+
+	return duration, nil
+}
+
+// lookbackSteps returns the number of steps needed before a step to cover the duration
+func lookbackSteps(duration, stepSize time.Duration) int {
+	if stepSize <= 0 {
+		return 0
+	}
+
+	steps := int(duration / stepSize)
+	if duration%stepSize != 0 {
+		steps++
+	}
+
+	return steps
+}
+
+// defaultMinSamples is the fewest samples a window can have a value from for functions which
+// compare samples, as a single sample has nothing to be compared with
+const defaultMinSamples = 2
+
+// minSamples returns the number of samples a window needs for functions which compare samples,
+// configured by the op, or else by the query, which is never fewer than defaultMinSamples
+func minSamples(configured int, controller *transform.Controller) int {
+	if configured <= 0 {
+		configured = controller.Options.MinSamples
+	}
+
+	if configured < defaultMinSamples {
+		return defaultMinSamples
+	}
+
+	return configured
+}
+
+// makeProcessor is a way to create a transform
+type makeProcessor func(op BaseOp, controller *transform.Controller) Processor
+
+// Processor is implemented by the underlying transforms
+type Processor interface {
+	// Process returns the value for a window of datapoints ending at the evaluation time
+	Process(datapoints ts.Datapoints, evaluationTime time.Time) float64
+}
+
+// blockPreprocessor is implemented by processors which rewrite their input block before the
+// windows are built, e.g. to merge replicas of a series. They return false when the block is used
+// as it is, and otherwise the rewritten block, which is closed once processed
+type blockPreprocessor interface {
+	preprocess(b block.Block) (block.Block, bool, error)
+}
+
+// seriesValidator is implemented by processors which check their input series, e.g. to raise warnings
+type seriesValidator interface {
+	validateSeries(series []float64)
+}
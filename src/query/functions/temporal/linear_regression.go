@@ -0,0 +1,215 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+const (
+	// PredictLinearType predicts the value of time series t seconds from now, based on the input series, using simple linear regression
+	PredictLinearType = "predict_linear"
+
+	// DerivType calculates the per-second derivative of the time series, using simple linear regression
+	DerivType = "deriv"
+)
+
+// LinearRegressionOptions configures the least squares fit used by deriv and predict_linear
+type LinearRegressionOptions struct {
+	// DecayHalfLife, when set, exponentially weights the samples in a window so that a
+	// sample's weight halves for every DecayHalfLife it is older than the newest sample.
+	// A zero value uses plain least squares
+	DecayHalfLife time.Duration
+	// MinSamples is the number of samples a fit needs, as a line through few samples gives a
+	// noisy slope. It is never fewer than defaultMinSamples, the least a line can be fit to
+	MinSamples int
+	// Reference is the time predict_linear predicts from, for aligning with other tools. It
+	// does not change the slope, so deriv is unaffected. The zero value falls back to the
+	// reference of the query
+	Reference utils.RegressionReference
+}
+
+type linearRegressionOp struct {
+	opType  string
+	seconds float64
+	opts    LinearRegressionOptions
+}
+
+// NewLinearRegressionOp creates a new linear regression op based on the type and arguments
+func NewLinearRegressionOp(args []interface{}, optype string, opts LinearRegressionOptions) (BaseOp, error) {
+	if optype != PredictLinearType && optype != DerivType {
+		return emptyOp, fmt.Errorf("unknown linear regression type: %s", optype)
+	}
+
+	numArgs := 1
+	if optype == PredictLinearType {
+		numArgs = 2
+	}
+
+	duration, err := rangeArg(args, optype, numArgs, 0)
+	if err != nil {
+		return emptyOp, err
+	}
+
+	if opts.DecayHalfLife < 0 {
+		return emptyOp, fmt.Errorf("decay half life cannot be negative: %v", opts.DecayHalfLife)
+	}
+
+	if opts.MinSamples < 0 {
+		return emptyOp, fmt.Errorf("min samples cannot be negative: %d", opts.MinSamples)
+	}
+
+	if opts.Reference < utils.ReferenceEvaluationTime || opts.Reference > utils.ReferenceWindowEnd {
+		return emptyOp, fmt.Errorf("unknown regression reference: %d", opts.Reference)
+	}
+
+	spec := linearRegressionOp{
+		opType: optype,
+		opts:   opts,
+	}
+
+	if optype == PredictLinearType {
+		seconds, ok := args[1].(float64)
+		if !ok {
+			return emptyOp, fmt.Errorf("unable to cast to scalar argument: %v", args[1])
+		}
+
+		spec.seconds = seconds
+	}
+
+	return BaseOp{
+		operatorType: optype,
+		duration:     duration,
+		processorFn:  makeLinearRegressionProcessor(spec),
+		args:         args[1:],
+	}, nil
+}
+
+func makeLinearRegressionProcessor(spec linearRegressionOp) makeProcessor {
+	linearRegressionOp := spec
+	return func(op BaseOp, controller *transform.Controller) Processor {
+		return &linearRegressionNode{op: linearRegressionOp, controller: controller}
+	}
+}
+
+type linearRegressionNode struct {
+	op         linearRegressionOp
+	controller *transform.Controller
+}
+
+func (l *linearRegressionNode) Process(datapoints ts.Datapoints, evaluationTime time.Time) float64 {
+	if len(datapoints) < minSamples(l.op.opts.MinSamples, l.controller) {
+		return math.NaN()
+	}
+
+	if l.op.opType == DerivType {
+		slope, _ := linearRegression(datapoints, datapoints[0].Timestamp, l.decayHalfLife())
+		return slope
+	}
+
+	reference := evaluationTime
+	switch l.reference() {
+	case utils.ReferenceWindowStart:
+		reference = datapoints[0].Timestamp
+	case utils.ReferenceWindowEnd:
+		reference = datapoints[len(datapoints)-1].Timestamp
+	}
+
+	slope, intercept := linearRegression(datapoints, reference, l.decayHalfLife())
+	return slope*l.op.seconds + intercept
+}
+
+// decayHalfLife returns the half life of the op, or else of the query
+func (l *linearRegressionNode) decayHalfLife() time.Duration {
+	if l.op.opts.DecayHalfLife > 0 {
+		return l.op.opts.DecayHalfLife
+	}
+
+	return l.controller.Options.RegressionDecayHalfLife
+}
+
+// reference returns the regression reference of the op, or else of the query
+func (l *linearRegressionNode) reference() utils.RegressionReference {
+	if l.op.opts.Reference != utils.ReferenceEvaluationTime {
+		return l.op.opts.Reference
+	}
+
+	return l.controller.Options.RegressionReference
+}
+
+// linearRegression performs a least squares fit of the datapoints against their timestamps,
+// in seconds relative to interceptTime. When decayHalfLife is non zero, each sample is
+// weighted by 2^(-age/decayHalfLife), where age is measured from the newest sample.
+// A constant series has a slope of exactly 0, and the fit is NaN if the datapoints share a
+// single timestamp, as there is no line through them. The timestamps are centered on their
+// mean before fitting, as the sums of squares of large offsets, e.g. from the epoch, would
+// otherwise cancel catastrophically
+func linearRegression(datapoints ts.Datapoints, interceptTime time.Time, decayHalfLife time.Duration) (float64, float64) {
+	var (
+		n          float64
+		sumX, sumY float64
+		constY     = true
+	)
+
+	newest := datapoints[len(datapoints)-1].Timestamp
+	weights := make([]float64, len(datapoints))
+	for i, dp := range datapoints {
+		weights[i] = 1.0
+		if decayHalfLife > 0 {
+			weights[i] = math.Exp2(-float64(newest.Sub(dp.Timestamp)) / float64(decayHalfLife))
+		}
+
+		if dp.Value != datapoints[0].Value {
+			constY = false
+		}
+
+		n += weights[i]
+		sumX += weights[i] * dp.Timestamp.Sub(interceptTime).Seconds()
+		sumY += weights[i] * dp.Value
+	}
+
+	meanX, meanY := sumX/n, sumY/n
+	var covXY, varX float64
+	for i, dp := range datapoints {
+		dx := dp.Timestamp.Sub(interceptTime).Seconds() - meanX
+		covXY += weights[i] * dx * (dp.Value - meanY)
+		varX += weights[i] * dx * dx
+	}
+
+	if varX == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	// The sums lose precision when cancelling, so a flat series could otherwise have a tiny slope
+	if constY {
+		return 0, datapoints[0].Value
+	}
+
+	slope := covXY / varX
+	intercept := meanY - slope*meanX
+	return slope, intercept
+}
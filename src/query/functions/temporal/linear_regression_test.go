@@ -0,0 +1,219 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processLinearRegression(t *testing.T, values [][]float64, args []interface{},
+	optype string, opts LinearRegressionOptions) [][]float64 {
+	now := time.Now()
+	bounds := block.Bounds{
+		Start:    now,
+		End:      now.Add(time.Duration(len(values[0])-1) * time.Minute),
+		StepSize: time.Minute,
+	}
+
+	block := test.NewBlockFromValues(bounds, values)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	op, err := NewLinearRegressionOp(args, optype, opts)
+	require.NoError(t, err)
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), block)
+	require.NoError(t, err)
+	return sink.Values
+}
+
+func TestDeriv(t *testing.T) {
+	values := [][]float64{
+		{0, 1, 2, 3, 4, 5},
+		{0, 2, math.NaN(), 6, 8, 10},
+	}
+
+	actual := processLinearRegression(t, values, []interface{}{3 * time.Minute}, DerivType, LinearRegressionOptions{})
+	require.Len(t, actual, 2)
+	require.Len(t, actual[0], 3, "the first steps are lookback only")
+	for _, v := range actual[0] {
+		assert.InDelta(t, 1.0/60, v, 1e-9)
+	}
+
+	for _, v := range actual[1] {
+		assert.InDelta(t, 2.0/60, v, 1e-9)
+	}
+}
+
+func TestPredictLinear(t *testing.T) {
+	values := [][]float64{{0, 1, 2, 3, 4, 5}}
+	args := []interface{}{3 * time.Minute, 60.0}
+	actual := processLinearRegression(t, values, args, PredictLinearType, LinearRegressionOptions{})
+	require.Len(t, actual, 1)
+	expected := []float64{4, 5, 6}
+	for i, v := range actual[0] {
+		assert.InDelta(t, expected[i], v, 1e-9)
+	}
+}
+
+func TestPredictLinearWithReference(t *testing.T) {
+	// The value is the time in minutes, so predicting a minute ahead of a reference gives the
+	// reference time plus one. The window ending at the fifth step has no sample at its end
+	values := [][]float64{{0, 1, 2, 3, math.NaN(), 5}}
+	args := []interface{}{3 * time.Minute, 60.0}
+	tests := []struct {
+		reference utils.RegressionReference
+		expected  []float64
+	}{
+		{reference: utils.ReferenceEvaluationTime, expected: []float64{4, 5, 6}},
+		{reference: utils.ReferenceWindowStart, expected: []float64{2, 3, 4}},
+		{reference: utils.ReferenceWindowEnd, expected: []float64{4, 4, 6}},
+	}
+
+	for _, tt := range tests {
+		opts := LinearRegressionOptions{Reference: tt.reference}
+		actual := processLinearRegression(t, values, args, PredictLinearType, opts)
+		require.Len(t, actual, 1)
+		assert.InDeltaSlice(t, tt.expected, actual[0], 1e-9, "reference %d", tt.reference)
+
+		deriv := processLinearRegression(t, values, args[:1], DerivType, opts)
+		assert.InDeltaSlice(t, []float64{1.0 / 60, 1.0 / 60, 1.0 / 60}, deriv[0], 1e-9, "deriv does not depend on the reference")
+	}
+
+	_, err := NewLinearRegressionOp(args, PredictLinearType, LinearRegressionOptions{Reference: utils.RegressionReference(3)})
+	assert.Error(t, err)
+}
+
+func TestLinearRegressionOnConstantSeries(t *testing.T) {
+	// Values which are not exactly representable would leave a tiny slope in the sums
+	values := [][]float64{{0.1, 0.1, 0.1, 0.1, 0.1, 0.1}}
+	for _, opts := range []LinearRegressionOptions{{}, {DecayHalfLife: time.Minute}} {
+		deriv := processLinearRegression(t, values, []interface{}{3 * time.Minute}, DerivType, opts)
+		assert.Equal(t, [][]float64{{0, 0, 0}}, deriv)
+
+		args := []interface{}{3 * time.Minute, 3600.0}
+		predicted := processLinearRegression(t, values, args, PredictLinearType, opts)
+		assert.Equal(t, [][]float64{{0.1, 0.1, 0.1}}, predicted)
+	}
+}
+
+func TestLinearRegressionWithSingleTimestamp(t *testing.T) {
+	now := time.Now()
+	datapoints := ts.Datapoints{{Timestamp: now, Value: 1}, {Timestamp: now, Value: 3}}
+	slope, intercept := linearRegression(datapoints, now, 0)
+	assert.True(t, math.IsNaN(slope), "there is no line through a single timestamp")
+	assert.True(t, math.IsNaN(intercept))
+
+	datapoints[1].Value = 1
+	slope, intercept = linearRegression(datapoints, now, 0)
+	assert.True(t, math.IsNaN(slope), "even when the values are constant")
+	assert.True(t, math.IsNaN(intercept))
+}
+
+func TestLinearRegressionWithLargeTimestamps(t *testing.T) {
+	// Unix timestamps with nanosecond offsets are far from the intercept time, so uncentered
+	// sums of squares would cancel catastrophically
+	start := time.Unix(1700000000, 123456789)
+	datapoints := make(ts.Datapoints, 120)
+	for i := range datapoints {
+		timestamp := start.Add(time.Duration(i) * 15 * time.Second)
+		seconds := float64(timestamp.UnixNano()) / float64(time.Second)
+		datapoints[i] = ts.Datapoint{Timestamp: timestamp, Value: 0.25*seconds + 10}
+	}
+
+	slope, intercept := linearRegression(datapoints, time.Unix(0, 0), 0)
+	assert.InDelta(t, 0.25, slope, 1e-12)
+	assert.InDelta(t, 10, intercept, 1e-2)
+
+	slope, intercept = linearRegression(datapoints, start, 0)
+	assert.InDelta(t, 0.25, slope, 1e-12)
+	assert.InDelta(t, datapoints[0].Value, intercept, 1e-6)
+}
+
+func TestDerivWithDecayHalfLife(t *testing.T) {
+	// The series rises by 1 per step and then by 10 per step for the most recent samples
+	values := [][]float64{{0, 0, 1, 2, 3, 4, 5, 15, 25, 35, 45}}
+	args := []interface{}{10 * time.Minute}
+
+	unweighted := processLinearRegression(t, values, args, DerivType, LinearRegressionOptions{})
+	require.Len(t, unweighted, 1)
+	require.Len(t, unweighted[0], 1)
+
+	weighted := processLinearRegression(t, values, args, DerivType, LinearRegressionOptions{
+		DecayHalfLife: time.Minute,
+	})
+	require.Len(t, weighted, 1)
+	require.Len(t, weighted[0], 1)
+
+	recentSlope := 10.0 / 60
+	assert.True(t, unweighted[0][0] < weighted[0][0], "weighted slope should follow the recent trend more closely")
+	assert.True(t, weighted[0][0] < recentSlope)
+}
+
+func TestDecayHalfLifeOnLinearSeries(t *testing.T) {
+	// A perfectly linear series has the same fit regardless of the weights
+	values := [][]float64{{0, 1, 2, 3, 4, 5}}
+	actual := processLinearRegression(t, values, []interface{}{3 * time.Minute}, DerivType, LinearRegressionOptions{
+		DecayHalfLife: 30 * time.Second,
+	})
+	require.Len(t, actual, 1)
+	for _, v := range actual[0] {
+		assert.InDelta(t, 1.0/60, v, 1e-9)
+	}
+}
+
+func TestLinearRegressionWithInvalidArgs(t *testing.T) {
+	_, err := NewLinearRegressionOp([]interface{}{time.Minute}, PredictLinearType, LinearRegressionOptions{})
+	assert.Error(t, err)
+
+	_, err = NewLinearRegressionOp([]interface{}{1.0}, DerivType, LinearRegressionOptions{})
+	assert.Error(t, err)
+
+	_, err = NewLinearRegressionOp([]interface{}{time.Minute}, DerivType, LinearRegressionOptions{
+		DecayHalfLife: -time.Minute,
+	})
+	assert.Error(t, err)
+
+	_, err = NewLinearRegressionOp([]interface{}{time.Minute}, DerivType, LinearRegressionOptions{MinSamples: -1})
+	assert.Error(t, err)
+}
+
+func TestDerivWithMinSamples(t *testing.T) {
+	// The window only has three real samples
+	values := [][]float64{{math.NaN(), math.NaN(), math.NaN(), 10, 20, 30}}
+	args := []interface{}{5 * time.Minute}
+	actual := processLinearRegression(t, values, args, DerivType, LinearRegressionOptions{})
+	require.Len(t, actual, 1)
+	assert.InDelta(t, 10.0/60, actual[0][0], 1e-9)
+
+	actual = processLinearRegression(t, values, args, DerivType, LinearRegressionOptions{MinSamples: 4})
+	assert.True(t, math.IsNaN(actual[0][0]), "windows with fewer samples have no value")
+}
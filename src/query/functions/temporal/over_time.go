@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+const (
+	// LastOverTimeType returns the most recent value in the specified interval
+	LastOverTimeType = "last_over_time"
+
+	// PresentOverTimeType returns 1 for series with any value in the specified interval
+	PresentOverTimeType = "present_over_time"
+)
+
+// overTimeProcessors are the processors of the functions aggregating the values of each window
+var overTimeProcessors = map[string]Processor{
+	LastOverTimeType:    lastNode{},
+	PresentOverTimeType: presentNode{},
+}
+
+// NewOverTimeOp creates a new op aggregating the values of each window, based on the type and arguments
+func NewOverTimeOp(args []interface{}, optype string) (BaseOp, error) {
+	processor, ok := overTimeProcessors[optype]
+	if !ok {
+		return emptyOp, fmt.Errorf("unknown over time type: %s", optype)
+	}
+
+	duration, err := rangeArg(args, optype, 1, 0)
+	if err != nil {
+		return emptyOp, err
+	}
+
+	return BaseOp{
+		operatorType: optype,
+		duration:     duration,
+		processorFn: func(BaseOp, *transform.Controller) Processor {
+			return processor
+		},
+		// The most recent value means the same as the input, so it keeps its name as in Prometheus
+		preservesName: optype == LastOverTimeType,
+	}, nil
+}
+
+type lastNode struct{}
+
+func (lastNode) Process(datapoints ts.Datapoints, _ time.Time) float64 {
+	if len(datapoints) == 0 {
+		return math.NaN()
+	}
+
+	return datapoints[len(datapoints)-1].Value
+}
+
+type presentNode struct{}
+
+func (presentNode) Process(datapoints ts.Datapoints, _ time.Time) float64 {
+	if len(datapoints) == 0 {
+		return math.NaN()
+	}
+
+	return 1
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processOverTime(t *testing.T, optype string, values [][]float64, metas []block.SeriesMeta) *executor.SinkNode {
+	now := time.Now()
+	bounds := block.Bounds{
+		Start:    now,
+		End:      now.Add(time.Duration(len(values[0])-1) * time.Minute),
+		StepSize: time.Minute,
+	}
+
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	op, err := NewOverTimeOp([]interface{}{3 * time.Minute}, optype)
+	require.NoError(t, err)
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+	require.NoError(t, err)
+	return sink
+}
+
+func TestOverTime(t *testing.T) {
+	nan := math.NaN()
+	// The windows lose values to NaNs until the last sample
+	values := [][]float64{{0, 4, 1, 2, nan, nan, nan, 5}}
+	metas := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "up", "job": "api"}}}
+
+	sink := processOverTime(t, LastOverTimeType, values, metas)
+	test.EqualsWithNans(t, [][]float64{{2, 2, 2, nan, 5}}, sink.Values)
+	assert.Equal(t, metas[0].Tags, sink.Metas[0].Tags, "last_over_time keeps the metric name")
+
+	sink = processOverTime(t, PresentOverTimeType, values, metas)
+	test.EqualsWithNans(t, [][]float64{{1, 1, 1, nan, 1}}, sink.Values)
+	assert.Equal(t, models.Tags{"job": "api"}, sink.Metas[0].Tags)
+}
+
+func TestOverTimeWithInvalidArgs(t *testing.T) {
+	_, err := NewOverTimeOp([]interface{}{3 * time.Minute}, "first_over_time")
+	assert.Error(t, err)
+
+	_, err = NewOverTimeOp([]interface{}{0.5}, LastOverTimeType)
+	assert.Error(t, err)
+
+	_, err = NewOverTimeOp(nil, PresentOverTimeType)
+	assert.Error(t, err)
+}
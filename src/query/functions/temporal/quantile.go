@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/internal/quantile"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+// QuantileOverTimeType calculates the φ-quantile (0 ≤ φ ≤ 1) of the values in the specified interval
+const QuantileOverTimeType = "quantile_over_time"
+
+// QuantileOptions configures quantile_over_time
+type QuantileOptions struct {
+	// InterpolationMethod determines how values between ranks are picked, defaulting
+	// to linear interpolation as Prometheus does
+	InterpolationMethod utils.InterpolationMethod
+}
+
+type quantileOp struct {
+	q    float64
+	opts QuantileOptions
+}
+
+// NewQuantileOverTimeOp creates a new quantile_over_time op based on the arguments
+func NewQuantileOverTimeOp(args []interface{}, opts QuantileOptions) (BaseOp, error) {
+	duration, err := rangeArg(args, QuantileOverTimeType, 2, 1)
+	if err != nil {
+		return emptyOp, err
+	}
+
+	q, ok := args[0].(float64)
+	if !ok {
+		return emptyOp, fmt.Errorf("unable to cast to scalar argument: %v", args[0])
+	}
+
+	spec := quantileOp{
+		q:    q,
+		opts: opts,
+	}
+
+	return BaseOp{
+		operatorType: QuantileOverTimeType,
+		duration:     duration,
+		processorFn:  makeQuantileProcessor(spec),
+		leadingArgs:  args[:1],
+	}, nil
+}
+
+func makeQuantileProcessor(spec quantileOp) makeProcessor {
+	quantileOp := spec
+	return func(op BaseOp, controller *transform.Controller) Processor {
+		return &quantileNode{op: quantileOp, controller: controller}
+	}
+}
+
+type quantileNode struct {
+	op         quantileOp
+	controller *transform.Controller
+}
+
+func (q *quantileNode) Process(datapoints ts.Datapoints, _ time.Time) float64 {
+	if len(datapoints) == 0 {
+		return math.NaN()
+	}
+
+	sorted := make([]float64, len(datapoints))
+	for i, dp := range datapoints {
+		sorted[i] = dp.Value
+	}
+
+	sort.Float64s(sorted)
+	return quantile.Interpolate(sorted, q.op.q, q.interpolationMethod())
+}
+
+// interpolationMethod returns the interpolation method of the op, or else of the query
+func (q *quantileNode) interpolationMethod() utils.InterpolationMethod {
+	if q.op.opts.InterpolationMethod != utils.LinearInterpolation {
+		return q.op.opts.InterpolationMethod
+	}
+
+	return q.controller.Options.InterpolationMethod
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processQuantileOverTime(t *testing.T, values [][]float64, q float64, method utils.InterpolationMethod) [][]float64 {
+	now := time.Now()
+	bounds := block.Bounds{
+		Start:    now,
+		End:      now.Add(time.Duration(len(values[0])-1) * time.Minute),
+		StepSize: time.Minute,
+	}
+
+	block := test.NewBlockFromValues(bounds, values)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	op, err := NewQuantileOverTimeOp([]interface{}{q, 3 * time.Minute}, QuantileOptions{InterpolationMethod: method})
+	require.NoError(t, err)
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), block)
+	require.NoError(t, err)
+	return sink.Values
+}
+
+func TestQuantileOverTimeWithInterpolationMethods(t *testing.T) {
+	// The first two windows hold 1, 2 and 4, the later ones lose values to NaNs
+	values := [][]float64{{0, 4, 1, 2, 4, math.NaN(), math.NaN()}}
+	tests := []struct {
+		method   utils.InterpolationMethod
+		expected []float64
+	}{
+		{method: utils.LinearInterpolation, expected: []float64{2.4, 2.4, 3.2, 4}},
+		{method: utils.LowerInterpolation, expected: []float64{2, 2, 2, 4}},
+		{method: utils.HigherInterpolation, expected: []float64{4, 4, 4, 4}},
+		{method: utils.NearestInterpolation, expected: []float64{2, 2, 4, 4}},
+	}
+
+	for _, tt := range tests {
+		actual := processQuantileOverTime(t, values, 0.6, tt.method)
+		require.Len(t, actual, 1)
+		require.Len(t, actual[0], len(tt.expected))
+		for i, v := range tt.expected {
+			assert.InDelta(t, v, actual[0][i], 1e-9, "method: %d, step: %d", tt.method, i)
+		}
+	}
+}
+
+func TestQuantileOverTimeWithNoValues(t *testing.T) {
+	values := [][]float64{{1, math.NaN(), math.NaN(), math.NaN()}}
+	actual := processQuantileOverTime(t, values, 0.5, utils.LinearInterpolation)
+	test.EqualsWithNans(t, [][]float64{{math.NaN()}}, actual)
+}
+
+func TestQuantileOverTimeWithInvalidArgs(t *testing.T) {
+	_, err := NewQuantileOverTimeOp([]interface{}{3 * time.Minute}, QuantileOptions{})
+	assert.Error(t, err)
+
+	_, err = NewQuantileOverTimeOp([]interface{}{3 * time.Minute, 0.5}, QuantileOptions{})
+	assert.Error(t, err)
+}
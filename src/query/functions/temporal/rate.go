@@ -0,0 +1,307 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+const (
+	// RateType calculates the per-second average rate of increase of the time series
+	RateType = "rate"
+
+	// IncreaseType calculates the increase in the time series
+	IncreaseType = "increase"
+
+	// DeltaType calculates the difference between the first and last value of each time series
+	DeltaType = "delta"
+
+	// resetRatio is the fraction of the previous value below which a decrease looks like a counter reset
+	resetRatio = 0.5
+	// gaugeDecreaseRatio is the fraction of sample pairs with partial decreases above which a series looks like a gauge
+	gaugeDecreaseRatio = 0.25
+	// minGaugeDecreases is the number of partial decreases needed before a series looks like a gauge
+	minGaugeDecreases = 2
+	// minSampledInterval is the shortest span of samples a change can be extrapolated or divided
+	// over, as timestamps only have millisecond precision
+	minSampledInterval = time.Millisecond
+)
+
+// CounterOptions configures the counter functions rate and increase
+type CounterOptions struct {
+	// CounterMaxValue, when set, is the value at which counters wrap. A decrease is then
+	// treated as a wrap past the max, adding max - oldVal + newVal, rather than as a
+	// reset to zero
+	CounterMaxValue float64
+	// MinSamples is the number of samples a window needs before its rate or increase is
+	// reported, e.g. to skip windows where a single scrape failure leaves only two samples.
+	// Values below defaultMinSamples are raised to it
+	MinSamples int
+	// DisableExtrapolation, when set, returns the raw change between the first and last
+	// samples, with rates taken over the time those samples span rather than the window.
+	// Extrapolation is on by default to match Prometheus
+	DisableExtrapolation bool
+	// ResetTolerance is the largest decrease which is not a counter reset, for counters exposed
+	// as floats which jitter down slightly from rounding. It defaults to 0, so that every
+	// decrease is a reset
+	ResetTolerance float64
+	// NonNegative, for delta, clamps negative changes to 0 and adds a warning, for gauges
+	// which are known to only increase. It is off by default to match Prometheus
+	NonNegative bool
+	// MergeReplicas, for rate and increase, merges series which only differ by the ReplicaTag into
+	// one counter before the windows are built, so that replicas lagging one another do not look
+	// like counter resets. Series without the tag are unaffected
+	MergeReplicas bool
+}
+
+type rateOp struct {
+	opType    string
+	isRate    bool
+	isCounter bool
+	duration  time.Duration
+	opts      CounterOptions
+}
+
+// NewRateOp creates a new rate op based on the type and arguments
+func NewRateOp(args []interface{}, optype string, opts CounterOptions) (BaseOp, error) {
+	spec := rateOp{
+		opType: optype,
+		opts:   opts,
+	}
+
+	switch optype {
+	case RateType:
+		spec.isRate = true
+		spec.isCounter = true
+	case IncreaseType:
+		spec.isCounter = true
+	case DeltaType:
+	default:
+		return emptyOp, fmt.Errorf("unknown rate type: %s", optype)
+	}
+
+	duration, err := rangeArg(args, optype, 1, 0)
+	if err != nil {
+		return emptyOp, err
+	}
+
+	if opts.CounterMaxValue < 0 {
+		return emptyOp, fmt.Errorf("counter max value cannot be negative: %v", opts.CounterMaxValue)
+	}
+
+	if opts.MinSamples < 0 {
+		return emptyOp, fmt.Errorf("min samples cannot be negative: %d", opts.MinSamples)
+	}
+
+	if opts.ResetTolerance < 0 {
+		return emptyOp, fmt.Errorf("reset tolerance cannot be negative: %v", opts.ResetTolerance)
+	}
+
+	if opts.NonNegative && spec.isCounter {
+		return emptyOp, fmt.Errorf("non negative is only supported for %s", DeltaType)
+	}
+
+	if opts.MergeReplicas && !spec.isCounter {
+		return emptyOp, fmt.Errorf("merging replicas is only supported for %s and %s", RateType, IncreaseType)
+	}
+
+	spec.duration = duration
+	return BaseOp{
+		operatorType: optype,
+		duration:     duration,
+		processorFn:  makeRateProcessor(spec),
+	}, nil
+}
+
+func makeRateProcessor(spec rateOp) makeProcessor {
+	rateOp := spec
+	return func(op BaseOp, controller *transform.Controller) Processor {
+		return &rateNode{op: rateOp, controller: controller}
+	}
+}
+
+type rateNode struct {
+	op         rateOp
+	controller *transform.Controller
+}
+
+// Process extrapolates the change across the window in the same way as Prometheus
+func (r *rateNode) Process(datapoints ts.Datapoints, evaluationTime time.Time) float64 {
+	if len(datapoints) < minSamples(r.op.opts.MinSamples, r.controller) {
+		return math.NaN()
+	}
+
+	first, last := datapoints[0], datapoints[len(datapoints)-1]
+	if last.Timestamp.Sub(first.Timestamp) < minSampledInterval {
+		// Samples sharing a timestamp, e.g. from broken ingestion, would divide by zero
+		r.controller.Options.Warnings.Add(fmt.Sprintf(
+			"%s found windows whose samples all share a timestamp, those steps were omitted", r.op.opType))
+		return math.NaN()
+	}
+
+	result := last.Value - first.Value
+	if r.op.isCounter {
+		result += r.counterCorrection(datapoints)
+	}
+
+	if result < 0 && !r.op.isCounter && (r.op.opts.NonNegative || r.controller.Options.NonNegative) {
+		r.controller.Options.Warnings.Add(fmt.Sprintf(
+			"%s found decreases in series which should not decrease, the changes were clamped to 0", r.op.opType))
+		return 0
+	}
+
+	sampledInterval := last.Timestamp.Sub(first.Timestamp).Seconds()
+	if r.op.opts.DisableExtrapolation || r.controller.Options.DisableExtrapolation {
+		if r.op.isRate {
+			result = result / sampledInterval
+		}
+
+		return result
+	}
+
+	rangeStart := evaluationTime.Add(-1 * r.op.duration)
+	durationToStart := first.Timestamp.Sub(rangeStart).Seconds()
+	durationToEnd := evaluationTime.Sub(last.Timestamp).Seconds()
+	averageDurationBetweenSamples := sampledInterval / float64(len(datapoints)-1)
+
+	if r.op.isCounter && result > 0 && first.Value >= 0 {
+		// Counters cannot go negative, so do not extrapolate past the point the counter would hit zero
+		durationToZero := sampledInterval * (first.Value / result)
+		if durationToZero < durationToStart {
+			durationToStart = durationToZero
+		}
+	}
+
+	// Extrapolate to the window boundaries if the samples are close enough to them,
+	// otherwise only extrapolate by half the average interval between samples
+	extrapolationThreshold := averageDurationBetweenSamples * 1.1
+	extrapolateToInterval := sampledInterval
+	if durationToStart < extrapolationThreshold {
+		extrapolateToInterval += durationToStart
+	} else {
+		extrapolateToInterval += averageDurationBetweenSamples / 2
+	}
+
+	if durationToEnd < extrapolationThreshold {
+		extrapolateToInterval += durationToEnd
+	} else {
+		extrapolateToInterval += averageDurationBetweenSamples / 2
+	}
+
+	result = result * (extrapolateToInterval / sampledInterval)
+	if r.op.isRate {
+		result = result / r.op.duration.Seconds()
+	}
+
+	return result
+}
+
+// preprocess merges the replicas of each counter when requested by the op or the query
+func (r *rateNode) preprocess(b block.Block) (block.Block, bool, error) {
+	if !r.op.isCounter || !(r.op.opts.MergeReplicas || r.controller.Options.MergeReplicas) {
+		return nil, false, nil
+	}
+
+	return mergeCounters(r.controller.BlockBuilder, b)
+}
+
+// validateSeries warns when a counter function is applied to a series which looks like a gauge.
+// It is only a heuristic, so it does not change the result
+func (r *rateNode) validateSeries(series []float64) {
+	if !r.op.isCounter || !r.controller.Options.WarnOnGaugeRates {
+		return
+	}
+
+	if looksLikeGauge(series) {
+		r.controller.Options.Warnings.Add(fmt.Sprintf(
+			"%s applied to series which look like gauges, consider delta or deriv instead", r.op.opType))
+	}
+}
+
+// looksLikeGauge returns true if a series frequently decreases without the drops to near zero
+// which counter resets cause
+func looksLikeGauge(series []float64) bool {
+	var pairs, decreases int
+	prev := math.NaN()
+	for _, value := range series {
+		if math.IsNaN(value) {
+			continue
+		}
+
+		if !math.IsNaN(prev) {
+			pairs++
+			if value < prev && value >= prev*resetRatio {
+				decreases++
+			}
+		}
+
+		prev = value
+	}
+
+	return decreases >= minGaugeDecreases && float64(decreases) >= float64(pairs)*gaugeDecreaseRatio
+}
+
+// counterMaxValue returns the value counters wrap at for the op, or else for the query
+func (r *rateNode) counterMaxValue() float64 {
+	if r.op.opts.CounterMaxValue > 0 {
+		return r.op.opts.CounterMaxValue
+	}
+
+	return r.controller.Options.CounterMaxValue
+}
+
+// resetTolerance returns the largest decrease which is not a reset for the op, or else for the query
+func (r *rateNode) resetTolerance() float64 {
+	if r.op.opts.ResetTolerance > 0 {
+		return r.op.opts.ResetTolerance
+	}
+
+	return r.controller.Options.ResetTolerance
+}
+
+// counterCorrection returns the amount to add to the raw difference to account for counter resets
+func (r *rateNode) counterCorrection(datapoints ts.Datapoints) float64 {
+	var correction float64
+	maxValue := r.counterMaxValue()
+	tolerance := r.resetTolerance()
+	prev := datapoints[0].Value
+	for _, dp := range datapoints[1:] {
+		if dp.Value < prev && prev-dp.Value >= tolerance {
+			if maxValue > 0 {
+				// The counter wrapped past the max, so the increase is max - prev + value
+				correction += maxValue
+			} else {
+				// The counter reset to zero, so the increase is value
+				correction += prev
+			}
+		}
+
+		prev = dp.Value
+	}
+
+	return correction
+}
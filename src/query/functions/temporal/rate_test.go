@@ -0,0 +1,408 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processRate(t *testing.T, values [][]float64, optype string, opts CounterOptions) [][]float64 {
+	now := time.Now()
+	bounds := block.Bounds{
+		Start:    now,
+		End:      now.Add(time.Duration(len(values[0])-1) * time.Minute),
+		StepSize: time.Minute,
+	}
+
+	block := test.NewBlockFromValues(bounds, values)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	op, err := NewRateOp([]interface{}{5 * time.Minute}, optype, opts)
+	require.NoError(t, err)
+	node := op.Node(c)
+	err = node.Process(parser.NodeID(0), block)
+	require.NoError(t, err)
+	return sink.Values
+}
+
+func TestRate(t *testing.T) {
+	// Samples a minute apart over a 5m window are extrapolated by a minute at the start,
+	// and every decrease in the second series is a counter reset
+	values := [][]float64{
+		{math.NaN(), 10, 20, 30, 40, 50},
+		{math.NaN(), 50, 40, 30, 20, 10},
+	}
+
+	tests := []struct {
+		optype   string
+		expected [][]float64
+	}{
+		{optype: RateType, expected: [][]float64{{50.0 / 300}, {(-40.0 + 140) * 1.25 / 300}}},
+		{optype: IncreaseType, expected: [][]float64{{50}, {(-40.0 + 140) * 1.25}}},
+		{optype: DeltaType, expected: [][]float64{{50}, {-50}}},
+	}
+
+	for _, tt := range tests {
+		actual := processRate(t, values, tt.optype, CounterOptions{})
+		require.Len(t, actual, len(tt.expected))
+		for i := range tt.expected {
+			assert.InDeltaSlice(t, tt.expected[i], actual[i], 1e-9, tt.optype)
+		}
+	}
+}
+
+func TestRateWithoutExtrapolation(t *testing.T) {
+	// The samples span four minutes of the 5m window, which raw rates divide by
+	values := [][]float64{
+		{math.NaN(), 10, 20, 30, 40, 50},
+		{math.NaN(), 50, 40, 30, 20, 10},
+	}
+
+	tests := []struct {
+		optype       string
+		extrapolated [][]float64
+		raw          [][]float64
+	}{
+		{
+			optype:       RateType,
+			extrapolated: [][]float64{{50.0 / 300}, {100 * 1.25 / 300}},
+			raw:          [][]float64{{40.0 / 240}, {100.0 / 240}},
+		},
+		{
+			optype:       IncreaseType,
+			extrapolated: [][]float64{{50}, {100 * 1.25}},
+			raw:          [][]float64{{40}, {100}},
+		},
+	}
+
+	for _, tt := range tests {
+		extrapolated := processRate(t, values, tt.optype, CounterOptions{})
+		raw := processRate(t, values, tt.optype, CounterOptions{DisableExtrapolation: true})
+		require.Len(t, raw, len(tt.raw))
+		for i := range tt.raw {
+			assert.InDeltaSlice(t, tt.extrapolated[i], extrapolated[i], 1e-9, tt.optype)
+			assert.InDeltaSlice(t, tt.raw[i], raw[i], 1e-9, tt.optype)
+		}
+	}
+}
+
+func TestIncreaseWithCounterMaxValue(t *testing.T) {
+	// The counter wraps from 100 past a max of 105 to 5, which is an increase of 10
+	values := [][]float64{{math.NaN(), 80, 90, 100, 5, 15}}
+
+	actual := processRate(t, values, IncreaseType, CounterOptions{})
+	assert.InDeltaSlice(t, []float64{35 * 1.25}, actual[0], 1e-9, "default should reset to zero")
+
+	actual = processRate(t, values, IncreaseType, CounterOptions{CounterMaxValue: 105})
+	assert.InDeltaSlice(t, []float64{40 * 1.25}, actual[0], 1e-9)
+
+	actual = processRate(t, values, RateType, CounterOptions{CounterMaxValue: 105})
+	assert.InDeltaSlice(t, []float64{40 * 1.25 / 300}, actual[0], 1e-9)
+}
+
+func TestIncreaseWithResetTolerance(t *testing.T) {
+	// The counter dips by 0.001 from rounding, which is not a reset within the tolerance
+	values := [][]float64{{math.NaN(), 80, 90, 100, 99.999, 110}}
+
+	actual := processRate(t, values, IncreaseType, CounterOptions{})
+	assert.InDeltaSlice(t, []float64{(30 + 100) * 1.25}, actual[0], 1e-9, "default should reset")
+
+	actual = processRate(t, values, IncreaseType, CounterOptions{ResetTolerance: 0.01})
+	assert.InDeltaSlice(t, []float64{30 * 1.25}, actual[0], 1e-9)
+
+	// Larger decreases are still resets
+	values = [][]float64{{math.NaN(), 80, 90, 100, 5, 15}}
+	actual = processRate(t, values, IncreaseType, CounterOptions{ResetTolerance: 0.01})
+	assert.InDeltaSlice(t, []float64{35 * 1.25}, actual[0], 1e-9)
+}
+
+func TestDeltaNonNegative(t *testing.T) {
+	// The first gauge dips over the window, the second only increases
+	values := [][]float64{
+		{math.NaN(), 100, 101, 97, 104, 98},
+		{math.NaN(), 10, 20, 30, 40, 50},
+	}
+
+	actual := processRate(t, values, DeltaType, CounterOptions{})
+	assert.InDeltaSlice(t, []float64{-2 * 1.25}, actual[0], 1e-9, "default should match Prometheus")
+
+	now := time.Now()
+	bounds := block.Bounds{Start: now, End: now.Add(5 * time.Minute), StepSize: time.Minute}
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	warnings := transform.NewWarnings()
+	c.Options = transform.Options{Warnings: warnings}
+	op, err := NewRateOp([]interface{}{5 * time.Minute}, DeltaType, CounterOptions{NonNegative: true})
+	require.NoError(t, err)
+	err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValues(bounds, values))
+	require.NoError(t, err)
+
+	require.Len(t, sink.Values, 2)
+	assert.Equal(t, []float64{0}, sink.Values[0])
+	assert.InDeltaSlice(t, []float64{40 * 1.25}, sink.Values[1], 1e-9)
+	assert.Len(t, warnings.Drain(), 1)
+}
+
+func TestRateWithEqualTimestamps(t *testing.T) {
+	now := time.Now()
+	datapoints := ts.Datapoints{
+		{Timestamp: now, Value: 1},
+		{Timestamp: now, Value: 5},
+		{Timestamp: now, Value: 9},
+	}
+
+	for _, opType := range []string{RateType, IncreaseType, DeltaType} {
+		for _, opts := range []CounterOptions{{}, {DisableExtrapolation: true}} {
+			op, err := NewRateOp([]interface{}{5 * time.Minute}, opType, opts)
+			require.NoError(t, err)
+			c, _ := executor.NewControllerWithSink(parser.NodeID(1))
+			warnings := transform.NewWarnings()
+			c.Options = transform.Options{Warnings: warnings}
+
+			actual := op.processorFn(op, c).Process(datapoints, now.Add(time.Minute))
+			assert.True(t, math.IsNaN(actual), "%s should omit the step, found %v", opType, actual)
+			assert.Len(t, warnings.Drain(), 1)
+		}
+	}
+}
+
+func TestRateWithTooFewValues(t *testing.T) {
+	values := [][]float64{{math.NaN(), math.NaN(), math.NaN(), math.NaN(), math.NaN(), 1}}
+	actual := processRate(t, values, RateType, CounterOptions{})
+	test.EqualsWithNans(t, [][]float64{{math.NaN()}}, actual)
+}
+
+func TestRateWithInvalidArgs(t *testing.T) {
+	_, err := NewRateOp([]interface{}{5 * time.Minute}, "irate", CounterOptions{})
+	assert.Error(t, err)
+
+	_, err = NewRateOp([]interface{}{5 * time.Minute}, RateType, CounterOptions{CounterMaxValue: -1})
+	assert.Error(t, err)
+
+	_, err = NewRateOp([]interface{}{1.0}, RateType, CounterOptions{})
+	assert.Error(t, err)
+
+	_, err = NewRateOp([]interface{}{5 * time.Minute}, RateType, CounterOptions{MinSamples: -1})
+	assert.Error(t, err)
+
+	_, err = NewRateOp([]interface{}{5 * time.Minute}, RateType, CounterOptions{ResetTolerance: -1})
+	assert.Error(t, err)
+
+	_, err = NewRateOp([]interface{}{5 * time.Minute}, IncreaseType, CounterOptions{NonNegative: true})
+	assert.Error(t, err)
+}
+
+func TestRateWithMinSamples(t *testing.T) {
+	// The window only has three real samples
+	values := [][]float64{{math.NaN(), math.NaN(), math.NaN(), 10, 20, 30}}
+	for _, optype := range []string{RateType, IncreaseType, DeltaType} {
+		actual := processRate(t, values, optype, CounterOptions{})
+		require.Len(t, actual, 1)
+		assert.False(t, math.IsNaN(actual[0][0]), "two samples are enough by default for %s", optype)
+
+		actual = processRate(t, values, optype, CounterOptions{MinSamples: 3})
+		assert.False(t, math.IsNaN(actual[0][0]), optype)
+
+		actual = processRate(t, values, optype, CounterOptions{MinSamples: 4})
+		assert.True(t, math.IsNaN(actual[0][0]), "windows with fewer samples have no value for %s", optype)
+	}
+}
+
+func TestRateKeepMetricNames(t *testing.T) {
+	values := [][]float64{{math.NaN(), 10, 20, 30, 40, 50}}
+	now := time.Now()
+	bounds := block.Bounds{
+		Start:    now,
+		End:      now.Add(5 * time.Minute),
+		StepSize: time.Minute,
+	}
+
+	metas := []block.SeriesMeta{{Tags: models.Tags{models.MetricName: "requests", "job": "api"}}}
+	process := func(keep bool) []block.SeriesMeta {
+		op, err := NewRateOp([]interface{}{5 * time.Minute}, RateType, CounterOptions{})
+		require.NoError(t, err)
+		op.KeepMetricNames = keep
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+		require.NoError(t, err)
+		return sink.Metas
+	}
+
+	assert.Equal(t, models.Tags{"job": "api"}, process(false)[0].Tags, "rate drops the name by default")
+	assert.Equal(t, metas[0].Tags, process(true)[0].Tags)
+}
+
+func TestRateWindowBoundaries(t *testing.T) {
+	// The first sample lies exactly on the left edge of the 5m window and the last exactly
+	// on the evaluation time, which is the right edge
+	values := [][]float64{
+		{1, math.NaN(), math.NaN(), 4, math.NaN(), 32},
+		{1, math.NaN(), 4, math.NaN(), math.NaN(), math.NaN()},
+	}
+
+	now := time.Now()
+	bounds := block.Bounds{
+		Start:    now,
+		End:      now.Add(5 * time.Minute),
+		StepSize: time.Minute,
+	}
+
+	process := func(leftInclusive bool) [][]float64 {
+		op, err := NewRateOp([]interface{}{5 * time.Minute}, DeltaType, CounterOptions{DisableExtrapolation: true})
+		require.NoError(t, err)
+		op.LeftInclusive = leftInclusive
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValues(bounds, values))
+		require.NoError(t, err)
+		return sink.Values
+	}
+
+	leftOpen := process(false)
+	assert.Equal(t, []float64{28}, leftOpen[0], "the right edge is included and the left edge is not")
+	assert.True(t, math.IsNaN(leftOpen[1][0]), "a single sample away from the left edge is too few")
+
+	closed := process(true)
+	assert.Equal(t, []float64{31}, closed[0], "both edges are included")
+	assert.Equal(t, []float64{3}, closed[1])
+}
+
+func TestRateGaugeWarning(t *testing.T) {
+	now := time.Now()
+	values := [][]float64{
+		{10, 20, 30, 40, 50, 60},
+		{50, 45, 48, 41, 44, 38},
+	}
+
+	bounds := block.Bounds{
+		Start:    now,
+		End:      now.Add(5 * time.Minute),
+		StepSize: time.Minute,
+	}
+
+	tests := []struct {
+		name     string
+		optype   string
+		values   [][]float64
+		enabled  bool
+		expected []string
+	}{
+		{name: "gauge rate", optype: RateType, values: values, enabled: true,
+			expected: []string{"rate applied to series which look like gauges, consider delta or deriv instead"}},
+		{name: "gauge increase", optype: IncreaseType, values: values, enabled: true,
+			expected: []string{"increase applied to series which look like gauges, consider delta or deriv instead"}},
+		{name: "disabled", optype: RateType, values: values},
+		{name: "delta", optype: DeltaType, values: values, enabled: true},
+		{name: "counter resets", optype: RateType, values: [][]float64{{10, 20, 1, 11, 2, 12}}, enabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := processRate(t, tt.values, tt.optype, CounterOptions{})
+
+			warnings := transform.NewWarnings()
+			c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+			c.Options = transform.Options{WarnOnGaugeRates: tt.enabled, Warnings: warnings}
+			op, err := NewRateOp([]interface{}{5 * time.Minute}, tt.optype, CounterOptions{})
+			require.NoError(t, err)
+			err = op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValues(bounds, tt.values))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, warnings.Drain())
+			test.EqualsWithNans(t, expected, sink.Values)
+		})
+	}
+}
+
+func TestIncreaseWithMergeReplicas(t *testing.T) {
+	// The replicas are scraped alternately, and the second lags the first by 2
+	nan := math.NaN()
+	values := [][]float64{
+		{nan, 20, nan, 40, nan, 60},
+		{nan, nan, 18, nan, 38, nan},
+		{nan, 10, 20, 30, 40, 50},
+	}
+
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "requests", "job": "api", ReplicaTag: "a"}},
+		{Tags: models.Tags{models.MetricName: "requests", "job": "api", ReplicaTag: "b"}},
+		{Tags: models.Tags{models.MetricName: "requests", "job": "web"}},
+	}
+
+	now := time.Now()
+	bounds := block.Bounds{Start: now, End: now.Add(5 * time.Minute), StepSize: time.Minute}
+	process := func(values [][]float64, metas []block.SeriesMeta) *executor.SinkNode {
+		op, err := NewRateOp([]interface{}{5 * time.Minute}, IncreaseType, CounterOptions{MergeReplicas: true})
+		require.NoError(t, err)
+		c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+		require.NoError(t, op.Node(c).Process(parser.NodeID(0), test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values)))
+		return sink
+	}
+
+	sink := process(values, metas)
+	require.Len(t, sink.Values, 2)
+	assert.InDeltaSlice(t, []float64{40 * 1.25}, sink.Values[0], 1e-9, "the lagging replica does not look like resets")
+	assert.InDeltaSlice(t, []float64{40 * 1.25}, sink.Values[1], 1e-9, "series without replicas are unaffected")
+	require.Len(t, sink.Metas, 2)
+	assert.Equal(t, models.Tags{"job": "api"}, sink.Metas[0].Tags)
+
+	// Without the replica tag, the interleaved samples are taken as a single counter with resets
+	interleaved := []float64{nan, 20, 18, 40, 38, 60}
+	sink = process([][]float64{interleaved}, []block.SeriesMeta{{Tags: models.Tags{"job": "api"}}})
+	assert.InDeltaSlice(t, []float64{(40 + 18 + 38) * 1.25}, sink.Values[0], 1e-9)
+
+	// Drops to near zero are still resets
+	values = [][]float64{{nan, 20, nan, 40, nan, 10}, {nan, nan, 18, nan, 38, nan}}
+	sink = process(values, metas[:2])
+	assert.InDeltaSlice(t, []float64{(20 + 10) * 1.25}, sink.Values[0], 1e-9)
+
+	_, err := NewRateOp([]interface{}{5 * time.Minute}, DeltaType, CounterOptions{MergeReplicas: true})
+	assert.Error(t, err)
+}
+
+func TestMergeCountersWithBuilder(t *testing.T) {
+	metas := []block.SeriesMeta{
+		{Tags: models.Tags{models.MetricName: "requests", ReplicaTag: "a"}},
+		{Tags: models.Tags{models.MetricName: "requests", ReplicaTag: "b"}},
+	}
+
+	values, bounds := test.GenerateValuesAndBounds(nil, nil)
+	errLimit := fmt.Errorf("too many series")
+	var built []block.SeriesMeta
+	_, _, err := mergeCounters(func(_ block.Metadata, seriesMeta []block.SeriesMeta) (block.Builder, error) {
+		built = seriesMeta
+		return nil, errLimit
+	}, test.NewBlockFromValuesWithSeriesMeta(bounds, metas, values))
+	assert.Equal(t, errLimit, err)
+	require.Len(t, built, 1, "the replicas are merged with the builder")
+	assert.Equal(t, models.Tags{models.MetricName: "requests"}, built[0].Tags)
+}
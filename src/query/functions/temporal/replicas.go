@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package temporal
+
+import (
+	"math"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+)
+
+// ReplicaTag distinguishes the replicas of a series, which hold the same counter but may be
+// scraped at different times
+const ReplicaTag = "replica"
+
+// mergeCounters merges the series which only differ by the ReplicaTag into a single counter,
+// keeping the others as they are. A replica behind the others decreases the merged values
+// slightly, so decreases are only kept when they look like counter resets, and the merged
+// counter otherwise holds its previous value. The merged block is built with the builder, and
+// is only returned if the block has replicas to merge
+func mergeCounters(newBuilder block.BuilderFn, b block.Block) (block.Block, bool, error) {
+	iter, err := b.SeriesIter()
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer iter.Close()
+	metas := iter.SeriesMeta()
+	hasReplicas := false
+	for _, meta := range metas {
+		if _, ok := meta.Tags[ReplicaTag]; ok {
+			hasReplicas = true
+			break
+		}
+	}
+
+	if !hasReplicas {
+		return nil, false, nil
+	}
+
+	var (
+		groupMetas []block.SeriesMeta
+		groups     = make(map[string]int, len(metas))
+		replicas   [][][]float64
+	)
+
+	for iter.Next() {
+		series, err := iter.Current()
+		if err != nil {
+			return nil, false, err
+		}
+
+		meta := series.Meta
+		if _, ok := meta.Tags[ReplicaTag]; ok {
+			tags := make(models.Tags, len(meta.Tags)-1)
+			for k, v := range meta.Tags {
+				if k != ReplicaTag {
+					tags[k] = v
+				}
+			}
+
+			meta.Tags = tags
+		}
+
+		id := meta.Tags.ID()
+		idx, ok := groups[id]
+		if !ok {
+			idx = len(groupMetas)
+			groups[id] = idx
+			groupMetas = append(groupMetas, meta)
+			replicas = append(replicas, nil)
+		}
+
+		replicas[idx] = append(replicas[idx], series.Values())
+	}
+
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return nil, false, err
+	}
+
+	steps := stepIter.StepCount()
+	stepIter.Close()
+	builder, err := newBuilder(iter.Meta(), groupMetas)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return nil, false, err
+	}
+
+	for _, group := range replicas {
+		for i, value := range mergeReplicaValues(group, steps) {
+			if err := builder.AppendValue(i, value); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	return builder.Build(), true, nil
+}
+
+// mergeReplicaValues takes the highest value of the replicas at each step, holding the previous
+// value instead of decreasing unless the decrease looks like a counter reset
+func mergeReplicaValues(replicas [][]float64, steps int) []float64 {
+	merged := make([]float64, steps)
+	prev := math.NaN()
+	for i := range merged {
+		value := math.NaN()
+		for _, values := range replicas {
+			if v := values[i]; !math.IsNaN(v) && (math.IsNaN(value) || v > value) {
+				value = v
+			}
+		}
+
+		if !math.IsNaN(value) && !math.IsNaN(prev) && value < prev && value >= prev*resetRatio {
+			value = prev
+		}
+
+		merged[i] = value
+		if !math.IsNaN(value) {
+			prev = value
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// TimestampType returns the time of each value as seconds since the epoch
+const TimestampType = "timestamp"
+
+// TimestampOptions configures the timestamp function
+type TimestampOptions struct {
+	// SampleTimes returns the timestamps of the underlying samples rather than of the steps,
+	// which differ when consolidation carries a value forward. Steps fall back to the step time
+	// if the block does not retain sample times. Step times are used by default as in Prometheus
+	SampleTimes bool
+}
+
+// TimestampOp stores required properties for timestamp
+type TimestampOp struct {
+	opts TimestampOptions
+}
+
+// NewTimestampOp creates a new timestamp op
+func NewTimestampOp(args []interface{}, opts TimestampOptions) (TimestampOp, error) {
+	if len(args) != 0 {
+		return TimestampOp{}, fmt.Errorf("invalid number of args for timestamp: %d", len(args))
+	}
+
+	return TimestampOp{opts: opts}, nil
+}
+
+// OpType for the operator
+func (o TimestampOp) OpType() string {
+	return TimestampType
+}
+
+// String representation
+func (o TimestampOp) String() string {
+	return fmt.Sprintf("type: %s, sample times: %t", o.OpType(), o.opts.SampleTimes)
+}
+
+// FormatExpr renders the function call on its input
+func (o TimestampOp) FormatExpr(inputs []string) string {
+	return parser.FormatFunction(TimestampType, inputs...)
+}
+
+// UsesSampleTimes returns true if the op needs the sources to retain sample times
+func (o TimestampOp) UsesSampleTimes() bool {
+	return o.opts.SampleTimes
+}
+
+// WithSampleTimes returns the op returning the timestamps of samples
+func (o TimestampOp) WithSampleTimes() parser.Params {
+	o.opts.SampleTimes = true
+	return o
+}
+
+// Node creates an execution node
+func (o TimestampOp) Node(controller *transform.Controller) transform.OpNode {
+	return &TimestampNode{op: o, controller: controller}
+}
+
+// TimestampNode is an execution node
+type TimestampNode struct {
+	op         TimestampOp
+	controller *transform.Controller
+}
+
+// Process the block
+func (n *TimestampNode) Process(ID parser.NodeID, b block.Block) error {
+	stepIter, err := b.StepIter()
+	if err != nil {
+		return err
+	}
+
+	var sampled block.SampleTimesBlock
+	if n.op.opts.SampleTimes {
+		sampled, _ = b.(block.SampleTimesBlock)
+	}
+
+	builder, err := n.controller.BlockBuilder(stepIter.Meta(), utils.DropMetricNames(stepIter.SeriesMeta()))
+	if err != nil {
+		return err
+	}
+
+	if err := builder.AddCols(stepIter.StepCount()); err != nil {
+		return err
+	}
+
+	for index := 0; stepIter.Next(); index++ {
+		step, err := stepIter.Current()
+		if err != nil {
+			return err
+		}
+
+		for i, value := range step.Values() {
+			if math.IsNaN(value) {
+				if err := builder.AppendValue(index, math.NaN()); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			t := step.Time()
+			if sampled != nil {
+				if sampleTime, ok := sampled.SampleTime(i, index); ok {
+					t = sampleTime
+				}
+			}
+
+			if err := builder.AppendValue(index, timestampSeconds(t)); err != nil {
+				return err
+			}
+		}
+	}
+
+	nextBlock := builder.Build()
+	defer nextBlock.Close()
+	return n.controller.Process(nextBlock)
+}
+
+// timestampSeconds returns the time as seconds since the epoch with millisecond precision
+func timestampSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()/int64(time.Millisecond)) / 1000
+}
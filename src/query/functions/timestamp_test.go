@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package functions
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/test"
+	"github.com/m3db/m3/src/query/test/executor"
+	"github.com/m3db/m3/src/query/test/fixtures"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processTimestamp fetches a series with a sample carried forward, shifted by the offset as for an
+// offset selector, and returns the timestamps
+func processTimestamp(
+	t *testing.T,
+	opts TimestampOptions,
+	consolidation ts.ConsolidationOptions,
+	offset time.Duration,
+) [][]float64 {
+	start := time.Unix(600, 0)
+	store := fixtures.NewMockStorage(fixtures.TestSeries{
+		Tags: models.Tags{models.MetricName: "up"},
+		Datapoints: ts.Datapoints{
+			{Timestamp: start, Value: 1},
+			{Timestamp: start.Add(90 * time.Second), Value: 2},
+			{Timestamp: start.Add(3 * time.Minute), Value: 3},
+		},
+	})
+
+	matcher, err := models.NewMatcher(models.MatchEqual, models.MetricName, "up")
+	require.NoError(t, err)
+	result, err := store.FetchBlocks(context.TODO(), &storage.FetchQuery{
+		TagMatchers: models.Matchers{matcher},
+		Start:       start,
+		End:         start.Add(4 * time.Minute),
+		Interval:    time.Minute,
+	}, &storage.FetchOptions{Consolidation: consolidation})
+	require.NoError(t, err)
+	require.Len(t, result.Blocks, 1)
+	b := result.Blocks[0]
+	if offset != 0 {
+		b = &offsetBlock{Block: b, offset: offset}
+	}
+
+	op, err := NewTimestampOp(nil, opts)
+	require.NoError(t, err)
+	c, sink := executor.NewControllerWithSink(parser.NodeID(1))
+	err = op.Node(c).Process(parser.NodeID(0), b)
+	require.NoError(t, err)
+	require.Len(t, sink.Metas, 1)
+	assert.Empty(t, sink.Metas[0].Tags, "the metric name is dropped")
+	return sink.Values
+}
+
+func TestTimestampOfSteps(t *testing.T) {
+	stepTimes := [][]float64{{600, 660, 720, 780, math.NaN()}}
+	retained := ts.ConsolidationOptions{RetainSampleTimes: true}
+	test.EqualsWithNans(t, stepTimes, processTimestamp(t, TimestampOptions{}, retained, 0))
+
+	// Without retained sample times, the sample mode falls back to the step times
+	test.EqualsWithNans(t, stepTimes, processTimestamp(t, TimestampOptions{SampleTimes: true}, ts.ConsolidationOptions{}, 0))
+}
+
+func TestTimestampOfSamples(t *testing.T) {
+	// The second and third steps carry the previous samples forward
+	retained := ts.ConsolidationOptions{RetainSampleTimes: true}
+	actual := processTimestamp(t, TimestampOptions{SampleTimes: true}, retained, 0)
+	test.EqualsWithNans(t, [][]float64{{600, 600, 690, 780, math.NaN()}}, actual)
+}
+
+func TestTimestampWithOffset(t *testing.T) {
+	// Steps move onto the timeline of the query, but samples are still reported at the times they
+	// were taken, e.g. timestamp(up offset 5m) is 5m behind the steps
+	retained := ts.ConsolidationOptions{RetainSampleTimes: true}
+	actual := processTimestamp(t, TimestampOptions{}, retained, 5*time.Minute)
+	test.EqualsWithNans(t, [][]float64{{900, 960, 1020, 1080, math.NaN()}}, actual)
+
+	actual = processTimestamp(t, TimestampOptions{SampleTimes: true}, retained, 5*time.Minute)
+	test.EqualsWithNans(t, [][]float64{{600, 600, 690, 780, math.NaN()}}, actual)
+}
+
+func TestTimestampWithInvalidArgs(t *testing.T) {
+	_, err := NewTimestampOp([]interface{}{1.0}, TimestampOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"sort"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+)
+
+// GroupSeries groups series by the matching tags. If without is set, the matching tags are excluded
+// from the grouping instead. It returns the indices of the series in each group and the metadata
+// for each group, in order of first appearance
+func GroupSeries(matchingTags []string, without bool, opName string, metas []block.SeriesMeta) ([][]int, []block.SeriesMeta) {
+	// Sort a copy of the tags so that the group ids do not depend on the order in the query
+	keys := make([]string, len(matchingTags))
+	copy(keys, matchingTags)
+	sort.Strings(keys)
+
+	tagsFn := func(tags models.Tags) models.Tags { return tags.TagsWithKeys(keys) }
+	if without {
+		tagsFn = func(tags models.Tags) models.Tags { return tags.TagsWithoutKeys(keys) }
+	}
+
+	groupIndices := make(map[string]int)
+	var (
+		buckets    [][]int
+		groupMetas []block.SeriesMeta
+	)
+
+	for i, meta := range metas {
+		tags := tagsFn(meta.Tags)
+		id := tags.ID()
+		idx, ok := groupIndices[id]
+		if !ok {
+			idx = len(buckets)
+			groupIndices[id] = idx
+			buckets = append(buckets, nil)
+			groupMetas = append(groupMetas, block.SeriesMeta{
+				Tags: tags,
+				Name: opName,
+			})
+		}
+
+		buckets[idx] = append(buckets[idx], i)
+	}
+
+	return buckets, groupMetas
+}
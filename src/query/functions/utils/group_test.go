@@ -0,0 +1,61 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var groupMetas = []block.SeriesMeta{
+	{Tags: models.Tags{models.MetricName: "up", "a": "1", "b": "1"}},
+	{Tags: models.Tags{models.MetricName: "up", "a": "1", "b": "2"}},
+	{Tags: models.Tags{models.MetricName: "up", "a": "2", "b": "1"}},
+}
+
+func TestGroupSeriesBy(t *testing.T) {
+	buckets, metas := GroupSeries([]string{"a"}, false, "sum", groupMetas)
+	assert.Equal(t, [][]int{{0, 1}, {2}}, buckets)
+	assert.Equal(t, []block.SeriesMeta{
+		{Tags: models.Tags{"a": "1"}, Name: "sum"},
+		{Tags: models.Tags{"a": "2"}, Name: "sum"},
+	}, metas)
+}
+
+func TestGroupSeriesWithout(t *testing.T) {
+	buckets, metas := GroupSeries([]string{"a"}, true, "sum", groupMetas)
+	assert.Equal(t, [][]int{{0, 2}, {1}}, buckets)
+	assert.Equal(t, []block.SeriesMeta{
+		{Tags: models.Tags{"b": "1"}, Name: "sum"},
+		{Tags: models.Tags{"b": "2"}, Name: "sum"},
+	}, metas)
+}
+
+func TestGroupSeriesWithNoTags(t *testing.T) {
+	buckets, metas := GroupSeries(nil, false, "sum", groupMetas)
+	assert.Equal(t, [][]int{{0, 1, 2}}, buckets)
+	assert.Len(t, metas, 1)
+	assert.Empty(t, metas[0].Tags)
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"github.com/m3db/m3/src/query/block"
+)
+
+// DropMetricName returns a copy of the series metadata without the metric name, for the output
+// of functions which change the meaning of the series. Series without tags, such as scalars,
+// have no name to drop and are returned as is, rather than as series with empty labels
+func DropMetricName(meta block.SeriesMeta) block.SeriesMeta {
+	if len(meta.Tags) == 0 {
+		return meta
+	}
+
+	tags := meta.Tags.WithoutName()
+	return block.SeriesMeta{
+		Tags:   tags,
+		Name:   tags.ID(),
+		Source: meta.Source,
+	}
+}
+
+// DropMetricNames drops the metric name from each of the series metadata
+func DropMetricNames(metas []block.SeriesMeta) []block.SeriesMeta {
+	dropped := make([]block.SeriesMeta, len(metas))
+	for i, meta := range metas {
+		dropped[i] = DropMetricName(meta)
+	}
+
+	return dropped
+}
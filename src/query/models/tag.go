@@ -152,11 +152,21 @@ func (t Tags) ID() string {
 
 // IDWithExcludes returns a string representation of the tags excluding some tag keys
 func (t Tags) IDWithExcludes(excludeKeys ...string) uint64 {
+	return t.idWithExcludes(false, excludeKeys)
+}
+
+// IDWithExcludesKeepingName returns a hash of the tags excluding some tag keys, but unlike
+// IDWithExcludes, including the metric name unless it is explicitly excluded
+func (t Tags) IDWithExcludesKeepingName(excludeKeys ...string) uint64 {
+	return t.idWithExcludes(true, excludeKeys)
+}
+
+func (t Tags) idWithExcludes(keepName bool, excludeKeys []string) uint64 {
 	sortedKeys, bufLength := t.sortKeys()
 	b := make([]byte, 0, bufLength)
 	for _, k := range sortedKeys {
-		// Always exclude the metric name by default
-		if k == MetricName {
+		// Exclude the metric name by default
+		if k == MetricName && !keepName {
 			continue
 		}
 
@@ -226,3 +236,39 @@ func (t Tags) WithoutName() Tags {
 	}
 	return tags
 }
+
+// TagsWithoutKeys returns only the tags which do not have the given keys, always excluding the name tag
+func (t Tags) TagsWithoutKeys(excludeKeys []string) Tags {
+	tags := make(Tags, len(t))
+	for k, v := range t {
+		if k == MetricName {
+			continue
+		}
+
+		found := false
+		for _, n := range excludeKeys {
+			if n == k {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			tags[k] = v
+		}
+	}
+
+	return tags
+}
+
+// TagsWithKeys returns only the tags which have the given keys
+func (t Tags) TagsWithKeys(includeKeys []string) Tags {
+	tags := make(Tags, len(includeKeys))
+	for _, k := range includeKeys {
+		if v, ok := t[k]; ok {
+			tags[k] = v
+		}
+	}
+
+	return tags
+}
@@ -110,3 +110,23 @@ func TestTagID(t *testing.T) {
 	tags["t2"] = "v2"
 	assert.Equal(t, tags.ID(), "t1=v1,t2=v2,")
 }
+
+func TestTagsWithKeys(t *testing.T) {
+	tags := Tags{MetricName: "up", "t1": "v1", "t2": "v2"}
+	assert.Equal(t, Tags{"t1": "v1"}, tags.TagsWithKeys([]string{"t1", "t3"}))
+	assert.Equal(t, Tags{MetricName: "up"}, tags.TagsWithKeys([]string{MetricName}))
+}
+
+func TestTagsWithoutKeys(t *testing.T) {
+	tags := Tags{MetricName: "up", "t1": "v1", "t2": "v2"}
+	assert.Equal(t, Tags{"t2": "v2"}, tags.TagsWithoutKeys([]string{"t1"}))
+	assert.Equal(t, Tags{"t1": "v1", "t2": "v2"}, tags.TagsWithoutKeys(nil))
+}
+
+func TestTagIDWithExcludesKeepingName(t *testing.T) {
+	tags := Tags{MetricName: "up", "t1": "v1", "t2": "v2"}
+	other := Tags{MetricName: "down", "t1": "v1", "t2": "v2"}
+	assert.Equal(t, tags.IDWithExcludes("t2"), other.IDWithExcludes("t2"))
+	assert.NotEqual(t, tags.IDWithExcludesKeepingName("t2"), other.IDWithExcludesKeepingName("t2"))
+	assert.Equal(t, tags.IDWithExcludesKeepingName(MetricName), other.IDWithExcludesKeepingName(MetricName))
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m3db/m3/src/query/util"
+)
+
+// FormattableParams are params which can be rendered back into a query expression
+type FormattableParams interface {
+	Params
+	// FormatExpr renders the op using the rendered expressions of its inputs, in input order
+	FormatExpr(inputs []string) string
+}
+
+// InfixParams are params rendered as a binary operator between their inputs, which are
+// parenthesized by precedence when the expression is formatted
+type InfixParams interface {
+	FormattableParams
+	// Precedence returns how tightly the operator binds, a higher precedence binding tighter
+	Precedence() int
+	// OperandPrecedence returns the lowest precedence of an input expression at the index which
+	// does not need parentheses
+	OperandPrecedence(input int) int
+}
+
+// MaxPrecedence is the precedence of expressions which are never parenthesized, such as selectors
+// and function calls
+const MaxPrecedence = math.MaxInt32
+
+// binaryPrecedence is the precedence of each PromQL binary operator
+var binaryPrecedence = map[string]int{
+	"or":     1,
+	"and":    2,
+	"unless": 2,
+	"==":     3,
+	"!=":     3,
+	"<":      3,
+	"<=":     3,
+	">":      3,
+	">=":     3,
+	"+":      4,
+	"-":      4,
+	"*":      5,
+	"/":      5,
+	"%":      5,
+	"^":      6,
+}
+
+// BinaryPrecedence returns the precedence of a binary operator, operators which are not part of
+// PromQL binding loosest
+func BinaryPrecedence(op string) int {
+	return binaryPrecedence[op]
+}
+
+// OperandPrecedence returns the lowest precedence of an operand of the binary operator which does not
+// need parentheses. Operators are left associative apart from ^, so an operand of the same precedence
+// only needs parentheses on the other side
+func OperandPrecedence(op string, rhs bool) int {
+	precedence := BinaryPrecedence(op)
+	if rhs != (op == "^") {
+		return precedence + 1
+	}
+
+	return precedence
+}
+
+// FormatFunction renders a function call with the given rendered arguments
+func FormatFunction(name string, args ...string) string {
+	return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+}
+
+// FormatLiteral renders a scalar or string literal argument
+func FormatLiteral(arg interface{}) string {
+	switch v := arg.(type) {
+	case float64:
+		return util.FormatValue(v)
+	case string:
+		return strconv.Quote(v)
+	case time.Duration:
+		return util.FormatDuration(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// FormatKeepMetricNames appends the keep_metric_names modifier to the expression when set
+func FormatKeepMetricNames(expr string, keep bool) string {
+	if !keep {
+		return expr
+	}
+
+	return expr + " keep_metric_names"
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package promql
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// Format renders a canonical PromQL expression from the nodes and edges of a parsed DAG
+func Format(nodes parser.Nodes, edges parser.Edges) (string, error) {
+	ops := make(map[parser.NodeID]parser.Params, len(nodes))
+	for _, node := range nodes {
+		ops[node.ID] = node.Op
+	}
+
+	// Parents are kept in edge order, which is the order of the op inputs
+	parents := make(map[parser.NodeID][]parser.NodeID, len(nodes))
+	hasChild := make(map[parser.NodeID]bool, len(nodes))
+	for _, edge := range edges {
+		parents[edge.ChildID] = append(parents[edge.ChildID], edge.ParentID)
+		hasChild[edge.ParentID] = true
+	}
+
+	var roots []parser.NodeID
+	for _, node := range nodes {
+		if !hasChild[node.ID] {
+			roots = append(roots, node.ID)
+		}
+	}
+
+	if len(roots) != 1 {
+		return "", fmt.Errorf("expected a single root node to format, found: %d", len(roots))
+	}
+
+	// format returns the rendered expression of the node along with its precedence
+	var format func(ID parser.NodeID) (string, int, error)
+	format = func(ID parser.NodeID) (string, int, error) {
+		op, ok := ops[ID].(parser.FormattableParams)
+		if !ok {
+			return "", 0, fmt.Errorf("unable to format node: %s, op: %v", ID, ops[ID])
+		}
+
+		infix, isInfix := op.(parser.InfixParams)
+		inputs := make([]string, 0, len(parents[ID]))
+		for i, parentID := range parents[ID] {
+			input, precedence, err := format(parentID)
+			if err != nil {
+				return "", 0, err
+			}
+
+			if isInfix && precedence < infix.OperandPrecedence(i) {
+				input = "(" + input + ")"
+			}
+
+			inputs = append(inputs, input)
+		}
+
+		precedence := parser.MaxPrecedence
+		if isInfix {
+			precedence = infix.Precedence()
+		}
+
+		return op.FormatExpr(inputs), precedence, nil
+	}
+
+	formatted, _, err := format(roots[0])
+	return formatted, err
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package promql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/functions/logical"
+	"github.com/m3db/m3/src/query/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formatQuery(t *testing.T, q string) string {
+	p, err := Parse(q)
+	require.NoError(t, err)
+	nodes, edges, err := p.DAG()
+	require.NoError(t, err)
+	formatted, err := Format(nodes, edges)
+	require.NoError(t, err)
+	return formatted
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{query: `up`, expected: `up`},
+		{query: `http_requests_total{method="GET", code=~"5.."} offset 5m`, expected: `http_requests_total{method="GET", code=~"5.."} offset 5m`},
+		{query: `{__name__="up"}`, expected: `up`},
+		{query: `sum(up) by (service, job)`, expected: `sum by (service, job) (up)`},
+		{query: `sum without (instance) (up)`, expected: `sum without (instance) (up)`},
+		{query: `sum(up)`, expected: `sum(up)`},
+		{query: `quantile(0.9, up) by (job)`, expected: `quantile by (job) (0.9, up)`},
+		{query: `topk(5, up)`, expected: `topk(5, up)`},
+		{query: `abs(up)`, expected: `abs(up)`},
+		{query: `clamp_min(up, 1.5)`, expected: `clamp_min(up, 1.5)`},
+		{query: `round(up)`, expected: `round(up)`},
+		{query: `histogram_fraction(0, 0.5, x_bucket)`, expected: `histogram_fraction(0, 0.5, x_bucket)`},
+		{query: `deriv(up[5m])`, expected: `deriv(up[5m])`},
+		{query: `rate(http_requests_total[5m])`, expected: `rate(http_requests_total[5m])`},
+		{query: `quantile_over_time(0.5, up[10m])`, expected: `quantile_over_time(0.5, up[10m])`},
+		{query: `predict_linear(up[1h] offset 1d, 3600)`, expected: `predict_linear(up[1h] offset 1d, 3600)`},
+		{query: `label_replace(up, "host", "$1", "instance", "(.*):.*")`, expected: `label_replace(up, "host", "$1", "instance", "(.*):.*")`},
+		{query: `label_replace(up, "__name__", "up_renamed", "", "")`, expected: `label_replace(up, "__name__", "up_renamed", "", "")`},
+		{query: `label_template(up, "addr", "{{.instance}}:{{.port}}")`, expected: `label_template(up, "addr", "{{.instance}}:{{.port}}")`},
+		{query: `label_from_value(up, "value")`, expected: `label_from_value(up, "value")`},
+		{query: `count_scalar(up)`, expected: `count_scalar(up)`},
+		{query: `count(up)`, expected: `count(up)`},
+		{query: `count by (job) (up)`, expected: `count by (job) (up)`},
+		{query: `last_over_time(up[5m])`, expected: `last_over_time(up[5m])`},
+		{query: `(a * b) keep_metric_names`, expected: `(a * b) keep_metric_names`},
+		{query: `(a * b) keep_metric_names / c`, expected: `(a * b) keep_metric_names / c`},
+		{query: `(up * 2) keep_metric_names`, expected: `(up * 2) keep_metric_names`},
+		{query: `(up > bool 1) keep_metric_names`, expected: `(up > bool 1) keep_metric_names`},
+		{query: `rate(up[5m]) keep_metric_names`, expected: `rate(up[5m]) keep_metric_names`},
+		{query: `abs(up) keep_metric_names + 1`, expected: `abs(up) keep_metric_names + 1`},
+		{query: `up and on(job) down`, expected: `up and on(job) down`},
+		{query: `a / ignoring(code) b`, expected: `a / ignoring(code) b`},
+		{query: `up and ignoring(instance) down`, expected: `up and ignoring(instance) down`},
+		{query: `up >= on(job) down`, expected: `up >= on(job) down`},
+		{query: `up != bool down`, expected: `up != bool down`},
+		{query: `up > 5`, expected: `up > 5`},
+		{query: `0.5 <= bool rate(up[5m])`, expected: `0.5 <= bool rate(up[5m])`},
+		{query: `a * on(instance) group_left b`, expected: `a * on(instance) group_left() b`},
+		{query: `(up and down) and sum(x) by (job)`, expected: `up and down and sum by (job) (x)`},
+		{query: `a - (b - c)`, expected: `a - (b - c)`},
+		{query: `(a - b) - c`, expected: `a - b - c`},
+		{query: `(a * b) + c`, expected: `a * b + c`},
+		{query: `a * (b + c)`, expected: `a * (b + c)`},
+		{query: `a ^ (b ^ c)`, expected: `a ^ b ^ c`},
+		{query: `(a ^ b) ^ c`, expected: `(a ^ b) ^ c`},
+		{query: `(a + b) > 5`, expected: `a + b > 5`},
+		{query: `2 * (a + b)`, expected: `2 * (a + b)`},
+		{query: `rate(a[5m]) / on(job) rate(b[5m])`, expected: `rate(a[5m]) / on(job) rate(b[5m])`},
+	}
+
+	for _, tt := range tests {
+		formatted := formatQuery(t, tt.query)
+		assert.Equal(t, tt.expected, formatted, tt.query)
+		assert.Equal(t, formatted, formatQuery(t, formatted), "formatting should be stable for %s", tt.query)
+	}
+}
+
+func TestFormatGroupLeft(t *testing.T) {
+	op := logical.BaseOp{
+		OperatorType: "*",
+		Matching: &logical.VectorMatching{
+			Card:           logical.CardManyToOne,
+			MatchingLabels: []string{"instance", "job"},
+			On:             true,
+			Include:        []string{"version"},
+		},
+	}
+
+	assert.Equal(t, `a * on(instance, job) group_left(version) b`, op.FormatExpr([]string{"a", "b"}))
+
+	op.Matching = &logical.VectorMatching{
+		Card:           logical.CardOneToMany,
+		MatchingLabels: []string{"instance"},
+	}
+	op.ReturnBool = true
+	assert.Equal(t, `a * bool ignoring(instance) group_right() b`, op.FormatExpr([]string{"a", "b"}))
+}
+
+func TestFormatDurationLiteral(t *testing.T) {
+	// Durations render as PromQL durations so that formatted queries parse again
+	assert.Equal(t, "5m", parser.FormatLiteral(5*time.Minute))
+	assert.Equal(t, "90m", parser.FormatLiteral(90*time.Minute))
+	assert.Equal(t, "1d", parser.FormatLiteral(24*time.Hour))
+	assert.Equal(t, "1500ms", parser.FormatLiteral(1500*time.Millisecond))
+}
+
+func TestFormatUnformattableOp(t *testing.T) {
+	_, err := Format(parser.Nodes{{ID: parser.NodeID("0"), Op: unformattableOp{}}}, nil)
+	assert.Error(t, err)
+}
+
+type unformattableOp struct{}
+
+func (unformattableOp) OpType() string { return "unknown" }
+func (unformattableOp) String() string { return "unknown" }
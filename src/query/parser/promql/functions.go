@@ -0,0 +1,137 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package promql
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3/src/query/functions"
+	"github.com/m3db/m3/src/query/functions/linear"
+	"github.com/m3db/m3/src/query/functions/tag"
+	"github.com/m3db/m3/src/query/functions/temporal"
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// functionConstructor creates the op for a function call from its name and arguments
+type functionConstructor func(name string, argValues []interface{}) (parser.Params, error)
+
+// functionConstructors holds the constructor for each supported function, keyed by function name
+var functionConstructors = make(map[string]functionConstructor)
+
+// registerFunction makes the functions with the given names resolvable by NewFunctionExpr, so that
+// new functions, e.g. another *_over_time variant, only need to be registered here
+func registerFunction(fn functionConstructor, names ...string) {
+	for _, name := range names {
+		functionConstructors[name] = fn
+	}
+}
+
+func init() {
+	registerFunction(func(name string, _ []interface{}) (parser.Params, error) {
+		return linear.NewMathOp(name)
+	}, linear.AbsType, linear.CeilType, linear.ExpType, linear.FloorType, linear.LnType,
+		linear.Log10Type, linear.Log2Type, linear.SqrtType)
+
+	registerFunction(func(string, []interface{}) (parser.Params, error) {
+		return linear.NewAbsentOp(), nil
+	}, linear.AbsentType)
+
+	registerFunction(func(name string, argValues []interface{}) (parser.Params, error) {
+		return linear.NewClampOp(argValues, name)
+	}, linear.ClampMinType, linear.ClampMaxType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return linear.NewHistogramFractionOp(argValues)
+	}, linear.HistogramFractionType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return linear.NewHistogramQuantileOp(argValues)
+	}, linear.HistogramQuantileType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return linear.NewRoundOp(argValues)
+	}, linear.RoundType)
+
+	registerFunction(func(name string, _ []interface{}) (parser.Params, error) {
+		return linear.NewDateOp(name)
+	}, linear.DayOfMonthType, linear.DayOfWeekType, linear.DaysInMonthType, linear.HourType,
+		linear.MinuteType, linear.MonthType, linear.YearType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return tag.NewLabelReplaceOp(argValues, tag.LabelReplaceOptions{})
+	}, tag.LabelReplaceType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return tag.NewLabelTemplateOp(argValues)
+	}, tag.LabelTemplateType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return tag.NewLabelFromValueOp(argValues)
+	}, tag.LabelFromValueType)
+
+	// count without grouping is resolved to CountOp by NewOperator, as its single untagged output
+	// series needs no grouping of the input series
+	registerFunction(func(string, []interface{}) (parser.Params, error) {
+		return functions.CountOp{}, nil
+	}, functions.CountType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return functions.NewCountScalarOp(argValues)
+	}, functions.CountScalarType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return functions.NewTimestampOp(argValues, functions.TimestampOptions{})
+	}, functions.TimestampType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return functions.NewTimeOp(argValues)
+	}, functions.TimeType)
+
+	registerFunction(func(name string, argValues []interface{}) (parser.Params, error) {
+		return functions.NewSortOp(argValues, name)
+	}, functions.SortType, functions.SortDescType)
+
+	registerFunction(func(name string, argValues []interface{}) (parser.Params, error) {
+		return temporal.NewLinearRegressionOp(argValues, name, temporal.LinearRegressionOptions{})
+	}, temporal.DerivType, temporal.PredictLinearType)
+
+	registerFunction(func(name string, argValues []interface{}) (parser.Params, error) {
+		return temporal.NewRateOp(argValues, name, temporal.CounterOptions{})
+	}, temporal.RateType, temporal.IncreaseType, temporal.DeltaType)
+
+	registerFunction(func(_ string, argValues []interface{}) (parser.Params, error) {
+		return temporal.NewQuantileOverTimeOp(argValues, temporal.QuantileOptions{})
+	}, temporal.QuantileOverTimeType)
+
+	registerFunction(func(name string, argValues []interface{}) (parser.Params, error) {
+		return temporal.NewOverTimeOp(argValues, name)
+	}, temporal.LastOverTimeType, temporal.PresentOverTimeType)
+}
+
+// NewFunctionExpr creates a new function expr based on the type
+func NewFunctionExpr(name string, argValues []interface{}) (parser.Params, error) {
+	fn, ok := functionConstructors[name]
+	if !ok {
+		return nil, fmt.Errorf("function not supported: %s", name)
+	}
+
+	return fn(name, argValues)
+}
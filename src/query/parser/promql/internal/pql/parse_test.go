@@ -0,0 +1,113 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseM3Functions(t *testing.T) {
+	queries := []string{
+		`count_scalar(up)`,
+		`histogram_fraction(0, 0.5, rate(http_bucket[5m]))`,
+		`label_from_value(up, "value")`,
+		`label_template(up, "dst", "{{.instance}}:{{.port}}")`,
+		`last_over_time(up[5m])`,
+		`present_over_time(up[5m])`,
+	}
+
+	for _, q := range queries {
+		expr, err := ParseExpr(q)
+		require.NoError(t, err, q)
+		call, ok := expr.(*Call)
+		require.True(t, ok, q)
+		assert.Equal(t, q, call.String())
+	}
+}
+
+func TestParseFunctionErrors(t *testing.T) {
+	_, err := ParseExpr(`unknown_function(up)`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown function with name "unknown_function"`)
+
+	_, err = ParseExpr(`histogram_fraction(0, up, up)`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `expected type scalar in call to function "histogram_fraction"`)
+
+	_, err = ParseExpr(`count_scalar(up) + up[5m]`)
+	require.Error(t, err)
+}
+
+func TestParseAtModifier(t *testing.T) {
+	for _, q := range []string{`up @ 100 offset 5m`, `up offset 5m @ 100`} {
+		expr, err := ParseExpr(q)
+		require.NoError(t, err, q)
+		vs, ok := expr.(*VectorSelector)
+		require.True(t, ok, q)
+		require.NotNil(t, vs.Timestamp, q)
+		assert.Equal(t, int64(100000), *vs.Timestamp, q)
+		assert.Equal(t, 5*time.Minute, vs.Offset, q)
+		assert.Equal(t, `up @ 100.000 offset 5m`, vs.String(), q)
+	}
+
+	expr, err := ParseExpr(`rate(up[5m] offset 1m @ -1.5)`)
+	require.NoError(t, err)
+	ms, ok := expr.(*Call).Args[0].(*MatrixSelector)
+	require.True(t, ok)
+	require.NotNil(t, ms.Timestamp)
+	assert.Equal(t, int64(-1500), *ms.Timestamp)
+	assert.Equal(t, `rate(up[5m] @ -1.500 offset 1m)`, expr.String())
+}
+
+func TestParseAtModifierErrors(t *testing.T) {
+	for q, msg := range map[string]string{
+		`up @ 100 @ 200`:               "@ <timestamp> may not be set multiple times",
+		`up @ 100 offset 5m @ 200`:     "@ <timestamp> may not be set multiple times",
+		`up offset 5m @ 100 offset 1m`: "offset may not be set multiple times",
+		`sum(up) @ 100`:                "@ modifier must be preceded by an instant or range selector",
+		`up @ 5m`:                      "unexpected duration",
+	} {
+		_, err := ParseExpr(q)
+		require.Error(t, err, q)
+		assert.Contains(t, err.Error(), msg, q)
+	}
+}
+
+func TestParseAtStartOrEnd(t *testing.T) {
+	for q, expected := range map[string]string{
+		`up @ start()`:                   `up @ start()`,
+		`up offset 5m @ end()`:           `up @ end() offset 5m`,
+		`rate(up[5m] @ end() offset 1m)`: `rate(up[5m] @ end() offset 1m)`,
+	} {
+		expr, err := ParseExpr(q)
+		require.NoError(t, err, q)
+		assert.Equal(t, expected, expr.String(), q)
+	}
+
+	for _, q := range []string{`up @ start`, `up @ now()`, `up @ end() @ 100`, `up @ 100 @ start()`} {
+		_, err := ParseExpr(q)
+		assert.Error(t, err, q)
+	}
+}
@@ -45,6 +45,7 @@ const (
 	itemDuration
 	itemBlank
 	itemTimes
+	itemAt
 
 	operatorsStart
 	// Operators.
@@ -97,5 +98,6 @@ const (
 	itemGroupLeft
 	itemGroupRight
 	itemBool
+	itemKeepMetricNames
 	keywordsEnd
 )
@@ -24,16 +24,18 @@ import (
 	"fmt"
 
 	"github.com/m3db/m3/src/query/errors"
+	"github.com/m3db/m3/src/query/functions"
 	"github.com/m3db/m3/src/query/parser"
-
-	pql "github.com/prometheus/prometheus/promql"
+	pql "github.com/m3db/m3/src/query/parser/promql/internal/pql"
 )
 
 type promParser struct {
 	expr pql.Expr
 }
 
-// Parse takes a promQL string and converts parses it into a DAG
+// Parse takes a promQL string and converts parses it into a DAG. Range and offset durations are
+// parsed with util.ParseDuration, so they may be compound, e.g. 1h30m, and function calls and
+// parenthesized expressions may be suffixed with keep_metric_names
 func Parse(q string) (parser.Parser, error) {
 	expr, err := pql.ParseExpr(q)
 	if err != nil {
@@ -74,6 +76,42 @@ func (p *parseState) transformLen() int {
 	return len(p.transforms)
 }
 
+// walkScalarBinary walks the vector side of a binary expression with a scalar, which becomes
+// a single input transform rather than a node
+func (p *parseState) walkScalarBinary(n *pql.BinaryExpr, vector pql.Expr, scalar float64, scalarLeft bool) error {
+	if err := p.walk(vector); err != nil {
+		return err
+	}
+
+	op, err := NewScalarBinaryOperator(n, scalar, scalarLeft)
+	if err != nil {
+		return err
+	}
+
+	opTransform := parser.NewTransformFromOperation(op, p.transformLen())
+	p.edges = append(p.edges, parser.Edge{
+		ParentID: p.lastTransformID(),
+		ChildID:  opTransform.ID,
+	})
+	p.transforms = append(p.transforms, opTransform)
+	return nil
+}
+
+// metricNameKeeper is implemented by the ops which drop metric names from their output unless
+// told to keep them
+type metricNameKeeper interface {
+	WithKeepMetricNames() parser.Params
+}
+
+// keepMetricNames applies the keep_metric_names modifier to the op producing the output of the
+// last walked expression. Ops which never drop metric names are left unchanged
+func (p *parseState) keepMetricNames() {
+	last := &p.transforms[len(p.transforms)-1]
+	if keeper, ok := last.Op.(metricNameKeeper); ok {
+		last.Op = keeper.WithKeepMetricNames()
+	}
+}
+
 func (p *parseState) walk(node pql.Node) error {
 	if node == nil {
 		return nil
@@ -86,7 +124,7 @@ func (p *parseState) walk(node pql.Node) error {
 			return err
 		}
 
-		op, err := NewOperator(n.Op)
+		op, err := NewOperator(n)
 		if err != nil {
 			return err
 		}
@@ -97,7 +135,7 @@ func (p *parseState) walk(node pql.Node) error {
 			ChildID:  opTransform.ID,
 		})
 		p.transforms = append(p.transforms, opTransform)
-		// TODO: handle labels, params
+		// TODO: handle params
 		return nil
 	case *pql.MatrixSelector:
 		operation, err := NewSelectorFromMatrix(n)
@@ -117,20 +155,36 @@ func (p *parseState) walk(node pql.Node) error {
 		p.transforms = append(p.transforms, parser.NewTransformFromOperation(operation, p.transformLen()))
 		return nil
 
+	case *pql.NumberLiteral:
+		p.transforms = append(p.transforms, parser.NewTransformFromOperation(functions.NewScalarOp(n.Val), p.transformLen()))
+		return nil
+
 	case *pql.Call:
 		expressions := n.Args
 		argValues := make([]interface{}, 0, len(expressions))
+		// Functions without an expression argument, e.g. time(), are sources
+		hasInput := false
 		for _, expr := range expressions {
+			if value, ok := foldConstant(expr); ok {
+				argValues = append(argValues, value)
+				continue
+			}
+
 			switch e := expr.(type) {
-			case *pql.NumberLiteral:
+			case *pql.StringLiteral:
 				argValues = append(argValues, e.Val)
 				continue
+			case *pql.MatrixSelector:
+				// Range functions need the window duration of their range vector argument
+				argValues = append(argValues, e.Range)
 			}
 
 			err := p.walk(expr)
 			if err != nil {
 				return err
 			}
+
+			hasInput = true
 		}
 
 		op, err := NewFunctionExpr(n.Func.Name, argValues)
@@ -139,14 +193,44 @@ func (p *parseState) walk(node pql.Node) error {
 		}
 
 		opTransform := parser.NewTransformFromOperation(op, p.transformLen())
-		p.edges = append(p.edges, parser.Edge{
-			ParentID: p.lastTransformID(),
-			ChildID:  opTransform.ID,
-		})
+		if hasInput {
+			p.edges = append(p.edges, parser.Edge{
+				ParentID: p.lastTransformID(),
+				ChildID:  opTransform.ID,
+			})
+		}
+
 		p.transforms = append(p.transforms, opTransform)
+		if n.KeepMetricNames {
+			p.keepMetricNames()
+		}
+
+		return nil
+
+	case *pql.ParenExpr:
+		if err := p.walk(n.Expr); err != nil {
+			return err
+		}
+
+		if n.KeepMetricNames {
+			p.keepMetricNames()
+		}
+
 		return nil
 
 	case *pql.BinaryExpr:
+		if scalar, ok := foldConstant(n); ok {
+			return p.walk(&pql.NumberLiteral{Val: scalar})
+		}
+
+		if scalar, ok := foldConstant(n.RHS); ok {
+			return p.walkScalarBinary(n, n.LHS, scalar, false)
+		}
+
+		if scalar, ok := foldConstant(n.LHS); ok {
+			return p.walkScalarBinary(n, n.RHS, scalar, true)
+		}
+
 		err := p.walk(n.LHS)
 		lhsID := p.lastTransformID()
 		if err != nil {
@@ -21,12 +21,18 @@
 package promql
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"github.com/m3db/m3/src/query/functions"
+	"github.com/m3db/m3/src/query/functions/aggregation"
 	"github.com/m3db/m3/src/query/functions/linear"
 	"github.com/m3db/m3/src/query/functions/logical"
+	"github.com/m3db/m3/src/query/functions/tag"
+	"github.com/m3db/m3/src/query/functions/temporal"
 	"github.com/m3db/m3/src/query/parser"
+	pql "github.com/m3db/m3/src/query/parser/promql/internal/pql"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -42,13 +48,37 @@ func TestDAGWithCountOp(t *testing.T) {
 	assert.Equal(t, transforms[0].Op.OpType(), functions.FetchType)
 	assert.Equal(t, transforms[0].ID, parser.NodeID("0"))
 	assert.Equal(t, transforms[1].ID, parser.NodeID("1"))
-	assert.Equal(t, transforms[1].Op.OpType(), functions.CountType)
+	assert.Equal(t, transforms[1].Op.OpType(), aggregation.CountType)
 	assert.Len(t, edges, 1)
 	assert.Equal(t, edges[0].ParentID, parser.NodeID("0"), "fetch should be the parent")
 	assert.Equal(t, edges[0].ChildID, parser.NodeID("1"), "aggregation should be the child")
 
 }
 
+func TestDAGWithUngroupedCountOp(t *testing.T) {
+	p, err := Parse(`count(http_requests_total{method="GET"})`)
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 2)
+	assert.Equal(t, functions.FetchType, transforms[0].Op.OpType())
+	_, ok := transforms[1].Op.(functions.CountOp)
+	assert.True(t, ok, "count without grouping does not need to group series")
+	require.Len(t, edges, 1)
+	assert.Equal(t, parser.NodeID("0"), edges[0].ParentID)
+	assert.Equal(t, parser.NodeID("1"), edges[0].ChildID)
+
+	for _, q := range []string{`count(up) by (job)`, `count without (job) (up)`, `count without () (up)`} {
+		p, err := Parse(q)
+		require.NoError(t, err)
+		transforms, _, err := p.DAG()
+		require.NoError(t, err)
+		_, ok := transforms[1].Op.(functions.CountOp)
+		assert.False(t, ok, "grouped counts are aggregations: %s", q)
+		assert.Equal(t, aggregation.CountType, transforms[1].Op.OpType())
+	}
+}
+
 func TestDAGWithEmptyExpression(t *testing.T) {
 	q := ""
 	_, err := Parse(q)
@@ -56,7 +86,7 @@ func TestDAGWithEmptyExpression(t *testing.T) {
 }
 
 func TestDAGWithUnknownOp(t *testing.T) {
-	q := "sum(http_requests_total{method=\"GET\"})"
+	q := "holt_winters(http_requests_total{method=\"GET\"}[5m], 0.1, 0.5)"
 	p, err := Parse(q)
 	require.NoError(t, err)
 	_, _, err = p.DAG()
@@ -280,3 +310,495 @@ func TestDAGWithYearOp(t *testing.T) {
 	assert.Len(t, transforms, 2)
 	assert.Equal(t, transforms[1].Op.OpType(), linear.YearType)
 }
+
+func TestDAGWithDerivOp(t *testing.T) {
+	q := "deriv(up[5m])"
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[0].Op.OpType(), functions.FetchType)
+	assert.Equal(t, transforms[1].Op.OpType(), temporal.DerivType)
+	assert.Len(t, edges, 1)
+	assert.Equal(t, edges[0].ParentID, parser.NodeID("0"), "fetch should be the parent")
+	assert.Equal(t, edges[0].ChildID, parser.NodeID("1"), "deriv op should be child")
+}
+
+func TestDAGWithPredictLinearOp(t *testing.T) {
+	q := "predict_linear(up[5m], 100)"
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[1].Op.OpType(), temporal.PredictLinearType)
+}
+
+func TestDAGWithSumOp(t *testing.T) {
+	q := "sum(up) by (service)"
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[0].Op.OpType(), functions.FetchType)
+	assert.Equal(t, transforms[1].Op.OpType(), aggregation.SumType)
+	assert.Len(t, edges, 1)
+	assert.Equal(t, edges[0].ParentID, parser.NodeID("0"), "fetch should be the parent")
+	assert.Equal(t, edges[0].ChildID, parser.NodeID("1"), "aggregation should be the child")
+}
+
+func TestDAGWithMinAndMaxOps(t *testing.T) {
+	for q, opType := range map[string]string{
+		"min(up) by (service)": aggregation.MinType,
+		"max(up) by (service)": aggregation.MaxType,
+	} {
+		p, err := Parse(q)
+		require.NoError(t, err)
+		transforms, _, err := p.DAG()
+		require.NoError(t, err)
+		require.Len(t, transforms, 2)
+		assert.Equal(t, opType, transforms[1].Op.OpType())
+	}
+}
+
+func TestDAGWithGroupedTopK(t *testing.T) {
+	for q, expected := range map[string]string{
+		"topk(3, up) by (region)":         "topk by (region) (3, input)",
+		"bottomk(2, up) without (region)": "bottomk without (region) (2, input)",
+	} {
+		p, err := Parse(q)
+		require.NoError(t, err)
+		transforms, _, err := p.DAG()
+		require.NoError(t, err)
+		require.Len(t, transforms, 2)
+		formatter, ok := transforms[1].Op.(interface{ FormatExpr([]string) string })
+		require.True(t, ok)
+		assert.Equal(t, expected, formatter.FormatExpr([]string{"input"}), q)
+	}
+}
+
+func TestDAGWithLabelReplaceOp(t *testing.T) {
+	q := "label_replace(up, \"dst\", \"$1\", \"src\", \"(.*)\")"
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[1].Op.OpType(), tag.LabelReplaceType)
+}
+
+func TestDAGWithLabelTemplateOp(t *testing.T) {
+	q := `label_template(up, "addr", "{{.instance}}:{{.port}}")`
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[1].Op.OpType(), tag.LabelTemplateType)
+
+	_, err = Parse(`label_template(up, "addr")`)
+	assert.Error(t, err, "the parser checks the arguments")
+}
+
+func TestDAGWithLabelFromValueOp(t *testing.T) {
+	p, err := Parse(`label_from_value(up, "value")`)
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[1].Op.OpType(), tag.LabelFromValueType)
+
+	_, err = Parse(`label_from_value(up[5m], "value")`)
+	assert.Error(t, err, "the parser checks the arguments")
+}
+
+func TestDAGWithCountScalarOp(t *testing.T) {
+	p, err := Parse("count_scalar(up)")
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[1].Op.OpType(), functions.CountScalarType)
+
+	_, err = Parse("count_scalar(up[5m])")
+	assert.Error(t, err, "the parser checks the arguments")
+}
+
+func TestDAGWithOverTimeOps(t *testing.T) {
+	for _, fn := range []string{temporal.LastOverTimeType, temporal.PresentOverTimeType} {
+		p, err := Parse(fn + "(up[5m])")
+		require.NoError(t, err, fn)
+		transforms, _, err := p.DAG()
+		require.NoError(t, err, fn)
+		assert.Len(t, transforms, 2, fn)
+		assert.Equal(t, fn, transforms[1].Op.OpType())
+
+		_, err = Parse(fn + "(up)")
+		assert.Error(t, err, "the parser checks the arguments of %s", fn)
+	}
+}
+
+func TestDAGWithQuantileOp(t *testing.T) {
+	q := "quantile(0.9, up) by (service)"
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[1].Op.OpType(), aggregation.QuantileType)
+}
+
+func TestDAGWithGroupedQuantileOfRate(t *testing.T) {
+	q := "quantile(0.9, rate(x[5m])) by (le)"
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 3)
+	assert.Equal(t, functions.FetchType, transforms[0].Op.OpType())
+	assert.Equal(t, temporal.RateType, transforms[1].Op.OpType())
+	assert.Equal(t, aggregation.QuantileType, transforms[2].Op.OpType())
+	require.Len(t, edges, 2)
+	assert.Equal(t, parser.Edge{ParentID: transforms[1].ID, ChildID: transforms[2].ID}, edges[1])
+
+	op, ok := transforms[2].Op.(aggregation.BaseOp)
+	require.True(t, ok)
+	assert.Equal(t, "quantile by (le) (0.9, input)", op.FormatExpr([]string{"input"}))
+}
+
+func TestDAGWithCountValues(t *testing.T) {
+	p, err := Parse(`count_values("version", build_info) by (job)`)
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 2)
+	assert.Equal(t, aggregation.CountValuesType, transforms[1].Op.OpType())
+	formatter, ok := transforms[1].Op.(interface{ FormatExpr([]string) string })
+	require.True(t, ok)
+	assert.Equal(t, `count_values by (job) ("version", input)`, formatter.FormatExpr([]string{"input"}))
+
+	_, err = NewOperator(&pql.AggregateExpr{
+		Op:    pql.ItemType(itemCountValues),
+		Expr:  &pql.VectorSelector{Name: "up"},
+		Param: &pql.NumberLiteral{Val: 1},
+	})
+	assert.Error(t, err, "count_values should require a string parameter")
+}
+
+func TestNewOperatorValidatesScalarParameter(t *testing.T) {
+	selector := &pql.VectorSelector{Name: "up"}
+	_, err := NewOperator(&pql.AggregateExpr{
+		Op:       pql.ItemType(itemQuantile),
+		Expr:     selector,
+		Grouping: []string{"le"},
+	})
+	assert.Error(t, err, "quantile should require a parameter")
+
+	_, err = NewOperator(&pql.AggregateExpr{
+		Op:    pql.ItemType(itemQuantile),
+		Expr:  selector,
+		Param: &pql.StringLiteral{Val: "le"},
+	})
+	assert.Error(t, err, "quantile should require a scalar parameter")
+
+	_, err = NewOperator(&pql.AggregateExpr{
+		Op:    pql.ItemType(itemSum),
+		Expr:  selector,
+		Param: &pql.NumberLiteral{Val: 0.9},
+	})
+	assert.Error(t, err, "sum should not take a parameter")
+
+	_, err = NewOperator(&pql.AggregateExpr{
+		Op:    pql.ItemType(itemQuantile),
+		Expr:  selector,
+		Param: &pql.ParenExpr{Expr: &pql.NumberLiteral{Val: 0.9}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestDAGWithQuantileOverTimeOp(t *testing.T) {
+	q := "quantile_over_time(0.9, up[5m])"
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 2)
+	assert.Equal(t, transforms[1].Op.OpType(), temporal.QuantileOverTimeType)
+}
+
+func TestDAGWithHistogramFraction(t *testing.T) {
+	p, err := Parse("histogram_fraction(0, 0.5, rate(x_bucket[5m]))")
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 3)
+	assert.Equal(t, temporal.RateType, transforms[1].Op.OpType())
+	assert.Equal(t, linear.HistogramFractionType, transforms[2].Op.OpType())
+	require.Len(t, edges, 2)
+	assert.Equal(t, parser.Edge{ParentID: transforms[1].ID, ChildID: transforms[2].ID}, edges[1])
+
+	_, err = Parse("histogram_fraction(0, x_bucket)")
+	assert.Error(t, err, "the parser checks the arguments")
+}
+
+func TestDAGWithArithmeticOp(t *testing.T) {
+	q := "up / ignoring(code) total"
+	p, err := Parse(q)
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	assert.Len(t, transforms, 3)
+	assert.Equal(t, transforms[2].Op.OpType(), logical.DivType)
+	assert.Len(t, edges, 2)
+}
+
+func TestDAGWithEmptyMatchingLabels(t *testing.T) {
+	tests := []struct {
+		query string
+		on    bool
+	}{
+		{query: "a + ignoring() b", on: false},
+		{query: "a + on() b", on: true},
+	}
+
+	for _, tt := range tests {
+		p, err := Parse(tt.query)
+		require.NoError(t, err)
+		transforms, _, err := p.DAG()
+		require.NoError(t, err)
+		require.Len(t, transforms, 3)
+		matching := transforms[2].Op.(logical.BaseOp).Matching
+		assert.Equal(t, tt.on, matching.On, tt.query)
+		assert.Empty(t, matching.MatchingLabels, tt.query)
+	}
+}
+
+func TestDAGWithComparisonOp(t *testing.T) {
+	p, err := Parse("up > bool down")
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 3)
+	assert.Equal(t, logical.GreaterType, transforms[2].Op.OpType())
+	assert.True(t, transforms[2].Op.(logical.BaseOp).ReturnBool)
+	assert.Len(t, edges, 2)
+}
+
+func TestDAGWithScalarComparisonOp(t *testing.T) {
+	p, err := Parse("5 < bool up")
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 2)
+	op, ok := transforms[1].Op.(logical.ScalarComparisonOp)
+	require.True(t, ok)
+	assert.Equal(t, logical.LesserType, op.OpType())
+	assert.Equal(t, 5.0, op.Scalar)
+	assert.True(t, op.ScalarLeft)
+	assert.True(t, op.ReturnBool)
+	require.Len(t, edges, 1)
+	assert.Equal(t, transforms[0].ID, edges[0].ParentID)
+}
+
+func TestDAGWithScalarArithmeticOp(t *testing.T) {
+	p, err := Parse("x * 2")
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 2, "the selector is not folded")
+	op, ok := transforms[1].Op.(logical.ScalarArithmeticOp)
+	require.True(t, ok)
+	assert.Equal(t, logical.MultiplyType, op.OpType())
+	assert.Equal(t, 2.0, op.Scalar)
+	assert.False(t, op.ScalarLeft)
+	require.Len(t, edges, 1)
+	assert.Equal(t, transforms[0].ID, edges[0].ParentID)
+}
+
+func TestDAGWithConstantFolding(t *testing.T) {
+	p, err := Parse("rate(x[5m]) * (60*60)")
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 3)
+	assert.Equal(t, temporal.RateType, transforms[1].Op.OpType())
+	op, ok := transforms[2].Op.(logical.ScalarArithmeticOp)
+	require.True(t, ok)
+	assert.Equal(t, 3600.0, op.Scalar)
+
+	p, err = Parse("-(1 / 0) - x")
+	require.NoError(t, err)
+	transforms, _, err = p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 2)
+	op, ok = transforms[1].Op.(logical.ScalarArithmeticOp)
+	require.True(t, ok)
+	assert.True(t, math.IsInf(op.Scalar, -1))
+	assert.True(t, op.ScalarLeft)
+
+	p, err = Parse("x * (2 * y)")
+	require.NoError(t, err)
+	transforms, _, err = p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 4, "expressions referencing selectors are not folded")
+	assert.Equal(t, logical.MultiplyType, transforms[3].Op.OpType())
+	_, ok = transforms[3].Op.(logical.BaseOp)
+	assert.True(t, ok)
+}
+
+func TestDAGWithScalars(t *testing.T) {
+	p, err := Parse("(2 + 3) * 4")
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 1, "constant expressions are a single scalar")
+	assert.Equal(t, functions.NumberLiteralType, transforms[0].Op.OpType())
+	assert.Equal(t, "20", transforms[0].Op.(functions.ScalarOp).FormatExpr(nil))
+	assert.Empty(t, edges)
+
+	p, err = Parse("(time() + 1) * time()")
+	require.NoError(t, err)
+	transforms, edges, err = p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 4)
+	assert.Equal(t, functions.TimeType, transforms[0].Op.OpType())
+	assert.Equal(t, functions.TimeType, transforms[2].Op.OpType())
+	op, ok := transforms[3].Op.(logical.BaseOp)
+	require.True(t, ok)
+	assert.True(t, op.LScalar && op.RScalar)
+	assert.Len(t, edges, 3, "time has no inputs")
+
+	p, err = Parse("up * time()")
+	require.NoError(t, err)
+	transforms, _, err = p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 3)
+	op = transforms[2].Op.(logical.BaseOp)
+	assert.False(t, op.LScalar, "up is a vector")
+	assert.True(t, op.RScalar)
+
+	p, err = Parse("time() > up")
+	require.NoError(t, err)
+	transforms, _, err = p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 3)
+	op = transforms[2].Op.(logical.BaseOp)
+	assert.True(t, op.LScalar)
+	assert.False(t, op.RScalar, "comparisons mark their scalar sides too")
+}
+
+func TestParseWithKeepMetricNames(t *testing.T) {
+	p, err := Parse(`rate(x[5m]) keep_metric_names`)
+	require.NoError(t, err)
+	transforms, edges, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 2)
+	rate, ok := transforms[1].Op.(temporal.BaseOp)
+	require.True(t, ok)
+	assert.True(t, rate.KeepMetricNames)
+	assert.Len(t, edges, 1)
+
+	p, err = Parse(`abs(x) KEEP_METRIC_NAMES`)
+	require.NoError(t, err)
+	transforms, _, err = p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 2)
+	abs, ok := transforms[1].Op.(linear.BaseOp)
+	require.True(t, ok)
+	assert.True(t, abs.KeepMetricNames)
+
+	p, err = Parse(`abs(x)`)
+	require.NoError(t, err)
+	transforms, _, err = p.DAG()
+	require.NoError(t, err)
+	assert.False(t, transforms[1].Op.(linear.BaseOp).KeepMetricNames)
+
+	p, err = Parse(`(a * b) keep_metric_names`)
+	require.NoError(t, err)
+	transforms, _, err = p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 3)
+	op, ok := transforms[2].Op.(logical.BaseOp)
+	require.True(t, ok)
+	assert.True(t, op.KeepMetricNames)
+
+	p, err = Parse(`(a * 2) keep_metric_names`)
+	require.NoError(t, err)
+	transforms, _, err = p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 2)
+	scalarOp, ok := transforms[1].Op.(logical.ScalarArithmeticOp)
+	require.True(t, ok)
+	assert.True(t, scalarOp.KeepMetricNames)
+
+	for _, q := range []string{`up keep_metric_names`, `up[5m] keep_metric_names`, `sum(up) keep_metric_names`} {
+		_, err = Parse(q)
+		assert.Error(t, err, "the modifier only applies to calls and parenthesized expressions: %s", q)
+	}
+}
+
+func TestParseWithCompoundDurations(t *testing.T) {
+	p, err := Parse(`rate(up[1h30m] offset 1d12h) + up offset 1500ms`)
+	require.NoError(t, err)
+	transforms, _, err := p.DAG()
+	require.NoError(t, err)
+	require.Len(t, transforms, 4)
+
+	fetch, ok := transforms[0].Op.(functions.FetchOp)
+	require.True(t, ok)
+	assert.Equal(t, 90*time.Minute, fetch.Range)
+	assert.Equal(t, 36*time.Hour, fetch.Offset)
+	assert.Equal(t, temporal.RateType, transforms[1].Op.OpType())
+
+	fetch, ok = transforms[2].Op.(functions.FetchOp)
+	require.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, fetch.Offset)
+
+	// Labels and strings named like durations are left alone
+	_, err = Parse(`sum by (offset) (up{offset="[5m]"}) > 1e3`)
+	assert.NoError(t, err)
+}
+
+func TestParseWithAtModifier(t *testing.T) {
+	var fetches []functions.FetchOp
+	for _, q := range []string{`rate(up[5m] @ 1500.5 offset 1h)`, `rate(up[5m] offset 1h @ 1500.5)`} {
+		p, err := Parse(q)
+		require.NoError(t, err, q)
+		transforms, _, err := p.DAG()
+		require.NoError(t, err, q)
+		require.Len(t, transforms, 2, q)
+
+		fetch, ok := transforms[0].Op.(functions.FetchOp)
+		require.True(t, ok, q)
+		require.NotNil(t, fetch.At, q)
+		assert.Equal(t, time.Unix(1500, int64(500*time.Millisecond)), *fetch.At, q)
+		assert.Equal(t, time.Hour, fetch.Offset, q)
+		fetches = append(fetches, fetch)
+	}
+
+	assert.Equal(t, fetches[0], fetches[1])
+
+	_, err := Parse(`up @ 100 offset 1h @ 200`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "@ <timestamp> may not be set multiple times")
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{query: `rate(up[1m1h])`, expected: `parse error at char 9: invalid duration "1m1h": unit "h" must come before "m"`},
+		{query: `rate(up[0s])`, expected: `parse error at char 9: duration must be greater than 0`},
+		{query: `rate(up[5m]) + up offset 1.5s`, expected: `parse error at char 26: invalid duration "1.5s"`},
+		{query: `rate(up[5m1])`, expected: `parse error at char 9: bad duration syntax: "5m1"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		require.Error(t, err, tt.query)
+		assert.Contains(t, err.Error(), tt.expected, tt.query)
+	}
+}
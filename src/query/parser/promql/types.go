@@ -22,97 +22,277 @@ package promql
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/m3db/m3/src/query/functions"
-	"github.com/m3db/m3/src/query/functions/linear"
+	"github.com/m3db/m3/src/query/functions/aggregation"
 	"github.com/m3db/m3/src/query/functions/logical"
 	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/parser"
 	"github.com/m3db/m3/src/query/parser/common"
+	pql "github.com/m3db/m3/src/query/parser/promql/internal/pql"
 
 	"github.com/prometheus/prometheus/pkg/labels"
-	"github.com/prometheus/prometheus/promql"
 )
 
 // NewSelectorFromVector creates a new fetchop
-func NewSelectorFromVector(n *promql.VectorSelector) (parser.Params, error) {
+func NewSelectorFromVector(n *pql.VectorSelector) (parser.Params, error) {
 	matchers, err := labelMatchersToModelMatcher(n.LabelMatchers)
 	if err != nil {
 		return nil, err
 	}
 
 	return functions.FetchOp{
-		Name:     n.Name,
-		Offset:   n.Offset,
-		Matchers: matchers,
+		Name:       n.Name,
+		Offset:     n.Offset,
+		Matchers:   matchers,
+		At:         atTime(n.Timestamp),
+		StartOrEnd: n.StartOrEnd,
 	}, nil
 }
 
 // NewSelectorFromMatrix creates a new fetchop
-func NewSelectorFromMatrix(n *promql.MatrixSelector) (parser.Params, error) {
+func NewSelectorFromMatrix(n *pql.MatrixSelector) (parser.Params, error) {
 	matchers, err := labelMatchersToModelMatcher(n.LabelMatchers)
 	if err != nil {
 		return nil, err
 	}
 
-	return functions.FetchOp{Name: n.Name, Offset: n.Offset, Matchers: matchers, Range: n.Range}, nil
+	return functions.FetchOp{
+		Name:       n.Name,
+		Offset:     n.Offset,
+		Matchers:   matchers,
+		Range:      n.Range,
+		At:         atTime(n.Timestamp),
+		StartOrEnd: n.StartOrEnd,
+	}, nil
 }
 
-// NewOperator creates a new operator based on the type
-func NewOperator(opType promql.ItemType) (parser.Params, error) {
-	switch getOpType(opType) {
-	case functions.CountType:
-		return functions.CountOp{}, nil
-	default:
-		// TODO: handle other types
-		return nil, fmt.Errorf("operator not supported: %s", opType)
+// atTime converts the timestamp in milliseconds set by the @ modifier of a selector to a time
+func atTime(timestamp *int64) *time.Time {
+	if timestamp == nil {
+		return nil
 	}
+
+	at := time.Unix(0, *timestamp*int64(time.Millisecond))
+	return &at
 }
 
-// NewBinaryOperator creates a new binary operator based on the type
-func NewBinaryOperator(expr *promql.BinaryExpr, lhs, rhs parser.NodeID) (parser.Params, error) {
-	switch getOpType(expr.Op) {
-	case logical.AndType:
-		return logical.NewAndOp(lhs, rhs, promMatchingToM3(expr.VectorMatching)), nil
+// NewOperator creates a new operator based on the type
+func NewOperator(expr *pql.AggregateExpr) (parser.Params, error) {
+	switch opType := getOpType(expr.Op); opType {
+	case aggregation.SumType, aggregation.AvgType, aggregation.MinType, aggregation.MaxType,
+		aggregation.CountType:
+		if expr.Param != nil {
+			return nil, fmt.Errorf("%s does not take a parameter, found: %v", opType, expr.Param)
+		}
+
+		if opType == aggregation.CountType && len(expr.Grouping) == 0 && !expr.Without {
+			return NewFunctionExpr(functions.CountType, nil)
+		}
+
+		return aggregation.NewAggregationOp(opType, aggregation.NodeParams{
+			MatchingTags: expr.Grouping,
+			Without:      expr.Without,
+		})
+	case aggregation.QuantileType:
+		param, err := scalarParameter(opType, expr)
+		if err != nil {
+			return nil, err
+		}
+
+		return aggregation.NewAggregationOp(opType, aggregation.NodeParams{
+			MatchingTags: expr.Grouping,
+			Without:      expr.Without,
+			Parameter:    param,
+		})
+	case aggregation.TopKType, aggregation.BottomKType:
+		param, err := scalarParameter(opType, expr)
+		if err != nil {
+			return nil, err
+		}
+
+		return aggregation.NewTakeOp(opType, aggregation.NodeParams{
+			MatchingTags: expr.Grouping,
+			Without:      expr.Without,
+			Parameter:    param,
+		})
+	case aggregation.CountValuesType:
+		param, ok := unwrapParens(expr.Param).(*pql.StringLiteral)
+		if !ok {
+			return nil, fmt.Errorf("expected a string parameter for %s, found: %v", opType, expr.Param)
+		}
+
+		return aggregation.NewCountValuesOp(aggregation.NodeParams{
+			MatchingTags: expr.Grouping,
+			Without:      expr.Without,
+			ValueLabel:   param.Val,
+		})
 	default:
 		// TODO: handle other types
 		return nil, fmt.Errorf("operator not supported: %s", expr.Op)
 	}
 }
 
-// NewFunctionExpr creates a new function expr based on the type
-func NewFunctionExpr(name string, argValues []interface{}) (parser.Params, error) {
-	switch name {
+// scalarParameter returns the scalar parameter of an aggregation, such as the phi of quantile,
+// which is passed to the op separately from the grouping labels
+func scalarParameter(opType string, expr *pql.AggregateExpr) (float64, error) {
+	param := unwrapParens(expr.Param)
+	if param == nil {
+		return 0, fmt.Errorf("expected a scalar parameter for %s", opType)
+	}
+
+	value, ok := foldConstant(param)
+	if !ok {
+		return 0, fmt.Errorf("expected a scalar parameter for %s, found: %v", opType, expr.Param)
+	}
+
+	return value, nil
+}
+
+// foldConstant evaluates expressions of number literals and arithmetic between them, e.g. 60 * 60,
+// so that they are computed once rather than at every step. Anything else, e.g. an expression
+// referencing a selector, is not constant
+func foldConstant(expr pql.Expr) (float64, bool) {
+	switch e := unwrapParens(expr).(type) {
+	case *pql.NumberLiteral:
+		return e.Val, true
+	case *pql.UnaryExpr:
+		value, ok := foldConstant(e.Expr)
+		if !ok {
+			return 0, false
+		}
 
-	case linear.AbsType, linear.CeilType, linear.ExpType, linear.FloorType, linear.LnType,
-		linear.Log10Type, linear.Log2Type, linear.SqrtType:
-		return linear.NewMathOp(name)
+		if e.Op == pql.ItemType(itemSUB) {
+			return -value, true
+		}
 
-	case linear.AbsentType:
-		return linear.NewAbsentOp(), nil
+		return value, true
+	case *pql.BinaryExpr:
+		lhs, ok := foldConstant(e.LHS)
+		if !ok {
+			return 0, false
+		}
 
-	case linear.ClampMinType, linear.ClampMaxType:
-		return linear.NewClampOp(argValues, name)
+		rhs, ok := foldConstant(e.RHS)
+		if !ok {
+			return 0, false
+		}
 
-	case linear.RoundType:
-		return linear.NewRoundOp(argValues)
+		// Comparisons between scalars are not folded
+		value, err := logical.ApplyScalarArithmetic(getOpType(e.Op), lhs, rhs)
+		if err != nil {
+			return 0, false
+		}
 
-	case linear.DayOfMonthType, linear.DayOfWeekType, linear.DaysInMonthType, linear.HourType,
-		linear.MinuteType, linear.MonthType, linear.YearType:
-		return linear.NewDateOp(name)
+		return value, true
+	default:
+		return 0, false
+	}
+}
+
+// unwrapParens returns the expression inside any parentheses
+func unwrapParens(expr pql.Expr) pql.Expr {
+	for {
+		paren, ok := expr.(*pql.ParenExpr)
+		if !ok {
+			return expr
+		}
+
+		expr = paren.Expr
+	}
+}
 
+// NewBinaryOperator creates a new binary operator based on the type
+func NewBinaryOperator(expr *pql.BinaryExpr, lhs, rhs parser.NodeID) (parser.Params, error) {
+	opType := getOpType(expr.Op)
+	switch opType {
+	case logical.AndType:
+		return logical.NewAndOp(lhs, rhs, promMatchingToM3(expr.VectorMatching)), nil
+	case logical.PlusType, logical.MinusType, logical.MultiplyType, logical.DivType,
+		logical.ExpType, logical.ModType:
+		op, err := logical.NewArithmeticOp(opType, lhs, rhs, promMatchingToM3(expr.VectorMatching))
+		return withScalarSides(op, expr), err
+	case logical.EqType, logical.NotEqType, logical.GreaterType, logical.LesserType,
+		logical.GreaterEqType, logical.LesserEqType:
+		op, err := logical.NewComparisonOp(opType, lhs, rhs, promMatchingToM3(expr.VectorMatching), expr.ReturnBool)
+		return withScalarSides(op, expr), err
 	default:
 		// TODO: handle other types
-		return nil, fmt.Errorf("function not supported: %s", name)
+		return nil, fmt.Errorf("operator not supported: %s", expr.Op)
+	}
+}
+
+// withScalarSides marks the sides of the op which are scalar expressions, e.g. time(), which are
+// combined with every series of the other side rather than matched
+func withScalarSides(op logical.BaseOp, expr *pql.BinaryExpr) logical.BaseOp {
+	op.LScalar = expr.LHS.Type() == pql.ValueTypeScalar
+	op.RScalar = expr.RHS.Type() == pql.ValueTypeScalar
+	return op
+}
+
+// NewScalarBinaryOperator creates a new operator for a binary expression between a vector and
+// a scalar, where scalarLeft is set if the scalar is the lhs
+func NewScalarBinaryOperator(expr *pql.BinaryExpr, scalar float64, scalarLeft bool) (parser.Params, error) {
+	opType := getOpType(expr.Op)
+	switch opType {
+	case logical.EqType, logical.NotEqType, logical.GreaterType, logical.LesserType,
+		logical.GreaterEqType, logical.LesserEqType:
+		return logical.NewScalarComparisonOp(opType, scalar, scalarLeft, expr.ReturnBool)
+	case logical.PlusType, logical.MinusType, logical.MultiplyType, logical.DivType,
+		logical.ExpType, logical.ModType:
+		return logical.NewScalarArithmeticOp(opType, scalar, scalarLeft)
+	default:
+		return nil, fmt.Errorf("operator not supported with a scalar: %s", expr.Op)
 	}
 }
 
-func getOpType(opType promql.ItemType) string {
+func getOpType(opType pql.ItemType) string {
 	switch opType {
-	case promql.ItemType(itemCount):
-		return functions.CountType
-	case promql.ItemType(itemLAND):
+	case pql.ItemType(itemAvg):
+		return aggregation.AvgType
+	case pql.ItemType(itemCount):
+		return aggregation.CountType
+	case pql.ItemType(itemSum):
+		return aggregation.SumType
+	case pql.ItemType(itemMin):
+		return aggregation.MinType
+	case pql.ItemType(itemMax):
+		return aggregation.MaxType
+	case pql.ItemType(itemQuantile):
+		return aggregation.QuantileType
+	case pql.ItemType(itemTopK):
+		return aggregation.TopKType
+	case pql.ItemType(itemBottomK):
+		return aggregation.BottomKType
+	case pql.ItemType(itemCountValues):
+		return aggregation.CountValuesType
+	case pql.ItemType(itemLAND):
 		return logical.AndType
+	case pql.ItemType(itemADD):
+		return logical.PlusType
+	case pql.ItemType(itemSUB):
+		return logical.MinusType
+	case pql.ItemType(itemMUL):
+		return logical.MultiplyType
+	case pql.ItemType(itemDIV):
+		return logical.DivType
+	case pql.ItemType(itemPOW):
+		return logical.ExpType
+	case pql.ItemType(itemMOD):
+		return logical.ModType
+	case pql.ItemType(itemEQL):
+		return logical.EqType
+	case pql.ItemType(itemNEQ):
+		return logical.NotEqType
+	case pql.ItemType(itemGTR):
+		return logical.GreaterType
+	case pql.ItemType(itemLSS):
+		return logical.LesserType
+	case pql.ItemType(itemGTE):
+		return logical.GreaterEqType
+	case pql.ItemType(itemLTE):
+		return logical.LesserEqType
 	default:
 		return common.UnknownOpType
 	}
@@ -138,7 +318,7 @@ func labelMatchersToModelMatcher(lMatchers []*labels.Matcher) (models.Matchers,
 }
 
 // promTypeToM3 converts a prometheus label type to m3 matcher type
-//TODO(nikunj): Consider merging with prompb code
+// TODO(nikunj): Consider merging with prompb code
 func promTypeToM3(labelType labels.MatchType) (models.MatchType, error) {
 	switch labelType {
 	case labels.MatchEqual:
@@ -155,22 +335,27 @@ func promTypeToM3(labelType labels.MatchType) (models.MatchType, error) {
 	}
 }
 
-func promVectorCardinalityToM3(card promql.VectorMatchCardinality) logical.VectorMatchCardinality {
+func promVectorCardinalityToM3(card pql.VectorMatchCardinality) logical.VectorMatchCardinality {
 	switch card {
-	case promql.CardOneToOne:
+	case pql.CardOneToOne:
 		return logical.CardOneToOne
-	case promql.CardManyToMany:
+	case pql.CardManyToMany:
 		return logical.CardManyToMany
-	case promql.CardManyToOne:
+	case pql.CardManyToOne:
 		return logical.CardManyToOne
-	case promql.CardOneToMany:
+	case pql.CardOneToMany:
 		return logical.CardOneToMany
 	}
 
 	panic(fmt.Sprintf("unknown prom cardinality %d", card))
 }
 
-func promMatchingToM3(vectorMatching *promql.VectorMatching) *logical.VectorMatching {
+func promMatchingToM3(vectorMatching *pql.VectorMatching) *logical.VectorMatching {
+	// Binary expressions between scalars have no matching
+	if vectorMatching == nil {
+		return &logical.VectorMatching{}
+	}
+
 	return &logical.VectorMatching{
 		Card:           promVectorCardinalityToM3(vectorMatching.Card),
 		MatchingLabels: vectorMatching.MatchingLabels,
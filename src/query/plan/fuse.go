@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package plan
+
+import (
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// FusableOp is implemented by element-wise ops which can be fused with the op they are applied to
+type FusableOp interface {
+	parser.Params
+	// Fuse returns a single op applying the input op and then this op, and false if they cannot be fused
+	Fuse(input parser.Params) (parser.Params, bool)
+}
+
+// FuseElementWise collapses chains of element-wise ops, e.g. abs(round(x)), into a single op per
+// chain so that values pass through the chain once. Ops are only fused into an input which nothing
+// else consumes, and only if the op accepts the input, so ops which reorder or drop series end a chain
+func FuseElementWise(nodes parser.Nodes, edges parser.Edges) (parser.Nodes, parser.Edges) {
+	ops := make(map[parser.NodeID]parser.Params, len(nodes))
+	for _, node := range nodes {
+		ops[node.ID] = node.Op
+	}
+
+	removed := make(map[parser.NodeID]bool)
+	for fused := true; fused; {
+		fused = false
+		parents, children := make(map[parser.NodeID]int), make(map[parser.NodeID]int)
+		for _, edge := range edges {
+			children[edge.ParentID]++
+			parents[edge.ChildID]++
+		}
+
+		for i, edge := range edges {
+			child, ok := ops[edge.ChildID].(FusableOp)
+			if !ok || parents[edge.ChildID] != 1 || children[edge.ParentID] != 1 {
+				continue
+			}
+
+			op, ok := child.Fuse(ops[edge.ParentID])
+			if !ok {
+				continue
+			}
+
+			ops[edge.ChildID] = op
+			removed[edge.ParentID] = true
+			edges = rewire(append(edges[:i:i], edges[i+1:]...), edge.ParentID, edge.ChildID)
+			fused = true
+			break
+		}
+	}
+
+	fusedNodes := make(parser.Nodes, 0, len(nodes)-len(removed))
+	for _, node := range nodes {
+		if !removed[node.ID] {
+			fusedNodes = append(fusedNodes, parser.Node{ID: node.ID, Op: ops[node.ID]})
+		}
+	}
+
+	return fusedNodes, edges
+}
+
+// rewire points the edges into the removed node at its replacement instead
+func rewire(edges parser.Edges, removed, replacement parser.NodeID) parser.Edges {
+	for i, edge := range edges {
+		if edge.ChildID == removed {
+			edges[i].ChildID = replacement
+		}
+	}
+
+	return edges
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/functions/linear"
+	"github.com/m3db/m3/src/query/parser"
+	"github.com/m3db/m3/src/query/parser/promql"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuseElementWise(t *testing.T) {
+	tests := []struct {
+		query string
+		nodes int
+	}{
+		{query: "abs(round(ceil(up)))", nodes: 2},
+		{query: "abs(sum(ceil(clamp_min(up, 1))))", nodes: 4},
+		{query: "abs(absent(ceil(up)))", nodes: 4},
+		{query: "abs(up) + ceil(floor(down))", nodes: 5},
+	}
+
+	for _, tt := range tests {
+		p, err := promql.Parse(tt.query)
+		require.NoError(t, err)
+		nodes, edges, err := p.DAG()
+		require.NoError(t, err)
+
+		fusedNodes, fusedEdges := FuseElementWise(nodes, edges)
+		assert.Len(t, fusedNodes, tt.nodes, tt.query)
+		assert.Len(t, fusedEdges, tt.nodes-1, tt.query)
+
+		formatted, err := promql.Format(fusedNodes, fusedEdges)
+		require.NoError(t, err)
+		assert.Equal(t, tt.query, formatted, "fusing should not change the query")
+
+		_, err = NewLogicalPlan(fusedNodes, fusedEdges)
+		require.NoError(t, err, tt.query)
+	}
+}
+
+func TestFuseElementWiseWithSharedInput(t *testing.T) {
+	ceil, err := linear.NewMathOp(linear.CeilType)
+	require.NoError(t, err)
+	abs, err := linear.NewMathOp(linear.AbsType)
+	require.NoError(t, err)
+
+	// The ceil node feeds both abs nodes, so fusing it into either would lose the other
+	nodes := parser.Nodes{
+		{ID: "0", Op: ceil},
+		{ID: "1", Op: abs},
+		{ID: "2", Op: abs},
+	}
+	edges := parser.Edges{{ParentID: "0", ChildID: "1"}, {ParentID: "0", ChildID: "2"}}
+
+	fusedNodes, fusedEdges := FuseElementWise(nodes, edges)
+	require.Len(t, fusedNodes, 3)
+	for i, node := range fusedNodes {
+		assert.Equal(t, nodes[i].ID, node.ID)
+		assert.Equal(t, nodes[i].Op.OpType(), node.Op.OpType())
+	}
+
+	assert.Equal(t, edges, fusedEdges)
+}
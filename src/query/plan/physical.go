@@ -22,11 +22,14 @@ package plan
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/m3db/m3/src/query/executor/transform"
+	"github.com/m3db/m3/src/query/functions/utils"
 	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/parser"
 	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/ts"
 )
 
 // PhysicalPlan represents the physical plan
@@ -36,6 +39,58 @@ type PhysicalPlan struct {
 	ResultStep ResultOp
 	TimeSpec   transform.TimeSpec
 	Debug      bool
+	// AlignStepsToEpoch aligns the steps of the sources to multiples of the step since the epoch
+	AlignStepsToEpoch bool
+	// WarnOnGaugeRates warns when counter functions are applied to gauges
+	WarnOnGaugeRates bool
+	// MergeReplicas merges the replicas of counters for rate and increase
+	MergeReplicas bool
+	// RegressionDecayHalfLife weights the samples fit by deriv and predict_linear
+	RegressionDecayHalfLife time.Duration
+	// CounterMaxValue is the value at which counters wrap for rate and increase
+	CounterMaxValue float64
+	// MinSamples is the number of samples a window of the counter and regression functions needs
+	MinSamples int
+	// DisableExtrapolation returns the raw changes of the counter functions
+	DisableExtrapolation bool
+	// ResetTolerance is the largest decrease of a counter which is not a reset
+	ResetTolerance float64
+	// NonNegative clamps the negative changes of delta to 0
+	NonNegative bool
+	// RegressionReference is the time predict_linear predicts from
+	RegressionReference utils.RegressionReference
+	// InterpolationMethod determines how quantiles pick values between ranks
+	InterpolationMethod utils.InterpolationMethod
+	// UnanchoredLabelReplace matches the regexes of label_replace anywhere in the source value
+	UnanchoredLabelReplace bool
+	// IncludeGroupSize adds the size of each group to sums, averages and counts
+	IncludeGroupSize bool
+	// RollupShards aggregates sums and counts in two phases over this many shards
+	RollupShards int
+	// IncludeTies makes topk, bottomk and range_topk take the ties of the k-th element
+	IncludeTies bool
+	// StreamingAggregation aggregates sums, counts, averages, minimums and maximums one series at a time
+	StreamingAggregation bool
+	// LeftInclusive closes the left edge of range windows
+	LeftInclusive bool
+	// SeriesLimit caps the number of series each selector returns
+	SeriesLimit int
+	// SeriesOffset skips the first series each selector matches
+	SeriesOffset int
+	// SelectorLookbacks overrides the lookback duration for the selectors of each metric name
+	SelectorLookbacks map[string]time.Duration
+	// MaxSeriesPerNode caps the series any node may emit
+	MaxSeriesPerNode int
+	// MaxBlockBytes caps the estimated size of the blocks built and fetched by the query
+	MaxBlockBytes int
+	// Consolidation configures how sources consolidate raw datapoints onto steps
+	Consolidation ts.ConsolidationOptions
+	// IncludeRawSamples returns the raw datapoints fetched by sources with the results
+	IncludeRawSamples bool
+	// MaxRawSamples caps the raw datapoints returned
+	MaxRawSamples int
+	// TagSanitizer rewrites the tag values of the series fetched by sources
+	TagSanitizer transform.TagSanitizer
 }
 
 // ResultOp is resonsible for delivering results to the clients
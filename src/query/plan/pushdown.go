@@ -0,0 +1,125 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package plan
+
+import (
+	"github.com/m3db/m3/src/query/parser"
+)
+
+// GroupedAggregation is implemented by aggregations which compute each group at a step from the
+// values of its series alone, and which are NaN when every value of the group is NaN
+type GroupedAggregation interface {
+	parser.Params
+	// Grouping returns the labels series are grouped by, or the labels which are dropped if
+	// without, and false if the aggregation cannot be pushed down
+	Grouping() (labels []string, without bool, ok bool)
+}
+
+// SeriesFilter is implemented by set operations which keep or drop each lhs value depending on
+// whether the rhs has a match, e.g. and
+type SeriesFilter interface {
+	parser.Params
+	// RHS returns the node the rhs is read from
+	RHS() parser.NodeID
+	// WithAggregatedLHS returns the op reading its lhs from the aggregation node instead, and false
+	// if series of a single group could match the rhs differently
+	WithAggregatedLHS(labels []string, without bool, aggregation parser.NodeID) (parser.Params, bool)
+}
+
+// PushDownAggregations rewrites aggregations of a set operation to aggregate its lhs instead, e.g.
+// sum by (job) (a and on(job) b) to sum by (job) (a) and on(job) b, so that the set operation
+// matches one series per group rather than every input series. The rewrite is only made when it
+// is result equivalent, which requires all series of a group to be kept or dropped together:
+//   - the set operation matches on labels the aggregation keeps, or ignores all of the labels
+//     the aggregation drops, and does not match on the metric name
+//   - the aggregation computes each group from the values of its series, and is NaN when all of
+//     them are, as a dropped group is in the original
+//   - nothing else consumes the set operation, and the aggregation has no other input
+func PushDownAggregations(nodes parser.Nodes, edges parser.Edges) (parser.Nodes, parser.Edges) {
+	ops := make(map[parser.NodeID]parser.Params, len(nodes))
+	for _, node := range nodes {
+		ops[node.ID] = node.Op
+	}
+
+	for pushed := true; pushed; {
+		pushed = false
+		parents, children := make(map[parser.NodeID]int), make(map[parser.NodeID]int)
+		for _, edge := range edges {
+			children[edge.ParentID]++
+			parents[edge.ChildID]++
+		}
+
+		for _, edge := range edges {
+			aggregation, ok := ops[edge.ChildID].(GroupedAggregation)
+			if !ok || parents[edge.ChildID] != 1 || children[edge.ParentID] != 1 {
+				continue
+			}
+
+			filter, ok := ops[edge.ParentID].(SeriesFilter)
+			if !ok {
+				continue
+			}
+
+			labels, without, ok := aggregation.Grouping()
+			if !ok {
+				continue
+			}
+
+			// The nodes swap ops rather than IDs, so that consumers of the aggregation which refer
+			// to it by ID read from the filter instead
+			op, ok := filter.WithAggregatedLHS(labels, without, edge.ParentID)
+			if !ok {
+				continue
+			}
+
+			ops[edge.ParentID], ops[edge.ChildID] = aggregation, op
+			edges = swap(edges, filter.RHS(), edge.ParentID, edge.ChildID)
+			pushed = true
+			break
+		}
+	}
+
+	rewritten := make(parser.Nodes, 0, len(nodes))
+	for _, node := range nodes {
+		rewritten = append(rewritten, parser.Node{ID: node.ID, Op: ops[node.ID]})
+	}
+
+	return rewritten, edges
+}
+
+// swap moves the rhs of the filter to the node which now holds the filter, after the edge from
+// the aggregation so that the inputs stay in order
+func swap(edges parser.Edges, rhs, from, to parser.NodeID) parser.Edges {
+	swapped := make(parser.Edges, 0, len(edges))
+	for _, edge := range edges {
+		switch {
+		case edge.ParentID == rhs && edge.ChildID == from:
+			continue
+		case edge.ParentID == from && edge.ChildID == to:
+			swapped = append(swapped, edge, parser.Edge{ParentID: rhs, ChildID: to})
+			continue
+		}
+
+		swapped = append(swapped, edge)
+	}
+
+	return swapped
+}
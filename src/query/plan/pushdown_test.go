@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/query/parser/promql"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushDownAggregations(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "sum by (job) (up and on(job) down)",
+			expected: "sum by (job) (up) and on(job) down",
+		},
+		{
+			query:    "max by (job, zone) (up and on(zone) down)",
+			expected: "max by (job, zone) (up) and on(zone) down",
+		},
+		{
+			query:    "count without (instance) (up and on(job) down)",
+			expected: "count without (instance) (up) and on(job) down",
+		},
+		{
+			query:    "avg without (instance) (up and ignoring(instance, zone) down)",
+			expected: "avg without (instance) (up) and ignoring(instance, zone) down",
+		},
+		{
+			query:    "sum(min by (job) (up and on(job) down) and on(job) other)",
+			expected: "sum(min by (job) (up) and on(job) down and on(job) other)",
+		},
+	}
+
+	for _, tt := range tests {
+		p, err := promql.Parse(tt.query)
+		require.NoError(t, err)
+		nodes, edges, err := p.DAG()
+		require.NoError(t, err)
+
+		pushedNodes, pushedEdges := PushDownAggregations(nodes, edges)
+		assert.Len(t, pushedNodes, len(nodes), tt.query)
+		assert.Len(t, pushedEdges, len(edges), tt.query)
+
+		formatted, err := promql.Format(pushedNodes, pushedEdges)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, formatted)
+
+		_, err = NewLogicalPlan(pushedNodes, pushedEdges)
+		require.NoError(t, err, tt.query)
+	}
+}
+
+func TestPushDownAggregationsLeavesUnsafeQueries(t *testing.T) {
+	queries := []string{
+		// Series of a group can have different signatures
+		"sum by (job) (up and down)",
+		"sum by (job) (up and on(instance) down)",
+		"sum without (instance) (up and on(instance) down)",
+		"sum by (job) (up and ignoring(instance) down)",
+		"sum without (instance, zone) (up and ignoring(instance) down)",
+		// The aggregation output has no metric name to match on
+		"sum by (__name__) (up and on(__name__) down)",
+		// The aggregation is not NaN for groups without values, or keeps input series
+		"topk(1, up and on(job) down)",
+		"quantile by (job) (0.5, up and on(job) down)",
+		// Only and drops whole series
+		"sum by (job) (up + on(job) down)",
+	}
+
+	for _, query := range queries {
+		p, err := promql.Parse(query)
+		require.NoError(t, err)
+		nodes, edges, err := p.DAG()
+		require.NoError(t, err)
+
+		pushedNodes, pushedEdges := PushDownAggregations(nodes, edges)
+		assert.Equal(t, edges, pushedEdges, query)
+		formatted, err := promql.Format(pushedNodes, pushedEdges)
+		require.NoError(t, err)
+		assert.Equal(t, query, formatted)
+	}
+}
@@ -29,8 +29,16 @@ import (
 )
 
 // FetchResultToBlockResult converts a fetch result into coordinator blocks
-func FetchResultToBlockResult(result *FetchResult, query *FetchQuery) (block.Result, error) {
-	alignedSeriesList, err := result.SeriesList.Align(query.Start, query.End, query.Interval)
+func FetchResultToBlockResult(result *FetchResult, query *FetchQuery, options *FetchOptions) (block.Result, error) {
+	var consolidation ts.ConsolidationOptions
+	if options != nil {
+		consolidation = options.Consolidation
+		if options.RawSeries != nil {
+			options.RawSeries(result.SeriesList)
+		}
+	}
+
+	alignedSeriesList, err := result.SeriesList.Align(query.Start, query.End, query.Interval, consolidation)
 	if err != nil {
 		return block.Result{}, err
 	}
@@ -40,6 +48,10 @@ func FetchResultToBlockResult(result *FetchResult, query *FetchQuery) (block.Res
 		return block.Result{}, err
 	}
 
+	if err := options.chargeBlock(multiBlock.SeriesMeta(), multiBlock.StepCount()); err != nil {
+		return block.Result{}, err
+	}
+
 	return block.Result{
 		Blocks: []block.Block{multiBlock},
 	}, nil
@@ -93,6 +105,17 @@ func (m multiSeriesBlock) SeriesMeta() []block.SeriesMeta {
 	return metas
 }
 
+// SampleTime returns the timestamp of the sample of the series at the step, if sample times
+// were retained during consolidation
+func (m multiSeriesBlock) SampleTime(series, step int) (time.Time, bool) {
+	values, ok := m.seriesList[series].Values().(ts.SampleTimedValues)
+	if !ok || step >= m.seriesList[series].Len() {
+		return time.Time{}, false
+	}
+
+	return values.SampleTimeAt(step)
+}
+
 // TODO: Actually free up resources
 func (m multiSeriesBlock) Close() error {
 	return nil
@@ -131,8 +154,7 @@ func (m *multiSeriesBlockStepIter) Current() (block.Step, error) {
 		}
 	}
 
-	bounds := m.block.meta.Bounds
-	t := bounds.Start.Add(time.Duration(m.index) * bounds.StepSize)
+	t := m.block.meta.Bounds.TimeForStep(m.index)
 	return block.NewColStep(t, values), nil
 }
 
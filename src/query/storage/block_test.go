@@ -0,0 +1,164 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchResultToBlockResultWithDuplicateTimestamps(t *testing.T) {
+	start := time.Unix(600, 0)
+	datapoints := ts.Datapoints{
+		{Timestamp: start, Value: 7},
+		{Timestamp: start, Value: 9},
+		{Timestamp: start, Value: 8},
+		{Timestamp: start.Add(time.Minute), Value: 1},
+	}
+
+	query := &FetchQuery{Start: start, End: start.Add(2 * time.Minute), Interval: time.Minute}
+	result := &FetchResult{
+		SeriesList: ts.SeriesList{ts.NewSeries("up", datapoints, models.Tags{"job": "a"})},
+	}
+
+	for _, tt := range []struct {
+		policy   ts.DuplicateTimestampPolicy
+		expected float64
+	}{
+		{policy: nil, expected: 8},
+		{policy: ts.DuplicateTimestampMax, expected: 9},
+		{policy: ts.DuplicateTimestampFirst, expected: 7},
+	} {
+		options := &FetchOptions{Consolidation: ts.ConsolidationOptions{DuplicateTimestampPolicy: tt.policy}}
+		blockResult, err := FetchResultToBlockResult(result, query, options)
+		require.NoError(t, err)
+		require.Len(t, blockResult.Blocks, 1)
+
+		iter, err := blockResult.Blocks[0].SeriesIter()
+		require.NoError(t, err)
+		require.True(t, iter.Next())
+		series, err := iter.Current()
+		require.NoError(t, err)
+		require.True(t, series.Len() > 1)
+		assert.Equal(t, []float64{tt.expected, 1}, series.Values()[:2])
+	}
+}
+
+func TestFetchResultToBlockResultWithRawSeries(t *testing.T) {
+	start := time.Unix(600, 0)
+	datapoints := ts.Datapoints{{Timestamp: start, Value: 7}, {Timestamp: start, Value: 9}}
+	query := &FetchQuery{Start: start, End: start.Add(2 * time.Minute), Interval: time.Minute}
+	result := &FetchResult{
+		SeriesList: ts.SeriesList{ts.NewSeries("up", datapoints, models.Tags{"job": "a"})},
+	}
+
+	var raw ts.SeriesList
+	_, err := FetchResultToBlockResult(result, query, &FetchOptions{RawSeries: func(seriesList ts.SeriesList) {
+		raw = seriesList
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, result.SeriesList, raw, "the series are passed before consolidation")
+}
+
+func TestFetchResultToBlockResultChargesBlock(t *testing.T) {
+	start := time.Unix(600, 0)
+	datapoints := ts.Datapoints{{Timestamp: start, Value: 7}}
+	query := &FetchQuery{Start: start, End: start.Add(2 * time.Minute), Interval: time.Minute}
+	result := &FetchResult{
+		SeriesList: ts.SeriesList{ts.NewSeries("up", datapoints, models.Tags{"job": "a"})},
+	}
+
+	var charged []block.SeriesMeta
+	errLimit := errors.New("limit exceeded")
+	_, err := FetchResultToBlockResult(result, query, &FetchOptions{
+		ChargeBlock: func(seriesMeta []block.SeriesMeta, steps int) error {
+			charged = seriesMeta
+			assert.Equal(t, 3, steps)
+			return errLimit
+		},
+	})
+	assert.Equal(t, errLimit, err)
+	require.Len(t, charged, 1)
+	assert.Equal(t, models.Tags{"job": "a"}, charged[0].Tags)
+}
+
+func benchmarkStorageBlock(b *testing.B) block.Block {
+	const numSeries, numSteps = 100, 1000
+	start := time.Unix(600, 0)
+	seriesList := make(ts.SeriesList, numSeries)
+	for i := range seriesList {
+		datapoints := make(ts.Datapoints, numSteps)
+		for j := range datapoints {
+			datapoints[j] = ts.Datapoint{Timestamp: start.Add(time.Duration(j) * time.Minute), Value: float64(i * j)}
+		}
+
+		seriesList[i] = ts.NewSeries("up", datapoints, models.Tags{"i": string(rune('a' + i%26))})
+	}
+
+	query := &FetchQuery{Start: start, End: start.Add(numSteps * time.Minute), Interval: time.Minute}
+	result, err := FetchResultToBlockResult(&FetchResult{SeriesList: seriesList}, query, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return result.Blocks[0]
+}
+
+// transposeForReaders transposes the block for each of several range functions reading it
+func transposeForReaders(blk block.Block) error {
+	const readers = 4
+	for r := 0; r < readers; r++ {
+		if _, err := block.Transpose(blk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func BenchmarkTransposeStorageBlockCached(b *testing.B) {
+	blk := benchmarkStorageBlock(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// The block is wrapped once per query, as the controller does for inputs it shares
+		if err := transposeForReaders(block.WithCachedRows(blk)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTransposeStorageBlockUncached(b *testing.B) {
+	blk := benchmarkStorageBlock(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := transposeForReaders(blk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -22,6 +22,9 @@ package fanout
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"time"
 
 	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/errors"
@@ -117,20 +120,300 @@ func (s *fanoutStorage) Type() storage.Type {
 
 func (s *fanoutStorage) FetchBlocks(
 	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
-	stores := filterStores(s.stores, s.writeFilter, query)
+	stores := filterStores(s.stores, s.fetchFilter, query)
+	responses, err := fetchBlocksWithDeadline(ctx, stores, query, options)
+	if err != nil {
+		return block.Result{}, err
+	}
+
 	blockResult := block.Result{}
-	for _, store := range stores {
-		result, err := store.FetchBlocks(ctx, query, options)
-		if err != nil {
-			return block.Result{}, err
+	var blocks []block.Block
+	for idx, response := range responses {
+		if response.timedOut {
+			blockResult.Warnings = append(blockResult.Warnings, fmt.Sprintf(
+				"storage %d timed out, results may be partial", idx))
+			continue
 		}
 
-		blockResult.Blocks = append(blockResult.Blocks, result.Blocks...)
+		// Stores which do not report a source are named by their type
+		source := response.result.Source
+		if source == "" {
+			source = stores[idx].Type().String()
+		}
+
+		for _, b := range response.result.Blocks {
+			blocks = append(blocks, block.NewSourceBlock(b, source))
+		}
+
+		blockResult.Warnings = append(blockResult.Warnings, response.result.Warnings...)
+	}
+
+	// The sources are recorded on each series, as the blocks may hold series from several stores
+	blockResult.Blocks, err = mergeBlocks(blocks, options)
+	if err != nil {
+		return block.Result{}, err
 	}
 
 	return blockResult, nil
 }
 
+type blocksResponse struct {
+	idx      int
+	result   block.Result
+	err      error
+	timedOut bool
+}
+
+// fetchTimeoutRatio is the share of the time left before the query deadline which stores are
+// given to respond, leaving the rest to process the results of the stores which did respond
+const fetchTimeoutRatio = 0.8
+
+// withFetchTimeout returns a context for the stores which expires before the query deadline
+func withFetchTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, time.Duration(float64(time.Until(deadline))*fetchTimeoutRatio))
+}
+
+// fetchBlocksWithDeadline fetches blocks from all stores in parallel, marking the stores which did
+// not respond before the fetch timeout as timed out. It fails if the query is canceled, if any
+// store fails for another reason, or if every store timed out
+func fetchBlocksWithDeadline(ctx context.Context, stores []storage.Storage,
+	query *storage.FetchQuery, options *storage.FetchOptions) ([]blocksResponse, error) {
+	if len(stores) == 0 {
+		return nil, nil
+	}
+
+	fetchCtx, cancel := withFetchTimeout(ctx)
+	defer cancel()
+
+	// Buffered so that stores which respond after the timeout do not block forever
+	received := make(chan blocksResponse, len(stores))
+	for idx, store := range stores {
+		go func(idx int, store storage.Storage) {
+			result, err := store.FetchBlocks(fetchCtx, query, options)
+			received <- blocksResponse{idx: idx, result: result, err: err}
+		}(idx, store)
+	}
+
+	responses := make([]blocksResponse, len(stores))
+	for idx := range responses {
+		responses[idx] = blocksResponse{idx: idx, timedOut: true}
+	}
+
+	var (
+		pending = len(stores)
+		err     error
+	)
+
+wait:
+	for ; pending > 0; pending-- {
+		select {
+		case response := <-received:
+			if response.err == context.DeadlineExceeded && ctx.Err() == nil {
+				response.timedOut = true
+			} else if response.err != nil {
+				pending--
+				err = response.err
+				break wait
+			}
+
+			responses[response.idx] = response
+		case <-fetchCtx.Done():
+			// Only the fetch timeout expiring gives partial results, a canceled query fails
+			err = ctx.Err()
+			break wait
+		}
+	}
+
+	// Stores which respond once their results are no longer awaited still return blocks to free
+	if pending > 0 {
+		go closeLateResponses(received, pending)
+	}
+
+	if err != nil {
+		for _, response := range responses {
+			closeBlocks(response.result.Blocks)
+		}
+
+		return nil, err
+	}
+
+	for _, response := range responses {
+		if !response.timedOut {
+			return responses, nil
+		}
+	}
+
+	return nil, context.DeadlineExceeded
+}
+
+// closeLateResponses waits for the pending responses, closing their blocks
+func closeLateResponses(received <-chan blocksResponse, pending int) {
+	for ; pending > 0; pending-- {
+		response := <-received
+		closeBlocks(response.result.Blocks)
+	}
+}
+
+func closeBlocks(blocks []block.Block) {
+	for _, b := range blocks {
+		b.Close()
+	}
+}
+
+// mergeBlocks merges the blocks which share bounds into one block. Series with matching tags are
+// combined into one series, and values present in several blocks are resolved with the duplicate
+// timestamp policy of the options. The blocks are closed if merging fails
+func mergeBlocks(blocks []block.Block, options *storage.FetchOptions) ([]block.Block, error) {
+	var consolidation ts.ConsolidationOptions
+	if options != nil {
+		consolidation = options.Consolidation
+	}
+
+	policy := consolidation.Policy()
+	var groups []blockGroup
+	for _, b := range blocks {
+		iter, err := b.StepIter()
+		if err != nil {
+			closeBlocks(blocks)
+			return nil, err
+		}
+
+		bounds := iter.Meta().Bounds
+		iter.Close()
+		groups = addToGroup(groups, bounds, b)
+	}
+
+	merged := make([]block.Block, 0, len(groups))
+	for idx, group := range groups {
+		if len(group.blocks) == 1 {
+			merged = append(merged, group.blocks[0])
+			continue
+		}
+
+		b, err := mergeGroup(group.blocks, policy, options)
+		if err != nil {
+			// The group failing to merge is closed by mergeGroup
+			closeBlocks(merged)
+			for _, remaining := range groups[idx+1:] {
+				closeBlocks(remaining.blocks)
+			}
+
+			return nil, err
+		}
+
+		merged = append(merged, b)
+	}
+
+	return merged, nil
+}
+
+type blockGroup struct {
+	bounds block.Bounds
+	blocks []block.Block
+}
+
+// addToGroup adds the block to the group with matching bounds, creating one if none match
+func addToGroup(groups []blockGroup, bounds block.Bounds, b block.Block) []blockGroup {
+	for i, group := range groups {
+		if group.bounds.Start.Equal(bounds.Start) && group.bounds.End.Equal(bounds.End) &&
+			group.bounds.StepSize == bounds.StepSize {
+			groups[i].blocks = append(groups[i].blocks, b)
+			return groups
+		}
+	}
+
+	return append(groups, blockGroup{bounds: bounds, blocks: []block.Block{b}})
+}
+
+// mergeGroup merges blocks with the same bounds, closing them once merged
+func mergeGroup(
+	blocks []block.Block,
+	policy ts.DuplicateTimestampPolicy,
+	options *storage.FetchOptions,
+) (block.Block, error) {
+	defer closeBlocks(blocks)
+
+	var (
+		meta       block.Metadata
+		seriesMeta []block.SeriesMeta
+		rows       [][]float64
+		indices    = make(map[string]int)
+	)
+
+	for i, b := range blocks {
+		iter, err := b.SeriesIter()
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			meta = iter.Meta()
+		}
+
+		for iter.Next() {
+			series, err := iter.Current()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+
+			id := series.Meta.Tags.ID()
+			idx, ok := indices[id]
+			if !ok {
+				indices[id] = len(rows)
+				seriesMeta = append(seriesMeta, series.Meta)
+				rows = append(rows, append([]float64(nil), series.Values()...))
+				continue
+			}
+
+			// Series served by several sources are merged, so cannot be attributed to one of them
+			if seriesMeta[idx].Source != series.Meta.Source {
+				seriesMeta[idx].Source = ""
+			}
+
+			values := rows[idx]
+			for step, v := range series.Values() {
+				if math.IsNaN(v) {
+					continue
+				}
+
+				if math.IsNaN(values[step]) {
+					values[step] = v
+				} else {
+					values[step] = policy(values[step], v)
+				}
+			}
+		}
+
+		iter.Close()
+	}
+
+	steps := meta.Bounds.Steps()
+	builder, err := options.NewBlockBuilder(meta, seriesMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := builder.AddCols(steps); err != nil {
+		return nil, err
+	}
+
+	for _, values := range rows {
+		for step := 0; step < steps && step < len(values); step++ {
+			if err := builder.AppendValue(step, values[step]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
 func (s *fanoutStorage) Close() error {
 	var lastErr error
 	for idx, store := range s.stores {
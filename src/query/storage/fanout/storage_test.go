@@ -23,13 +23,17 @@ package fanout
 import (
 	"context"
 	"fmt"
+	"math"
 	"testing"
 	"time"
 
 	"github.com/m3db/m3/src/dbnode/encoding"
+	"github.com/m3db/m3/src/query/block"
 	"github.com/m3db/m3/src/query/errors"
+	"github.com/m3db/m3/src/query/models"
 	"github.com/m3db/m3/src/query/policy/filter"
 	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/storage/mock"
 	"github.com/m3db/m3/src/query/test/local"
 	"github.com/m3db/m3/src/query/test/seriesiter"
 	"github.com/m3db/m3/src/query/ts"
@@ -166,3 +170,261 @@ func TestFanoutWriteSuccess(t *testing.T) {
 	})
 	assert.NoError(t, err)
 }
+
+// slowStorage does not respond to block fetches until it is released, ignoring the context
+type slowStorage struct {
+	mock.Storage
+	release chan struct{}
+}
+
+func (s *slowStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	<-s.release
+	return s.Storage.FetchBlocks(ctx, query, options)
+}
+
+// closeRecordingBlock records when the block is closed
+type closeRecordingBlock struct {
+	block.Block
+	closed chan struct{}
+}
+
+func (b closeRecordingBlock) Close() error {
+	close(b.closed)
+	return b.Block.Close()
+}
+
+func newFanoutTestBlock(t *testing.T, bounds block.Bounds, tags []models.Tags, values [][]float64) block.Block {
+	seriesMeta := make([]block.SeriesMeta, len(tags))
+	for i, t := range tags {
+		seriesMeta[i] = block.SeriesMeta{Name: t.ID(), Tags: t}
+	}
+
+	builder := block.NewColumnBlockBuilder(block.Metadata{Bounds: bounds}, seriesMeta)
+	require.NoError(t, builder.AddCols(bounds.Steps()))
+	for _, row := range values {
+		for idx, v := range row {
+			require.NoError(t, builder.AppendValue(idx, v))
+		}
+	}
+
+	return builder.Build()
+}
+
+func TestFanoutFetchBlocksMergesAndTimesOut(t *testing.T) {
+	setup()
+	now := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: now, End: now.Add(2 * time.Minute), StepSize: time.Minute}
+	a1 := models.Tags{"a": "1"}
+	a2 := models.Tags{"a": "2"}
+	nan := math.NaN()
+
+	store1 := mock.NewMockStorage()
+	store1.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{newFanoutTestBlock(t, bounds, []models.Tags{a1}, [][]float64{{1, nan, 3}})},
+	}, nil)
+	store2 := mock.NewMockStorage()
+	store2.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{newFanoutTestBlock(t, bounds, []models.Tags{a2, a1},
+			[][]float64{{7, 8, 9}, {5, 2, 1}})},
+		Source: "zone-b",
+	}, nil)
+	slow := &slowStorage{Storage: mock.NewMockStorage(), release: make(chan struct{})}
+	defer close(slow.release)
+
+	store := NewStorage([]storage.Storage{store1, store2, slow}, filterFunc(true), filterFunc(true))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := store.FetchBlocks(ctx, &storage.FetchQuery{}, &storage.FetchOptions{
+		Consolidation: ts.ConsolidationOptions{DuplicateTimestampPolicy: ts.DuplicateTimestampMax},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"storage 2 timed out, results may be partial"}, result.Warnings)
+	assert.Empty(t, result.Source)
+	require.Len(t, result.Blocks, 1)
+
+	iter, err := result.Blocks[0].SeriesIter()
+	require.NoError(t, err)
+	defer iter.Close()
+	expected := map[string][]float64{
+		a1.ID(): {5, 2, 3},
+		a2.ID(): {7, 8, 9},
+	}
+	// Series merged from several stores have no single source
+	sources := map[string]string{
+		a1.ID(): "",
+		a2.ID(): "zone-b",
+	}
+	require.Equal(t, 2, iter.SeriesCount())
+	for iter.Next() {
+		series, err := iter.Current()
+		require.NoError(t, err)
+		assert.Equal(t, expected[series.Meta.Tags.ID()], series.Values())
+		assert.Equal(t, sources[series.Meta.Tags.ID()], series.Meta.Source)
+	}
+}
+
+func TestFanoutFetchBlocksMergesWithBlockBuilder(t *testing.T) {
+	setup()
+	now := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: now, End: now.Add(2 * time.Minute), StepSize: time.Minute}
+	a1 := models.Tags{"a": "1"}
+	a2 := models.Tags{"a": "2"}
+
+	closed1, closed2 := make(chan struct{}), make(chan struct{})
+	store1 := mock.NewMockStorage()
+	store1.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{closeRecordingBlock{
+			Block:  newFanoutTestBlock(t, bounds, []models.Tags{a1}, [][]float64{{1, 2, 3}}),
+			closed: closed1,
+		}},
+	}, nil)
+	store2 := mock.NewMockStorage()
+	store2.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{closeRecordingBlock{
+			Block:  newFanoutTestBlock(t, bounds, []models.Tags{a2}, [][]float64{{4, 5, 6}}),
+			closed: closed2,
+		}},
+	}, nil)
+
+	store := NewStorage([]storage.Storage{store1, store2}, filterFunc(true), filterFunc(true))
+	errLimit := fmt.Errorf("limit exceeded")
+	var built []block.SeriesMeta
+	_, err := store.FetchBlocks(context.TODO(), &storage.FetchQuery{}, &storage.FetchOptions{
+		BlockBuilder: func(_ block.Metadata, seriesMeta []block.SeriesMeta) (block.Builder, error) {
+			built = seriesMeta
+			return nil, errLimit
+		},
+	})
+	assert.Equal(t, errLimit, err)
+	assert.Len(t, built, 2, "the merged block is built with the builder of the options")
+	for _, closed := range []chan struct{}{closed1, closed2} {
+		select {
+		case <-closed:
+		default:
+			t.Fatal("the fetched blocks were not closed when merging failed")
+		}
+	}
+}
+
+func TestFanoutFetchBlocksClosesLateBlocks(t *testing.T) {
+	setup()
+	now := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: now, End: now.Add(2 * time.Minute), StepSize: time.Minute}
+	tags := []models.Tags{{"a": "1"}}
+
+	store1 := mock.NewMockStorage()
+	store1.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{newFanoutTestBlock(t, bounds, tags, [][]float64{{1, 2, 3}})},
+	}, nil)
+	slow := &slowStorage{Storage: mock.NewMockStorage(), release: make(chan struct{})}
+	closed := make(chan struct{})
+	slow.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{closeRecordingBlock{
+			Block:  newFanoutTestBlock(t, bounds, tags, [][]float64{{4, 5, 6}}),
+			closed: closed,
+		}},
+	}, nil)
+
+	store := NewStorage([]storage.Storage{store1, slow}, filterFunc(true), filterFunc(true))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result, err := store.FetchBlocks(ctx, &storage.FetchQuery{}, nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Blocks, 1)
+
+	// The slow store responds after the deadline, and its blocks are freed rather than dropped
+	close(slow.release)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the blocks of the late response were not closed")
+	}
+}
+
+func TestFanoutFetchBlocksAllTimedOut(t *testing.T) {
+	setup()
+	slow := &slowStorage{Storage: mock.NewMockStorage(), release: make(chan struct{})}
+	defer close(slow.release)
+
+	store := NewStorage([]storage.Storage{slow}, filterFunc(true), filterFunc(true))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := store.FetchBlocks(ctx, &storage.FetchQuery{}, nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestFanoutFetchBlocksTimesOutBeforeQueryDeadline(t *testing.T) {
+	setup()
+	slow := &slowStorage{Storage: mock.NewMockStorage(), release: make(chan struct{})}
+	defer close(slow.release)
+
+	store := NewStorage([]storage.Storage{mock.NewMockStorage(), slow}, filterFunc(true), filterFunc(true))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	result, err := store.FetchBlocks(ctx, &storage.FetchQuery{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"storage 1 timed out, results may be partial"}, result.Warnings)
+	assert.NoError(t, ctx.Err(), "partial results are returned while the query can still use them")
+}
+
+func TestFanoutFetchBlocksCanceled(t *testing.T) {
+	setup()
+	slow := &slowStorage{Storage: mock.NewMockStorage(), release: make(chan struct{})}
+	defer close(slow.release)
+
+	store := NewStorage([]storage.Storage{mock.NewMockStorage(), slow}, filterFunc(true), filterFunc(true))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := store.FetchBlocks(ctx, &storage.FetchQuery{}, nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestFanoutFetchBlocksUsesFetchFilter(t *testing.T) {
+	setup()
+	now := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: now, End: now.Add(2 * time.Minute), StepSize: time.Minute}
+	fetched := mock.NewMockStorage()
+	fetched.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{newFanoutTestBlock(t, bounds, []models.Tags{{"a": "1"}}, [][]float64{{1, 2, 3}})},
+	}, nil)
+
+	// Block fetches pick their stores like other reads, regardless of the write filter
+	store := NewStorage([]storage.Storage{fetched}, filterFunc(true), filterFunc(false))
+	result, err := store.FetchBlocks(context.TODO(), &storage.FetchQuery{}, nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Blocks, 1)
+}
+
+func TestFanoutFetchBlocksRecordsSourcePerSeries(t *testing.T) {
+	setup()
+	now := time.Now().Truncate(time.Minute)
+	bounds := block.Bounds{Start: now, End: now.Add(2 * time.Minute), StepSize: time.Minute}
+	later := block.Bounds{Start: bounds.End, End: bounds.End.Add(2 * time.Minute), StepSize: time.Minute}
+	a1 := models.Tags{"a": "1"}
+
+	store1 := mock.NewMockStorage()
+	store1.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{newFanoutTestBlock(t, bounds, []models.Tags{a1}, [][]float64{{1, 2, 3}})},
+		Source: "zone-a",
+	}, nil)
+	store2 := mock.NewMockStorage()
+	store2.SetFetchBlocksResult(block.Result{
+		Blocks: []block.Block{newFanoutTestBlock(t, later, []models.Tags{a1}, [][]float64{{4, 5, 6}})},
+	}, nil)
+
+	store := NewStorage([]storage.Storage{store1, store2}, filterFunc(true), filterFunc(true))
+	result, err := store.FetchBlocks(context.TODO(), &storage.FetchQuery{}, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Blocks, 2)
+
+	// Blocks which are not merged keep the source of their store, named by its type if unknown
+	for i, source := range []string{"zone-a", "local"} {
+		iter, err := result.Blocks[i].StepIter()
+		require.NoError(t, err)
+		metas := iter.SeriesMeta()
+		iter.Close()
+		require.Len(t, metas, 1)
+		assert.Equal(t, source, metas[0].Source)
+	}
+}
@@ -43,6 +43,20 @@ const (
 	TypeMultiDC
 )
 
+// String returns the name of the storage type, which is used as the source of the series it serves
+func (t Type) String() string {
+	switch t {
+	case TypeLocalDC:
+		return "local"
+	case TypeRemoteDC:
+		return "remote"
+	case TypeMultiDC:
+		return "multi"
+	default:
+		return "unknown"
+	}
+}
+
 // Storage provides an interface for reading and writing to the tsdb
 type Storage interface {
 	Querier
@@ -80,6 +94,39 @@ func (q *FetchQuery) String() string {
 type FetchOptions struct {
 	Limit    int
 	KillChan chan struct{}
+	// Consolidation configures how raw datapoints are consolidated onto the query steps
+	Consolidation ts.ConsolidationOptions
+	// RawSeries, when set, is called by FetchBlocks with the raw series fetched, before they are
+	// consolidated into blocks
+	RawSeries func(ts.SeriesList)
+	// BlockBuilder, when set, creates the builders of blocks built while fetching, e.g. when
+	// merging the blocks of several stores, so that the limits of the query apply to them
+	BlockBuilder block.BuilderFn
+	// ChargeBlock, when set, accounts the estimated bytes of blocks created while fetching other
+	// than by the BlockBuilder, e.g. from consolidated series, failing the fetch if the query would
+	// hold more than allowed
+	ChargeBlock func(seriesMeta []block.SeriesMeta, steps int) error
+}
+
+// NewBlockBuilder creates a builder for a block built while fetching, using the BlockBuilder
+// of the options if set
+func (o *FetchOptions) NewBlockBuilder(
+	blockMeta block.Metadata, seriesMeta []block.SeriesMeta) (block.Builder, error) {
+	if o == nil || o.BlockBuilder == nil {
+		return block.NewColumnBlockBuilder(blockMeta, seriesMeta), nil
+	}
+
+	return o.BlockBuilder(blockMeta, seriesMeta)
+}
+
+// chargeBlock accounts the bytes of a block created while fetching, using the ChargeBlock of the
+// options if set
+func (o *FetchOptions) chargeBlock(seriesMeta []block.SeriesMeta, steps int) error {
+	if o == nil || o.ChargeBlock == nil {
+		return nil
+	}
+
+	return o.ChargeBlock(seriesMeta, steps)
 }
 
 // Querier handles queries against a storage.
@@ -256,11 +256,12 @@ func (s *localStorage) FetchBlocks(
 		return block.Result{}, err
 	}
 
-	res, err := storage.FetchResultToBlockResult(fetchResult, query)
+	res, err := storage.FetchResultToBlockResult(fetchResult, query, options)
 	if err != nil {
 		return block.Result{}, err
 	}
 
+	res.Source = s.Type().String()
 	return res, nil
 }
 
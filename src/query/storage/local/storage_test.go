@@ -223,6 +223,22 @@ func TestLocalRead(t *testing.T) {
 	assert.Equal(t, tags, results.SeriesList[0].Tags)
 }
 
+func TestLocalFetchBlocksSetsSource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store, sessions := setup(t, ctrl)
+	testTags := seriesiter.GenerateTag()
+	sessions.forEach(func(session *client.MockSession) {
+		session.EXPECT().FetchTagged(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(seriesiter.NewMockSeriesIters(ctrl, testTags, 1, 2), true, nil)
+	})
+	searchReq := newFetchReq()
+	searchReq.Interval = time.Minute
+	result, err := store.FetchBlocks(context.TODO(), searchReq, &storage.FetchOptions{Limit: 100})
+	require.NoError(t, err)
+	assert.Equal(t, "local", result.Source)
+}
+
 func TestLocalReadNoClustersForTimeRangeError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -61,5 +61,16 @@ func (s *remoteStorage) Close() error {
 
 func (s *remoteStorage) FetchBlocks(
 	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
-	return block.Result{}, errors.ErrNotImplemented
+	fetchResult, err := s.Fetch(ctx, query, options)
+	if err != nil {
+		return block.Result{}, err
+	}
+
+	res, err := storage.FetchResultToBlockResult(fetchResult, query, options)
+	if err != nil {
+		return block.Result{}, err
+	}
+
+	res.Source = s.Type().String()
+	return res, nil
 }
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+)
+
+// SearchSeries returns the label sets of the series matching the matchers between start and end,
+// without fetching their values. The matchers are pushed down to the storage, and identical
+// label sets, e.g. from several namespaces, are returned once, sorted by their ID
+func SearchSeries(
+	ctx context.Context,
+	querier Querier,
+	matchers models.Matchers,
+	start, end time.Time,
+	options *FetchOptions,
+) ([]block.SeriesMeta, error) {
+	results, err := querier.FetchTags(ctx, &FetchQuery{
+		TagMatchers: matchers,
+		Start:       start,
+		End:         end,
+	}, options)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(results.Metrics))
+	metas := make([]block.SeriesMeta, 0, len(results.Metrics))
+	for _, metric := range results.Metrics {
+		id := metric.Tags.ID()
+		if _, ok := seen[id]; ok {
+			continue
+		}
+
+		seen[id] = struct{}{}
+		metas = append(metas, block.SeriesMeta{Tags: metric.Tags, Name: id})
+	}
+
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+	return metas, nil
+}
+
+// LabelNames returns the sorted, distinct label names of the series matching the matchers between
+// start and end. Storages cannot resolve label names natively, so they are derived from a series
+// search
+func LabelNames(
+	ctx context.Context,
+	querier Querier,
+	matchers models.Matchers,
+	start, end time.Time,
+	options *FetchOptions,
+) ([]string, error) {
+	metas, err := SearchSeries(ctx, querier, matchers, start, end, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, meta := range metas {
+		for name := range meta.Tags {
+			names = append(names, name)
+		}
+	}
+
+	return sortedUnique(names), nil
+}
+
+// LabelValues returns the sorted, distinct values of the named label on the series matching the
+// matchers between start and end, derived from a series search
+func LabelValues(
+	ctx context.Context,
+	querier Querier,
+	name string,
+	matchers models.Matchers,
+	start, end time.Time,
+	options *FetchOptions,
+) ([]string, error) {
+	metas, err := SearchSeries(ctx, querier, matchers, start, end, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, meta := range metas {
+		if value, ok := meta.Tags[name]; ok {
+			values = append(values, value)
+		}
+	}
+
+	return sortedUnique(values), nil
+}
+
+func sortedUnique(values []string) []string {
+	if len(values) == 0 {
+		return []string{}
+	}
+
+	sort.Strings(values)
+	unique := values[:1]
+	for _, value := range values[1:] {
+		if value != unique[len(unique)-1] {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
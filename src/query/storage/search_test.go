@@ -0,0 +1,141 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/storage/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchSeries(t *testing.T) {
+	first := models.Tags{"__name__": "up", "job": "a"}
+	second := models.Tags{"__name__": "up", "job": "b"}
+	store := mock.NewMockStorage()
+	store.SetFetchTagsResult(&storage.SearchResults{
+		Metrics: models.Metrics{
+			{Namespace: "unaggregated", ID: second.ID(), Tags: second},
+			{Namespace: "unaggregated", ID: first.ID(), Tags: first},
+			{Namespace: "aggregated", ID: second.ID(), Tags: second},
+		},
+	}, nil)
+
+	matcher, err := models.NewMatcher(models.MatchEqual, "__name__", "up")
+	require.NoError(t, err)
+	now := time.Now()
+	metas, err := storage.SearchSeries(context.TODO(), store, models.Matchers{matcher},
+		now.Add(-time.Hour), now, &storage.FetchOptions{})
+	require.NoError(t, err)
+	require.Len(t, metas, 2)
+	assert.Equal(t, first, metas[0].Tags)
+	assert.Equal(t, first.ID(), metas[0].Name)
+	assert.Equal(t, second, metas[1].Tags)
+	assert.Equal(t, second.ID(), metas[1].Name)
+}
+
+func TestSearchSeriesError(t *testing.T) {
+	store := mock.NewMockStorage()
+	store.SetFetchTagsResult(nil, errors.New("search failed"))
+	_, err := storage.SearchSeries(context.TODO(), store, nil, time.Time{}, time.Now(), &storage.FetchOptions{})
+	assert.Error(t, err)
+}
+
+// filteringQuerier applies the pushed down matchers to a fixed set of series
+type filteringQuerier struct {
+	storage.Storage
+	series []models.Tags
+}
+
+func (q *filteringQuerier) FetchTags(
+	_ context.Context, query *storage.FetchQuery, _ *storage.FetchOptions) (*storage.SearchResults, error) {
+	var metrics models.Metrics
+	for _, tags := range q.series {
+		matched := true
+		for _, matcher := range query.TagMatchers {
+			if !matcher.Matches(tags[matcher.Name]) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			metrics = append(metrics, &models.Metric{ID: tags.ID(), Tags: tags})
+		}
+	}
+
+	return &storage.SearchResults{Metrics: metrics}, nil
+}
+
+func newFilteringQuerier() *filteringQuerier {
+	return &filteringQuerier{
+		Storage: mock.NewMockStorage(),
+		series: []models.Tags{
+			{"__name__": "up", "job": "b", "instance": "1"},
+			{"__name__": "up", "job": "a", "instance": "2"},
+			{"__name__": "requests", "job": "a", "code": "200"},
+			{"__name__": "requests", "job": "a", "code": "500"},
+		},
+	}
+}
+
+func TestLabelNames(t *testing.T) {
+	querier := newFilteringQuerier()
+	now := time.Now()
+	names, err := storage.LabelNames(context.TODO(), querier, nil, now.Add(-time.Hour), now, &storage.FetchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"__name__", "code", "instance", "job"}, names)
+
+	matcher, err := models.NewMatcher(models.MatchEqual, "__name__", "up")
+	require.NoError(t, err)
+	names, err = storage.LabelNames(context.TODO(), querier, models.Matchers{matcher},
+		now.Add(-time.Hour), now, &storage.FetchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"__name__", "instance", "job"}, names)
+}
+
+func TestLabelValues(t *testing.T) {
+	querier := newFilteringQuerier()
+	now := time.Now()
+	values, err := storage.LabelValues(context.TODO(), querier, "job", nil,
+		now.Add(-time.Hour), now, &storage.FetchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values)
+
+	matcher, err := models.NewMatcher(models.MatchRegexp, "__name__", "req.*")
+	require.NoError(t, err)
+	values, err = storage.LabelValues(context.TODO(), querier, "job", models.Matchers{matcher},
+		now.Add(-time.Hour), now, &storage.FetchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, values)
+
+	values, err = storage.LabelValues(context.TODO(), querier, "missing", nil,
+		now.Add(-time.Hour), now, &storage.FetchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, values)
+}
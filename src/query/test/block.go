@@ -29,7 +29,6 @@ import (
 
 // NewBlockFromValues creates a new block using the provided values
 func NewBlockFromValues(bounds block.Bounds, seriesValues [][]float64) block.Block {
-	blockMeta := block.Metadata{Bounds: bounds}
 	seriesMeta := make([]block.SeriesMeta, len(seriesValues))
 	for i := range seriesMeta {
 		tags := make(models.Tags)
@@ -41,8 +40,17 @@ func NewBlockFromValues(bounds block.Bounds, seriesValues [][]float64) block.Blo
 		}
 	}
 
+	return NewBlockFromValuesWithSeriesMeta(bounds, seriesMeta, seriesValues)
+}
+
+// NewBlockFromValuesWithSeriesMeta creates a new block using the provided values and series metadata
+func NewBlockFromValuesWithSeriesMeta(bounds block.Bounds, seriesMeta []block.SeriesMeta, seriesValues [][]float64) block.Block {
+	blockMeta := block.Metadata{Bounds: bounds}
 	columnBuilder := block.NewColumnBlockBuilder(blockMeta, seriesMeta)
-	columnBuilder.AddCols(len(seriesValues[0]))
+	if len(seriesValues) > 0 {
+		columnBuilder.AddCols(len(seriesValues[0]))
+	}
+
 	for _, seriesVal := range seriesValues {
 		for idx, val := range seriesVal {
 			columnBuilder.AppendValue(idx, val)
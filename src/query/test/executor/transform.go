@@ -40,6 +40,8 @@ func NewControllerWithSink(ID parser.NodeID) (*transform.Controller, *SinkNode)
 // SinkNode is a test node useful for comparisons
 type SinkNode struct {
 	Values [][]float64
+	Metas  []block.SeriesMeta
+	Meta   block.Metadata
 }
 
 // Process processes and stores the last block output in the sink node
@@ -49,6 +51,7 @@ func (s *SinkNode) Process(ID parser.NodeID, block block.Block) error {
 		return err
 	}
 
+	s.Meta = iter.Meta()
 	for iter.Next() {
 		val, err := iter.Current()
 		if err != nil {
@@ -60,7 +63,31 @@ func (s *SinkNode) Process(ID parser.NodeID, block block.Block) error {
 			values[i] = val.ValueAtStep(i)
 		}
 		s.Values = append(s.Values, values)
+		s.Metas = append(s.Metas, val.Meta)
 	}
 
 	return nil
 }
+
+// NewControllerWithBlockSink creates a new controller which has a sink keeping the blocks it is
+// sent, for tests which inspect the block itself rather than its values
+func NewControllerWithBlockSink(ID parser.NodeID) (*transform.Controller, *BlockSinkNode) {
+	c := &transform.Controller{
+		ID: ID,
+	}
+
+	node := &BlockSinkNode{}
+	c.AddTransform(node)
+	return c, node
+}
+
+// BlockSinkNode is a test node which keeps the last block it processes
+type BlockSinkNode struct {
+	Block block.Block
+}
+
+// Process stores the block in the sink node
+func (s *BlockSinkNode) Process(_ parser.NodeID, b block.Block) error {
+	s.Block = b
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fixtures
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+// NewBlockFromValues creates a block with a series for each row of values, which have a value
+// per step of the bounds. Series without metadata are named by index, e.g. series_0 {i="0"}
+func NewBlockFromValues(bounds block.Bounds, values [][]float64, seriesMeta []block.SeriesMeta) (block.Block, error) {
+	if len(seriesMeta) > len(values) {
+		return nil, fmt.Errorf("%d series metadata for %d series", len(seriesMeta), len(values))
+	}
+
+	steps := bounds.Steps()
+	metas := make([]block.SeriesMeta, len(values))
+	for i, row := range values {
+		if len(row) != steps {
+			return nil, fmt.Errorf("series %d has %d values for %d steps", i, len(row), steps)
+		}
+
+		if i < len(seriesMeta) {
+			metas[i] = seriesMeta[i]
+			continue
+		}
+
+		name := fmt.Sprintf("series_%d", i)
+		metas[i] = block.SeriesMeta{
+			Name: name,
+			Tags: models.Tags{models.MetricName: name, "i": fmt.Sprint(i)},
+		}
+	}
+
+	builder := block.NewColumnBlockBuilder(block.Metadata{Bounds: bounds}, metas)
+	if err := builder.AddCols(steps); err != nil {
+		return nil, err
+	}
+
+	for _, row := range values {
+		for idx, v := range row {
+			if err := builder.AppendValue(idx, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+// NewBounds creates bounds of the number of steps ending at end
+func NewBounds(end time.Time, step time.Duration, steps int) block.Bounds {
+	return block.Bounds{
+		Start:    end.Add(-time.Duration(steps-1) * step),
+		End:      end,
+		StepSize: step,
+	}
+}
+
+// NewTestSeries creates a series with a datapoint at each step of the bounds, skipping NaNs,
+// for the in-memory storage
+func NewTestSeries(tags models.Tags, bounds block.Bounds, values []float64) TestSeries {
+	datapoints := make(ts.Datapoints, 0, len(values))
+	for i, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+
+		datapoints = append(datapoints, ts.Datapoint{Timestamp: bounds.TimeForStep(i), Value: v})
+	}
+
+	return TestSeries{Tags: tags, Datapoints: datapoints}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fixtures
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/ts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEqualQuery creates a query for the series with the tag value, covering every step of the bounds
+func newEqualQuery(t *testing.T, name, value string, bounds block.Bounds) *storage.FetchQuery {
+	matcher, err := models.NewMatcher(models.MatchEqual, name, value)
+	require.NoError(t, err)
+	return &storage.FetchQuery{
+		TagMatchers: models.Matchers{matcher},
+		Start:       bounds.Start,
+		End:         bounds.End.Add(bounds.StepSize),
+		Interval:    bounds.StepSize,
+	}
+}
+
+func TestSeriesStorageFetchesMatchingSeries(t *testing.T) {
+	bounds := NewBounds(time.Now().Truncate(time.Minute), time.Minute, 3)
+	store := NewMockStorage(
+		NewTestSeries(models.Tags{"job": "api"}, bounds, []float64{1, 2, 3}),
+		NewTestSeries(models.Tags{"job": "db"}, bounds, []float64{4, 5, 6}),
+	)
+
+	query := newEqualQuery(t, "job", "api", bounds)
+	result, err := store.Fetch(context.TODO(), query, nil)
+	require.NoError(t, err)
+	require.Len(t, result.SeriesList, 1)
+	assert.Equal(t, "api", result.SeriesList[0].Tags["job"])
+	assert.Equal(t, 3, result.SeriesList[0].Len())
+
+	tags, err := store.FetchTags(context.TODO(), query, nil)
+	require.NoError(t, err)
+	require.Len(t, tags.Metrics, 1)
+	assert.Equal(t, models.Tags{"job": "api"}, tags.Metrics[0].Tags)
+}
+
+func TestSeriesStorageFetchBlocks(t *testing.T) {
+	bounds := NewBounds(time.Now().Truncate(time.Minute), time.Minute, 3)
+	store := NewMockStorage(NewTestSeries(models.Tags{"job": "api"}, bounds, []float64{1, 2, 3}))
+
+	result, err := store.FetchBlocks(context.TODO(), newEqualQuery(t, "job", "api", bounds), nil)
+	require.NoError(t, err)
+	require.Len(t, result.Blocks, 1)
+
+	iter, err := result.Blocks[0].SeriesIter()
+	require.NoError(t, err)
+	require.True(t, iter.Next())
+	series, err := iter.Current()
+	require.NoError(t, err)
+	for i, expected := range []float64{1, 2, 3} {
+		assert.Equal(t, expected, series.ValueAtStep(i))
+	}
+	assert.False(t, iter.Next())
+}
+
+func TestSeriesStorageWrite(t *testing.T) {
+	bounds := NewBounds(time.Now().Truncate(time.Minute), time.Minute, 2)
+	store := NewMockStorage()
+	query := newEqualQuery(t, "job", "api", bounds)
+
+	err := store.Write(context.TODO(), &storage.WriteQuery{
+		Tags:       models.Tags{"job": "api"},
+		Datapoints: ts.Datapoints{{Timestamp: bounds.End, Value: 2}},
+	})
+	require.NoError(t, err)
+	err = store.Write(context.TODO(), &storage.WriteQuery{
+		Tags:       models.Tags{"job": "api"},
+		Datapoints: ts.Datapoints{{Timestamp: bounds.Start, Value: 1}},
+	})
+	require.NoError(t, err)
+
+	result, err := store.Fetch(context.TODO(), query, nil)
+	require.NoError(t, err)
+	require.Len(t, result.SeriesList, 1)
+	values := result.SeriesList[0].Values()
+	require.Equal(t, 2, values.Len())
+	assert.Equal(t, 1.0, values.ValueAt(0))
+	assert.Equal(t, 2.0, values.ValueAt(1))
+
+	assert.Error(t, store.Write(context.TODO(), &storage.WriteQuery{}))
+}
+
+func TestNewBlockFromValues(t *testing.T) {
+	bounds := NewBounds(time.Now().Truncate(time.Minute), time.Minute, 2)
+	meta := block.SeriesMeta{Name: "up", Tags: models.Tags{"job": "api"}}
+	b, err := NewBlockFromValues(bounds, [][]float64{{1, 2}, {3, 4}}, []block.SeriesMeta{meta})
+	require.NoError(t, err)
+
+	iter, err := b.SeriesIter()
+	require.NoError(t, err)
+	metas := iter.SeriesMeta()
+	require.Len(t, metas, 2)
+	assert.Equal(t, meta, metas[0])
+	assert.Equal(t, "series_1", metas[1].Name)
+	assert.Equal(t, models.Tags{models.MetricName: "series_1", "i": "1"}, metas[1].Tags)
+
+	var values [][]float64
+	for iter.Next() {
+		series, err := iter.Current()
+		require.NoError(t, err)
+		values = append(values, series.Values())
+	}
+	assert.Equal(t, [][]float64{{1, 2}, {3, 4}}, values)
+}
+
+func TestNewBlockFromValuesErrors(t *testing.T) {
+	bounds := NewBounds(time.Now(), time.Minute, 2)
+	_, err := NewBlockFromValues(bounds, [][]float64{{1}}, nil)
+	assert.Error(t, err)
+
+	metas := []block.SeriesMeta{{Name: "a"}, {Name: "b"}}
+	_, err = NewBlockFromValues(bounds, [][]float64{{1, 2}}, metas)
+	assert.Error(t, err)
+}
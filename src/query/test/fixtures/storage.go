@@ -0,0 +1,166 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package fixtures provides in-memory storage and block fixtures for tests.
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/m3db/m3/src/query/block"
+	"github.com/m3db/m3/src/query/models"
+	"github.com/m3db/m3/src/query/storage"
+	"github.com/m3db/m3/src/query/ts"
+)
+
+// TestSeries is a series held by the in-memory storage
+type TestSeries struct {
+	Tags       models.Tags
+	Datapoints ts.Datapoints
+}
+
+// memStorage is a storage.Storage serving its series from memory
+type memStorage struct {
+	sync.RWMutex
+	series []TestSeries
+}
+
+// NewMockStorage creates an in-memory storage serving the series. Fetches return the series
+// matching every matcher with their datapoints in the query range, and writes add datapoints,
+// creating series as needed
+func NewMockStorage(series ...TestSeries) storage.Storage {
+	s := &memStorage{}
+	for _, testSeries := range series {
+		s.add(testSeries.Tags, testSeries.Datapoints)
+	}
+
+	return s
+}
+
+// add appends the datapoints to the series with the tags, keeping datapoints in time order
+func (s *memStorage) add(tags models.Tags, datapoints ts.Datapoints) {
+	id := tags.ID()
+	for i := range s.series {
+		if s.series[i].Tags.ID() == id {
+			merged := append(s.series[i].Datapoints, datapoints...)
+			sort.SliceStable(merged, func(a, b int) bool { return merged[a].Timestamp.Before(merged[b].Timestamp) })
+			s.series[i].Datapoints = merged
+			return
+		}
+	}
+
+	copied := make(models.Tags, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+
+	sorted := append(ts.Datapoints(nil), datapoints...)
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a].Timestamp.Before(sorted[b].Timestamp) })
+	s.series = append(s.series, TestSeries{Tags: copied, Datapoints: sorted})
+}
+
+// matching returns the series which match all the matchers, with their datapoints in the query range
+func (s *memStorage) matching(query *storage.FetchQuery) []TestSeries {
+	s.RLock()
+	defer s.RUnlock()
+
+	var matched []TestSeries
+	for _, series := range s.series {
+		if !matchesAll(query.TagMatchers, series.Tags) {
+			continue
+		}
+
+		var datapoints ts.Datapoints
+		for _, dp := range series.Datapoints {
+			if !dp.Timestamp.Before(query.Start) && !dp.Timestamp.After(query.End) {
+				datapoints = append(datapoints, dp)
+			}
+		}
+
+		matched = append(matched, TestSeries{Tags: series.Tags, Datapoints: datapoints})
+	}
+
+	return matched
+}
+
+// matchesAll returns true if the tags match every matcher. Missing tags match as empty values
+func matchesAll(matchers models.Matchers, tags models.Tags) bool {
+	for _, matcher := range matchers {
+		if !matcher.Matches(tags[matcher.Name]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *memStorage) Fetch(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (*storage.FetchResult, error) {
+	matched := s.matching(query)
+	seriesList := make(ts.SeriesList, 0, len(matched))
+	for _, series := range matched {
+		seriesList = append(seriesList, ts.NewSeries(series.Tags.ID(), series.Datapoints, series.Tags))
+	}
+
+	return &storage.FetchResult{SeriesList: seriesList, LocalOnly: true}, nil
+}
+
+func (s *memStorage) FetchTags(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (*storage.SearchResults, error) {
+	matched := s.matching(query)
+	metrics := make(models.Metrics, 0, len(matched))
+	for _, series := range matched {
+		metrics = append(metrics, &models.Metric{ID: series.Tags.ID(), Tags: series.Tags})
+	}
+
+	return &storage.SearchResults{Metrics: metrics}, nil
+}
+
+func (s *memStorage) FetchBlocks(
+	ctx context.Context, query *storage.FetchQuery, options *storage.FetchOptions) (block.Result, error) {
+	result, err := s.Fetch(ctx, query, options)
+	if err != nil {
+		return block.Result{}, err
+	}
+
+	return storage.FetchResultToBlockResult(result, query, options)
+}
+
+func (s *memStorage) Write(ctx context.Context, query *storage.WriteQuery) error {
+	if query == nil || len(query.Tags) == 0 {
+		return fmt.Errorf("unable to write a series without tags")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.add(query.Tags, query.Datapoints)
+	return nil
+}
+
+func (s *memStorage) Type() storage.Type {
+	return storage.TypeLocalDC
+}
+
+func (s *memStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ts
+
+import (
+	"math"
+	"time"
+)
+
+// DuplicateTimestampPolicy resolves datapoints which share a timestamp, e.g. from replicas which
+// have been merged, into one value. It is called in order for each duplicate with the value
+// resolved so far
+type DuplicateTimestampPolicy func(existing, duplicate float64) float64
+
+var (
+	// DuplicateTimestampLast keeps the last datapoint written
+	DuplicateTimestampLast DuplicateTimestampPolicy = func(_, duplicate float64) float64 { return duplicate }
+
+	// DuplicateTimestampFirst keeps the first datapoint written
+	DuplicateTimestampFirst DuplicateTimestampPolicy = func(existing, _ float64) float64 { return existing }
+
+	// DuplicateTimestampMax keeps the largest value, ignoring NaNs
+	DuplicateTimestampMax DuplicateTimestampPolicy = func(existing, duplicate float64) float64 {
+		if math.IsNaN(existing) || duplicate > existing {
+			return duplicate
+		}
+
+		return existing
+	}
+
+	// DuplicateTimestampMin keeps the smallest value, ignoring NaNs
+	DuplicateTimestampMin DuplicateTimestampPolicy = func(existing, duplicate float64) float64 {
+		if math.IsNaN(existing) || duplicate < existing {
+			return duplicate
+		}
+
+		return existing
+	}
+)
+
+// ConsolidationOptions configures how raw datapoints are consolidated onto fixed steps
+type ConsolidationOptions struct {
+	// DuplicateTimestampPolicy picks the value of datapoints sharing a timestamp, and defaults
+	// to DuplicateTimestampLast
+	DuplicateTimestampPolicy DuplicateTimestampPolicy
+	// RetainSampleTimes keeps the timestamp of the sample consolidated onto each step, which
+	// differs from the step time when a value is carried forward
+	RetainSampleTimes bool
+	// LookbackDuration, when positive, limits how long after a datapoint its value is carried
+	// forward onto later steps, so gaps longer than it are NaN. By default values are carried
+	// forward until the next datapoint however far it is
+	LookbackDuration time.Duration
+}
+
+// Policy returns the duplicate timestamp policy, falling back to DuplicateTimestampLast
+func (o ConsolidationOptions) Policy() DuplicateTimestampPolicy {
+	if o.DuplicateTimestampPolicy == nil {
+		return DuplicateTimestampLast
+	}
+
+	return o.DuplicateTimestampPolicy
+}
+
+// resolve returns the value of the datapoints sharing the timestamp of the datapoint at idx,
+// which are expected to be adjacent as datapoints are in time order
+func (o ConsolidationOptions) resolve(datapoints Datapoints, idx int) float64 {
+	policy := o.Policy()
+	t := datapoints[idx].Timestamp
+	first := idx
+	for first > 0 && datapoints[first-1].Timestamp.Equal(t) {
+		first--
+	}
+
+	value := datapoints[first].Value
+	for i := first + 1; i < len(datapoints) && datapoints[i].Timestamp.Equal(t); i++ {
+		value = policy(value, datapoints[i].Value)
+	}
+
+	return value
+}
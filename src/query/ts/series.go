@@ -55,8 +55,8 @@ func (s *Series) Len() int { return s.vals.Len() }
 func (s *Series) Values() Values { return s.vals }
 
 // Align adjusts the datapoints to start, end and a fixed interval
-func (s *Series) Align(start, end time.Time, interval time.Duration) (*Series, error) {
-	fixedVals, err := alignValues(s.Values(), start, end, interval)
+func (s *Series) Align(start, end time.Time, interval time.Duration, opts ConsolidationOptions) (*Series, error) {
+	fixedVals, err := alignValues(s.Values(), start, end, interval, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -64,10 +64,10 @@ func (s *Series) Align(start, end time.Time, interval time.Duration) (*Series, e
 	return NewSeries(s.name, fixedVals, s.Tags), nil
 }
 
-func alignValues(values Values, start, end time.Time, interval time.Duration) (FixedResolutionMutableValues, error) {
+func alignValues(values Values, start, end time.Time, interval time.Duration, opts ConsolidationOptions) (FixedResolutionMutableValues, error) {
 	switch vals := values.(type) {
 	case Datapoints:
-		return RawPointsToFixedStep(vals, start, end, interval)
+		return RawPointsToFixedStep(vals, start, end, interval, opts)
 	case FixedResolutionMutableValues:
 		// TODO: Align fixed resolution as well once storages can return those directly
 		return vals, nil
@@ -101,10 +101,10 @@ func (seriesList SeriesList) Resolution() (time.Duration, error) {
 }
 
 // Align aligns each series to the given start, end and step.
-func (seriesList SeriesList) Align(start, end time.Time, interval time.Duration) (SeriesList, error) {
+func (seriesList SeriesList) Align(start, end time.Time, interval time.Duration, opts ConsolidationOptions) (SeriesList, error) {
 	alignedList := make(SeriesList, len(seriesList))
 	for i, s := range seriesList {
-		alignedSeries, err := s.Align(start, end, interval)
+		alignedSeries, err := s.Align(start, end, interval, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -83,11 +83,19 @@ type FixedResolutionMutableValues interface {
 	MillisPerStep() time.Duration
 }
 
+// SampleTimedValues are values which retain the timestamp of the sample consolidated onto each step
+type SampleTimedValues interface {
+	// SampleTimeAt returns the timestamp of the sample at the step, if the step has a sample
+	SampleTimeAt(n int) (time.Time, bool)
+}
+
 type fixedResolutionValues struct {
 	millisPerStep time.Duration
 	numSteps      int
 	values        []float64
 	startTime     time.Time
+	// sampleTimes, when retained, are the timestamps of the samples at each step
+	sampleTimes []time.Time
 }
 
 func (b *fixedResolutionValues) MillisPerStep() time.Duration { return b.millisPerStep }
@@ -120,6 +128,16 @@ func (b *fixedResolutionValues) StartTimeForStep(n int) time.Time {
 	return b.startTime.Add(time.Duration(n) * b.MillisPerStep())
 }
 
+// SampleTimeAt returns the timestamp of the sample at the step, if sample times are retained
+// and the step has a sample
+func (b *fixedResolutionValues) SampleTimeAt(n int) (time.Time, bool) {
+	if b.sampleTimes == nil || b.sampleTimes[n].IsZero() {
+		return time.Time{}, false
+	}
+
+	return b.sampleTimes[n], true
+}
+
 // SetValueAt sets the value at the given entry
 func (b *fixedResolutionValues) SetValueAt(n int, v float64) {
 	b.values[n] = v
@@ -143,7 +161,14 @@ func newFixedStepValues(millisPerStep time.Duration, numSteps int, initialValue
 }
 
 // RawPointsToFixedStep converts raw datapoints into the interval required within the bounds specified. For every time step, it finds the closest point.
-func RawPointsToFixedStep(datapoints Datapoints, start time.Time, end time.Time, interval time.Duration) (FixedResolutionMutableValues, error) {
+// Datapoints sharing a timestamp are resolved into one value using the consolidation options
+func RawPointsToFixedStep(
+	datapoints Datapoints,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	opts ConsolidationOptions,
+) (FixedResolutionMutableValues, error) {
 	if end.Before(start) {
 		return nil, fmt.Errorf("start cannot be after end, start: %v, end: %v", start, end)
 	}
@@ -160,6 +185,10 @@ func RawPointsToFixedStep(datapoints Datapoints, start time.Time, end time.Time,
 	}
 
 	fixStepValues := newFixedStepValues(interval, numSteps, math.NaN(), start)
+	if opts.RetainSampleTimes {
+		fixStepValues.sampleTimes = make([]time.Time, numSteps)
+	}
+
 	fixedResIdx := 0
 	dpIdx := 0
 	numPoints := len(datapoints)
@@ -177,10 +206,21 @@ func RawPointsToFixedStep(datapoints Datapoints, start time.Time, end time.Time,
 		}
 
 		// If datapoint aligns to the time or its the first datapoint then take that
+		sampleIdx := dpIdx - 1
 		if datapoints.DatapointAt(dpIdx).Timestamp == t || dpIdx == 0 {
-			fixStepValues.values[fixedResIdx] = datapoints.ValueAt(dpIdx)
-		} else {
-			fixStepValues.values[fixedResIdx] = datapoints.ValueAt(dpIdx - 1)
+			sampleIdx = dpIdx
+		}
+
+		sampleTime := datapoints.DatapointAt(sampleIdx).Timestamp
+		if opts.LookbackDuration > 0 && t.Sub(sampleTime) > opts.LookbackDuration {
+			// The step is left as NaN since the datapoint is too old to carry forward
+			fixedResIdx++
+			continue
+		}
+
+		fixStepValues.values[fixedResIdx] = opts.resolve(datapoints, sampleIdx)
+		if fixStepValues.sampleTimes != nil {
+			fixStepValues.sampleTimes[fixedResIdx] = sampleTime
 		}
 
 		fixedResIdx++
@@ -91,7 +91,7 @@ func createExamples() []testSample {
 func TestRawPointsToFixedStep(t *testing.T) {
 	samples := createExamples()
 	for idx, sample := range samples {
-		fixdRes, err := RawPointsToFixedStep(sample.input, sample.start, sample.end, sample.interval)
+		fixdRes, err := RawPointsToFixedStep(sample.input, sample.start, sample.end, sample.interval, ConsolidationOptions{})
 		require.NoError(t, err)
 		if !sample.hasNans {
 			assert.Equal(t, fixdRes.(*fixedResolutionValues).values, sample.expected, "Datapoints: %s, description: %s", sample.input, sample.description)
@@ -111,3 +111,101 @@ func TestRawPointsToFixedStep(t *testing.T) {
 		}
 	}
 }
+
+func TestRawPointsToFixedStepWithDuplicateTimestamps(t *testing.T) {
+	start := time.Unix(600, 0)
+	datapoints := Datapoints{
+		{Timestamp: start, Value: 1},
+		{Timestamp: start, Value: 5},
+		{Timestamp: start, Value: 3},
+		{Timestamp: start.Add(time.Second), Value: math.NaN()},
+		{Timestamp: start.Add(time.Second), Value: 2},
+		{Timestamp: start.Add(2 * time.Second), Value: 4},
+		{Timestamp: start.Add(3 * time.Second), Value: 6},
+	}
+
+	tests := []struct {
+		name     string
+		policy   DuplicateTimestampPolicy
+		expected []float64
+	}{
+		{name: "default", expected: []float64{3, 2, 4}},
+		{name: "last", policy: DuplicateTimestampLast, expected: []float64{3, 2, 4}},
+		{name: "first", policy: DuplicateTimestampFirst, expected: []float64{1, math.NaN(), 4}},
+		{name: "max", policy: DuplicateTimestampMax, expected: []float64{5, 2, 4}},
+		{name: "min", policy: DuplicateTimestampMin, expected: []float64{1, 2, 4}},
+	}
+
+	// Steps between datapoints take the previous datapoints, which are resolved the same way
+	for _, offset := range []time.Duration{0, 500 * time.Millisecond} {
+		for _, tt := range tests {
+			opts := ConsolidationOptions{DuplicateTimestampPolicy: tt.policy}
+			fixedRes, err := RawPointsToFixedStep(datapoints, start.Add(offset), start.Add(offset+3*time.Second), time.Second, opts)
+			require.NoError(t, err)
+			values := fixedRes.(*fixedResolutionValues).values
+			require.Len(t, values, len(tt.expected), tt.name)
+			for i, v := range tt.expected {
+				if math.IsNaN(v) {
+					assert.True(t, math.IsNaN(values[i]), "%s at %d", tt.name, i)
+				} else {
+					assert.Equal(t, v, values[i], "%s at %d", tt.name, i)
+				}
+			}
+		}
+	}
+}
+
+func TestRawPointsToFixedStepRetainsSampleTimes(t *testing.T) {
+	start := time.Unix(600, 0)
+	datapoints := Datapoints{
+		{Timestamp: start, Value: 1},
+		{Timestamp: start.Add(1500 * time.Millisecond), Value: 2},
+		{Timestamp: start.Add(3 * time.Second), Value: 3},
+	}
+
+	fixedRes, err := RawPointsToFixedStep(datapoints, start, start.Add(3*time.Second), time.Second, ConsolidationOptions{})
+	require.NoError(t, err)
+	_, ok := fixedRes.(SampleTimedValues).SampleTimeAt(0)
+	assert.False(t, ok, "sample times are not retained by default")
+
+	opts := ConsolidationOptions{RetainSampleTimes: true}
+	fixedRes, err = RawPointsToFixedStep(datapoints, start, start.Add(3*time.Second), time.Second, opts)
+	require.NoError(t, err)
+	sampleTimes := fixedRes.(SampleTimedValues)
+
+	// The second step carries the first sample forward, and the third carries the second
+	for i, expected := range []time.Time{start, start, start.Add(1500 * time.Millisecond)} {
+		sampleTime, ok := sampleTimes.SampleTimeAt(i)
+		require.True(t, ok, "step %d", i)
+		assert.True(t, expected.Equal(sampleTime), "step %d: %v", i, sampleTime)
+	}
+}
+
+func TestRawPointsToFixedStepWithLookbackDuration(t *testing.T) {
+	start := time.Unix(600, 0)
+	datapoints := Datapoints{
+		{Timestamp: start, Value: 1},
+		{Timestamp: start.Add(8 * time.Minute), Value: 2},
+	}
+
+	fixedRes, err := RawPointsToFixedStep(datapoints, start, start.Add(9*time.Minute), time.Minute, ConsolidationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 1, 1, 1, 1, 1, 1, 1, 2}, fixedRes.(*fixedResolutionValues).values,
+		"values are carried forward until the next datapoint by default")
+
+	opts := ConsolidationOptions{LookbackDuration: 5 * time.Minute}
+	fixedRes, err = RawPointsToFixedStep(datapoints, start, start.Add(9*time.Minute), time.Minute, opts)
+	require.NoError(t, err)
+	values := fixedRes.(*fixedResolutionValues).values
+	require.Len(t, values, 9)
+	for i, value := range values {
+		switch i {
+		case 6, 7:
+			assert.True(t, math.IsNaN(value), "step %d is more than the lookback after the datapoint", i)
+		case 8:
+			assert.Equal(t, 2.0, value)
+		default:
+			assert.Equal(t, 1.0, value, "step %d", i)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package util
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		in       float64
+		expected string
+	}{
+		{in: 0, expected: "0"},
+		{in: 1, expected: "1"},
+		{in: -42, expected: "-42"},
+		{in: 1.5, expected: "1.5"},
+		{in: 0.1, expected: "0.1"},
+		{in: 1.0 / 3, expected: "0.3333333333333333"},
+		{in: 1e21, expected: "1000000000000000000000"},
+		{in: 1.5e-7, expected: "0.00000015"},
+		{in: math.NaN(), expected: "NaN"},
+		{in: math.Inf(1), expected: "+Inf"},
+		{in: math.Inf(-1), expected: "-Inf"},
+	}
+
+	for _, tt := range tests {
+		formatted := FormatValue(tt.in)
+		assert.Equal(t, tt.expected, formatted)
+		if math.IsNaN(tt.in) {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(formatted, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.in, parsed, "formatted values round trip")
+	}
+}
@@ -27,6 +27,8 @@ import (
 	"io"
 	"math"
 	"strconv"
+
+	"github.com/m3db/m3/src/query/util"
 )
 
 var (
@@ -216,7 +218,7 @@ func (w *Writer) WriteFloat64(n float64) {
 	if math.IsNaN(n) || math.IsInf(n, 0) {
 		w.writeNull()
 	} else {
-		_, w.err = fmt.Fprintf(w.w, "%f", n)
+		_, w.err = w.w.WriteString(util.FormatValue(n))
 	}
 
 	w.endValue()
@@ -33,7 +33,7 @@ import (
 func TestWriteValues(t *testing.T) {
 	testWrite(t, "true", func(w *Writer) { w.WriteBool(true) })
 	testWrite(t, "false", func(w *Writer) { w.WriteBool(false) })
-	testWrite(t, "3.145000", func(w *Writer) { w.WriteFloat64(3.145) })
+	testWrite(t, "3.145", func(w *Writer) { w.WriteFloat64(3.145) })
 	testWrite(t, "null", func(w *Writer) { w.WriteFloat64(math.NaN()) })
 	testWrite(t, "null", func(w *Writer) { w.WriteFloat64(math.Inf(1)) })
 	testWrite(t, "null", func(w *Writer) { w.WriteFloat64(math.Inf(-1)) })
@@ -62,7 +62,7 @@ func TestWriteValues(t *testing.T) {
 }
 
 func TestWriteObject(t *testing.T) {
-	testWrite(t, "{\"foo\":null,\"bar\":3.145000,\"zed\":\"Hello World\",\"nan\":null,\"infinity\":null,\"bad\\u0006\":null}", func(w *Writer) {
+	testWrite(t, "{\"foo\":null,\"bar\":3.145,\"zed\":\"Hello World\",\"nan\":null,\"infinity\":null,\"bad\\u0006\":null}", func(w *Writer) {
 		w.BeginObject()
 		w.BeginObjectField("foo")
 		w.WriteNull()
@@ -81,7 +81,7 @@ func TestWriteObject(t *testing.T) {
 }
 
 func TestWriteArray(t *testing.T) {
-	testWrite(t, "[\"Hello World\",3.145000,null,24,false,null,null]", func(w *Writer) {
+	testWrite(t, "[\"Hello World\",3.145,null,24,false,null,null]", func(w *Writer) {
 		w.BeginArray()
 		w.WriteString("Hello World")
 		w.WriteFloat64(3.145)
@@ -95,7 +95,7 @@ func TestWriteArray(t *testing.T) {
 }
 
 func TestWriteComplexObject(t *testing.T) {
-	testWrite(t, "{\"foo\":{\"bar\":{\"elements\":[\"Hello World\",3.145000,null,24,false],\"empty\":null}}}",
+	testWrite(t, "{\"foo\":{\"bar\":{\"elements\":[\"Hello World\",3.145,null,24,false],\"empty\":null}}}",
 		func(w *Writer) {
 			w.BeginObject()
 			w.BeginObjectField("foo")
@@ -29,3 +29,13 @@ func HasEmptyString(strs ...string) bool {
 	}
 	return false
 }
+
+// ContainsString returns whether the strings contain the given string
+func ContainsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
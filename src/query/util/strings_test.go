@@ -34,3 +34,9 @@ func TestHasEmptyString(t *testing.T) {
 	assert.Equal(t, true, HasEmptyString("q", "", "e"))
 	assert.Equal(t, true, HasEmptyString("q", "w", ""))
 }
+
+func TestContainsString(t *testing.T) {
+	assert.Equal(t, false, ContainsString(nil, ""))
+	assert.Equal(t, true, ContainsString([]string{"q", "w"}, "w"))
+	assert.Equal(t, false, ContainsString([]string{"q", "w"}, "e"))
+}
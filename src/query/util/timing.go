@@ -45,3 +45,112 @@ func ParseTimeString(s string) (time.Time, error) {
 func DurationToMS(duration time.Duration) int64 {
 	return duration.Nanoseconds() / int64(time.Millisecond)
 }
+
+// durationUnits are the units accepted by ParseDuration, in decreasing order of size
+var durationUnits = []struct {
+	unit     string
+	duration time.Duration
+}{
+	{unit: "y", duration: 365 * 24 * time.Hour},
+	{unit: "w", duration: 7 * 24 * time.Hour},
+	{unit: "d", duration: 24 * time.Hour},
+	{unit: "h", duration: time.Hour},
+	{unit: "m", duration: time.Minute},
+	{unit: "s", duration: time.Second},
+	{unit: "ms", duration: time.Millisecond},
+}
+
+// ParseDuration parses a Prometheus style duration such as 1h30m, 1w or 100ms. Each unit may appear
+// at most once and units must be in decreasing order of size
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration: empty string")
+	}
+
+	if s == "0" {
+		return 0, nil
+	}
+
+	var total time.Duration
+	lastUnit := -1
+	for rest := s; rest != ""; {
+		i := 0
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q: expected a number at %q", s, rest)
+		}
+
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: number out of range", s)
+		}
+
+		rest = rest[i:]
+		j := 0
+		for j < len(rest) && !isDigit(rest[j]) {
+			j++
+		}
+
+		unit := rest[:j]
+		rest = rest[j:]
+		if unit == "" {
+			return 0, fmt.Errorf("invalid duration %q: missing unit", s)
+		}
+
+		unitIdx := -1
+		for idx, u := range durationUnits {
+			if u.unit == unit {
+				unitIdx = idx
+				break
+			}
+		}
+
+		if unitIdx < 0 {
+			return 0, fmt.Errorf("invalid duration %q: unknown unit %q", s, unit)
+		}
+
+		if unitIdx <= lastUnit {
+			return 0, fmt.Errorf("invalid duration %q: unit %q must come before %q", s, unit, durationUnits[lastUnit].unit)
+		}
+
+		lastUnit = unitIdx
+		unitDuration := durationUnits[unitIdx].duration
+		if n > int64(math.MaxInt64-total)/int64(unitDuration) {
+			return 0, fmt.Errorf("invalid duration %q: duration out of range", s)
+		}
+
+		total += time.Duration(n) * unitDuration
+	}
+
+	return total, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// FormatDuration formats a duration as a Prometheus style duration using the largest unit
+// which represents it exactly, such as 90m or 1w
+func FormatDuration(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+
+	prefix := ""
+	if d < 0 {
+		prefix = "-"
+		d = -d
+	}
+
+	for _, u := range durationUnits {
+		if d%u.duration == 0 {
+			return fmt.Sprintf("%s%d%s", prefix, d/u.duration, u.unit)
+		}
+	}
+
+	// Sub millisecond durations cannot be represented in a query
+	return prefix + d.String()
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected time.Duration
+	}{
+		{in: "0", expected: 0},
+		{in: "5m", expected: 5 * time.Minute},
+		{in: "1h30m", expected: 90 * time.Minute},
+		{in: "1d", expected: 24 * time.Hour},
+		{in: "1w", expected: 7 * 24 * time.Hour},
+		{in: "1y", expected: 365 * 24 * time.Hour},
+		{in: "100ms", expected: 100 * time.Millisecond},
+		{in: "1s500ms", expected: 1500 * time.Millisecond},
+		{in: "1w2d3h4m5s6ms", expected: 9*24*time.Hour + 3*time.Hour + 4*time.Minute + 5*time.Second + 6*time.Millisecond},
+		{in: "90s", expected: 90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDuration(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.expected, d, tt.in)
+	}
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"1",
+		"m",
+		"1m1h",
+		"1m1m",
+		"1ms1s",
+		"1.5s",
+		"-1m",
+		"1x",
+		"1 m",
+		"1us",
+		"99999999999999999999s",
+		"9999999999y",
+	}
+
+	for _, in := range tests {
+		_, err := ParseDuration(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParseDurationOutOfOrderError(t *testing.T) {
+	_, err := ParseDuration("1m1h")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unit "h" must come before "m"`)
+}
+
+func TestParseDurationRoundTrip(t *testing.T) {
+	// Inputs which parse must give a non negative duration which formats back to the same duration
+	tests := []string{
+		"0", "1h30m", "1w", "1d", "100ms", "1y1ms", "9223372036854ms", "9223372036854775807ms",
+		"1m1h", "1.5s", "", "1ms1", "1µs", "١s", "01m", "1h0m", "0s0ms",
+	}
+
+	for _, in := range tests {
+		d, err := ParseDuration(in)
+		if err != nil {
+			continue
+		}
+
+		assert.True(t, d >= 0, in)
+		again, err := ParseDuration(FormatDuration(d))
+		require.NoError(t, err, in)
+		assert.Equal(t, d, again, in)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		in       time.Duration
+		expected string
+	}{
+		{in: 0, expected: "0s"},
+		{in: 5 * time.Minute, expected: "5m"},
+		{in: 90 * time.Minute, expected: "90m"},
+		{in: 2 * time.Hour, expected: "2h"},
+		{in: 7 * 24 * time.Hour, expected: "1w"},
+		{in: 1500 * time.Millisecond, expected: "1500ms"},
+	}
+
+	for _, tt := range tests {
+		formatted := FormatDuration(tt.in)
+		assert.Equal(t, tt.expected, formatted)
+		d, err := ParseDuration(formatted)
+		require.NoError(t, err)
+		assert.Equal(t, tt.in, d)
+	}
+}